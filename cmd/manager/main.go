@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/datacache"
+	"github.com/lcpu-club/lfs-auto-grader/internal/logging"
 	"github.com/lcpu-club/lfs-auto-grader/internal/manager"
 )
 
@@ -16,21 +25,273 @@ func defaultValue(s, def string) string {
 	return s
 }
 
+func defaultDuration(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Fatalf("invalid duration %q: %v", s, err)
+	}
+	return d
+}
+
+func defaultInt64(s string, def int64) int64 {
+	if s == "" {
+		return def
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Fatalf("invalid integer %q: %v", s, err)
+	}
+	return v
+}
+
+func defaultFloat(s string, def float64) float64 {
+	if s == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		log.Fatalf("invalid number %q: %v", s, err)
+	}
+	return v
+}
+
+func encryptData(keyHex, in, out string) error {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return fmt.Errorf("invalid PROBLEM_DATA_KEY: %w", err)
+	}
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := datacache.Encrypt(dst, src, key); err != nil {
+		dst.Close()
+		os.Remove(out)
+		return err
+	}
+	return dst.Close()
+}
+
 func main() {
 	conf := &config.ManagerConfig{}
 	conf.Endpoint = flag.String("endpoint", defaultValue(os.Getenv("ENDPOINT"), "https://hpcgame.pku.edu.cn"), "API endpoint")
 	conf.RunnerID = flag.String("runner-id", os.Getenv("RUNNER_ID"), "Runner ID")
 	conf.RunnerKey = flag.String("runner-key", os.Getenv("RUNNER_KEY"), "Runner Key")
+	conf.WorkDir = flag.String("work-dir", os.Getenv("WORK_DIR"), "Root of per-runner temp directories (default: system temp dir)")
+	conf.CacheDir = flag.String("cache-dir", os.Getenv("CACHE_DIR"), "Problem data cache directory (default: <work-dir>/lfs-auto-grader/cache)")
+	conf.LogLevel = flag.String("log-level", defaultValue(os.Getenv("LOG_LEVEL"), "info"), "Minimum log level: debug, info, warn or error")
+	conf.LogFormat = flag.String("log-format", defaultValue(os.Getenv("LOG_FORMAT"), logging.FormatText), "Log format: text, or json with level, runner and solution fields for Loki/ELK")
+	conf.PollMinInterval = flag.Duration("poll-min-interval", defaultDuration(os.Getenv("POLL_MIN_INTERVAL"), 250*time.Millisecond), "Minimum poll interval")
+	conf.PollMaxInterval = flag.Duration("poll-max-interval", defaultDuration(os.Getenv("POLL_MAX_INTERVAL"), 5*time.Second), "Maximum poll interval when idle")
+	conf.LongPollTimeout = flag.Duration("long-poll-timeout", defaultDuration(os.Getenv("LONG_POLL_TIMEOUT"), 0), "Server-side long-poll wait (0 to disable)")
+	conf.APITimeout = flag.Duration("api-timeout", defaultDuration(os.Getenv("API_TIMEOUT"), 30*time.Second), "Timeout of a single AOI API call, excluding the long-poll wait (0 to disable)")
+	conf.APIUploadTimeout = flag.Duration("api-upload-timeout", defaultDuration(os.Getenv("API_UPLOAD_TIMEOUT"), 5*time.Minute), "Timeout of uploading details and logs to AOI (0 to disable)")
+	conf.APIKeepAlive = flag.Duration("api-keep-alive", defaultDuration(os.Getenv("API_KEEP_ALIVE"), 30*time.Second), "TCP keep-alive interval of connections to AOI")
+	conf.APIMaxIdleConns = flag.Int("api-max-idle-conns", int(defaultInt64(os.Getenv("API_MAX_IDLE_CONNS"), 100)), "Maximum idle connections kept to AOI")
+	conf.APIIdleConnTimeout = flag.Duration("api-idle-conn-timeout", defaultDuration(os.Getenv("API_IDLE_CONN_TIMEOUT"), 90*time.Second), "How long idle connections to AOI are kept")
+	conf.Workers = flag.Int("workers", int(defaultInt64(os.Getenv("WORKERS"), 1)), "Number of solutions judged concurrently, fetched in batches when several workers are free")
+	conf.PushDispatch = flag.Bool("push", os.Getenv("PUSH_DISPATCH") == "true", "Receive solutions via WebSocket push, falling back to polling")
+	conf.ScopedTokens = flag.Bool("scoped-tokens", os.Getenv("SCOPED_TOKENS") == "true", "Use per-operation scoped tokens when the platform supports them")
+	conf.AdapterTimeout = flag.Duration("adapter-timeout", defaultDuration(os.Getenv("ADAPTER_TIMEOUT"), 30*time.Second), "Timeout for manager-side adapters")
+	conf.AdapterMaxInputSize = flag.Int64("adapter-max-input-size", defaultInt64(os.Getenv("ADAPTER_MAX_INPUT_SIZE"), 64<<20), "Maximum report size in bytes accepted by adapters")
+	conf.AdapterMaxTests = flag.Int("adapter-max-tests", int(defaultInt64(os.Getenv("ADAPTER_MAX_TESTS"), 1000)), "Maximum number of tests shown in solution details; extra tests are truncated")
+	conf.AdapterDir = flag.String("adapter-dir", os.Getenv("ADAPTER_DIR"), "Directory of trusted external adapters usable as exec:<name>")
+	conf.SeccompDir = flag.String("seccomp-dir", os.Getenv("SECCOMP_DIR"), "Directory of named seccomp profiles (<name>.json)")
+	conf.SeccompProfile = flag.String("seccomp-profile", os.Getenv("SECCOMP_PROFILE"), "Default seccomp profile name")
+	conf.AppArmorProfile = flag.String("apparmor-profile", os.Getenv("APPARMOR_PROFILE"), "Default AppArmor profile name")
+	conf.AllowedSecurityProfiles = flag.String("allowed-security-profiles", os.Getenv("ALLOWED_SECURITY_PROFILES"), "Comma-separated profiles judge configs may select, e.g. seccomp:unconfined,apparmor:judge-gpu,selinux:type:container_t")
+	conf.DefaultUser = flag.String("default-user", defaultValue(os.Getenv("DEFAULT_USER"), "1000:1000"), "Default container user (uid:gid)")
+	conf.AllowRoot = flag.Bool("allow-root", os.Getenv("ALLOW_ROOT") == "true", "Allow judge configs to run containers as root")
+	conf.EnvDenylist = flag.String("env-denylist", defaultValue(os.Getenv("ENV_DENYLIST"), "LD_*,DOCKER_*,HTTP_PROXY,HTTPS_PROXY,FTP_PROXY,ALL_PROXY,NO_PROXY"), "Comma-separated env names (globs allowed) judge configs may not set")
+	conf.EnvAllowlist = flag.String("env-allowlist", os.Getenv("ENV_ALLOWLIST"), "If set, the only env names (globs allowed) judge configs may set")
+	conf.ResultWebhook = flag.String("result-webhook", os.Getenv("RESULT_WEBHOOK"), "URL to mirror final verdicts to")
+	conf.WebhookSecret = flag.String("webhook-secret", os.Getenv("WEBHOOK_SECRET"), "HMAC secret for signing webhook payloads")
+	conf.ErrorReportDSN = flag.String("error-report-dsn", defaultValue(os.Getenv("ERROR_REPORT_DSN"), os.Getenv("SENTRY_DSN")), "Sentry DSN to report panics, container execution failures and report parse failures to, empty to disable")
+	conf.IncidentWebhook = flag.String("incident-webhook", os.Getenv("INCIDENT_WEBHOOK"), "Comma-separated webhook URLs for runner incidents: poll failures, docker outages, disk pressure, repeated internal errors")
+	conf.IncidentWebhookFormat = flag.String("incident-webhook-format", defaultValue(os.Getenv("INCIDENT_WEBHOOK_FORMAT"), "json"), "Incident webhook payload format: json, slack or feishu")
+	conf.IncidentCooldown = flag.Duration("incident-cooldown", defaultDuration(os.Getenv("INCIDENT_COOLDOWN"), 30*time.Minute), "Minimum interval between repeated incidents about the same subject")
+	conf.IncidentPollFailures = flag.Int("incident-poll-failures", int(defaultInt64(os.Getenv("INCIDENT_POLL_FAILURES"), 5)), "Consecutive poll failures before an incident is raised")
+	conf.IncidentProblemErrors = flag.Int("incident-problem-errors", int(defaultInt64(os.Getenv("INCIDENT_PROBLEM_ERRORS"), 3)), "Internal errors on one problem within 10 minutes before an incident is raised")
+	conf.CapabilityReportInterval = flag.Duration("capability-report-interval", defaultDuration(os.Getenv("CAPABILITY_REPORT_INTERVAL"), time.Hour), "How often to report the runner version, executor and resources to AOI (0 to report only on startup)")
+	conf.ColdStartSLO = flag.Duration("cold-start-slo", defaultDuration(os.Getenv("COLD_START_SLO"), 30*time.Second), "Latency budget from poll to running (0 to disable)")
+	conf.ColdStartViolations = flag.Int("cold-start-violations", int(defaultInt64(os.Getenv("COLD_START_VIOLATIONS"), 3)), "Consecutive SLO violations per image before prefetch and alert")
+	conf.PrefetchInterval = flag.Duration("prefetch-interval", defaultDuration(os.Getenv("PREFETCH_INTERVAL"), 10*time.Minute), "How often to pull hot and cold-start-violating images on every host ahead of time (0 to disable)")
+	conf.PrefetchImages = flag.Int("prefetch-images", int(defaultInt64(os.Getenv("PREFETCH_IMAGES"), 5)), "Number of most used images in the last hour to prefetch")
+	conf.SecretsFile = flag.String("secrets-file", os.Getenv("SECRETS_FILE"), "File of KEY=VALUE secrets injectable into judge containers")
+	conf.VaultAddr = flag.String("vault-addr", os.Getenv("VAULT_ADDR"), "Vault address for judge secrets")
+	conf.VaultToken = flag.String("vault-token", os.Getenv("VAULT_TOKEN"), "Vault token")
+	conf.VaultPath = flag.String("vault-path", os.Getenv("VAULT_PATH"), "Vault KV v2 path holding judge secrets")
+	conf.CPUSet = flag.String("cpuset", os.Getenv("CPUSET"), "Cores this runner may pin jobs to, e.g. 0-15; unpinned jobs avoid them (default: all online cores)")
+	conf.IPv6SubnetPool = flag.String("ipv6-subnet-pool", os.Getenv("IPV6_SUBNET_POOL"), "IPv6 prefix to carve per-job /64 subnets from (empty for IPv4 only)")
+	conf.ShapingImage = flag.String("shaping-image", defaultValue(os.Getenv("SHAPING_IMAGE"), "nicolaka/netshoot"), "Helper image with tc used to apply network shaping")
+
+	conf.FlakyThreshold = flag.Float64("flaky-threshold", defaultFloat(os.Getenv("FLAKY_THRESHOLD"), 0.3), "Pass/fail flip rate at which a test is flagged as flaky")
+	conf.FlakyRetry = flag.Bool("flaky-retry", os.Getenv("FLAKY_RETRY") == "true", "Re-run failed flaky-flagged tests once before finalizing the score")
+	conf.LogUploadLimit = flag.Int64("log-upload-limit", defaultInt64(os.Getenv("LOG_UPLOAD_LIMIT"), 1<<20), "Bytes of container output uploaded to AOI per solution (0 to disable)")
+	conf.LogDir = flag.String("log-dir", os.Getenv("LOG_DIR"), "Directory for per-solution container logs (default: <work-dir>/lfs-auto-grader/<runner-id>/logs)")
+	conf.LogMaxSize = flag.Int64("log-max-size", defaultInt64(os.Getenv("LOG_MAX_SIZE"), 16<<20), "Size in bytes at which a local solution log is rotated")
+	conf.LogTTL = flag.Duration("log-ttl", defaultDuration(os.Getenv("LOG_TTL"), 30*24*time.Hour), "How long local solution logs are kept (0 to keep forever)")
+	conf.ArtifactMaxSize = flag.Int64("artifact-max-size", defaultInt64(os.Getenv("ARTIFACT_MAX_SIZE"), 4<<20), "Bytes per artifact a container may push through judgerproto (0 to disable)")
+	conf.ArtifactMaxTotal = flag.Int64("artifact-max-total", defaultInt64(os.Getenv("ARTIFACT_MAX_TOTAL"), 16<<20), "Total bytes of artifacts a container may push per solution")
+	conf.CoreDumpTarget = flag.String("core-dump-target", defaultValue(os.Getenv("CORE_DUMP_TARGET"), "/cores"), "Directory inside judge containers that kernel.core_pattern writes cores to")
+	conf.GPUDevices = flag.String("gpus", os.Getenv("GPU_DEVICES"), "Comma-separated GPU device IDs (or MIG UUIDs) this runner may allocate")
+	conf.GPUSampleInterval = flag.Duration("gpu-sample-interval", defaultDuration(os.Getenv("GPU_SAMPLE_INTERVAL"), 2*time.Second), "How often to sample utilization and memory of a job's GPUs via nvidia-smi (0 to disable)")
+	conf.GPUSlots = flag.Int64("gpu-slots", defaultInt64(os.Getenv("GPU_SLOTS"), 1), "Number of shares each GPU is split into for problems requesting a fraction of a GPU")
+	conf.GPULockDir = flag.String("gpu-lock-dir", os.Getenv("GPU_LOCK_DIR"), "Host-wide directory of GPU lock files shared by every runner on the host (default: <tmp>/lfs-auto-grader-gpu-locks)")
+	conf.TrustedHookImages = flag.String("trusted-hook-images", os.Getenv("TRUSTED_HOOK_IMAGES"), "Comma-separated images judge configs may use for pre/post commands")
+	conf.TrustedServiceImages = flag.String("trusted-service-images", os.Getenv("TRUSTED_SERVICE_IMAGES"), "Comma-separated images judge configs may use for services (empty for any image)")
+	conf.MPIListen = flag.String("mpi-listen", os.Getenv("MPI_LISTEN"), "Address to accept MPI worker requests from lead runners on, empty to disable")
+	conf.MPIPeers = flag.String("mpi-peers", os.Getenv("MPI_PEERS"), "Comma-separated RPC addresses of peer runners used as MPI workers")
+	conf.MPIToken = flag.String("mpi-token", os.Getenv("MPI_TOKEN"), "Shared token authenticating RPC between runners")
+	conf.MPIAdvertise = flag.String("mpi-advertise", os.Getenv("MPI_ADVERTISE"), "Address of this node written to MPI hostfiles, defaults to the hostname")
+	conf.MPITLSCert = flag.String("mpi-tls-cert", os.Getenv("MPI_TLS_CERT"), "Certificate file for serving MPI RPC over TLS, plain HTTP (trusted networks only) when empty")
+	conf.MPITLSKey = flag.String("mpi-tls-key", os.Getenv("MPI_TLS_KEY"), "Private key file for mpi-tls-cert")
+	conf.MPITLSCA = flag.String("mpi-tls-ca", os.Getenv("MPI_TLS_CA"), "CA file used to verify https:// MPI peers, system roots when empty")
+	conf.DockerHosts = flag.String("docker-hosts", os.Getenv("DOCKER_HOSTS"), "Comma-separated Docker daemon addresses to place jobs on, empty to use DOCKER_HOST")
+	conf.ExecutorBackend = flag.String("executor", defaultValue(os.Getenv("EXECUTOR_BACKEND"), "docker"), "Container runtime backend: docker or nerdctl (containerd without dockerd)")
+	conf.ContainerdNamespace = flag.String("containerd-namespace", defaultValue(os.Getenv("CONTAINERD_NAMESPACE"), "lfs-auto-grader"), "containerd namespace used by the nerdctl backend")
+	conf.AllowedRuntimes = flag.String("allowed-runtimes", os.Getenv("ALLOWED_RUNTIMES"), "Comma-separated OCI runtimes judge configs may select, e.g. runsc,kata-runtime")
+	conf.AllowedDevices = flag.String("allowed-devices", os.Getenv("ALLOWED_DEVICES"), "Comma-separated host device paths (globs allowed) judge configs may pass through, e.g. /dev/infiniband/*,/dev/kfd")
+	conf.CheckpointDir = flag.String("checkpoint-dir", os.Getenv("CHECKPOINT_DIR"), "Shared directory where running jobs are checkpointed on drain and resumed by other runners")
+	conf.CancelPollInterval = flag.Duration("cancel-poll-interval", defaultDuration(os.Getenv("CANCEL_POLL_INTERVAL"), 15*time.Second), "How often to check whether the running solution was cancelled (0 to rely on push only)")
+	conf.AdminListen = flag.String("admin-listen", os.Getenv("ADMIN_LISTEN"), "Address for the local admin API, e.g. 127.0.0.1:9090, empty to disable")
+	conf.AdminToken = flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "Bearer token required by the admin API")
+	conf.MetricsListen = flag.String("metrics-listen", os.Getenv("METRICS_LISTEN"), "Address serving Prometheus metrics at /metrics without authentication, e.g. 10.0.0.5:9100, empty to disable")
+	conf.ScratchDir = flag.String("scratch-dir", os.Getenv("SCRATCH_DIR"), "Root of per-job output and other temp dirs, e.g. local NVMe or tmpfs (default: the runner's work dir)")
+	conf.ScratchJobLimit = flag.Int64("scratch-job-limit", defaultInt64(os.Getenv("SCRATCH_JOB_LIMIT"), 0), "Bytes a job may write to its output dir before it is stopped (0 to disable)")
+	conf.ScratchTotalLimit = flag.Int64("scratch-total-limit", defaultInt64(os.Getenv("SCRATCH_TOTAL_LIMIT"), 0), "Bytes of scratch usage at which polling pauses until jobs free space (0 to disable)")
+	conf.BuildCacheDir = flag.String("build-cache-dir", os.Getenv("BUILD_CACHE_DIR"), "Host root of build caches judge configs may mount (pip, npm, ccache, cargo, go, uv), empty to disable")
+	conf.BuildCacheConfig = flag.String("build-cache-config", os.Getenv("BUILD_CACHE_CONFIG"), "JSON file of extra or overriding build caches: name -> {target, env, readOnly}")
+	conf.CompilerCacheSize = flag.Int64("compiler-cache-size", defaultInt64(os.Getenv("COMPILER_CACHE_SIZE"), 5<<30), "Size limit in bytes of each ccache/sccache dir, enforced by the cache tool")
+	conf.CompilerCacheTotal = flag.Int64("compiler-cache-total", defaultInt64(os.Getenv("COMPILER_CACHE_TOTAL"), 0), "Total bytes of compiler cache dirs above which least recently used ones are evicted by GC (0 to disable)")
+	conf.GCInterval = flag.Duration("gc-interval", defaultDuration(os.Getenv("GC_INTERVAL"), 10*time.Minute), "How often to reclaim disk space (0 to disable)")
+	conf.GCDiskPath = flag.String("gc-disk-path", defaultValue(os.Getenv("GC_DISK_PATH"), "/var/lib/docker"), "Path whose filesystem usage triggers judge image pruning, usually the container runtime data root")
+	conf.GCHighWatermark = flag.Float64("gc-high-watermark", defaultFloat(os.Getenv("GC_HIGH_WATERMARK"), 0.85), "Disk usage ratio above which least recently used judge images are pruned")
+	conf.GCLowWatermark = flag.Float64("gc-low-watermark", defaultFloat(os.Getenv("GC_LOW_WATERMARK"), 0.70), "Disk usage ratio image pruning stops at")
+	conf.GCTempTTL = flag.Duration("gc-temp-ttl", defaultDuration(os.Getenv("GC_TEMP_TTL"), 6*time.Hour), "Age at which temp dirs not owned by a running job are removed")
+	conf.DefaultTimeout = flag.Int64("default-timeout", defaultInt64(os.Getenv("DEFAULT_TIMEOUT"), 600), "Timeout in seconds for problems that do not set one")
+	conf.MaxTimeout = flag.Int64("max-timeout", defaultInt64(os.Getenv("MAX_TIMEOUT"), 0), "Maximum timeout in seconds a problem may request (0 for no limit)")
+	conf.DefaultMemoryLimit = flag.Int64("default-memory-limit", defaultInt64(os.Getenv("DEFAULT_MEMORY_LIMIT"), 2048), "Memory limit in MB for problems that do not set one")
+	conf.MaxMemoryLimit = flag.Int64("max-memory-limit", defaultInt64(os.Getenv("MAX_MEMORY_LIMIT"), 0), "Maximum memory limit in MB a problem may request (0 for no limit)")
+	conf.DefaultCPULimit = flag.Float64("default-cpu-limit", defaultFloat(os.Getenv("DEFAULT_CPU_LIMIT"), 0), "CPU limit in cores for problems that do not set one (0 for unlimited)")
+	conf.MaxCPULimit = flag.Float64("max-cpu-limit", defaultFloat(os.Getenv("MAX_CPU_LIMIT"), 0), "Maximum CPU limit in cores a problem may request (0 for no limit)")
+	conf.OutputLimit = flag.Int64("output-limit", defaultInt64(os.Getenv("OUTPUT_LIMIT"), 64), "Total stdout and stderr in MB for problems that do not set outputLimit")
+	conf.MaxOutputLimit = flag.Int64("max-output-limit", defaultInt64(os.Getenv("MAX_OUTPUT_LIMIT"), 0), "Maximum outputLimit in MB a problem may request (0 for no limit)")
+	conf.MaxHugePages = flag.Int64("max-hugepages", defaultInt64(os.Getenv("MAX_HUGEPAGES"), 0), "Maximum hugetlbfs size in MB a problem may request (0 to disallow huge pages)")
+	conf.SimilarityConfig = flag.String("similarity-config", os.Getenv("SIMILARITY_CONFIG"), "JSON file of per-contest similarity checks run after judging: contest ID or * -> {url | command, language, timeout, problems}")
+	conf.WarmPoolSize = flag.Int("warm-pool-size", int(defaultInt64(os.Getenv("WARM_POOL_SIZE"), 0)), "Idle pre-started judge containers kept per hot problem that opts in with warm_pool (0 to disable)")
+	conf.WarmPoolProblems = flag.Int("warm-pool-problems", int(defaultInt64(os.Getenv("WARM_POOL_PROBLEMS"), 3)), "Number of most frequently judged problems to keep warm containers for")
+	conf.WarmPoolIdle = flag.Duration("warm-pool-idle", defaultDuration(os.Getenv("WARM_POOL_IDLE"), 30*time.Minute), "How long a warm container may stay idle before it is removed")
+	conf.AdmissionCPURatio = flag.Float64("admission-cpu-ratio", defaultFloat(os.Getenv("ADMISSION_CPU_RATIO"), 0), "Pause polling when CPU limits of running jobs plus the next job would exceed this multiple of host cores (0 to disable)")
+	conf.AdmissionMemoryRatio = flag.Float64("admission-memory-ratio", defaultFloat(os.Getenv("ADMISSION_MEMORY_RATIO"), 0), "Pause polling when memory limits of running jobs plus the next job would exceed this fraction of host memory (0 to disable)")
+	conf.RecordDir = flag.String("record-dir", os.Getenv("RECORD_DIR"), "Directory to record every solution payload and its results to for local replay, empty to disable")
+
+	// manager stats [flags]：输出历史运行统计
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		flag.CommandLine.Parse(os.Args[2:])
+		if err := manager.PrintStats(conf, os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// manager costs [flags] [user|problem|contest|solution]：按维度导出评测开销 CSV
+	if len(os.Args) > 1 && os.Args[1] == "costs" {
+		flag.CommandLine.Parse(os.Args[2:])
+		groupBy := "user"
+		if flag.NArg() > 0 {
+			groupBy = flag.Arg(0)
+		}
+		if err := manager.ExportCosts(conf, groupBy, os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// manager replay [flags] <record>：在本地通过完整流程重新评测录制的任务并与录制结果比较
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		flag.CommandLine.Parse(os.Args[2:])
+		if flag.NArg() != 1 {
+			log.Fatalln("usage: manager replay [flags] <record-dir>/<solution>-<task>")
+		}
+		if err := manager.Replay(conf, flag.Arg(0), os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// manager dry-run [flags] <judge-config.json>：输出解析后的执行配置，不启动容器
+	if len(os.Args) > 1 && os.Args[1] == "dry-run" {
+		flag.CommandLine.Parse(os.Args[2:])
+		if flag.NArg() != 1 {
+			log.Fatalln("usage: manager dry-run [flags] <judge-config.json|solution.json>")
+		}
+		if err := manager.DryRun(conf, flag.Arg(0), os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// manager stage-data [flags] <url> <hash>：预先下载加密的题目数据
+	if len(os.Args) > 1 && os.Args[1] == "stage-data" {
+		flag.CommandLine.Parse(os.Args[2:])
+		if flag.NArg() != 2 {
+			log.Fatalln("usage: manager stage-data [flags] <url> <hash>")
+		}
+		if err := manager.StageProblemData(conf, flag.Arg(0), flag.Arg(1)); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// manager encrypt-data <in> <out>：使用 PROBLEM_DATA_KEY（hex）加密题目数据
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-data" {
+		if len(os.Args) != 4 {
+			log.Fatalln("usage: PROBLEM_DATA_KEY=<hex> manager encrypt-data <in> <out>")
+		}
+		if err := encryptData(os.Getenv("PROBLEM_DATA_KEY"), os.Args[2], os.Args[3]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
 
 	flag.Parse()
 
+	if err := logging.Setup(os.Stderr, *conf.LogLevel, *conf.LogFormat, *conf.RunnerID); err != nil {
+		log.Fatalln(err)
+	}
+
 	s := manager.NewManager(conf)
 
 	if err := s.Init(); err != nil {
 		log.Fatalln(err)
 	}
 
-	if err := s.Start(); err != nil {
+	// 收到 SIGINT / SIGTERM 时停止领取新任务，等待当前评测完成后退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 收到 SIGUSR1 时排空：运行中的评测保存检查点交给其他 runner 恢复，然后退出
+	drain := make(chan os.Signal, 1)
+	signal.Notify(drain, syscall.SIGUSR1)
+	go func() {
+		for range drain {
+			s.Drain()
+		}
+	}()
+
+	if err := s.Start(ctx); err != nil {
 		log.Fatalln(err)
 	}
+	if err := s.Close(); err != nil {
+		log.Println("Failed to close manager:", err)
+	}
 }
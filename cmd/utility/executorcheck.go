@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/urfave/cli/v2"
+)
+
+func executorCheckCommand(app *cli.App) {
+	app.Commands = append(app.Commands, &cli.Command{
+		Name:  "executor-check",
+		Usage: "Run the executor conformance suite against a real container runtime",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "backend",
+				Usage:   "Executor backend: docker or nerdctl",
+				Value:   "docker",
+				EnvVars: []string{"EXECUTOR_BACKEND"},
+			},
+			&cli.StringFlag{
+				Name:    "host",
+				Usage:   "Docker daemon or containerd socket address (default from environment)",
+				EnvVars: []string{"DOCKER_HOST"},
+			},
+			&cli.StringFlag{
+				Name:    "namespace",
+				Usage:   "containerd namespace for the nerdctl backend",
+				Value:   "lfs-auto-grader",
+				EnvVars: []string{"CONTAINERD_NAMESPACE"},
+			},
+			&cli.StringFlag{
+				Name:  "image",
+				Usage: "Image used by the checks",
+				Value: executortest.DefaultImage,
+			},
+		},
+		Action: executorCheckHandler,
+	})
+}
+
+func executorCheckHandler(c *cli.Context) error {
+	var exec executor.Executor
+	var err error
+	switch c.String("backend") {
+	case "docker":
+		exec, err = executor.NewDockerExecutor(c.String("host"))
+	case "nerdctl":
+		exec, err = executor.NewNerdctlExecutor(c.String("host"), c.String("namespace"))
+	default:
+		err = fmt.Errorf("unknown executor backend %q", c.String("backend"))
+	}
+	if err != nil {
+		return err
+	}
+	defer exec.Close()
+
+	failed := 0
+	_, err = executortest.RunSuite(context.Background(), exec, c.String("image"), func(r executortest.Result) {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("FAIL %-14s %s: %v\n", r.Name, r.Duration.Round(time.Millisecond), r.Err)
+		} else {
+			fmt.Printf("ok   %-14s %s\n", r.Name, r.Duration.Round(time.Millisecond))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(executortest.Cases))
+	}
+	return nil
+}
@@ -49,6 +49,7 @@ func main() {
 
 	registerCommand(app)
 	pollCommand(app)
+	executorCheckCommand(app)
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -7,6 +7,7 @@ require (
 	github.com/fedstackjs/azukiiro v0.1.8
 	github.com/go-resty/resty/v2 v2.12.0
 	github.com/urfave/cli/v2 v2.27.5
+	golang.org/x/net v0.47.0
 )
 
 require (
@@ -51,7 +52,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.39.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
-	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 	golang.org/x/time v0.7.0 // indirect
@@ -0,0 +1,216 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// BenchmarkReportName benchmark1 adapter 的默认报告文件名（pytest --benchmark-json=/output/benchmark.json）
+const BenchmarkReportName = "benchmark.json"
+
+// BenchmarkStats pytest-benchmark 的统计结果（秒）
+type BenchmarkStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Rounds int     `json:"rounds"`
+}
+
+// Benchmark pytest-benchmark 单个测试的结果
+type Benchmark struct {
+	Name     string         `json:"name"`
+	Fullname string         `json:"fullname"`
+	Stats    BenchmarkStats `json:"stats"`
+}
+
+// BenchmarkReport pytest-benchmark --benchmark-json 产出的 JSON 结构
+type BenchmarkReport struct {
+	Benchmarks []Benchmark `json:"benchmarks"`
+}
+
+// BenchmarkTarget 单个测试的计分标准，在 rc.Variables["benchmarks"] 中按测试名（name 或 fullname）声明。
+// baseline 与 target 为运行时间（秒），运行时间不优于 baseline 得 0 分，达到 target 得满分
+type BenchmarkTarget struct {
+	Curve
+	Weight float64 `json:"weight"` // 权重，默认 1
+	Metric string  `json:"metric"` // 使用的统计量：min / mean / median（默认）
+}
+
+// ParseBenchmarkReport 从文件解析 pytest-benchmark JSON 报告
+func ParseBenchmarkReport(filepath string) (*BenchmarkReport, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var report BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, jsonSyntaxError(data, err)
+	}
+	if report.Benchmarks == nil {
+		return nil, &ReportError{Problems: []string{"benchmarks 缺失（是否使用了 --benchmark-json？）"}}
+	}
+	var problems reportProblems
+	for i, b := range report.Benchmarks {
+		if b.Name == "" {
+			problems.addf("benchmarks[%d].name 缺失", i)
+		}
+		if b.Stats.Rounds == 0 {
+			problems.addf("benchmarks[%d].stats 缺失或 rounds 为 0", i)
+		}
+	}
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ParseBenchmarkTargets 从题目变量中读取各测试的计分标准
+func ParseBenchmarkTargets(vars map[string]any) (map[string]BenchmarkTarget, error) {
+	raw, ok := vars["benchmarks"]
+	if !ok {
+		return nil, fmt.Errorf("benchmark targets are not declared in variables.benchmarks")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var targets map[string]BenchmarkTarget
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("invalid variables.benchmarks: %w", err)
+	}
+	for name, t := range targets {
+		if t.Baseline <= t.Target || t.Target <= 0 {
+			return nil, fmt.Errorf("benchmark %s: baseline must be greater than target and target must be positive", name)
+		}
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("benchmark %s: %w", name, err)
+		}
+	}
+	return targets, nil
+}
+
+// runtime 返回计分使用的运行时间
+func (b *Benchmark) runtime(metric string) float64 {
+	switch metric {
+	case "min":
+		return b.Stats.Min
+	case "mean":
+		return b.Stats.Mean
+	default:
+		return b.Stats.Median
+	}
+}
+
+// CalculateBenchmarkScore 根据测得的运行时间与声明的计分标准计算加权分数，
+// 已声明但未出现在报告中的测试（如运行失败）计 0 分，未声明的测试仅展示不计分
+func CalculateBenchmarkScore(report *BenchmarkReport, targets map[string]BenchmarkTarget) *LFS1Result {
+	found := make(map[string]bool)
+	var totalWeight, weighted float64
+	reached := 0
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0, len(report.Benchmarks))
+	for i := range report.Benchmarks {
+		b := &report.Benchmarks[i]
+		key := b.Fullname
+		t, ok := targets[key]
+		if !ok {
+			key = b.Name
+			t, ok = targets[key]
+		}
+		if !ok {
+			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+				Name:       extractTestName(b.Name),
+				Score:      0,
+				ScoreScale: 0,
+				Status:     aoiclient.StatusAccepted,
+				Summary:    fmt.Sprintf("未配置计分标准，运行时间 %s", formatDuration(b.runtime(""))),
+				Tests:      []*aoiclient.SolutionDetailsTest{},
+			})
+			continue
+		}
+		found[key] = true
+
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		runtime := b.runtime(t.Metric)
+		score := t.Score(runtime)
+		totalWeight += weight
+		weighted += score * weight
+
+		status := aoiclient.StatusWrongAnswer
+		if score >= 100 {
+			status = aoiclient.StatusAccepted
+			reached++
+		}
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       extractTestName(b.Name),
+			Score:      score,
+			ScoreScale: 100,
+			Status:     status,
+			Summary: fmt.Sprintf("运行时间 %s（基线 %s，目标 %s，%d 轮）",
+				formatDuration(runtime), formatDuration(t.Baseline), formatDuration(t.Target), b.Stats.Rounds),
+			Tests: []*aoiclient.SolutionDetailsTest{},
+		})
+	}
+
+	missing := 0
+	for _, name := range slices.Sorted(maps.Keys(targets)) {
+		if found[name] {
+			continue
+		}
+		t := targets[name]
+		missing++
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += weight
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       extractTestName(name),
+			Score:      0,
+			ScoreScale: 100,
+			Status:     aoiclient.StatusRuntimeError,
+			Summary:    "未找到测量结果（测试未运行或运行失败）",
+			Tests:      []*aoiclient.SolutionDetailsTest{},
+		})
+	}
+
+	var score float64
+	var status, message string
+	count := len(found) + missing
+	switch {
+	case count == 0:
+		status = aoiclient.StatusInternalError
+		message = "未找到任何计分的性能测试"
+	default:
+		score = weighted / totalWeight
+		if reached == count {
+			status = aoiclient.StatusAccepted
+			message = fmt.Sprintf("全部 %d 项性能测试达到目标", count)
+		} else {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("%d/%d 项性能测试达到目标，得分 %.2f", reached, count, score)
+		}
+		if missing > 0 {
+			message += fmt.Sprintf("，%d 项未运行", missing)
+		}
+	}
+
+	return &LFS1Result{
+		Score:   score,
+		Status:  status,
+		Message: message,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: message,
+			Jobs:    jobs,
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package adapters
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// CargoReportName cargo1 adapter 的默认报告文件名，
+// 评测脚本应将 cargo test -- -Z unstable-options --format json 的标准输出写入该文件
+const CargoReportName = "cargo-test.json"
+
+// CargoEvent libtest JSON 输出中的一行事件
+type CargoEvent struct {
+	Type     string  `json:"type"`  // suite / test / bench
+	Event    string  `json:"event"` // started / ok / failed / ignored / timeout（超过 60 秒仍在运行的警告，之后仍会给出结果）
+	Name     string  `json:"name"`
+	Stdout   string  `json:"stdout"`
+	Message  string  `json:"message"`
+	ExecTime float64 `json:"exec_time"`
+}
+
+// CargoTest 单个测试的最终结果
+type CargoTest struct {
+	Name     string
+	Outcome  string // ok / failed / ignored
+	Duration float64
+	Output   string
+	Slow     bool // 运行期间 libtest 报告过超时警告
+}
+
+// CargoReport 一次 cargo test 的全部测试结果，多个测试二进制的结果按出现顺序合并
+type CargoReport struct {
+	Tests []CargoTest
+}
+
+// ParseCargoReport 从文件解析 libtest JSON 输出，忽略非 JSON 行（如 cargo 自身的编译输出）
+func ParseCargoReport(filepath string) (*CargoReport, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	defer f.Close()
+
+	report := &CargoReport{}
+	slow := make(map[string]bool)
+	events := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var ev CargoEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			continue
+		}
+		events++
+		if ev.Type != "test" {
+			continue
+		}
+		switch ev.Event {
+		case "timeout":
+			// 只是运行时间过长的警告，测试结束后还会有 ok / failed 事件
+			slow[ev.Name] = true
+		case "ok", "failed", "ignored":
+			output := ev.Stdout
+			if output == "" {
+				output = ev.Message
+			}
+			report.Tests = append(report.Tests, CargoTest{
+				Name:     ev.Name,
+				Outcome:  ev.Event,
+				Duration: ev.ExecTime,
+				Output:   output,
+				Slow:     slow[ev.Name],
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	if events == 0 {
+		return nil, &ReportError{Problems: []string{"未找到 libtest JSON 事件（是否使用了 -Z unstable-options --format json？）"}}
+	}
+	return report, nil
+}
+
+// cargoSummary 生成测试用例的摘要信息（包含运行时间）
+func cargoSummary(test *CargoTest) string {
+	var summary string
+	switch test.Outcome {
+	case "ok":
+		summary = "通过"
+	case "ignored":
+		summary = "跳过"
+	default:
+		summary = "测试失败"
+		// panic 信息通常位于输出开头
+		if output := strings.TrimSpace(test.Output); output != "" {
+			if len(output) > 200 {
+				output = output[:200] + "..."
+			}
+			summary = output
+		}
+	}
+	if test.Slow {
+		summary += "，运行超过 60 秒"
+	}
+	return fmt.Sprintf("%s (%s)", summary, formatDuration(test.Duration))
+}
+
+// CalculateCargoScore 根据 cargo test 结果计算分数
+// 分数 = (passed / total) * 100，ignored 的测试不计入总数
+func CalculateCargoScore(report *CargoReport) *LFS1Result {
+	passed, failed, ignored := 0, 0, 0
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0, len(report.Tests))
+	for i := range report.Tests {
+		test := &report.Tests[i]
+		var score float64
+		status := aoiclient.StatusWrongAnswer
+		switch test.Outcome {
+		case "ok":
+			passed++
+			score = 100
+			status = aoiclient.StatusAccepted
+		case "ignored":
+			ignored++
+			status = "Skipped"
+		default:
+			failed++
+		}
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       test.Name,
+			Score:      score,
+			ScoreScale: 1,
+			Status:     status,
+			Summary:    cargoSummary(test),
+			Tests:      []*aoiclient.SolutionDetailsTest{},
+		})
+	}
+
+	total := passed + failed
+	var status, message string
+	var score float64
+	if total == 0 {
+		status = aoiclient.StatusInternalError
+		message = "未找到任何测试用例"
+	} else {
+		score = float64(passed) / float64(total) * 100
+		if failed == 0 {
+			status = aoiclient.StatusAccepted
+			message = fmt.Sprintf("全部通过 %d/%d 测试点", passed, total)
+		} else if passed > 0 {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("通过 %d/%d 测试点，失败 %d 个", passed, total, failed)
+		} else {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("未通过任何测试点 (0/%d)", total)
+		}
+	}
+	if ignored > 0 {
+		message += fmt.Sprintf("，跳过 %d 个", ignored)
+	}
+
+	return &LFS1Result{
+		Score:   score,
+		Status:  status,
+		Message: message,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: message,
+			Jobs:    jobs,
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+func writeReport(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCargoScore(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		report string
+		score  float64
+		status string
+		tests  int
+	}{
+		{
+			name: "all pass",
+			report: `   Compiling demo v0.1.0 (/work)
+    Finished test [unoptimized + debuginfo] target(s) in 0.52s
+     Running unittests src/lib.rs (target/debug/deps/demo-1a2b3c)
+{ "type": "suite", "event": "started", "test_count": 2 }
+{ "type": "test", "event": "started", "name": "tests::add" }
+{ "type": "test", "event": "started", "name": "tests::sub" }
+{ "type": "test", "name": "tests::add", "event": "ok", "exec_time": 0.001 }
+{ "type": "test", "name": "tests::sub", "event": "ok", "exec_time": 0.002 }
+{ "type": "suite", "event": "ok", "passed": 2, "failed": 0, "ignored": 0, "measured": 0, "filtered_out": 0, "exec_time": 0.003 }`,
+			score:  100,
+			status: aoiclient.StatusAccepted,
+			tests:  2,
+		},
+		{
+			name: "failed and ignored",
+			report: `{ "type": "suite", "event": "started", "test_count": 3 }
+{ "type": "test", "event": "started", "name": "tests::add" }
+{ "type": "test", "event": "started", "name": "tests::div" }
+{ "type": "test", "name": "tests::add", "event": "ok", "exec_time": 0.001 }
+{ "type": "test", "name": "tests::div", "event": "failed", "exec_time": 0.001, "stdout": "thread 'tests::div' panicked at src/lib.rs:12:9:\nattempt to divide by zero\n" }
+{ "type": "test", "name": "tests::slow", "event": "ignored" }
+{ "type": "suite", "event": "failed", "passed": 1, "failed": 1, "ignored": 1, "measured": 0, "filtered_out": 0, "exec_time": 0.002 }`,
+			score:  50,
+			status: aoiclient.StatusWrongAnswer,
+			tests:  3,
+		},
+		{
+			// 超过 60 秒的测试先给出 timeout 警告，之后仍以 ok 结束
+			name: "slow test warning",
+			report: `{ "type": "suite", "event": "started", "test_count": 2 }
+{ "type": "test", "event": "started", "name": "tests::fast" }
+{ "type": "test", "event": "started", "name": "tests::big_input" }
+{ "type": "test", "name": "tests::fast", "event": "ok", "exec_time": 0.001 }
+{ "type": "test", "event": "timeout", "name": "tests::big_input" }
+{ "type": "test", "name": "tests::big_input", "event": "ok", "exec_time": 75.2 }
+{ "type": "suite", "event": "ok", "passed": 2, "failed": 0, "ignored": 0, "measured": 0, "filtered_out": 0, "exec_time": 75.2 }`,
+			score:  100,
+			status: aoiclient.StatusAccepted,
+			tests:  2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := ParseCargoReport(writeReport(t, CargoReportName, tc.report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := CalculateCargoScore(report)
+			if result.Score != tc.score || result.Status != tc.status {
+				t.Errorf("score = %v %q, want %v %q", result.Score, result.Status, tc.score, tc.status)
+			}
+			if len(result.Details.Jobs) != tc.tests {
+				t.Errorf("got %d jobs, want %d", len(result.Details.Jobs), tc.tests)
+			}
+		})
+	}
+}
+
+func TestCargoReportWithoutEvents(t *testing.T) {
+	if _, err := ParseCargoReport(writeReport(t, CargoReportName, "running 2 tests\ntest tests::add ... ok\n")); err == nil {
+		t.Fatal("plain libtest output parsed without error")
+	}
+}
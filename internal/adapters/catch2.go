@@ -0,0 +1,219 @@
+package adapters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// Catch2ReportName catch2 adapter 的默认报告文件名（--reporter xml --out /output/catch2.xml，建议加 -d yes 记录耗时）
+const Catch2ReportName = "catch2.xml"
+
+// Catch2Expression 一条断言
+type Catch2Expression struct {
+	Success  string `xml:"success,attr"`
+	Type     string `xml:"type,attr"` // REQUIRE / CHECK 等
+	Filename string `xml:"filename,attr"`
+	Line     int    `xml:"line,attr"`
+	Original string `xml:"Original"`
+	Expanded string `xml:"Expanded"`
+}
+
+// Catch2Message 异常、致命错误或显式 FAIL 的信息
+type Catch2Message struct {
+	Filename string `xml:"filename,attr"`
+	Line     int    `xml:"line,attr"`
+	Text     string `xml:",chardata"`
+}
+
+// Catch2Results section 的断言统计
+type Catch2Results struct {
+	Successes int `xml:"successes,attr"`
+	Failures  int `xml:"failures,attr"`
+}
+
+// Catch2Section 测试用例中的 section，可嵌套
+type Catch2Section struct {
+	Name                 string             `xml:"name,attr"`
+	Sections             []Catch2Section    `xml:"Section"`
+	Expressions          []Catch2Expression `xml:"Expression"`
+	Exceptions           []Catch2Message    `xml:"Exception"`
+	FatalErrorConditions []Catch2Message    `xml:"FatalErrorCondition"`
+	Failures             []Catch2Message    `xml:"Failure"`
+	OverallResults       Catch2Results      `xml:"OverallResults"`
+}
+
+// Catch2TestCase 单个测试用例，自身也可包含断言与 section
+type Catch2TestCase struct {
+	Name string `xml:"name,attr"`
+	Tags string `xml:"tags,attr"`
+	Catch2Section
+	OverallResult struct {
+		Success  string `xml:"success,attr"`
+		Duration string `xml:"durationInSeconds,attr"`
+	} `xml:"OverallResult"`
+}
+
+// Catch2Report Catch2 XML reporter 的输出，兼容 v2（Catch > Group > TestCase）与 v3（Catch2TestRun > TestCase）
+type Catch2Report struct {
+	XMLName   xml.Name
+	TestCases []Catch2TestCase `xml:"TestCase"`
+	Groups    []struct {
+		TestCases []Catch2TestCase `xml:"TestCase"`
+	} `xml:"Group"`
+}
+
+// ParseCatch2Report 从文件解析 Catch2 XML 报告
+func ParseCatch2Report(filepath string) (*Catch2Report, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var report Catch2Report
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, xmlSyntaxError(data, err)
+	}
+	if report.XMLName.Local != "Catch" && report.XMLName.Local != "Catch2TestRun" {
+		return nil, &ReportError{Problems: []string{fmt.Sprintf("根元素应为 <Catch2TestRun> 或 <Catch>，实际为 <%s>（是否使用了 --reporter xml？）", report.XMLName.Local)}}
+	}
+	for _, g := range report.Groups {
+		report.TestCases = append(report.TestCases, g.TestCases...)
+	}
+	report.Groups = nil
+	return &report, nil
+}
+
+// failureMessages 返回 section 自身（不含子 section）的失败信息
+func (s *Catch2Section) failureMessages() []string {
+	var messages []string
+	for _, e := range s.Expressions {
+		if e.Success == "true" {
+			continue
+		}
+		message := fmt.Sprintf("%s( %s )", e.Type, strings.TrimSpace(e.Original))
+		if expanded := strings.TrimSpace(e.Expanded); expanded != "" && expanded != strings.TrimSpace(e.Original) {
+			message += " with expansion: " + expanded
+		}
+		messages = append(messages, fmt.Sprintf("%s (line %d)", message, e.Line))
+	}
+	for _, list := range [][]Catch2Message{s.Exceptions, s.FatalErrorConditions, s.Failures} {
+		for _, m := range list {
+			messages = append(messages, fmt.Sprintf("%s (line %d)", strings.TrimSpace(m.Text), m.Line))
+		}
+	}
+	return messages
+}
+
+// collectSections 将嵌套 section 展开为 "外层 / 内层" 形式的测试，每个叶子 section 为一个测试
+func collectSections(prefix string, sections []Catch2Section, tests []*aoiclient.SolutionDetailsTest) []*aoiclient.SolutionDetailsTest {
+	for i := range sections {
+		s := &sections[i]
+		name := s.Name
+		if prefix != "" {
+			name = prefix + " / " + s.Name
+		}
+		messages := s.failureMessages()
+		if len(s.Sections) > 0 {
+			tests = collectSections(name, s.Sections, tests)
+			if len(messages) == 0 {
+				continue
+			}
+		}
+		test := &aoiclient.SolutionDetailsTest{
+			Name:       name,
+			Score:      100,
+			ScoreScale: 1,
+			Status:     aoiclient.StatusAccepted,
+			Summary:    "通过",
+		}
+		if len(messages) > 0 || s.OverallResults.Failures > 0 {
+			test.Score = 0
+			test.Status = aoiclient.StatusWrongAnswer
+			test.Summary = strings.Join(messages, "\n")
+			if test.Summary == "" {
+				test.Summary = "测试失败"
+			}
+		}
+		tests = append(tests, test)
+	}
+	return tests
+}
+
+// CalculateCatch2Score 根据 Catch2 报告计算分数
+// 分数 = (passed / total) * 100，每个 TestCase 为一个测试点，section 作为测试点下的子项
+func CalculateCatch2Score(report *Catch2Report) *LFS1Result {
+	total := len(report.TestCases)
+	passed := 0
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0, total)
+	for i := range report.TestCases {
+		tc := &report.TestCases[i]
+		tests := collectSections("", tc.Sections, []*aoiclient.SolutionDetailsTest{})
+
+		var score float64
+		status := aoiclient.StatusWrongAnswer
+		summary := "测试失败"
+		if tc.OverallResult.Success == "true" {
+			passed++
+			score = 100
+			status = aoiclient.StatusAccepted
+			summary = "通过"
+		} else if messages := tc.failureMessages(); len(messages) > 0 {
+			// 用例级别（不在任何 section 中）的失败
+			summary = messages[0]
+		} else {
+			for _, t := range tests {
+				if t.Status != aoiclient.StatusAccepted {
+					summary = t.Name + ": " + t.Summary
+					break
+				}
+			}
+		}
+		if len(summary) > 200 {
+			summary = summary[:200] + "..."
+		}
+		duration, _ := strconv.ParseFloat(tc.OverallResult.Duration, 64)
+
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       tc.Name,
+			Score:      score,
+			ScoreScale: 1,
+			Status:     status,
+			Summary:    fmt.Sprintf("%s (%s)", summary, formatDuration(duration)),
+			Tests:      tests,
+		})
+	}
+
+	var status, message string
+	var score float64
+	if total == 0 {
+		status = aoiclient.StatusInternalError
+		message = "未找到任何测试用例"
+	} else {
+		score = float64(passed) / float64(total) * 100
+		if passed == total {
+			status = aoiclient.StatusAccepted
+			message = fmt.Sprintf("全部通过 %d/%d 测试点", passed, total)
+		} else if passed > 0 {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("通过 %d/%d 测试点，失败 %d 个", passed, total, total-passed)
+		} else {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("未通过任何测试点 (0/%d)", total)
+		}
+	}
+
+	return &LFS1Result{
+		Score:   score,
+		Status:  status,
+		Message: message,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: message,
+			Jobs:    jobs,
+		},
+	}
+}
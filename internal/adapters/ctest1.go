@@ -0,0 +1,226 @@
+package adapters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// CTestReportName ctest -T Test 生成的报告文件名（位于 Testing/<tag>/ 下，评测脚本需复制到 /output）
+const CTestReportName = "Test.xml"
+
+// CTestValue 测量值，测试输出可能经过编码与压缩
+type CTestValue struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+}
+
+// CTestMeasurement 测试结果中的一项测量值
+type CTestMeasurement struct {
+	Type  string     `xml:"type,attr"`
+	Name  string     `xml:"name,attr"`
+	Value CTestValue `xml:"Value"`
+}
+
+// CTestResults 单个测试的测量值与输出
+type CTestResults struct {
+	NamedMeasurements []CTestMeasurement `xml:"NamedMeasurement"`
+	Measurement       CTestMeasurement   `xml:"Measurement"`
+}
+
+// CTestTest CTest 单个测试
+type CTestTest struct {
+	Status   string       `xml:"Status,attr"` // passed / failed / notrun
+	Name     string       `xml:"Name"`
+	Path     string       `xml:"Path"`
+	FullName string       `xml:"FullName"`
+	Results  CTestResults `xml:"Results"`
+}
+
+// CTestReport CTest/CDash Test.xml 的结构
+type CTestReport struct {
+	XMLName xml.Name    `xml:"Site"`
+	Tests   []CTestTest `xml:"Testing>Test"`
+}
+
+// ParseCTestReport 从文件解析 CTest Test.xml
+func ParseCTestReport(filepath string) (*CTestReport, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var report CTestReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		if _, ok := err.(xml.UnmarshalError); ok {
+			return nil, &ReportError{Problems: []string{"根元素应为 <Site>（是否为 ctest -T Test 生成的 Test.xml？）"}}
+		}
+		return nil, xmlSyntaxError(data, err)
+	}
+	var problems reportProblems
+	for i, t := range report.Tests {
+		if t.Name == "" {
+			problems.addf("第 %d 个 <Test> 缺少 <Name>", i+1)
+		}
+		switch t.Status {
+		case "passed", "failed", "notrun":
+		default:
+			problems.addf("测试 %s 的 Status 取值未知: %q", t.Name, t.Status)
+		}
+	}
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// measurement 返回指定名称的测量值
+func (t *CTestTest) measurement(name string) string {
+	for _, m := range t.Results.NamedMeasurements {
+		if m.Name == name {
+			return strings.TrimSpace(m.Value.Text)
+		}
+	}
+	return ""
+}
+
+// Duration 返回测试运行时间（秒）
+func (t *CTestTest) Duration() float64 {
+	d, _ := strconv.ParseFloat(t.measurement("Execution Time"), 64)
+	return d
+}
+
+// Output 返回测试输出，CTest 默认以 base64 + zlib 压缩保存
+func (t *CTestTest) Output() string {
+	v := t.Results.Measurement.Value
+	if v.Encoding != "base64" {
+		return v.Text
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.Text))
+	if err != nil {
+		return ""
+	}
+	if v.Compression == "" {
+		return string(data)
+	}
+	// CTest 标注为 gzip，实际使用 zlib 格式
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else if gr, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		r = gr
+	} else {
+		return ""
+	}
+	out, _ := io.ReadAll(io.LimitReader(r, 1<<20))
+	return string(out)
+}
+
+// ctestSummary 生成测试用例的摘要信息（包含运行时间）
+func ctestSummary(test *CTestTest) string {
+	var summary string
+	switch test.Status {
+	case "passed":
+		summary = "通过"
+	case "notrun":
+		summary = "未运行"
+		if status := test.measurement("Completion Status"); status != "" {
+			summary += "：" + status
+		}
+	default:
+		summary = "测试失败"
+		if code := test.measurement("Exit Code"); code != "" {
+			summary = code
+			if value := test.measurement("Exit Value"); value != "" {
+				summary += " " + value
+			}
+		}
+		// 附上输出末尾，通常包含断言信息
+		if output := strings.TrimSpace(test.Output()); output != "" {
+			if len(output) > 200 {
+				output = "..." + output[len(output)-200:]
+			}
+			summary += "\n" + output
+		}
+	}
+	return fmt.Sprintf("%s (%s)", summary, formatDuration(test.Duration()))
+}
+
+// CalculateCTestScore 根据 CTest 报告计算分数
+// 分数 = (passed / total) * 100，未运行的测试计为未通过
+func CalculateCTestScore(report *CTestReport) *LFS1Result {
+	total := len(report.Tests)
+	if total == 0 {
+		message := "未找到任何测试用例"
+		return &LFS1Result{
+			Score:   0,
+			Status:  aoiclient.StatusInternalError,
+			Message: message,
+			Details: &aoiclient.SolutionDetails{
+				Version: 1,
+				Summary: message,
+				Jobs:    []*aoiclient.SolutionDetailsJob{},
+			},
+		}
+	}
+
+	passed, notRun := 0, 0
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0, total)
+	for i := range report.Tests {
+		test := &report.Tests[i]
+		var score float64
+		status := aoiclient.StatusWrongAnswer
+		switch test.Status {
+		case "passed":
+			passed++
+			score = 100
+			status = aoiclient.StatusAccepted
+		case "notrun":
+			notRun++
+		}
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       test.Name,
+			Score:      score,
+			ScoreScale: 1,
+			Status:     status,
+			Summary:    ctestSummary(test),
+			Tests:      []*aoiclient.SolutionDetailsTest{},
+		})
+	}
+
+	var status, message string
+	failed := total - passed - notRun
+	if passed == total {
+		status = aoiclient.StatusAccepted
+		message = fmt.Sprintf("全部通过 %d/%d 测试点", passed, total)
+	} else if passed > 0 {
+		status = aoiclient.StatusWrongAnswer
+		message = fmt.Sprintf("通过 %d/%d 测试点，失败 %d 个", passed, total, failed)
+	} else {
+		status = aoiclient.StatusWrongAnswer
+		message = fmt.Sprintf("未通过任何测试点 (0/%d)", total)
+	}
+	if notRun > 0 {
+		message += fmt.Sprintf("，未运行 %d 个", notRun)
+	}
+
+	return &LFS1Result{
+		Score:   float64(passed) / float64(total) * 100,
+		Status:  status,
+		Message: message,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: message,
+			Jobs:    jobs,
+		},
+	}
+}
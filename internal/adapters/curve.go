@@ -0,0 +1,51 @@
+package adapters
+
+import (
+	"fmt"
+	"math"
+)
+
+// Curve 将测得的指标映射为分数（0-100）的计分曲线。
+// target 小于 baseline 时指标越小越好（如运行时间），反之越大越好（如吞吐量）；
+// 不优于 baseline 得 0 分，达到 target 得满分，其间按 scale 插值
+type Curve struct {
+	Baseline float64 `json:"baseline"`
+	Target   float64 `json:"target"`
+	Scale    string  `json:"scale"` // linear（默认）/ log
+}
+
+// Validate 检查曲线参数
+func (c Curve) Validate() error {
+	if c.Baseline == c.Target {
+		return fmt.Errorf("baseline and target must differ")
+	}
+	switch c.Scale {
+	case "", "linear":
+	case "log":
+		if c.Baseline <= 0 || c.Target <= 0 {
+			return fmt.Errorf("baseline and target must be positive for log scale")
+		}
+	default:
+		return fmt.Errorf("unknown scale %q", c.Scale)
+	}
+	return nil
+}
+
+// Score 计算 value 对应的分数。运行时间、吞吐量等指标只能为正，
+// 非正值或非有限值只可能来自异常或伪造的输出，计 0 分
+func (c Curve) Score(value float64) float64 {
+	if !ValidMetric(value) {
+		return 0
+	}
+	baseline, target := c.Baseline, c.Target
+	if c.Scale == "log" {
+		value, baseline, target = math.Log(value), math.Log(baseline), math.Log(target)
+	}
+	ratio := (value - baseline) / (target - baseline)
+	return math.Max(0, math.Min(1, ratio)) * 100
+}
+
+// ValidMetric 判断测得的指标是否为有限的正数
+func ValidMetric(value float64) bool {
+	return value > 0 && !math.IsInf(value, 1)
+}
@@ -0,0 +1,124 @@
+package adapters
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ExecPrefix 外部 adapter 的类型前缀，如 "exec:junit"
+const ExecPrefix = "exec:"
+
+// ResolveExec 将 "exec:<path>" 解析为 dir 下的可执行文件。
+// 只允许运行 manager 配置的 adapter 目录中的程序，相对路径相对于该目录
+func ResolveExec(adapter, dir string) (string, error) {
+	name := strings.TrimPrefix(adapter, ExecPrefix)
+	if dir == "" {
+		return "", errors.New("external adapters are disabled (no adapter dir configured)")
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("adapter %q is outside the adapter dir", name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("adapter %q not found: %w", name, err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+		return "", fmt.Errorf("adapter %q is not executable", name)
+	}
+	return path, nil
+}
+
+// RunExec 在资源限制下运行外部 adapter：报告文件作为 stdin，
+// stdout 的每一行交给 onLine 处理（通常为 judgerproto 消息），stderr 交给 onStderr
+func (l Limits) RunExec(ctx context.Context, path, input string, env []string, onLine, onStderr func(string)) error {
+	if _, err := statReport(input); err != nil {
+		return err
+	}
+	// 检查之后文件可能被替换，打开时同样不跟随符号链接，并再次确认是普通文件
+	f, err := os.OpenFile(input, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &ReportError{Problems: []string{fmt.Sprintf("%s 不是普通文件", filepath.Base(input))}}
+	}
+	if l.MaxInputSize > 0 && info.Size() > l.MaxInputSize {
+		return fmt.Errorf("report file too large: %d bytes (limit %d)", info.Size(), l.MaxInputSize)
+	}
+
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = f
+	cmd.Env = env
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start adapter: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			onStderr(scanner.Text())
+		}
+	}()
+
+	// 输出总量同样受输入大小上限约束，单行可容纳完整的详情消息
+	var out io.Reader = stdout
+	maxLine := 1 << 20
+	if l.MaxInputSize > 0 {
+		out = io.LimitReader(stdout, 2*l.MaxInputSize)
+		maxLine = int(l.MaxInputSize) + 1<<20
+	}
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+	scanErr := scanner.Err()
+	io.Copy(io.Discard, stdout)
+	<-done
+
+	err = cmd.Wait()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrAdapterTimeout
+	}
+	if err != nil {
+		return fmt.Errorf("adapter failed: %w", err)
+	}
+	if scanErr != nil {
+		return fmt.Errorf("failed to read adapter output: %w", scanErr)
+	}
+	return nil
+}
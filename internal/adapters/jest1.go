@@ -0,0 +1,232 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// JestReportName jest1 adapter 的默认报告文件名（jest --json --outputFile=/output/jest.json）
+const JestReportName = "jest.json"
+
+// JestAssertionResult Jest 单个测试结果
+type JestAssertionResult struct {
+	AncestorTitles  []string `json:"ancestorTitles"`
+	FullName        string   `json:"fullName"`
+	Title           string   `json:"title"`
+	Status          string   `json:"status"`   // passed / failed / pending / skipped / todo / disabled
+	Duration        *float64 `json:"duration"` // 毫秒，未运行时为 null
+	FailureMessages []string `json:"failureMessages"`
+}
+
+// JestTestResult Jest 单个测试文件的结果
+type JestTestResult struct {
+	Name             string                `json:"name"`
+	Status           string                `json:"status"`
+	Message          string                `json:"message"`
+	AssertionResults []JestAssertionResult `json:"assertionResults"`
+}
+
+// MochaTest Mocha JSON reporter 输出的单个测试
+type MochaTest struct {
+	Title     string  `json:"title"`
+	FullTitle string  `json:"fullTitle"`
+	File      string  `json:"file"`
+	Duration  float64 `json:"duration"` // 毫秒
+	Err       struct {
+		Message string `json:"message"`
+	} `json:"err"`
+}
+
+// JestReport jest --json 的输出，同时兼容 mocha --reporter json 的输出
+type JestReport struct {
+	TestResults []JestTestResult `json:"testResults"`
+
+	// Mocha 格式
+	Stats    *struct{}   `json:"stats"`
+	Passes   []MochaTest `json:"passes"`
+	Failures []MochaTest `json:"failures"`
+	Pending  []MochaTest `json:"pending"`
+}
+
+// ParseJestReport 从文件解析 Jest/Mocha JSON 报告
+func ParseJestReport(filepath string) (*JestReport, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var report JestReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, jsonSyntaxError(data, err)
+	}
+	if report.TestResults == nil && report.Stats == nil {
+		return nil, &ReportError{Problems: []string{"testResults 缺失（是否使用了 jest --json 或 mocha --reporter json？）"}}
+	}
+	if report.Stats != nil {
+		report.TestResults = mochaToJest(&report)
+	}
+	return &report, nil
+}
+
+// mochaToJest 将 Mocha 的结果按测试文件转换为 Jest 格式
+func mochaToJest(report *JestReport) []JestTestResult {
+	var results []JestTestResult
+	index := make(map[string]int)
+	add := func(tests []MochaTest, status string) {
+		for _, t := range tests {
+			i, ok := index[t.File]
+			if !ok {
+				i = len(results)
+				index[t.File] = i
+				results = append(results, JestTestResult{Name: t.File, Status: "passed"})
+			}
+			duration := t.Duration
+			r := JestAssertionResult{
+				FullName: t.FullTitle,
+				Title:    t.Title,
+				Status:   status,
+				Duration: &duration,
+			}
+			if status == "failed" {
+				results[i].Status = "failed"
+				r.FailureMessages = []string{t.Err.Message}
+			}
+			results[i].AssertionResults = append(results[i].AssertionResults, r)
+		}
+	}
+	add(report.Passes, "passed")
+	add(report.Failures, "failed")
+	add(report.Pending, "pending")
+	return results
+}
+
+// jestSummary 生成测试用例的摘要信息（包含运行时间）
+func jestSummary(r *JestAssertionResult) string {
+	var summary string
+	switch r.Status {
+	case "passed":
+		summary = "通过"
+	case "failed":
+		summary = "测试失败"
+		if len(r.FailureMessages) > 0 {
+			// 只取第一行断言信息，完整堆栈过长
+			message := strings.TrimSpace(r.FailureMessages[0])
+			if first, _, ok := strings.Cut(message, "\n"); ok {
+				message = first
+			}
+			if len(message) > 200 {
+				message = message[:200] + "..."
+			}
+			summary = message
+		}
+	default:
+		summary = "跳过"
+	}
+	var duration float64
+	if r.Duration != nil {
+		duration = *r.Duration / 1000
+	}
+	return fmt.Sprintf("%s (%s)", summary, formatDuration(duration))
+}
+
+// CalculateJestScore 根据 Jest/Mocha 报告计算分数
+// 分数 = (passed / total) * 100，跳过的测试不计入总数；无法运行的测试文件单独列出，
+// 每个至少计为一个失败的测试，使加载失败不能绕过其中的测试提高分数
+func CalculateJestScore(report *JestReport) *LFS1Result {
+	passed, failed, skipped := 0, 0, 0
+	var suiteErrors []string
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0)
+	for _, suite := range report.TestResults {
+		// 测试文件加载失败（如语法错误）时没有任何测试结果
+		if suite.Status == "failed" && len(suite.AssertionResults) == 0 {
+			name := filepath.Base(suite.Name)
+			suiteErrors = append(suiteErrors, name)
+			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+				Name:       name,
+				Score:      0,
+				ScoreScale: 1,
+				Status:     aoiclient.StatusInternalError,
+				Summary:    extractErrorSummary(suite.Message),
+				Tests:      []*aoiclient.SolutionDetailsTest{},
+			})
+			continue
+		}
+		for i := range suite.AssertionResults {
+			r := &suite.AssertionResults[i]
+			var score float64
+			status := aoiclient.StatusWrongAnswer
+			switch r.Status {
+			case "passed":
+				passed++
+				score = 100
+				status = aoiclient.StatusAccepted
+			case "failed":
+				failed++
+			default:
+				skipped++
+				status = "Skipped"
+			}
+			name := r.FullName
+			if name == "" {
+				name = r.Title
+			}
+			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+				Name:       name,
+				Score:      score,
+				ScoreScale: 1,
+				Status:     status,
+				Summary:    jestSummary(r),
+				Tests:      []*aoiclient.SolutionDetailsTest{},
+			})
+		}
+	}
+
+	total := passed + failed + len(suiteErrors)
+	var status, message string
+	var score float64
+	switch {
+	case passed+failed == 0 && len(suiteErrors) > 0:
+		status = aoiclient.StatusInternalError
+		message = fmt.Sprintf("测试加载失败: %d 个测试文件无法运行", len(suiteErrors))
+	case total == 0:
+		status = aoiclient.StatusInternalError
+		message = "未找到任何测试用例"
+	default:
+		score = float64(passed) / float64(total) * 100
+		if failed == 0 && len(suiteErrors) == 0 {
+			status = aoiclient.StatusAccepted
+			message = fmt.Sprintf("全部通过 %d/%d 测试点", passed, total)
+		} else if passed > 0 {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("通过 %d/%d 测试点，失败 %d 个", passed, total, failed)
+		} else {
+			status = aoiclient.StatusWrongAnswer
+			message = fmt.Sprintf("未通过任何测试点 (0/%d)", total)
+		}
+		if len(suiteErrors) > 0 {
+			message += fmt.Sprintf("，%d 个测试文件无法运行", len(suiteErrors))
+		}
+	}
+	if skipped > 0 {
+		message += fmt.Sprintf("，跳过 %d 个", skipped)
+	}
+
+	summary := message
+	if len(suiteErrors) > 0 {
+		summary += "\n无法运行的测试文件: " + strings.Join(suiteErrors, ", ")
+	}
+	return &LFS1Result{
+		Score:   score,
+		Status:  status,
+		Message: message,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: summary,
+			Jobs:    jobs,
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package adapters
+
+import (
+	"testing"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+func TestJestScore(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		report string
+		score  float64
+		status string
+	}{
+		{
+			name: "jest all pass",
+			report: `{"numFailedTestSuites":0,"numPassedTests":2,"numTotalTests":2,"success":true,"testResults":[
+				{"name":"/work/__tests__/sum.test.js","status":"passed","message":"","assertionResults":[
+					{"ancestorTitles":["sum"],"fullName":"sum adds","title":"adds","status":"passed","duration":3,"failureMessages":[]},
+					{"ancestorTitles":["sum"],"fullName":"sum handles zero","title":"handles zero","status":"passed","duration":1,"failureMessages":[]}
+				]}
+			]}`,
+			score:  100,
+			status: aoiclient.StatusAccepted,
+		},
+		{
+			name: "jest failed and skipped",
+			report: `{"success":false,"testResults":[
+				{"name":"/work/__tests__/sum.test.js","status":"failed","message":"","assertionResults":[
+					{"ancestorTitles":["sum"],"fullName":"sum adds","title":"adds","status":"passed","duration":3,"failureMessages":[]},
+					{"ancestorTitles":["sum"],"fullName":"sum overflows","title":"overflows","status":"failed","duration":2,"failureMessages":["Error: expect(received).toBe(expected)\n\nExpected: 0\nReceived: 1"]},
+					{"ancestorTitles":["sum"],"fullName":"sum later","title":"later","status":"pending","duration":null,"failureMessages":[]}
+				]}
+			]}`,
+			score:  50,
+			status: aoiclient.StatusWrongAnswer,
+		},
+		{
+			// 加载失败的测试文件计为一个失败的测试，不能靠让测试文件崩溃得满分
+			name: "jest suite fails to load",
+			report: `{"success":false,"testResults":[
+				{"name":"/work/__tests__/sum.test.js","status":"passed","message":"","assertionResults":[
+					{"fullName":"sum adds","title":"adds","status":"passed","duration":3,"failureMessages":[]},
+					{"fullName":"sum handles zero","title":"handles zero","status":"passed","duration":1,"failureMessages":[]},
+					{"fullName":"sum negatives","title":"negatives","status":"passed","duration":1,"failureMessages":[]}
+				]},
+				{"name":"/work/__tests__/mul.test.js","status":"failed","message":"  ● Test suite failed to run\n\n    SyntaxError: Unexpected token (3:4)","assertionResults":[]}
+			]}`,
+			score:  75,
+			status: aoiclient.StatusWrongAnswer,
+		},
+		{
+			name: "jest every suite fails to load",
+			report: `{"success":false,"testResults":[
+				{"name":"/work/__tests__/mul.test.js","status":"failed","message":"  ● Test suite failed to run\n\n    SyntaxError: Unexpected token (3:4)","assertionResults":[]}
+			]}`,
+			score:  0,
+			status: aoiclient.StatusInternalError,
+		},
+		{
+			name: "mocha",
+			report: `{"stats":{"suites":1,"tests":2,"passes":1,"pending":0,"failures":1},
+				"passes":[{"title":"adds","fullTitle":"sum adds","file":"/work/test/sum.js","duration":2,"err":{}}],
+				"failures":[{"title":"overflows","fullTitle":"sum overflows","file":"/work/test/sum.js","duration":1,"err":{"message":"expected 1 to equal 0"}}],
+				"pending":[]}`,
+			score:  50,
+			status: aoiclient.StatusWrongAnswer,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := ParseJestReport(writeReport(t, JestReportName, tc.report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := CalculateJestScore(report)
+			if result.Score != tc.score || result.Status != tc.status {
+				t.Errorf("score = %v %q, want %v %q", result.Score, result.Status, tc.score, tc.status)
+			}
+		})
+	}
+}
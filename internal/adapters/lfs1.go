@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
@@ -33,6 +34,8 @@ type PytestTestPhase struct {
 	Outcome  string           `json:"outcome"`
 	Crash    *PytestCrashInfo `json:"crash,omitempty"`
 	Longrepr string           `json:"longrepr,omitempty"`
+	Stdout   string           `json:"stdout,omitempty"`
+	Stderr   string           `json:"stderr,omitempty"`
 }
 
 // PytestTestCase pytest 单个测试用例
@@ -70,6 +73,7 @@ type LFS1Result struct {
 	Score   float64
 	Status  string
 	Message string
+	Metrics map[string]float64 // 可选，随结果上报的指标
 	Details *aoiclient.SolutionDetails
 }
 
@@ -84,6 +88,9 @@ func ParsePytestReport(filepath string) (*PytestReport, error) {
 
 // ParsePytestReportFromBytes 从字节数组解析 pytest JSON 报告
 func ParsePytestReportFromBytes(data []byte) (*PytestReport, error) {
+	if err := ValidatePytestReport(data); err != nil {
+		return nil, err
+	}
 	var report PytestReport
 	if err := json.Unmarshal(data, &report); err != nil {
 		return nil, fmt.Errorf("failed to parse report JSON: %w", err)
@@ -91,6 +98,48 @@ func ParsePytestReportFromBytes(data []byte) (*PytestReport, error) {
 	return &report, nil
 }
 
+// ParsePytestReports 解析多个 pytest JSON 报告并合并为一个
+func ParsePytestReports(paths []string) (*PytestReport, error) {
+	reports := make([]*PytestReport, 0, len(paths))
+	for _, path := range paths {
+		report, err := ParsePytestReport(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		reports = append(reports, report)
+	}
+	return MergeReports(reports), nil
+}
+
+// MergeReports 合并多次 pytest 运行（如按阶段分别运行）的报告：
+// 测试与收集错误按顺序拼接，summary 与耗时累加，退出码取第一个非零值
+func MergeReports(reports []*PytestReport) *PytestReport {
+	if len(reports) == 1 {
+		return reports[0]
+	}
+	merged := &PytestReport{}
+	for _, r := range reports {
+		if merged.Created == 0 {
+			merged.Created = r.Created
+			merged.Root = r.Root
+			merged.Environment = r.Environment
+		}
+		merged.Duration += r.Duration
+		if merged.ExitCode == 0 {
+			merged.ExitCode = r.ExitCode
+		}
+		merged.Summary.Passed += r.Summary.Passed
+		merged.Summary.Failed += r.Summary.Failed
+		merged.Summary.Skipped += r.Summary.Skipped
+		merged.Summary.XFailed += r.Summary.XFailed
+		merged.Summary.Total += r.Summary.Total
+		merged.Summary.Collected += r.Summary.Collected
+		merged.Collectors = append(merged.Collectors, r.Collectors...)
+		merged.Tests = append(merged.Tests, r.Tests...)
+	}
+	return merged
+}
+
 // extractTestName 从 nodeid 提取测试名称
 // 例如: "tests/test_data.py::test_get_batch" -> "test_get_batch"
 func extractTestName(nodeid string) string {
@@ -181,6 +230,80 @@ func generateTestSummary(test *PytestTestCase) string {
 	return fmt.Sprintf("%s (%s)", summary, durationStr)
 }
 
+// LiveTestJob 将实时上报的单个测试结果转换为详情中的 Job
+func LiveTestJob(name, outcome string, duration float64, message string) *aoiclient.SolutionDetailsJob {
+	var score float64
+	if outcome == "passed" || outcome == "xfailed" || outcome == "xpassed" {
+		score = 100
+	}
+	summary := formatDuration(duration)
+	if message != "" {
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		summary = fmt.Sprintf("%s (%s)", message, summary)
+	}
+	return &aoiclient.SolutionDetailsJob{
+		Name:       extractTestName(name),
+		Score:      score,
+		ScoreScale: 1,
+		Status:     outcomeToStatus(outcome),
+		Summary:    summary,
+		Tests:      []*aoiclient.SolutionDetailsTest{},
+	}
+}
+
+// maxDetailOutput 单条详情输出的大小上限（字节）
+const maxDetailOutput = 8 << 10
+
+// TruncateOutput 截断过长的输出，保留开头与结尾（错误信息通常在末尾）
+func TruncateOutput(s string) string {
+	if len(s) <= maxDetailOutput {
+		return s
+	}
+	head, tail := s[:maxDetailOutput/4], s[len(s)-maxDetailOutput*3/4:]
+	omitted := len(s) - len(head) - len(tail)
+	return strings.ToValidUTF8(head, "") + fmt.Sprintf("\n... (省略 %d 字节) ...\n", omitted) + strings.ToValidUTF8(tail, "")
+}
+
+// failureDetails 生成未通过测试的详细信息：失败位置、完整错误信息以及各阶段捕获的输出
+func failureDetails(test *PytestTestCase) []*aoiclient.SolutionDetailsTest {
+	tests := []*aoiclient.SolutionDetailsTest{}
+	add := func(name, summary string) {
+		tests = append(tests, &aoiclient.SolutionDetailsTest{
+			Name:       name,
+			Score:      0,
+			ScoreScale: 1,
+			Status:     outcomeToStatus(test.Outcome),
+			Summary:    TruncateOutput(summary),
+		})
+	}
+	phases := []struct {
+		name  string
+		phase *PytestTestPhase
+	}{{"setup", test.Setup}, {"call", test.Call}, {"teardown", test.Teardown}}
+	for _, p := range phases {
+		if p.phase == nil {
+			continue
+		}
+		if p.phase.Outcome == "failed" {
+			if crash := p.phase.Crash; crash != nil {
+				add(p.name+" 失败位置", fmt.Sprintf("%s:%d: %s", crash.Path, crash.Lineno, crash.Message))
+			}
+			if p.phase.Longrepr != "" {
+				add(p.name+" 错误信息", p.phase.Longrepr)
+			}
+		}
+		if p.phase.Stdout != "" {
+			add(p.name+" 标准输出", p.phase.Stdout)
+		}
+		if p.phase.Stderr != "" {
+			add(p.name+" 标准错误", p.phase.Stderr)
+		}
+	}
+	return tests
+}
+
 // getCollectionErrors 从 collectors 中提取收集阶段的错误
 func getCollectionErrors(collectors []PytestCollector) []PytestCollector {
 	var errors []PytestCollector
@@ -192,6 +315,21 @@ func getCollectionErrors(collectors []PytestCollector) []PytestCollector {
 	return errors
 }
 
+// compileErrorMarkers 收集错误中表示提交无法编译（而非评测环境问题）的异常
+var compileErrorMarkers = []string{"SyntaxError", "IndentationError", "TabError"}
+
+// IsCompileError 收集错误是否由提交的语法错误引起
+func IsCompileError(collectionErrors []PytestCollector) bool {
+	for _, ce := range collectionErrors {
+		for _, marker := range compileErrorMarkers {
+			if strings.Contains(ce.Longrepr, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // extractErrorSummary 从 longrepr 中提取简短的错误摘要
 func extractErrorSummary(longrepr string) string {
 	// 查找最后一行（通常是实际的错误信息，如 "ModuleNotFoundError: No module named 'xxx'"）
@@ -220,9 +358,84 @@ func extractErrorSummary(longrepr string) string {
 	return longrepr
 }
 
+// MergeRetry 用重试报告中通过的测试覆盖原报告中的对应结果，并更新 summary。
+// 返回重试通过的测试数
+func MergeRetry(report, retry *PytestReport) int {
+	passed := make(map[string]PytestTestCase)
+	for _, t := range retry.Tests {
+		if t.Outcome == "passed" {
+			passed[t.NodeID] = t
+		}
+	}
+	recovered := 0
+	for i, t := range report.Tests {
+		r, ok := passed[t.NodeID]
+		if !ok || t.Outcome == "passed" {
+			continue
+		}
+		switch t.Outcome {
+		case "failed":
+			report.Summary.Failed--
+		case "error":
+			// PytestReportSummary 未记录 error 数量
+		default:
+			continue
+		}
+		report.Summary.Passed++
+		report.Tests[i] = r
+		recovered++
+	}
+	return recovered
+}
+
+// MergeResults 用 subset 中的测试结果覆盖 base 中的同名测试（无论是否通过），
+// subset 中新出现的测试追加到末尾，并按合并后的测试重新统计 summary
+func MergeResults(base, subset *PytestReport) {
+	index := make(map[string]int, len(base.Tests))
+	for i, t := range base.Tests {
+		index[t.NodeID] = i
+	}
+	for _, t := range subset.Tests {
+		if i, ok := index[t.NodeID]; ok {
+			base.Tests[i] = t
+		} else {
+			base.Tests = append(base.Tests, t)
+		}
+	}
+
+	summary := PytestReportSummary{Collected: base.Summary.Collected}
+	for _, t := range base.Tests {
+		switch t.Outcome {
+		case "passed":
+			summary.Passed++
+		case "failed":
+			summary.Failed++
+		case "skipped":
+			summary.Skipped++
+		case "xfailed":
+			summary.XFailed++
+		}
+		summary.Total++
+	}
+	base.Summary = summary
+	base.Collectors = subset.Collectors
+	base.ExitCode = subset.ExitCode
+}
+
+// FailedTests 返回报告中未通过的测试 nodeid
+func FailedTests(report *PytestReport) []string {
+	var failed []string
+	for _, t := range report.Tests {
+		if t.Outcome == "failed" || t.Outcome == "error" {
+			failed = append(failed, t.NodeID)
+		}
+	}
+	return failed
+}
+
 // CalculateScore 根据 pytest 报告计算分数
-// 分数 = (passed / total) * 100
-func CalculateScore(report *PytestReport) *LFS1Result {
+// 分数 = (passed / total) * 满分，按 policy 取整；policy 为 nil 时为百分制
+func CalculateScore(report *PytestReport, policy *ScorePolicy) *LFS1Result {
 	summary := report.Summary
 	total := summary.Total
 	// xfailed 算作通过
@@ -231,27 +444,34 @@ func CalculateScore(report *PytestReport) *LFS1Result {
 	// 首先检查是否有收集阶段的错误
 	collectionErrors := getCollectionErrors(report.Collectors)
 	if total == 0 && len(collectionErrors) > 0 {
-		// 收集阶段出错，无法执行任何测试
+		// 收集阶段出错，无法执行任何测试；提交存在语法错误时视为编译错误
 		var errorMessages []string
 		jobs := make([]*aoiclient.SolutionDetailsJob, 0, len(collectionErrors))
+		status := aoiclient.StatusInternalError
+		message := fmt.Sprintf("测试收集失败: %d 个模块无法导入", len(collectionErrors))
+		if IsCompileError(collectionErrors) {
+			status = aoiclient.StatusCompileError
+			message = fmt.Sprintf("编译失败: %d 个模块存在语法错误", len(collectionErrors))
+		}
 
 		for _, ce := range collectionErrors {
 			errorSummary := extractErrorSummary(ce.Longrepr)
+			if status == aoiclient.StatusCompileError {
+				errorSummary = TruncateOutput(ce.Longrepr)
+			}
 			errorMessages = append(errorMessages, ce.NodeID)
 
 			// 为每个收集错误创建一个 Job
 			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
 				Name:       ce.NodeID,
 				Score:      0,
-				ScoreScale: 1,
-				Status:     aoiclient.StatusInternalError,
+				ScoreScale: policy.jobScale(),
+				Status:     status,
 				Summary:    errorSummary,
 				Tests:      []*aoiclient.SolutionDetailsTest{},
 			})
 		}
 
-		message := fmt.Sprintf("测试收集失败: %d 个模块无法导入", len(collectionErrors))
-
 		details := &aoiclient.SolutionDetails{
 			Version: 1,
 			Summary: message + "\n失败模块: " + strings.Join(errorMessages, ", "),
@@ -260,7 +480,7 @@ func CalculateScore(report *PytestReport) *LFS1Result {
 
 		return &LFS1Result{
 			Score:   0,
-			Status:  aoiclient.StatusInternalError,
+			Status:  status,
 			Message: message,
 			Details: details,
 		}
@@ -286,7 +506,7 @@ func CalculateScore(report *PytestReport) *LFS1Result {
 	// 计算分数
 	var score float64
 	if total > 0 {
-		score = float64(passed) / float64(total) * 100
+		score = policy.Score(float64(passed) / float64(total))
 	} else {
 		score = 0
 	}
@@ -326,17 +546,20 @@ func CalculateScore(report *PytestReport) *LFS1Result {
 
 		// 计算单个测试的分数
 		var testScore float64
+		tests := []*aoiclient.SolutionDetailsTest{}
 		if test.Outcome == "passed" || test.Outcome == "xfailed" || test.Outcome == "xpassed" {
 			testScore = 100
+		} else if test.Outcome == "failed" || test.Outcome == "error" {
+			tests = failureDetails(&test)
 		}
 
 		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
 			Name:       testName,
 			Score:      testScore,
-			ScoreScale: 1,
+			ScoreScale: policy.jobScale(),
 			Status:     testStatus,
 			Summary:    testSummary,
-			Tests:      []*aoiclient.SolutionDetailsTest{},
+			Tests:      tests,
 		})
 	}
 
@@ -363,7 +586,7 @@ func ProcessAndPrint(reportPath string) error {
 		return err
 	}
 
-	result := CalculateScore(report)
+	result := CalculateScore(report, nil)
 
 	// 输出 Patch 消息
 	judgerproto.NewPatchMessage(&judgerproto.PatchBody{
@@ -0,0 +1,146 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 默认 adapter 资源限制
+const (
+	DefaultAdapterTimeout = 30 * time.Second
+	DefaultMaxInputSize   = 64 << 20 // 64 MB
+	DefaultMaxTests       = 1000
+	MaxConcurrentParsers  = 4 // 进程内同时运行的 adapter 数，包括超时后仍未返回的
+)
+
+// parserSlots 限制同时运行的 adapter。超时的 adapter 无法被中止，返回前一直占用名额，
+// 因此进程内解析报告的内存不超过 MaxConcurrentParsers 个报告的解析开销
+var parserSlots = make(chan struct{}, MaxConcurrentParsers)
+
+// ErrAdapterTimeout adapter 运行超时
+var ErrAdapterTimeout = errors.New("adapter timed out")
+
+// Limits manager 侧 adapter 的资源限制。
+// manager 进程由所有评测共享，异常的报告不应拖垮整个进程。
+//
+// adapter 在 manager 进程内的 goroutine 中运行，内置 adapter 一次性读入并解码整个报告，
+// 不检查 ctx，也没有单独的内存限制：单次解析的内存只由 MaxInputSize 与 JSON/XML 解码的开销间接限制。
+// 超时后 Run 立即返回，但 adapter 会继续运行到自行结束，期间占用的内存与 CPU 不会释放；
+// 同时运行的 adapter 数由 MaxConcurrentParsers 限制，超出时等待空闲名额，等待时间计入超时。
+// 需要更严格隔离的解析应使用在子进程中运行的外部 adapter（exec:<name>）
+type Limits struct {
+	Timeout      time.Duration // 单次 adapter 运行超时
+	MaxInputSize int64         // 输入文件大小上限（字节），用于限制解析时的内存占用
+	MaxTests     int           // 详情中展示的测试点数量上限，超出部分截断，不影响分数
+}
+
+// DefaultLimits 返回默认资源限制
+func DefaultLimits() Limits {
+	return Limits{
+		Timeout:      DefaultAdapterTimeout,
+		MaxInputSize: DefaultMaxInputSize,
+		MaxTests:     DefaultMaxTests,
+	}
+}
+
+// statReport 检查报告文件。报告由评测容器写入，不跟随符号链接，
+// 避免读取 /dev/zero、FIFO 等特殊文件或宿主机文件
+func statReport(input string) (os.FileInfo, error) {
+	info, err := os.Lstat(input)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &ReportError{Problems: []string{fmt.Sprintf("%s 不是普通文件", filepath.Base(input))}}
+	}
+	return info, nil
+}
+
+// Run 在资源限制下对输入文件运行 adapter。
+// 超时后 adapter 所在的 goroutine 会被放弃，其结果被丢弃，但 goroutine 会运行到 adapter 返回为止。
+func (l Limits) Run(ctx context.Context, input string, fn func(input string) (*LFS1Result, error)) (*LFS1Result, error) {
+	return l.RunAll(ctx, []string{input}, func(inputs []string) (*LFS1Result, error) {
+		return fn(inputs[0])
+	})
+}
+
+// RunAll 与 Run 相同，但 adapter 同时读取多个输入文件，大小上限按文件总大小计算
+func (l Limits) RunAll(ctx context.Context, inputs []string, fn func(inputs []string) (*LFS1Result, error)) (*LFS1Result, error) {
+	var total int64
+	for _, input := range inputs {
+		info, err := statReport(input)
+		if err != nil {
+			return nil, err
+		}
+		total += info.Size()
+	}
+	if l.MaxInputSize > 0 && total > l.MaxInputSize {
+		return nil, &ReportError{Problems: []string{fmt.Sprintf("报告文件过大（%d 字节，上限 %d 字节）", total, l.MaxInputSize)}}
+	}
+
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	// 等待空闲名额；名额在 adapter 实际返回时才归还，超时放弃的 adapter 仍然计入
+	select {
+	case parserSlots <- struct{}{}:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrAdapterTimeout
+		}
+		return nil, ctx.Err()
+	}
+
+	type outcome struct {
+		result *LFS1Result
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() { <-parserSlots }()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("adapter panicked: %v", r)}
+			}
+		}()
+		// 等待名额期间已超时的不再开始解析
+		if err := ctx.Err(); err != nil {
+			done <- outcome{err: err}
+			return
+		}
+		result, err := fn(inputs)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err == nil {
+			l.truncate(o.result)
+		}
+		return o.result, o.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrAdapterTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// truncate 详情中的测试点超过上限时只保留前 MaxTests 个，并在摘要中注明
+func (l Limits) truncate(result *LFS1Result) {
+	if l.MaxTests <= 0 || result == nil || result.Details == nil {
+		return
+	}
+	details := result.Details
+	if n := len(details.Jobs); n > l.MaxTests {
+		details.Jobs = details.Jobs[:l.MaxTests]
+		details.Summary += fmt.Sprintf("\n测试点过多，详情仅展示前 %d 个（共 %d 个），分数按全部测试点计算", l.MaxTests, n)
+	}
+}
@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunAllBoundsAbandonedParsers(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(report, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	limits := Limits{Timeout: 20 * time.Millisecond}
+
+	// 占满名额的 adapter 超时后仍在运行
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range MaxConcurrentParsers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limits.Run(context.Background(), report, func(string) (*LFS1Result, error) {
+				<-release
+				return &LFS1Result{}, nil
+			})
+			if !errors.Is(err, ErrAdapterTimeout) {
+				t.Errorf("blocked adapter returned %v, want ErrAdapterTimeout", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	started := false
+	_, err := limits.Run(context.Background(), report, func(string) (*LFS1Result, error) {
+		started = true
+		return &LFS1Result{}, nil
+	})
+	if !errors.Is(err, ErrAdapterTimeout) || started {
+		t.Fatalf("Run with no free parser slots = %v (started %v), want ErrAdapterTimeout without starting", err, started)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := limits.Run(context.Background(), report, func(string) (*LFS1Result, error) {
+			return &LFS1Result{Score: 100}, nil
+		})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("parser slots were not released: %v", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package adapters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// PerfReportName perf1 adapter 的默认报告文件名，评测容器将测得的指标写入其中，
+// 格式为 {"指标名": 数值}，如 {"runtime": 1.25, "throughput": 3200}
+const PerfReportName = "metrics.json"
+
+// MetricCurve 单个指标的计分标准，在 rc.Variables["curves"] 中按指标名声明
+type MetricCurve struct {
+	Curve
+	Weight float64 `json:"weight"` // 权重，默认 1
+	Unit   string  `json:"unit"`   // 展示用单位，如 s、GFLOPS
+}
+
+// ParsePerfReport 从文件读取评测容器上报的指标
+func ParsePerfReport(filepath string) (map[string]float64, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var metrics map[string]float64
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			if typeErr.Field == "" {
+				return nil, &ReportError{Problems: []string{"报告顶层必须是 {\"指标名\": 数值} 形式的对象"}}
+			}
+			return nil, &ReportError{Problems: []string{fmt.Sprintf("指标 %s 应为数字", typeErr.Field)}}
+		}
+		return nil, jsonSyntaxError(data, err)
+	}
+	return metrics, nil
+}
+
+// ParseMetricCurves 从题目变量中读取各指标的计分曲线
+func ParseMetricCurves(vars map[string]any) (map[string]MetricCurve, error) {
+	raw, ok := vars["curves"]
+	if !ok {
+		return nil, fmt.Errorf("scoring curves are not declared in variables.curves")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var curves map[string]MetricCurve
+	if err := json.Unmarshal(data, &curves); err != nil {
+		return nil, fmt.Errorf("invalid variables.curves: %w", err)
+	}
+	if len(curves) == 0 {
+		return nil, fmt.Errorf("variables.curves is empty")
+	}
+	for name, c := range curves {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("curve %s: %w", name, err)
+		}
+	}
+	return curves, nil
+}
+
+// formatMetric 格式化指标数值
+func formatMetric(value float64, unit string) string {
+	if unit == "" {
+		return fmt.Sprintf("%g", value)
+	}
+	return fmt.Sprintf("%g %s", value, unit)
+}
+
+// CalculatePerfScore 按计分曲线将各指标映射为分数并加权平均，缺失或无效（非正、非有限）的指标计 0 分。
+// 测得的有效指标同时作为 Metrics 上报
+func CalculatePerfScore(metrics map[string]float64, curves map[string]MetricCurve) *LFS1Result {
+	var totalWeight, weighted float64
+	reached, missing, invalid := 0, 0, 0
+	jobs := make([]*aoiclient.SolutionDetailsJob, 0, len(curves))
+	for _, name := range slices.Sorted(maps.Keys(curves)) {
+		c := curves[name]
+		weight := c.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += weight
+
+		value, ok := metrics[name]
+		if !ok {
+			missing++
+			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+				Name:       name,
+				Score:      0,
+				ScoreScale: 100,
+				Status:     aoiclient.StatusRuntimeError,
+				Summary:    "评测未上报该指标",
+				Tests:      []*aoiclient.SolutionDetailsTest{},
+			})
+			continue
+		}
+		if !ValidMetric(value) {
+			invalid++
+			jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+				Name:       name,
+				Score:      0,
+				ScoreScale: 100,
+				Status:     aoiclient.StatusRuntimeError,
+				Summary:    fmt.Sprintf("上报的指标 %g 无效", value),
+				Tests:      []*aoiclient.SolutionDetailsTest{},
+			})
+			continue
+		}
+
+		score := c.Score(value)
+		weighted += score * weight
+		status := aoiclient.StatusWrongAnswer
+		if score >= 100 {
+			status = aoiclient.StatusAccepted
+			reached++
+		}
+		jobs = append(jobs, &aoiclient.SolutionDetailsJob{
+			Name:       name,
+			Score:      score,
+			ScoreScale: 100,
+			Status:     status,
+			Summary: fmt.Sprintf("测得 %s（基线 %s，目标 %s）",
+				formatMetric(value, c.Unit), formatMetric(c.Baseline, c.Unit), formatMetric(c.Target, c.Unit)),
+			Tests: []*aoiclient.SolutionDetailsTest{},
+		})
+	}
+
+	score := weighted / totalWeight
+	var status, message string
+	if reached == len(curves) {
+		status = aoiclient.StatusAccepted
+		message = fmt.Sprintf("全部 %d 项指标达到目标", len(curves))
+	} else {
+		status = aoiclient.StatusWrongAnswer
+		message = fmt.Sprintf("%d/%d 项指标达到目标，得分 %.2f", reached, len(curves), score)
+	}
+	if missing > 0 {
+		message += fmt.Sprintf("，%d 项未上报", missing)
+	}
+	if invalid > 0 {
+		message += fmt.Sprintf("，%d 项无效", invalid)
+	}
+
+	valid := make(map[string]float64, len(metrics))
+	for name, value := range metrics {
+		if ValidMetric(value) {
+			valid[name] = value
+		}
+	}
+
+	return &LFS1Result{
+		Score:   score,
+		Status:  status,
+		Message: message,
+		Metrics: valid,
+		Details: &aoiclient.SolutionDetails{
+			Version: 1,
+			Summary: message,
+			Jobs:    jobs,
+		},
+	}
+}
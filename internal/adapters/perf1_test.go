@@ -0,0 +1,76 @@
+package adapters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+func TestCurveScore(t *testing.T) {
+	faster := Curve{Baseline: 10, Target: 2}
+	higher := Curve{Baseline: 100, Target: 1000, Scale: "log"}
+	for _, tc := range []struct {
+		name  string
+		curve Curve
+		value float64
+		want  float64
+	}{
+		{"linear baseline", faster, 10, 0},
+		{"linear midway", faster, 6, 50},
+		{"linear target", faster, 2, 100},
+		{"linear beyond target", faster, 1, 100},
+		{"linear worse than baseline", faster, 20, 0},
+		{"linear zero", faster, 0, 0},
+		{"linear negative", faster, -1, 0},
+		{"linear NaN", faster, math.NaN(), 0},
+		{"linear infinity", Curve{Baseline: 1, Target: 10}, math.Inf(1), 0},
+		{"log midway", higher, math.Sqrt(100 * 1000), 50},
+		{"log zero", Curve{Baseline: 10, Target: 1, Scale: "log"}, 0, 0},
+		{"log negative", Curve{Baseline: 10, Target: 1, Scale: "log"}, -3, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.curve.Score(tc.value); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Score(%v) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPerfScore(t *testing.T) {
+	curves, err := ParseMetricCurves(map[string]any{"curves": map[string]any{
+		"runtime":    map[string]any{"baseline": 4.0, "target": 1.0, "unit": "s"},
+		"throughput": map[string]any{"baseline": 1000, "target": 4000, "unit": "ops/s"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		report string
+		score  float64
+		status string
+	}{
+		{"all targets", `{"runtime": 0.9, "throughput": 4200}`, 100, aoiclient.StatusAccepted},
+		{"partial", `{"runtime": 2.5, "throughput": 1000}`, 25, aoiclient.StatusWrongAnswer},
+		{"missing metric", `{"runtime": 1.0}`, 50, aoiclient.StatusWrongAnswer},
+		{"zero runtime", `{"runtime": 0, "throughput": 4000}`, 50, aoiclient.StatusWrongAnswer},
+		{"negative metrics", `{"runtime": -1, "throughput": -5}`, 0, aoiclient.StatusWrongAnswer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics, err := ParsePerfReport(writeReport(t, PerfReportName, tc.report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := CalculatePerfScore(metrics, curves)
+			if math.Abs(result.Score-tc.score) > 1e-9 || result.Status != tc.status {
+				t.Errorf("score = %v %q, want %v %q", result.Score, result.Status, tc.score, tc.status)
+			}
+			for name, value := range result.Metrics {
+				if !ValidMetric(value) {
+					t.Errorf("invalid metric %s = %v reported", name, value)
+				}
+			}
+		})
+	}
+}
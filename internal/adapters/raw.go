@@ -0,0 +1,100 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// RawReportName raw adapter 的默认报告文件名
+const RawReportName = "result.json"
+
+// RawReport 评分逻辑完全在评测镜像中的题目由容器写出的结果，manager 只做校验后原样转发：
+//
+//	{"score": 85, "status": "Wrong Answer", "message": "...", "jobs": [{"name": "...", "score": 10, "scoreScale": 10, "status": "Accepted", "tests": [...]}]}
+//
+// score 为百分制（配置了计分规则时按规则换算），jobs 与详情中的 jobs 格式相同，可省略
+type RawReport struct {
+	Score   *float64                        `json:"score"`
+	Status  string                          `json:"status"`
+	Message string                          `json:"message"`
+	Jobs    []*aoiclient.SolutionDetailsJob `json:"jobs"`
+}
+
+// ParseRawReport 从文件解析并校验 raw 结果，不允许出现未知字段
+func ParseRawReport(filepath string) (*RawReport, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	var report RawReport
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&report); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &ReportError{Problems: []string{fmt.Sprintf("%s 类型错误，应为 %s", typeErr.Field, typeErr.Type)}}
+		}
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, &ReportError{Problems: []string{"未知字段 " + field}}
+		}
+		return nil, jsonSyntaxError(data, err)
+	}
+	if err := validateRawReport(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// validateRawReport 检查必填字段与分数范围
+func validateRawReport(report *RawReport) error {
+	var problems reportProblems
+	switch {
+	case report.Score == nil:
+		problems.addf("score 缺失")
+	case math.IsNaN(*report.Score) || *report.Score < 0 || *report.Score > 100:
+		problems.addf("score 应在 0 到 100 之间，实际为 %g", *report.Score)
+	}
+	if report.Status == "" {
+		problems.addf("status 缺失")
+	}
+	for i, job := range report.Jobs {
+		if job == nil {
+			problems.addf("jobs[%d] 应为对象", i)
+			continue
+		}
+		if job.Name == "" {
+			problems.addf("jobs[%d].name 缺失", i)
+		}
+		if job.Status == "" {
+			problems.addf("jobs[%d].status 缺失", i)
+		}
+		if job.Score < 0 || job.ScoreScale < 0 || job.Score > job.ScoreScale {
+			problems.addf("jobs[%d] 的 score（%g）应在 0 到 scoreScale（%g）之间", i, job.Score, job.ScoreScale)
+		}
+	}
+	return problems.err()
+}
+
+// RawResult 将 raw 结果转换为上报结果，有 jobs 时同时上传详情
+func RawResult(report *RawReport) *LFS1Result {
+	result := &LFS1Result{
+		Score:   *report.Score,
+		Status:  report.Status,
+		Message: report.Message,
+	}
+	if len(report.Jobs) > 0 {
+		result.Details = &aoiclient.SolutionDetails{
+			Version: 1,
+			Jobs:    report.Jobs,
+			Summary: report.Message,
+		}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReportAdapter 仅需解析报告文件即可得出结果的内置 adapter
+type ReportAdapter struct {
+	ReportName string                                                      // 默认报告文件名，可由 report_name 变量覆盖
+	Parse      func(path string, vars map[string]any) (*LFS1Result, error) // 解析报告并计算结果，vars 为题目变量
+}
+
+// ReportAdapters 按名称注册的报告类 adapter（lfs1 需要额外的合并与重试逻辑，单独处理）
+var ReportAdapters = map[string]ReportAdapter{
+	"ctest1": {
+		ReportName: CTestReportName,
+		Parse: func(path string, _ map[string]any) (*LFS1Result, error) {
+			report, err := ParseCTestReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculateCTestScore(report), nil
+		},
+	},
+	"cargo1": {
+		ReportName: CargoReportName,
+		Parse: func(path string, _ map[string]any) (*LFS1Result, error) {
+			report, err := ParseCargoReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculateCargoScore(report), nil
+		},
+	},
+	"jest1": {
+		ReportName: JestReportName,
+		Parse: func(path string, _ map[string]any) (*LFS1Result, error) {
+			report, err := ParseJestReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculateJestScore(report), nil
+		},
+	},
+	"catch2": {
+		ReportName: Catch2ReportName,
+		Parse: func(path string, _ map[string]any) (*LFS1Result, error) {
+			report, err := ParseCatch2Report(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculateCatch2Score(report), nil
+		},
+	},
+	"benchmark1": {
+		ReportName: BenchmarkReportName,
+		Parse: func(path string, vars map[string]any) (*LFS1Result, error) {
+			targets, err := ParseBenchmarkTargets(vars)
+			if err != nil {
+				return nil, err
+			}
+			report, err := ParseBenchmarkReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculateBenchmarkScore(report, targets), nil
+		},
+	},
+	"perf1": {
+		ReportName: PerfReportName,
+		Parse: func(path string, vars map[string]any) (*LFS1Result, error) {
+			curves, err := ParseMetricCurves(vars)
+			if err != nil {
+				return nil, err
+			}
+			metrics, err := ParsePerfReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return CalculatePerfScore(metrics, curves), nil
+		},
+	},
+	"raw": {
+		ReportName: RawReportName,
+		Parse: func(path string, _ map[string]any) (*LFS1Result, error) {
+			report, err := ParseRawReport(path)
+			if err != nil {
+				return nil, err
+			}
+			return RawResult(report), nil
+		},
+	},
+}
+
+// RegisterReportAdapter 注册自定义的报告类 adapter，供嵌入的评测器扩展。
+// ReportAdapters 不加锁读取，须在开始评测之前注册；不能覆盖已有或保留的名称
+func RegisterReportAdapter(name string, ra ReportAdapter) error {
+	switch {
+	case name == "" || name == "lfs1" || name == "proto" || strings.HasPrefix(name, ExecPrefix):
+		return fmt.Errorf("adapter name %q is reserved", name)
+	case ra.Parse == nil:
+		return fmt.Errorf("adapter %q has no parser", name)
+	case ra.ReportName == "":
+		return fmt.Errorf("adapter %q has no default report name", name)
+	}
+	if _, ok := ReportAdapters[name]; ok {
+		return fmt.Errorf("adapter %q is already registered", name)
+	}
+	ReportAdapters[name] = ra
+	return nil
+}
@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// 分数取整方式
+const (
+	RoundNone     = ""           // 不取整
+	RoundFloor    = "floor"      // 向下取整
+	RoundNearest  = "round"      // 四舍五入到整数
+	RoundDecimals = "2-decimals" // 四舍五入到两位小数
+)
+
+// ScorePolicy 分数换算规则：通过比例换算为满分 Total 的分数后按 Rounding 取整。
+// nil 表示默认的百分制、不取整
+type ScorePolicy struct {
+	Total    float64 `json:"total"`     // 满分，默认 100
+	JobScale float64 `json:"job_scale"` // 详情中各测试点的 scoreScale，默认 1
+	Rounding string  `json:"rounding"`  // floor、round 或 2-decimals，默认不取整
+}
+
+// Validate 检查换算规则
+func (p *ScorePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Total < 0 || p.JobScale < 0 {
+		return fmt.Errorf("score total and job_scale must not be negative")
+	}
+	switch p.Rounding {
+	case RoundNone, RoundFloor, RoundNearest, RoundDecimals:
+	default:
+		return fmt.Errorf("unknown score rounding %q", p.Rounding)
+	}
+	return nil
+}
+
+func (p *ScorePolicy) total() float64 {
+	if p == nil || p.Total == 0 {
+		return 100
+	}
+	return p.Total
+}
+
+func (p *ScorePolicy) jobScale() float64 {
+	if p == nil || p.JobScale == 0 {
+		return 1
+	}
+	return p.JobScale
+}
+
+// Score 将 0-1 的通过比例换算为分数
+func (p *ScorePolicy) Score(ratio float64) float64 {
+	return p.Round(ratio * p.total())
+}
+
+// Round 按取整方式处理分数
+func (p *ScorePolicy) Round(score float64) float64 {
+	if p == nil {
+		return score
+	}
+	switch p.Rounding {
+	case RoundFloor:
+		// 消除浮点误差，避免 0.7*100 之类的结果被向下取整为 69
+		return math.Floor(score + 1e-9)
+	case RoundNearest:
+		return math.Round(score)
+	case RoundDecimals:
+		return math.Round(score*100) / 100
+	}
+	return score
+}
+
+// Rescale 将百分制的分数（容器直接上报或其他 adapter 计算的结果）按本规则换算
+func (p *ScorePolicy) Rescale(score float64) float64 {
+	if p == nil {
+		return score
+	}
+	return p.Score(score / 100)
+}
+
+// ScaleDetails 配置了 job_scale 时覆盖详情中各测试点的 scoreScale，返回修改后的副本
+func (p *ScorePolicy) ScaleDetails(details *aoiclient.SolutionDetails) *aoiclient.SolutionDetails {
+	if p == nil || p.JobScale == 0 || details == nil {
+		return details
+	}
+	copied := *details
+	copied.Jobs = make([]*aoiclient.SolutionDetailsJob, len(details.Jobs))
+	for i, job := range details.Jobs {
+		scaled := *job
+		scaled.ScoreScale = p.JobScale
+		copied.Jobs[i] = &scaled
+	}
+	return &copied
+}
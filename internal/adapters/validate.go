@@ -0,0 +1,139 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReportError 评测报告结构不符合预期，Problems 为面向出题人的具体问题描述
+type ReportError struct {
+	Problems []string
+}
+
+func (e *ReportError) Error() string {
+	return "评测报告格式错误: " + strings.Join(e.Problems, "; ")
+}
+
+// reportProblems 收集报告中的问题，没有问题时返回 nil
+type reportProblems []string
+
+func (p *reportProblems) addf(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p reportProblems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	// 问题过多时只展示前几条
+	if len(p) > 5 {
+		p = append(p[:5:5], fmt.Sprintf("另有 %d 个问题", len(p)-5))
+	}
+	return &ReportError{Problems: p}
+}
+
+// jsonSyntaxError 将 JSON 语法错误转换为带行列号的描述
+func jsonSyntaxError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		before := data[:min(int(syntaxErr.Offset), len(data))]
+		line := bytes.Count(before, []byte("\n")) + 1
+		column := len(before) - bytes.LastIndexByte(before, '\n')
+		return &ReportError{Problems: []string{fmt.Sprintf("JSON 语法错误（第 %d 行第 %d 列）: %v", line, column, syntaxErr)}}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ReportError{Problems: []string{"报告文件为空"}}
+	}
+	return err
+}
+
+// knownOutcomes pytest-json-report 可能出现的测试结果
+var knownOutcomes = map[string]bool{
+	"passed": true, "failed": true, "skipped": true, "xfailed": true, "xpassed": true, "error": true,
+}
+
+// ValidatePytestReport 检查 pytest JSON 报告的结构，返回 *ReportError 描述缺失或矛盾的字段
+func ValidatePytestReport(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &ReportError{Problems: []string{"报告顶层必须是 JSON 对象"}}
+		}
+		return jsonSyntaxError(data, err)
+	}
+
+	var problems reportProblems
+	exitCode, hasExitCode := raw["exitcode"].(float64)
+	if _, ok := raw["exitcode"]; !ok {
+		problems.addf("exitcode 缺失")
+	} else if !hasExitCode {
+		problems.addf("exitcode 应为数字")
+	}
+
+	summary, ok := raw["summary"].(map[string]any)
+	switch {
+	case raw["summary"] == nil:
+		problems.addf("summary 缺失")
+	case !ok:
+		problems.addf("summary 应为对象")
+	default:
+		if _, ok := summary["total"]; !ok {
+			problems.addf("summary.total 缺失")
+		}
+		for key, value := range summary {
+			if _, ok := value.(float64); !ok {
+				problems.addf("summary.%s 应为数字", key)
+			}
+		}
+	}
+
+	tests, ok := raw["tests"].([]any)
+	switch {
+	case raw["tests"] == nil:
+		problems.addf("tests 缺失（是否使用了 --json-report？）")
+	case !ok:
+		problems.addf("tests 应为数组")
+	default:
+		for i, item := range tests {
+			test, ok := item.(map[string]any)
+			if !ok {
+				problems.addf("tests[%d] 应为对象", i)
+				continue
+			}
+			if nodeid, _ := test["nodeid"].(string); nodeid == "" {
+				problems.addf("tests[%d].nodeid 缺失", i)
+			}
+			outcome, _ := test["outcome"].(string)
+			if outcome == "" {
+				problems.addf("tests[%d].outcome 缺失", i)
+			} else if !knownOutcomes[outcome] {
+				problems.addf("tests[%d].outcome 取值未知: %q", i, outcome)
+			}
+		}
+		if total, ok := summary["total"].(float64); ok && int(total) != len(tests) {
+			problems.addf("summary.total 为 %d，但 tests 中有 %d 项", int(total), len(tests))
+		}
+		collectors, _ := raw["collectors"].([]any)
+		if len(tests) == 0 && hasExitCode && exitCode == 0 && len(collectors) == 0 {
+			problems.addf("tests 为空但 exitcode 为 0（测试是否被全部过滤？）")
+		}
+	}
+	return problems.err()
+}
+
+// xmlSyntaxError 将 XML 语法错误转换为带行号的描述
+func xmlSyntaxError(data []byte, err error) error {
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &ReportError{Problems: []string{fmt.Sprintf("XML 语法错误（第 %d 行）: %s", syntaxErr.Line, syntaxErr.Msg)}}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ReportError{Problems: []string{"报告文件为空"}}
+	}
+	return err
+}
@@ -1,7 +1,156 @@
 package config
 
+import "time"
+
 type ManagerConfig struct {
 	Endpoint  *string
 	RunnerID  *string
 	RunnerKey *string
+	WorkDir   *string // 临时目录根路径，实际使用 <WorkDir>/<RunnerID>
+	CacheDir  *string // 题目数据缓存目录，可由多个实例共享
+
+	LogLevel  *string // 日志级别：debug、info、warn 或 error
+	LogFormat *string // 日志格式：text 或 json
+
+	PollMinInterval *time.Duration // 空闲时的最小轮询间隔
+	PollMaxInterval *time.Duration // 空闲退避的最大轮询间隔
+	LongPollTimeout *time.Duration // 服务端长轮询等待时间，0 表示不启用
+	Workers         *int           // 同时运行的评测数，有多个空闲 worker 时批量领取任务
+
+	APITimeout         *time.Duration // 单次 AOI API 调用的超时，0 表示不限制
+	APIUploadTimeout   *time.Duration // 上传评测详情与日志的超时
+	APIKeepAlive       *time.Duration // 到 AOI 的 TCP keep-alive 间隔
+	APIMaxIdleConns    *int           // 到 AOI 的最大空闲连接数
+	APIIdleConnTimeout *time.Duration // 空闲连接的保留时间
+	PushDispatch       *bool          // 通过 WebSocket 接收推送任务，断线时回退到轮询
+	ScopedTokens       *bool          // 按操作类别使用分类令牌，平台不支持时回退到 runner key
+
+	AdapterTimeout      *time.Duration // manager 侧 adapter 运行超时
+	AdapterMaxInputSize *int64         // adapter 输入文件大小上限（字节）
+	AdapterMaxTests     *int           // 详情中展示的测试点数量上限
+	AdapterDir          *string        // 外部 adapter（exec:<path>）所在目录，只允许运行其中的程序
+
+	SeccompDir      *string // seccomp 配置目录，judge config 按名称引用 <SeccompDir>/<name>.json
+	SeccompProfile  *string // 默认 seccomp 配置名
+	AppArmorProfile *string // 默认 AppArmor 配置名
+
+	AllowedSecurityProfiles *string // judge config 可选择的安全配置（逗号分隔），如 seccomp:unconfined、apparmor:<名称>、selinux:<标签>；seccomp 目录中的命名配置无需列出
+
+	DefaultUser *string // 容器默认运行用户（uid:gid）
+	AllowRoot   *bool   // 是否允许 judge config 要求以 root 运行
+
+	EnvDenylist  *string // 禁止 judge config 设置的环境变量（逗号分隔，支持 * 通配）
+	EnvAllowlist *string // 非空时 judge config 只能设置这些环境变量
+
+	ResultWebhook *string // 最终评测结果镜像推送地址
+	WebhookSecret *string // webhook 签名密钥
+
+	ErrorReportDSN *string // Sentry DSN，配置时上报 panic、容器执行失败与报告解析失败
+
+	IncidentWebhook       *string        // 运维告警 webhook 地址（逗号分隔），为空时告警只记录日志
+	IncidentWebhookFormat *string        // 告警消息格式：json、slack 或 feishu
+	IncidentCooldown      *time.Duration // 同一对象的重复告警的最小间隔
+	IncidentPollFailures  *int           // 连续轮询失败多少次后告警
+	IncidentProblemErrors *int           // 同一题目 10 分钟内出现多少次内部错误后告警
+
+	CapabilityReportInterval *time.Duration // 向 AOI 上报版本与可用资源的间隔，0 表示只在启动时上报
+
+	ColdStartSLO        *time.Duration // 从收到任务到开始运行的延迟目标，0 表示不检查
+	ColdStartViolations *int           // 同一镜像连续超标多少次后触发预拉取与告警
+	PrefetchInterval    *time.Duration // 在全部主机上预拉取热门镜像的间隔，0 表示不预拉取
+	PrefetchImages      *int           // 每次预拉取的热门镜像数（按最近一小时的使用次数），不含冷启动超标的镜像
+
+	SecretsFile *string // 密钥文件（KEY=VALUE）
+	VaultAddr   *string // Vault 地址
+	VaultToken  *string // Vault 令牌
+	VaultPath   *string // Vault KV v2 路径，如 secret/data/judge
+
+	CPUSet *string // 本实例可分配用于绑核的核心列表，如 "0-15"，为空时使用全部核心
+
+	IPv6SubnetPool *string // 评测网络 IPv6 前缀池（如 fd00:1a6e::/48），每个网络分配一个 /64
+	ShapingImage   *string // 配置 tc netem 使用的辅助镜像（需包含 tc 与 sleep）
+
+	FlakyThreshold *float64 // 测试结果翻转率达到该值时标记为不稳定
+	FlakyRetry     *bool    // 是否在出分前重试失败的不稳定测试
+
+	LogUploadLimit *int64         // 每次评测上传到 AOI 的容器日志上限（字节），0 表示不上传
+	LogDir         *string        // 本地评测日志目录，默认 <WorkDir>/<RunnerID>/logs
+	LogMaxSize     *int64         // 单个本地日志文件的轮转大小（字节）
+	LogTTL         *time.Duration // 本地日志保留时间，0 表示不清理
+
+	ArtifactMaxSize  *int64 // 容器通过 judgerproto 推送的单个产物大小上限（字节），0 表示不接收
+	ArtifactMaxTotal *int64 // 单次评测推送的产物总大小上限（字节）
+
+	CoreDumpTarget *string // 容器内 core dump 目录，需与宿主机 kernel.core_pattern 的目录一致
+
+	GPUDevices *string // 可分配的 GPU 设备 ID 列表，如 "0,1,2,3"，也可以是 MIG 实例 UUID，同一主机的实例通过锁文件共享
+	GPUSlots   *int64  // 每个 GPU 划分的份数，大于 1 时允许题目按 fraction 申请部分 GPU，同一主机的实例需一致
+	GPULockDir *string // GPU 锁文件目录，同一主机的实例需一致，默认为系统临时目录下的 lfs-auto-grader-gpu-locks
+
+	GPUSampleInterval *time.Duration // 评测运行期间通过 nvidia-smi 采样 GPU 利用率与显存的间隔，0 表示不采样
+
+	TrustedHookImages    *string // 允许 judge config 用于 pre/post 命令的镜像列表（逗号分隔）
+	TrustedServiceImages *string // 允许 judge config 用于服务容器的镜像列表（逗号分隔），为空时不限制
+
+	MPIListen    *string // 接受其他 runner 启动 MPI worker 的 RPC 监听地址，为空时不接受
+	MPIPeers     *string // 可作为 MPI worker 的其他 runner 的 RPC 地址（逗号分隔），为空时不领导多节点任务
+	MPIToken     *string // runner 之间 RPC 的共享令牌
+	MPIAdvertise *string // 本节点写入 hostfile 的地址，默认为主机名
+	MPITLSCert   *string // 设置时 mpi-listen 以 TLS 提供 RPC 的证书文件，未设置时为明文 HTTP，只应在可信网络中使用
+	MPITLSKey    *string // mpi-tls-cert 对应的私钥文件
+	MPITLSCA     *string // 以 https:// 访问 peer 时用于校验其证书的 CA 文件，为空时使用系统根证书
+
+	DockerHosts *string // 由本实例驱动的 Docker 守护进程地址（逗号分隔），为空时使用 DOCKER_HOST；nerdctl 后端为 containerd 套接字地址
+
+	ExecutorBackend     *string // 容器运行时后端：docker 或 nerdctl（直接使用 containerd）
+	ContainerdNamespace *string // nerdctl 后端使用的 containerd 命名空间
+
+	AllowedRuntimes *string // 允许 judge config 选择的 OCI 运行时（逗号分隔，如 runsc,kata-runtime）
+	AllowedDevices  *string // 允许 judge config 直通的宿主机设备（逗号分隔，支持 * 通配，如 /dev/infiniband/*,/dev/kfd,/dev/dri/*）
+
+	CheckpointDir *string // 各 runner 共享的检查点目录，排空时运行中的评测保存到此处并由其他 runner 恢复；多 task 汇总的各 task 结果同样保存在此处
+
+	CancelPollInterval *time.Duration // 评测期间查询取消状态的间隔，0 表示只接受推送的取消消息
+
+	AdminListen *string // 本机管理接口监听地址（如 127.0.0.1:9090），为空时不启用
+	AdminToken  *string // 管理接口令牌
+
+	MetricsListen *string // Prometheus 指标（GET /metrics）监听地址，不需要令牌，为空时不启用
+
+	RecordDir *string // 录制每个任务的原始负载与结果的目录，供 replay 在本地重现，为空时不录制
+
+	ScratchDir        *string // 评测输出等临时目录的根路径（如本地 NVMe 或 tmpfs），实际使用 <ScratchDir>/<RunnerID>
+	ScratchJobLimit   *int64  // 单个评测输出目录的大小上限（字节），超过时终止评测，0 表示不限制
+	ScratchTotalLimit *int64  // 临时目录的总大小上限（字节），达到时暂停领取新任务，0 表示不限制
+
+	BuildCacheDir    *string // 构建缓存的宿主机根目录，为空时不允许 judge config 启用构建缓存
+	BuildCacheConfig *string // 自定义构建缓存的 JSON 文件（名称 -> target、env、readOnly），覆盖或补充内置缓存
+
+	CompilerCacheSize  *int64 // 单个 ccache/sccache 缓存目录的大小上限（字节），由缓存工具自行淘汰
+	CompilerCacheTotal *int64 // 全部编译缓存目录的总大小上限（字节），GC 时按最近使用删除，0 表示不限制
+
+	GCInterval      *time.Duration // 磁盘回收间隔，0 表示不回收
+	GCDiskPath      *string        // 按该路径所在文件系统的使用率决定是否删除镜像，通常为容器运行时的数据目录
+	GCHighWatermark *float64       // 磁盘使用率超过该比例时按最近使用时间删除评测镜像
+	GCLowWatermark  *float64       // 删除镜像直到磁盘使用率低于该比例
+	GCTempTTL       *time.Duration // 不属于运行中评测的临时目录超过该时间未修改时删除
+
+	DefaultTimeout     *int64   // 题目未指定时的运行时间限制（秒）
+	MaxTimeout         *int64   // 运行时间限制上限（秒），超过时截断，0 表示不限制
+	DefaultMemoryLimit *int64   // 题目未指定时的内存限制（MB）
+	MaxMemoryLimit     *int64   // 内存限制上限（MB），0 表示不限制
+	DefaultCPULimit    *float64 // 题目未指定时的 CPU 限制（核心数），0 表示不限制
+	MaxCPULimit        *float64 // CPU 限制上限（核心数），不限制 CPU 的题目同样被截断，0 表示不限制
+	OutputLimit        *int64   // 题目未指定时的容器输出总量限制（MB）
+	MaxOutputLimit     *int64   // 容器输出总量限制上限（MB），0 表示不限制
+	MaxHugePages       *int64   // 题目可申请的 hugetlbfs 大小上限（MB），0 表示不允许使用大页
+
+	SimilarityConfig *string // 按比赛配置评测后查重的 JSON 文件（比赛 ID 或 * -> url/command 等），为空时不查重
+
+	WarmPoolSize     *int           // 每道热门题目预先启动的空闲评测容器数，0 表示不启用
+	WarmPoolProblems *int           // 保持预热容器的热门题目数，按最近一小时的评测次数选出
+	WarmPoolIdle     *time.Duration // 预热容器空闲超过该时间后删除
+
+	AdmissionCPURatio    *float64 // 运行中评测申请的 CPU 总量超过主机核心数的该倍数时暂停领取任务，0 表示不限制
+	AdmissionMemoryRatio *float64 // 运行中评测申请的内存总量超过主机内存的该比例时暂停领取任务，0 表示不限制
 }
@@ -0,0 +1,306 @@
+package datacache
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Cache 题目数据缓存，按数据哈希解压存放，多个 manager 实例可共享同一目录
+type Cache struct {
+	root string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// New 创建题目数据缓存
+func New(root string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &Cache{root: root, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+func (c *Cache) lock(hash string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[hash]
+	if !ok {
+		l = new(sync.Mutex)
+		c.locks[hash] = l
+	}
+	return l
+}
+
+// Prepare 确保数据已下载并解压，返回缓存中的解压目录
+func (c *Cache) Prepare(ctx context.Context, url, hash string) (string, error) {
+	if hash == "" || strings.ContainsAny(hash, `/\.`) {
+		return "", fmt.Errorf("invalid problem data hash %q", hash)
+	}
+	dir := filepath.Join(c.root, hash)
+
+	l := c.lock(hash)
+	l.Lock()
+	defer l.Unlock()
+
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	archive, err := c.download(ctx, url, hash)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive)
+
+	// 先解压到临时目录再原子重命名，避免其他实例看到不完整的数据
+	tmp, err := os.MkdirTemp(filepath.Join(c.root, "tmp"), "extract-"+hash+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := extract(archive, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to extract problem data: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		// 其他实例已完成解压
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// ExtractTo 下载压缩包并直接解压到 dst，不写入缓存，用于每次评测都不同的提交数据
+func (c *Cache) ExtractTo(ctx context.Context, url, hash, dst string) error {
+	archive, err := c.download(ctx, url, hash)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+	if err := extract(archive, dst); err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return nil
+}
+
+// download 下载数据到临时文件，哈希为 sha256 时进行校验
+func (c *Cache) download(ctx context.Context, url, hash string) (string, error) {
+	f, err := os.CreateTemp(filepath.Join(c.root, "tmp"), "download-"+hash+"-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download problem data: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download problem data: %s", res.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), res.Body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download problem data: %w", err)
+	}
+	if len(hash) == sha256.Size*2 && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), hash) {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("problem data hash mismatch")
+	}
+	return f.Name(), nil
+}
+
+// Clone 将缓存目录以硬链接方式克隆到 dst，跨文件系统时退化为复制。
+// 硬链接与缓存共享 inode，dst 只能以只读方式使用
+func (c *Cache) Clone(src, dst string) error {
+	return c.clone(src, dst, true)
+}
+
+// Copy 将缓存目录完整复制到 dst，用于可写的挂载，修改不会影响缓存
+func (c *Cache) Copy(src, dst string) error {
+	return c.clone(src, dst, false)
+}
+
+func (c *Cache) clone(src, dst string, link bool) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if link {
+				if err := os.Link(path, target); err == nil {
+					return nil
+				}
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// extract 根据文件头识别 zip / tar / tar.gz 并解压
+func extract(archive, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return extractZip(archive, dst)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, dst)
+	default:
+		return extractTar(br, dst)
+	}
+}
+
+// safeJoin 防止压缩包中的路径穿越
+func safeJoin(dst, name string) (string, error) {
+	target := filepath.Join(dst, name)
+	if target != dst && !strings.HasPrefix(target, dst+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path in archive: %s", name)
+	}
+	return target, nil
+}
+
+func extractZip(archive, dst string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		target, err := safeJoin(dst, zf.Name)
+		if err != nil {
+			return err
+		}
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, rc, zf.Mode().Perm()|0o444)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := safeJoin(dst, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode).Perm()|0o444); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(target string, r io.Reader, perm os.FileMode) error {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
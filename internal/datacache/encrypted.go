@@ -0,0 +1,244 @@
+package datacache
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 加密数据格式：magic | 7 字节随机 nonce 前缀 | 若干分块。
+// 每块明文最多 encChunkSize 字节，使用 AES-256-GCM 加密，
+// nonce 为 前缀 || 块序号(4 字节) || 是否最后一块(1 字节)，可防止分块被重排或截断
+const (
+	encMagic      = "LFSENC1\n"
+	encPrefixSize = 7
+	encChunkSize  = 64 << 10
+	expiresSuffix = ".expires"
+)
+
+var errTruncated = errors.New("encrypted data is truncated")
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encPrefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Encrypt 以分块 AES-256-GCM 加密 r 并写入 w，供出题人预先加密题目数据
+func Encrypt(w io.Writer, r io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, encPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, encMagic); err != nil {
+		return err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+
+	buf := make([]byte, encChunkSize)
+	next := make([]byte, encChunkSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	for counter := uint32(0); ; counter++ {
+		// 预读下一块以确定当前块是否为最后一块
+		m, nextErr := io.ReadFull(r, next)
+		if nextErr != nil && nextErr != io.ErrUnexpectedEOF && nextErr != io.EOF {
+			return nextErr
+		}
+		last := m == 0
+		if _, err := w.Write(gcm.Seal(nil, chunkNonce(prefix, counter, last), buf[:n], nil)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		buf, next, n = next, buf, m
+	}
+}
+
+// decryptFile 解密 src 并写入 dst
+func decryptFile(src, dst string, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	header := make([]byte, len(encMagic)+encPrefixSize)
+	if _, err := io.ReadFull(in, header); err != nil || !bytes.HasPrefix(header, []byte(encMagic)) {
+		return fmt.Errorf("not an encrypted problem data archive")
+	}
+	prefix := header[len(encMagic):]
+
+	sealedSize := encChunkSize + gcm.Overhead()
+	buf := make([]byte, sealedSize)
+	next := make([]byte, sealedSize)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return errTruncated
+	}
+	for counter := uint32(0); ; counter++ {
+		m, nextErr := io.ReadFull(in, next)
+		if nextErr != nil && nextErr != io.ErrUnexpectedEOF && nextErr != io.EOF {
+			return nextErr
+		}
+		last := m == 0
+		plain, err := gcm.Open(buf[:0], chunkNonce(prefix, counter, last), buf[:n], nil)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt problem data: %w", err)
+		}
+		if _, err := out.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		buf, next, n = next[:sealedSize], buf[:sealedSize], m
+	}
+}
+
+// Stage 仅下载加密数据到缓存，不解密，用于比赛开始前预先分发
+func (c *Cache) Stage(ctx context.Context, url, hash string) (string, error) {
+	if hash == "" || strings.ContainsAny(hash, `/\.`) {
+		return "", fmt.Errorf("invalid problem data hash %q", hash)
+	}
+	blob := filepath.Join(c.root, "encrypted", hash)
+
+	l := c.lock("encrypted/" + hash)
+	l.Lock()
+	defer l.Unlock()
+
+	if _, err := os.Stat(blob); err == nil {
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		return "", err
+	}
+	archive, err := c.download(ctx, url, hash)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(archive, blob); err != nil {
+		os.Remove(archive)
+		return "", err
+	}
+	return blob, nil
+}
+
+// PrepareEncrypted 确保加密数据已下载，并解密解压到缓存，返回解压目录。
+// 解压目录在 expires 之后由 WipeExpired 删除，加密原文件保留
+func (c *Cache) PrepareEncrypted(ctx context.Context, url, hash string, key []byte, expires time.Time) (string, error) {
+	blob, err := c.Stage(ctx, url, hash)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(c.root, hash)
+
+	l := c.lock(hash)
+	l.Lock()
+	defer l.Unlock()
+
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	archive, err := os.CreateTemp(filepath.Join(c.root, "tmp"), "decrypt-"+hash+"-")
+	if err != nil {
+		return "", err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+	if err := decryptFile(blob, archive.Name(), key); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Join(c.root, "tmp"), "extract-"+hash+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := extract(archive.Name(), tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to extract problem data: %w", err)
+	}
+	// 先写入过期标记，保证解压目录出现时一定会被清理
+	if err := os.WriteFile(dir+expiresSuffix, []byte(expires.UTC().Format(time.RFC3339)), 0o644); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// WipeExpired 删除已过期的解密数据，返回删除的数量
+func (c *Cache) WipeExpired() int {
+	markers, _ := filepath.Glob(filepath.Join(c.root, "*"+expiresSuffix))
+	wiped := 0
+	for _, marker := range markers {
+		data, err := os.ReadFile(marker)
+		if err != nil {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+		if err != nil || time.Now().Before(expires) {
+			continue
+		}
+		hash := strings.TrimSuffix(filepath.Base(marker), expiresSuffix)
+		l := c.lock(hash)
+		l.Lock()
+		err = os.RemoveAll(filepath.Join(c.root, hash))
+		if err == nil {
+			os.Remove(marker)
+			wiped++
+		}
+		l.Unlock()
+	}
+	return wiped
+}
@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/checkpoint"
+)
+
+// checkpointID 检查点名称，保存与恢复时使用同一名称，位于各自的检查点目录中
+const checkpointID = "checkpoint"
+
+// Checkpoint 以 CRIU 将运行中的容器保存到 dir 并停止容器，需要 Docker 开启 experimental 且宿主机安装 CRIU。
+// 之后可在任意主机上以相同配置创建容器，并设置 RestoreFrom 为 dir 恢复运行
+func (e *DockerExecutor) Checkpoint(ctx context.Context, containerID, dir string) error {
+	err := e.client.CheckpointCreate(ctx, containerID, checkpoint.CreateOptions{
+		CheckpointID:  checkpointID,
+		CheckpointDir: dir,
+		Exit:          true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint container: %w", err)
+	}
+	return nil
+}
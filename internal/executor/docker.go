@@ -1,33 +1,44 @@
 package executor
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 )
 
+// logDrainTimeout 容器结束后等待日志处理完毕的最长时间
+const logDrainTimeout = 10 * time.Second
+
 // DockerExecutor Docker 执行器
 type DockerExecutor struct {
 	client *client.Client
+	local  bool // 守护进程在本机，可从内核日志读取 OOM kill 的进程
 }
 
-// NewDockerExecutor 创建 Docker 执行器
-func NewDockerExecutor() (*DockerExecutor, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// NewDockerExecutor 创建 Docker 执行器，host 为空时使用环境变量（DOCKER_HOST 等）指定的守护进程
+func NewDockerExecutor(host string) (*DockerExecutor, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 
 	return &DockerExecutor{
 		client: cli,
+		local:  strings.HasPrefix(cli.DaemonHost(), "unix://"),
 	}, nil
 }
 
@@ -38,57 +49,59 @@ func (e *DockerExecutor) Execute(ctx context.Context, config *ExecuteConfig) (*E
 
 // ExecuteWithLogs 执行评测任务并实时获取日志
 func (e *DockerExecutor) ExecuteWithLogs(ctx context.Context, config *ExecuteConfig, callback LogCallback) (*ExecuteResult, error) {
-	// 创建容器配置
-	containerConfig := &container.Config{
-		Image:      config.Image,
-		Cmd:        config.Command,
-		WorkingDir: config.WorkDir,
-		Env:        e.buildEnvList(config.Env),
-	}
-
-	// 创建宿主机配置
-	hostConfig := &container.HostConfig{
-		Resources: container.Resources{},
-		Mounts:    e.buildMounts(config.Mounts),
-	}
-
-	// 设置资源限制
-	if config.MemoryLimit > 0 {
-		hostConfig.Resources.Memory = config.MemoryLimit * 1024 * 1024 // 转换为字节
-		hostConfig.Resources.MemorySwap = hostConfig.Resources.Memory  // 禁用 swap
-	}
-	if config.CPULimit > 0 {
-		hostConfig.Resources.NanoCPUs = int64(config.CPULimit * 1e9)
-	}
-
-	// 创建容器
-	resp, err := e.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	containerID, err := e.createContainer(ctx, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
+		return nil, err
 	}
-	containerID := resp.ID
 
 	// 确保清理容器
 	defer e.Cleanup(context.Background(), containerID)
 
-	// 启动容器
-	if err := e.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+	// 启动前订阅 oom 事件，避免遗漏启动后立即发生的 OOM
+	oom := e.watchOOM(containerID)
+
+	// 启动容器，指定检查点时从检查点恢复
+	startOptions := container.StartOptions{}
+	if config.RestoreFrom != "" {
+		startOptions.CheckpointID = checkpointID
+		startOptions.CheckpointDir = config.RestoreFrom
+	}
+	if err := e.client.ContainerStart(ctx, containerID, startOptions); err != nil {
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
+	if config.OnStart != nil {
+		config.OnStart(containerID)
+	}
 
 	// 设置超时上下文
 	var execCtx context.Context
 	var cancel context.CancelFunc
 	if config.Timeout > 0 {
-		execCtx, cancel = context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
+		execCtx, cancel = context.WithTimeout(ctx, config.Timeout)
 	} else {
 		execCtx, cancel = context.WithCancel(ctx)
 	}
 	defer cancel()
 
-	// 获取日志
-	if callback != nil {
-		go e.streamLogsWithCallback(execCtx, containerID, callback)
+	// 采样资源使用情况
+	statsCtx, stopStats := context.WithCancel(execCtx)
+	defer stopStats()
+	var cpuExceeded atomic.Bool
+	sampler := e.sampleStats(statsCtx, containerID, config.CPUTimeLimit, func() {
+		cpuExceeded.Store(true)
+		e.Stop(context.Background(), containerID)
+	})
+
+	// 获取日志，输出超限时终止容器，OutputDrain 时丢弃其余输出直到容器结束
+	var outputExceeded atomic.Bool
+	var logsDone <-chan struct{}
+	if callback != nil || config.OutputLimit > 0 {
+		logsDone = e.streamLogs(execCtx, containerID, callback, config.OutputLimit, config.OutputDrain, func() {
+			outputExceeded.Store(true)
+			if !config.OutputDrain {
+				e.Stop(context.Background(), containerID)
+			}
+		})
 	}
 
 	// 等待容器结束
@@ -113,11 +126,26 @@ func (e *DockerExecutor) ExecuteWithLogs(ctx context.Context, config *ExecuteCon
 		}
 	}
 
-	// 检查 OOM
+	stopStats()
+	result.Usage = sampler.result()
+	result.CPUTimeExceeded = cpuExceeded.Load()
+
+	// 等待剩余日志处理完毕，避免丢失容器最后输出的协议消息
+	if logsDone != nil {
+		select {
+		case <-logsDone:
+		case <-time.After(logDrainTimeout):
+		}
+	}
+	result.OutputLimitExceeded = outputExceeded.Load()
+
+	// 检查 OOM：主进程的 OOMKilled 标记与 cgroup 内任一进程的 oom 事件
+	result.OOMKills = oomKills(containerID, oom.wait(), e.local)
 	inspect, err := e.client.ContainerInspect(ctx, containerID)
 	if err == nil && inspect.State != nil {
 		result.OOM = inspect.State.OOMKilled
 	}
+	result.OOM = result.OOM || len(result.OOMKills) > 0
 
 	// 获取输出
 	stdout, stderr, err := e.getLogs(ctx, containerID)
@@ -129,6 +157,160 @@ func (e *DockerExecutor) ExecuteWithLogs(ctx context.Context, config *ExecuteCon
 	return result, nil
 }
 
+// DaemonInfo Docker 守护进程的运行模式与负载
+type DaemonInfo struct {
+	Rootless    bool // 以 rootless 模式运行
+	UsernsRemap bool // 启用了 userns-remap
+
+	ContainersRunning int   // 运行中的容器数（包括其他 manager 实例的容器）
+	NCPU              int   // CPU 核心数
+	MemTotal          int64 // 内存总量（字节）
+}
+
+// createContainer 根据执行配置创建容器
+func (e *DockerExecutor) createContainer(ctx context.Context, config *ExecuteConfig) (string, error) {
+	// 创建容器配置
+	containerConfig := &container.Config{
+		Image:      config.Image,
+		Cmd:        config.Command,
+		WorkingDir: config.WorkDir,
+		Env:        e.buildEnvList(config.Env),
+		Labels:     config.Labels,
+		User:       config.User,
+	}
+
+	// 创建宿主机配置
+	hostConfig := &container.HostConfig{
+		Resources:   container.Resources{},
+		Mounts:      e.buildMounts(config.Mounts),
+		Tmpfs:       config.Tmpfs,
+		SecurityOpt: e.buildSecurityOpt(config),
+		ShmSize:     config.ShmSize << 20,
+	}
+	if config.HugePages > 0 {
+		hostConfig.Mounts = append(hostConfig.Mounts, hugePagesMount(config))
+	}
+
+	// 设置资源限制
+	if config.MemoryLimit > 0 {
+		hostConfig.Resources.Memory = config.MemoryLimit * 1024 * 1024 // 转换为字节
+		hostConfig.Resources.MemorySwap = hostConfig.Resources.Memory  // 禁用 swap
+	}
+	if config.CPULimit > 0 {
+		hostConfig.Resources.NanoCPUs = int64(config.CPULimit * 1e9)
+	}
+	hostConfig.Resources.CpusetCpus = config.CpusetCpus
+	hostConfig.Resources.CpusetMems = config.CpusetMems
+	if len(config.GPUDevices) > 0 {
+		hostConfig.Resources.DeviceRequests = []container.DeviceRequest{{
+			Driver:       "nvidia",
+			DeviceIDs:    config.GPUDevices,
+			Capabilities: [][]string{{"gpu"}},
+		}}
+	}
+	if config.PidsLimit > 0 {
+		hostConfig.Resources.PidsLimit = &config.PidsLimit
+	}
+	for _, d := range config.Devices {
+		hostConfig.Resources.Devices = append(hostConfig.Resources.Devices, container.DeviceMapping{
+			PathOnHost:        d.Source,
+			PathInContainer:   d.Target,
+			CgroupPermissions: d.Permissions,
+		})
+	}
+	hostConfig.ReadonlyRootfs = config.ReadOnlyRootfs
+	hostConfig.Runtime = config.Runtime
+	hostConfig.CapDrop = config.CapDrop
+	hostConfig.CapAdd = config.CapAdd
+	if config.CoreDumps {
+		hostConfig.Resources.Ulimits = append(hostConfig.Resources.Ulimits, &container.Ulimit{Name: "core", Soft: -1, Hard: -1})
+	}
+	if config.NetworkDisabled {
+		containerConfig.NetworkDisabled = true
+		hostConfig.NetworkMode = "none"
+	} else if config.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(config.Network)
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	if config.Network != "" && !strings.HasPrefix(config.Network, "container:") && len(config.NetworkAliases) > 0 {
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				config.Network: {Aliases: config.NetworkAliases},
+			},
+		}
+	}
+
+	// 创建容器
+	resp, err := e.client.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// StartDetached 创建并启动容器后立即返回容器 ID，不等待其结束，调用方负责 Cleanup。
+// 用于持有网络命名空间的辅助容器等
+func (e *DockerExecutor) StartDetached(ctx context.Context, config *ExecuteConfig) (string, error) {
+	containerID, err := e.createContainer(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	if err := e.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		e.Cleanup(context.Background(), containerID)
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	return containerID, nil
+}
+
+// Info 查询 Docker 守护进程的运行模式与负载
+func (e *DockerExecutor) Info(ctx context.Context) (*DaemonInfo, error) {
+	info, err := e.client.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	result := &DaemonInfo{
+		ContainersRunning: info.ContainersRunning,
+		NCPU:              info.NCPU,
+		MemTotal:          info.MemTotal,
+	}
+	for _, opt := range info.SecurityOptions {
+		switch {
+		case strings.Contains(opt, "name=rootless"):
+			result.Rootless = true
+		case strings.Contains(opt, "name=userns"):
+			result.UsernsRemap = true
+		}
+	}
+	return result, nil
+}
+
+// EnsureImage 确保镜像存在于本地，不存在时拉取
+func (e *DockerExecutor) EnsureImage(ctx context.Context, ref string) error {
+	_, _, err := e.client.ImageInspectWithRaw(ctx, ref)
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect image: %w", err)
+	}
+	return e.PullImage(ctx, ref)
+}
+
+// PullImage 拉取镜像，本地已存在时更新到最新版本
+func (e *DockerExecutor) PullImage(ctx context.Context, ref string) error {
+	reader, err := e.client.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	defer reader.Close()
+	// 拉取进度需要读完才会结束
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	return nil
+}
+
 // StreamLogs 流式获取容器日志
 func (e *DockerExecutor) StreamLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
 	return e.client.ContainerLogs(ctx, containerID, container.LogsOptions{
@@ -181,6 +363,42 @@ func (e *DockerExecutor) buildMounts(mounts []Mount) []mount.Mount {
 	return result
 }
 
+// hugePagesMount 通过 local 卷驱动为容器挂载独立的 hugetlbfs，size 限制其中可分配的大页总量。
+// 匿名卷随容器一起删除
+func hugePagesMount(config *ExecuteConfig) mount.Mount {
+	return mount.Mount{
+		Type:   mount.TypeVolume,
+		Target: "/dev/hugepages",
+		VolumeOptions: &mount.VolumeOptions{
+			DriverConfig: &mount.Driver{
+				Name: "local",
+				Options: map[string]string{
+					"type":   "hugetlbfs",
+					"device": "none",
+					"o":      fmt.Sprintf("pagesize=%s,size=%dM,mode=1777", config.HugePageSize, config.HugePages),
+				},
+			},
+		},
+	}
+}
+
+func (e *DockerExecutor) buildSecurityOpt(config *ExecuteConfig) []string {
+	var result []string
+	if config.SeccompProfile != "" {
+		result = append(result, "seccomp="+config.SeccompProfile)
+	}
+	if config.AppArmorProfile != "" {
+		result = append(result, "apparmor="+config.AppArmorProfile)
+	}
+	if config.SELinuxLabel != "" {
+		result = append(result, "label="+config.SELinuxLabel)
+	}
+	if config.NoNewPrivileges {
+		result = append(result, "no-new-privileges")
+	}
+	return result
+}
+
 func (e *DockerExecutor) getLogs(ctx context.Context, containerID string) (stdout, stderr string, err error) {
 	reader, err := e.client.ContainerLogs(ctx, containerID, container.LogsOptions{
 		ShowStdout: true,
@@ -192,31 +410,12 @@ func (e *DockerExecutor) getLogs(ctx context.Context, containerID string) (stdou
 	}
 	defer reader.Close()
 
-	var stdoutBuf, stderrBuf strings.Builder
-	_, err = stdcopy.StdCopy(&stdoutBuf, &stderrBuf, reader)
+	stdoutBuf := &limitedBuffer{limit: maxResultOutput}
+	stderrBuf := &limitedBuffer{limit: maxResultOutput}
+	_, err = stdcopy.StdCopy(stdoutBuf, stderrBuf, reader)
 	if err != nil {
 		return "", "", err
 	}
 
 	return stdoutBuf.String(), stderrBuf.String(), nil
 }
-
-func (e *DockerExecutor) streamLogsWithCallback(ctx context.Context, containerID string, callback LogCallback) {
-	reader, err := e.StreamLogs(ctx, containerID)
-	if err != nil {
-		return
-	}
-	defer reader.Close()
-
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Docker 日志格式有 8 字节头，需要跳过
-		if len(line) > 8 {
-			line = line[8:]
-		}
-		if err := callback(line); err != nil {
-			return
-		}
-	}
-}
@@ -3,20 +3,64 @@ package executor
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // ExecuteConfig 评测执行配置
 type ExecuteConfig struct {
 	Image       string            `json:"image"`       // Docker 镜像
 	Command     []string          `json:"command"`     // 执行命令
-	Timeout     int64             `json:"timeout"`     // 超时时间（秒）
+	Timeout     time.Duration     `json:"timeout"`     // 超时时间，0 为不限制
 	MemoryLimit int64             `json:"memoryLimit"` // 内存限制（MB）
 	CPULimit    float64           `json:"cpuLimit"`    // CPU 限制（核心数）
 	Env         map[string]string `json:"env"`         // 环境变量
 	WorkDir     string            `json:"workDir"`     // 工作目录
 	Mounts      []Mount           `json:"mounts"`      // 挂载配置
+	Tmpfs       map[string]string `json:"tmpfs"`       // 挂载的 tmpfs，容器内路径 -> 挂载选项（如 "size=64m"）
+	Labels      map[string]string `json:"labels"`      // 容器标签
+	User        string            `json:"user"`        // 容器运行用户（uid:gid）
+	CpusetCpus  string            `json:"cpusetCpus"`  // 绑定的 CPU 核心，如 "0,1,2,3"
+	CpusetMems  string            `json:"cpusetMems"`  // 绑定的 NUMA 内存节点
+	GPUDevices  []string          `json:"gpuDevices"`  // 分配的 GPU 设备 ID（NVIDIA）
+	Devices     []Device          `json:"devices"`     // 直通的宿主机设备（如 InfiniBand、/dev/kfd）
+	OutputLimit int64             `json:"outputLimit"` // stdout 与 stderr 总量上限（字节），超出后终止容器，0 为不限制
+	OutputDrain bool              `json:"outputDrain"` // 输出超限后不终止容器，停止转发并丢弃其余输出直到容器结束
+
+	CPUTimeLimit time.Duration `json:"cpuTimeLimit"` // 累计 CPU 时间上限（cgroup 内全部进程与线程之和），超出后终止容器，0 为不限制
+
+	ShmSize      int64  `json:"shmSize"`      // /dev/shm 大小（MB），0 使用运行时默认值（64MB）
+	HugePages    int64  `json:"hugePages"`    // 挂载到 /dev/hugepages 的 hugetlbfs 大小上限（MB），0 为不挂载
+	HugePageSize string `json:"hugePageSize"` // 大页大小，如 "2M"、"1G"
+
+	NetworkDisabled bool     `json:"networkDisabled"` // 禁用网络
+	Network         string   `json:"network"`         // 加入的网络，为空时使用默认 bridge，"container:<id>" 共享其他容器的网络
+	NetworkAliases  []string `json:"networkAliases"`  // 在 Network 中的别名（主机名），仅对自定义网络有效
+	ReadOnlyRootfs  bool     `json:"readOnlyRootfs"`  // 只读根文件系统
+	PidsLimit       int64    `json:"pidsLimit"`       // 进程数上限，0 为不限制
+	NoNewPrivileges bool     `json:"noNewPrivileges"` // 禁止提权
+	CapDrop         []string `json:"capDrop"`         // 移除的 capability，如 ["ALL"]
+	CapAdd          []string `json:"capAdd"`          // 额外添加的 capability，仅用于 manager 自身的辅助容器
+	CoreDumps       bool     `json:"coreDumps"`       // 允许生成 core dump（不限制 core 文件大小）
+
+	SeccompProfile  string `json:"seccompProfile"`  // seccomp 配置内容（JSON），"unconfined" 表示不限制，空为 Docker 默认
+	AppArmorProfile string `json:"appArmorProfile"` // AppArmor 配置名，空为 Docker 默认
+	SELinuxLabel    string `json:"seLinuxLabel"`    // SELinux 标签，如 "type:container_t"
+	Runtime         string `json:"runtime"`         // OCI 运行时（如 runsc、kata-runtime），空为守护进程默认
+
+	RestoreFrom string                   `json:"restoreFrom"` // 从该目录中的 CRIU 检查点恢复，而不是重新运行 Command
+	OnStart     func(containerID string) `json:"-"`           // 容器启动后回调，用于记录容器 ID
 }
 
+// 容器标签键，用于区分同一主机上的多个 manager 实例
+const (
+	LabelRunnerID   = "club.lcpu.lfs-auto-grader.runner-id"
+	LabelSolutionID = "club.lcpu.lfs-auto-grader.solution-id"
+	LabelTaskID     = "club.lcpu.lfs-auto-grader.task-id"
+	LabelService    = "club.lcpu.lfs-auto-grader.service"
+	LabelMPILead    = "club.lcpu.lfs-auto-grader.mpi-lead"
+	LabelWarm       = "club.lcpu.lfs-auto-grader.warm"
+)
+
 // Mount 挂载配置
 type Mount struct {
 	Source   string `json:"source"`   // 宿主机路径
@@ -24,17 +68,46 @@ type Mount struct {
 	ReadOnly bool   `json:"readOnly"` // 是否只读
 }
 
+// Device 设备直通配置
+type Device struct {
+	Source      string `json:"source"`      // 宿主机设备路径，目录表示其下的全部设备
+	Target      string `json:"target"`      // 容器内路径
+	Permissions string `json:"permissions"` // cgroup 设备权限，如 "rwm"
+}
+
 // ExecuteResult 执行结果
 type ExecuteResult struct {
 	ExitCode int    // 退出码
 	Stdout   string // 标准输出
 	Stderr   string // 标准错误
 	TimedOut bool   // 是否超时
-	OOM      bool   // 是否内存超限
+	OOM      bool   // 是否内存超限，包括子进程被 OOM kill 的情况
+
+	OOMKills []OOMKill // 被 OOM kill 的进程，可获取时按发生顺序排列
+
+	OutputLimitExceeded bool // 输出超过 OutputLimit 被终止
+	CPUTimeExceeded     bool // 累计 CPU 时间超过 CPUTimeLimit 被终止
+
+	Usage *ResourceUsage // 资源使用情况
+}
+
+// LogStream 日志来源
+type LogStream int
+
+const (
+	Stdout LogStream = iota // 标准输出
+	Stderr                  // 标准错误
+)
+
+func (s LogStream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
 }
 
-// LogCallback 日志回调函数
-type LogCallback func(line string) error
+// LogCallback 日志回调函数，按行调用，同一容器的回调不会并发执行
+type LogCallback func(stream LogStream, line string) error
 
 // Executor 执行器接口
 type Executor interface {
@@ -44,12 +117,51 @@ type Executor interface {
 	// ExecuteWithLogs 执行评测任务并实时获取日志
 	ExecuteWithLogs(ctx context.Context, config *ExecuteConfig, callback LogCallback) (*ExecuteResult, error)
 
+	// EnsureImage 确保镜像存在于本地
+	EnsureImage(ctx context.Context, image string) error
+
+	// PullImage 拉取镜像
+	PullImage(ctx context.Context, image string) error
+
+	// StartDetached 启动容器但不等待其结束，返回容器 ID
+	StartDetached(ctx context.Context, config *ExecuteConfig) (string, error)
+
+	// StartSession 启动持续运行的容器，之后可多次在其中执行命令
+	StartSession(ctx context.Context, config *ExecuteConfig) (Session, error)
+
 	// StreamLogs 流式获取容器日志
 	StreamLogs(ctx context.Context, containerID string) (io.ReadCloser, error)
 
+	// FollowLogs 跟随容器日志并按行回调
+	FollowLogs(ctx context.Context, containerID string, callback LogCallback) <-chan struct{}
+
+	// CreateNetwork 创建评测网络，返回网络 ID
+	CreateNetwork(ctx context.Context, config *NetworkConfig) (string, error)
+
+	// RemoveNetwork 删除评测网络
+	RemoveNetwork(ctx context.Context, networkID string) error
+
 	// Stop 停止执行中的任务
 	Stop(ctx context.Context, containerID string) error
 
 	// Cleanup 清理资源
 	Cleanup(ctx context.Context, containerID string) error
+
+	// Checkpoint 以 CRIU 将运行中的容器保存到 dir 并停止容器
+	Checkpoint(ctx context.Context, containerID, dir string) error
+
+	// Info 查询容器运行时的运行模式与负载
+	Info(ctx context.Context) (*DaemonInfo, error)
+
+	// ListImages 列出本地镜像
+	ListImages(ctx context.Context) ([]ImageInfo, error)
+
+	// RemoveImage 删除镜像引用，被容器使用的镜像不会被删除
+	RemoveImage(ctx context.Context, ref string) error
+
+	// ListContainers 列出带有全部指定标签的容器，包括已退出的容器
+	ListContainers(ctx context.Context, labels map[string]string) ([]ContainerInfo, error)
+
+	// Close 释放与容器运行时的连接
+	Close() error
 }
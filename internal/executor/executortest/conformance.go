@@ -0,0 +1,273 @@
+package executortest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// DefaultImage 一致性检查默认使用的镜像，需要提供 sh、sleep、head、tail 与 yes
+const DefaultImage = "busybox:1.36"
+
+// Case 一项一致性检查，在真实的容器运行时上验证 manager 依赖的执行器行为
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, exec executor.Executor, image string) error
+}
+
+// Cases 全部一致性检查
+var Cases = []Case{
+	{"exit-code", checkExitCode},
+	{"log-streams", checkLogStreams},
+	{"timeout", checkTimeout},
+	{"oom", checkOOM},
+	{"output-limit", checkOutputLimit},
+	{"output-mount", checkOutputMount},
+	{"stop", checkStop},
+	{"session", checkSession},
+	{"network", checkNetwork},
+}
+
+// Result 一项检查的结果
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// RunSuite 依次运行全部检查，每项检查结束后调用 report（可为 nil），返回全部结果。
+// 检查会创建并删除容器与网络，应在不承担评测的主机上运行
+func RunSuite(ctx context.Context, exec executor.Executor, image string, report func(Result)) ([]Result, error) {
+	if image == "" {
+		image = DefaultImage
+	}
+	if err := exec.EnsureImage(ctx, image); err != nil {
+		return nil, fmt.Errorf("failed to prepare image %s: %w", image, err)
+	}
+	var results []Result
+	for _, c := range Cases {
+		start := time.Now()
+		caseCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		err := c.Run(caseCtx, exec, image)
+		cancel()
+		r := Result{Name: c.Name, Err: err, Duration: time.Since(start)}
+		results = append(results, r)
+		if report != nil {
+			report(r)
+		}
+	}
+	return results, nil
+}
+
+func conformanceConfig(image string, command ...string) *executor.ExecuteConfig {
+	return &executor.ExecuteConfig{
+		Image:       image,
+		Command:     command,
+		Timeout:     30 * time.Second,
+		MemoryLimit: 128,
+		Labels:      map[string]string{executor.LabelRunnerID: "executortest"},
+	}
+}
+
+func checkExitCode(ctx context.Context, exec executor.Executor, image string) error {
+	result, err := exec.Execute(ctx, conformanceConfig(image, "sh", "-c", "exit 3"))
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 3 || result.TimedOut || result.OOM {
+		return fmt.Errorf("expected exit code 3, got %+v", result)
+	}
+	return nil
+}
+
+func checkLogStreams(ctx context.Context, exec executor.Executor, image string) error {
+	var mu sync.Mutex
+	var stdout, stderr []string
+	_, err := exec.ExecuteWithLogs(ctx, conformanceConfig(image, "sh", "-c", "echo out1; echo err1 >&2; echo out2"),
+		func(stream executor.LogStream, line string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if stream == executor.Stderr {
+				stderr = append(stderr, line)
+			} else {
+				stdout = append(stdout, line)
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(stdout, []string{"out1", "out2"}) || !slices.Equal(stderr, []string{"err1"}) {
+		return fmt.Errorf("unexpected log lines: stdout %q, stderr %q", stdout, stderr)
+	}
+	return nil
+}
+
+func checkTimeout(ctx context.Context, exec executor.Executor, image string) error {
+	config := conformanceConfig(image, "sleep", "60")
+	config.Timeout = 2 * time.Second
+	start := time.Now()
+	result, err := exec.Execute(ctx, config)
+	if err != nil {
+		return err
+	}
+	if !result.TimedOut {
+		return fmt.Errorf("expected timeout, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		return fmt.Errorf("container was stopped %s after the 2s timeout", elapsed)
+	}
+	return nil
+}
+
+func checkOOM(ctx context.Context, exec executor.Executor, image string) error {
+	// tail 缓存没有换行的输入直到内存耗尽
+	config := conformanceConfig(image, "sh", "-c", "head -c 512m /dev/zero | tail")
+	config.MemoryLimit = 32
+	result, err := exec.Execute(ctx, config)
+	if err != nil {
+		return err
+	}
+	if !result.OOM {
+		return fmt.Errorf("expected OOM, got %+v", result)
+	}
+	return nil
+}
+
+func checkOutputLimit(ctx context.Context, exec executor.Executor, image string) error {
+	config := conformanceConfig(image, "yes")
+	config.OutputLimit = 64 << 10
+	result, err := exec.ExecuteWithLogs(ctx, config, func(executor.LogStream, string) error { return nil })
+	if err != nil {
+		return err
+	}
+	if !result.OutputLimitExceeded {
+		return fmt.Errorf("expected output limit to be exceeded, got %+v", result)
+	}
+	return nil
+}
+
+func checkOutputMount(ctx context.Context, exec executor.Executor, image string) error {
+	dir, err := os.MkdirTemp("", "executortest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// 容器内的用户可能经过 userns 映射，允许任意用户写入
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return err
+	}
+	config := conformanceConfig(image, "sh", "-c", "echo report > /output/report.json")
+	config.Mounts = []executor.Mount{{Source: dir, Target: "/output"}}
+	result, err := exec.Execute(ctx, config)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("writing to /output failed: %+v", result)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "report.json"))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(data)) != "report" {
+		return fmt.Errorf("unexpected report content %q", data)
+	}
+	return nil
+}
+
+func checkStop(ctx context.Context, exec executor.Executor, image string) error {
+	config := conformanceConfig(image, "sleep", "60")
+	started := make(chan string, 1)
+	config.OnStart = func(id string) { started <- id }
+	done := make(chan error, 1)
+	var result *executor.ExecuteResult
+	go func() {
+		var err error
+		result, err = exec.Execute(ctx, config)
+		done <- err
+	}()
+
+	var id string
+	select {
+	case id = <-started:
+	case err := <-done:
+		return fmt.Errorf("container exited before OnStart: %v", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := exec.Stop(ctx, id); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-time.After(30 * time.Second):
+		return fmt.Errorf("execute did not return after the container was stopped")
+	}
+	if result.ExitCode == 0 || result.TimedOut {
+		return fmt.Errorf("expected a killed container, got %+v", result)
+	}
+	return nil
+}
+
+func checkSession(ctx context.Context, exec executor.Executor, image string) error {
+	session, err := exec.StartSession(ctx, conformanceConfig(image, "sleep", "infinity"))
+	if err != nil {
+		return err
+	}
+	defer session.Close(context.Background())
+
+	// 同一会话中的命令共享文件系统
+	first, err := session.Exec(ctx, &executor.ExecConfig{Command: []string{"sh", "-c", "echo state > /tmp/state"}}, nil)
+	if err != nil {
+		return err
+	}
+	if first.ExitCode != 0 {
+		return fmt.Errorf("first exec failed: %+v", first)
+	}
+	var lines []string
+	second, err := session.Exec(ctx, &executor.ExecConfig{Command: []string{"sh", "-c", "cat /tmp/state; exit 5"}},
+		func(stream executor.LogStream, line string) error {
+			lines = append(lines, line)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if second.ExitCode != 5 || !slices.Equal(lines, []string{"state"}) {
+		return fmt.Errorf("unexpected second exec: %+v, output %q", second, lines)
+	}
+	return nil
+}
+
+func checkNetwork(ctx context.Context, exec executor.Executor, image string) error {
+	id, err := exec.CreateNetwork(ctx, &executor.NetworkConfig{
+		Name:     fmt.Sprintf("executortest-%d", time.Now().UnixNano()),
+		Labels:   map[string]string{executor.LabelRunnerID: "executortest"},
+		Internal: true,
+	})
+	if err != nil {
+		return err
+	}
+	config := conformanceConfig(image, "true")
+	config.Network = id
+	result, err := exec.Execute(ctx, config)
+	removeErr := exec.RemoveNetwork(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("container on network failed: %+v", result)
+	}
+	return removeErr
+}
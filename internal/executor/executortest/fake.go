@@ -0,0 +1,503 @@
+// Package executortest 提供脚本化的执行器替身与针对真实容器运行时的一致性检查，
+// 前者用于在没有 Docker 的环境中测试 manager 的评测流程，后者用于在比赛前验证执行器后端。
+package executortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// Script 一次容器运行或会话内命令的脚本化结果
+type Script struct {
+	ExitCode int
+	Stdout   []string      // 依次回调的标准输出行
+	Stderr   []string      // 在标准输出之后回调的标准错误行
+	Duration time.Duration // 模拟的运行时间，期间可被 Stop 或 ctx 取消中断
+
+	TimedOut            bool
+	OOM                 bool
+	OOMKills            []executor.OOMKill // 非空时 OOM 同样为 true
+	OutputLimitExceeded bool
+	CPUTimeExceeded     bool // Usage.CPUTime 超过 CPUTimeLimit 时同样为 true
+	Usage               *executor.ResourceUsage
+
+	// Files 运行结束时写入的文件，键为容器内路径（如 /output/report.json），
+	// 按挂载关系写到对应的宿主机目录
+	Files map[string]string
+
+	Err error // 非 nil 时运行直接返回该错误
+}
+
+// Run 一次记录的容器运行
+type Run struct {
+	ContainerID string
+	Config      executor.ExecuteConfig
+	Exec        *executor.ExecConfig // 会话内执行的命令，容器运行时为 nil
+}
+
+// ErrUnscripted 没有剩余脚本且未设置默认脚本
+var ErrUnscripted = errors.New("executortest: no script for this run")
+
+// Fake 脚本化的执行器替身，按调用顺序消费脚本，记录全部运行、镜像与网络操作
+type Fake struct {
+	Default *Script             // 脚本耗尽后使用的结果，为 nil 时运行返回 ErrUnscripted
+	Daemon  executor.DaemonInfo // Info 返回的守护进程信息
+
+	mu         sync.Mutex
+	scripts    []Script
+	imageErrs  map[string]error
+	runs       []Run
+	images     []string
+	removed    map[string]bool // RemoveImage 删除且之后未再准备的镜像
+	networks   map[string]*executor.NetworkConfig
+	containers map[string]chan struct{} // 运行中的容器，关闭表示已停止
+	checkpoint map[string]string        // 容器 ID -> 检查点目录
+	nextID     int
+}
+
+// NewFake 创建执行器替身，scripts 按运行顺序消费
+func NewFake(scripts ...Script) *Fake {
+	return &Fake{
+		scripts:    scripts,
+		imageErrs:  make(map[string]error),
+		removed:    make(map[string]bool),
+		networks:   make(map[string]*executor.NetworkConfig),
+		containers: make(map[string]chan struct{}),
+		checkpoint: make(map[string]string),
+	}
+}
+
+var _ executor.Executor = (*Fake)(nil)
+
+// Push 追加脚本
+func (f *Fake) Push(scripts ...Script) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts = append(f.scripts, scripts...)
+}
+
+// FailImage 使该镜像的 EnsureImage 与 PullImage 返回 err
+func (f *Fake) FailImage(image string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.imageErrs[image] = err
+}
+
+// Runs 返回记录的全部运行
+func (f *Fake) Runs() []Run {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Run(nil), f.runs...)
+}
+
+// Images 返回按顺序准备过的镜像
+func (f *Fake) Images() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.images...)
+}
+
+// Networks 返回尚未删除的网络数
+func (f *Fake) Networks() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.networks)
+}
+
+// Running 返回尚未停止或清理的容器数，用于检查资源泄漏
+func (f *Fake) Running() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, stopped := range f.containers {
+		select {
+		case <-stopped:
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// next 取出下一个脚本并登记容器，调用方需持有锁
+func (f *Fake) next() (Script, string, chan struct{}, error) {
+	var script Script
+	switch {
+	case len(f.scripts) > 0:
+		script = f.scripts[0]
+		f.scripts = f.scripts[1:]
+	case f.Default != nil:
+		script = *f.Default
+	default:
+		return Script{}, "", nil, ErrUnscripted
+	}
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	stopped := make(chan struct{})
+	f.containers[id] = stopped
+	return script, id, stopped, nil
+}
+
+// stop 标记容器已停止，可重复调用
+func (f *Fake) stop(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stopped, ok := f.containers[id]
+	if !ok {
+		return false
+	}
+	select {
+	case <-stopped:
+	default:
+		close(stopped)
+	}
+	return true
+}
+
+// play 按脚本回调日志、写入文件并等待运行时间，返回是否被提前中断
+func play(ctx context.Context, script *Script, mounts []executor.Mount, stopped <-chan struct{}, timeout time.Duration, callback executor.LogCallback) (bool, error) {
+	if callback != nil {
+		for _, line := range script.Stdout {
+			if err := callback(executor.Stdout, line); err != nil {
+				return false, err
+			}
+		}
+		for _, line := range script.Stderr {
+			if err := callback(executor.Stderr, line); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := writeFiles(script.Files, mounts); err != nil {
+		return false, err
+	}
+
+	wait := script.Duration
+	if timeout > 0 && wait > timeout {
+		wait = timeout
+	}
+	if wait <= 0 {
+		return false, nil
+	}
+	select {
+	case <-time.After(wait):
+		return false, nil
+	case <-stopped:
+		return true, nil
+	case <-ctx.Done():
+		return true, nil
+	}
+}
+
+// writeFiles 将容器内路径映射到挂载的宿主机目录并写入
+func writeFiles(files map[string]string, mounts []executor.Mount) error {
+	for target, content := range files {
+		var host string
+		for _, m := range mounts {
+			if rel, ok := strings.CutPrefix(target, strings.TrimSuffix(m.Target, "/")+"/"); ok && !m.ReadOnly {
+				host = filepath.Join(m.Source, rel)
+				break
+			}
+		}
+		if host == "" {
+			return fmt.Errorf("executortest: %s is not under a writable mount", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(host), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(host, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Fake) Execute(ctx context.Context, config *executor.ExecuteConfig) (*executor.ExecuteResult, error) {
+	return f.ExecuteWithLogs(ctx, config, nil)
+}
+
+func (f *Fake) ExecuteWithLogs(ctx context.Context, config *executor.ExecuteConfig, callback executor.LogCallback) (*executor.ExecuteResult, error) {
+	f.mu.Lock()
+	script, id, stopped, err := f.next()
+	if err == nil {
+		f.runs = append(f.runs, Run{ContainerID: id, Config: *config})
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer f.stop(id)
+	if script.Err != nil {
+		return nil, script.Err
+	}
+	if config.OnStart != nil {
+		config.OnStart(id)
+	}
+
+	interrupted, err := play(ctx, &script, config.Mounts, stopped, config.Timeout, callback)
+	if err != nil {
+		return nil, err
+	}
+	result := &executor.ExecuteResult{
+		ExitCode:            script.ExitCode,
+		Stdout:              strings.Join(script.Stdout, "\n"),
+		Stderr:              strings.Join(script.Stderr, "\n"),
+		TimedOut:            script.TimedOut,
+		OOM:                 script.OOM || len(script.OOMKills) > 0,
+		OOMKills:            script.OOMKills,
+		OutputLimitExceeded: script.OutputLimitExceeded,
+		CPUTimeExceeded:     script.CPUTimeExceeded || cpuTimeExceeded(config.CPUTimeLimit, script.Usage),
+		Usage:               script.Usage,
+	}
+	if config.Timeout > 0 && script.Duration > config.Timeout {
+		result.TimedOut = true
+	}
+	if interrupted || result.TimedOut || result.CPUTimeExceeded {
+		// 与 Docker 一致，被停止的容器以 SIGKILL 退出
+		result.ExitCode = 137
+	}
+	return result, nil
+}
+
+func (f *Fake) EnsureImage(ctx context.Context, image string) error {
+	return f.PullImage(ctx, image)
+}
+
+func (f *Fake) PullImage(ctx context.Context, image string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.imageErrs[image]; err != nil {
+		return err
+	}
+	f.images = append(f.images, image)
+	delete(f.removed, image)
+	return nil
+}
+
+// StartDetached 登记一个持续运行的容器，不消费脚本
+func (f *Fake) StartDetached(ctx context.Context, config *executor.ExecuteConfig) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	f.containers[id] = make(chan struct{})
+	f.runs = append(f.runs, Run{ContainerID: id, Config: *config})
+	return id, nil
+}
+
+func (f *Fake) StartSession(ctx context.Context, config *executor.ExecuteConfig) (executor.Session, error) {
+	id, err := f.StartDetached(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeSession{f: f, id: id, config: *config}, nil
+}
+
+func (f *Fake) StreamLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+// FollowLogs 替身的后台容器不产生日志，ctx 结束或容器停止时返回
+func (f *Fake) FollowLogs(ctx context.Context, containerID string, callback executor.LogCallback) <-chan struct{} {
+	done := make(chan struct{})
+	f.mu.Lock()
+	stopped := f.containers[containerID]
+	f.mu.Unlock()
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+		}
+	}()
+	return done
+}
+
+func (f *Fake) CreateNetwork(ctx context.Context, config *executor.NetworkConfig) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("fake-net-%d", f.nextID)
+	copied := *config
+	f.networks[id] = &copied
+	return id, nil
+}
+
+func (f *Fake) RemoveNetwork(ctx context.Context, networkID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.networks[networkID]; !ok {
+		return fmt.Errorf("executortest: no such network %s", networkID)
+	}
+	delete(f.networks, networkID)
+	return nil
+}
+
+func (f *Fake) Stop(ctx context.Context, containerID string) error {
+	if !f.stop(containerID) {
+		return fmt.Errorf("executortest: no such container %s", containerID)
+	}
+	return nil
+}
+
+func (f *Fake) Cleanup(ctx context.Context, containerID string) error {
+	f.stop(containerID)
+	return nil
+}
+
+// Checkpoint 记录检查点目录并停止容器
+func (f *Fake) Checkpoint(ctx context.Context, containerID, dir string) error {
+	if !f.stop(containerID) {
+		return fmt.Errorf("executortest: no such container %s", containerID)
+	}
+	f.mu.Lock()
+	f.checkpoint[containerID] = dir
+	f.mu.Unlock()
+	return os.MkdirAll(dir, 0o755)
+}
+
+// Checkpoints 返回容器 ID 到检查点目录的映射
+func (f *Fake) Checkpoints() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := make(map[string]string, len(f.checkpoint))
+	for k, v := range f.checkpoint {
+		result[k] = v
+	}
+	return result
+}
+
+func (f *Fake) Info(ctx context.Context) (*executor.DaemonInfo, error) {
+	info := f.Daemon
+	return &info, nil
+}
+
+// ListImages 返回准备过且未被删除的镜像，每个镜像以自身为 ID 与标签
+func (f *Fake) ListImages(ctx context.Context) ([]executor.ImageInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var images []executor.ImageInfo
+	seen := make(map[string]bool)
+	for _, image := range f.images {
+		if seen[image] || f.removed[image] {
+			continue
+		}
+		seen[image] = true
+		images = append(images, executor.ImageInfo{ID: image, Tags: []string{image}})
+	}
+	return images, nil
+}
+
+func (f *Fake) RemoveImage(ctx context.Context, ref string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !slices.Contains(f.images, ref) || f.removed[ref] {
+		return fmt.Errorf("executortest: no such image %s", ref)
+	}
+	f.removed[ref] = true
+	return nil
+}
+
+// ListContainers 返回登记过的容器，已停止或清理的容器视为已退出
+func (f *Fake) ListContainers(ctx context.Context, labels map[string]string) ([]executor.ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var containers []executor.ContainerInfo
+	for _, run := range f.runs {
+		stopped, ok := f.containers[run.ContainerID]
+		if !ok || run.Exec != nil || !hasLabels(run.Config.Labels, labels) {
+			continue
+		}
+		info := executor.ContainerInfo{ID: run.ContainerID, Labels: run.Config.Labels, Running: true}
+		select {
+		case <-stopped:
+			info.Running = false
+		default:
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+func hasLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fake) Close() error {
+	return nil
+}
+
+// fakeSession 会话内的每条命令消费一个脚本
+type fakeSession struct {
+	f      *Fake
+	id     string
+	config executor.ExecuteConfig
+}
+
+func (s *fakeSession) ID() string {
+	return s.id
+}
+
+func (s *fakeSession) Exec(ctx context.Context, config *executor.ExecConfig, callback executor.LogCallback) (*executor.ExecResult, error) {
+	s.f.mu.Lock()
+	stopped := s.f.containers[s.id]
+	script, id, _, err := s.f.next()
+	if err == nil {
+		// 命令不是独立容器，不计入运行中的容器
+		delete(s.f.containers, id)
+		s.f.runs = append(s.f.runs, Run{ContainerID: s.id, Config: s.config, Exec: config})
+	}
+	s.f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if script.Err != nil {
+		return nil, script.Err
+	}
+
+	start := time.Now()
+	interrupted, err := play(ctx, &script, s.config.Mounts, stopped, config.Timeout, callback)
+	if err != nil {
+		return nil, err
+	}
+	result := &executor.ExecResult{
+		ExitCode:            script.ExitCode,
+		TimedOut:            script.TimedOut,
+		OutputLimitExceeded: script.OutputLimitExceeded,
+		CPUTimeExceeded:     script.CPUTimeExceeded || cpuTimeExceeded(s.config.CPUTimeLimit, script.Usage),
+		Duration:            time.Since(start),
+	}
+	if config.Timeout > 0 && script.Duration > config.Timeout {
+		result.TimedOut = true
+	}
+	if interrupted || result.TimedOut || result.CPUTimeExceeded {
+		result.ExitCode = 137
+		s.f.stop(s.id)
+	}
+	return result, nil
+}
+
+func (s *fakeSession) Close(ctx context.Context) *executor.ExecuteResult {
+	s.f.stop(s.id)
+	return &executor.ExecuteResult{}
+}
+
+// cpuTimeExceeded 脚本的资源使用是否超过 CPU 时间上限
+func cpuTimeExceeded(limit time.Duration, usage *executor.ResourceUsage) bool {
+	return limit > 0 && usage != nil && usage.CPUTime > limit
+}
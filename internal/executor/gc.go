@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+)
+
+// ImageInfo 本地镜像
+type ImageInfo struct {
+	ID   string
+	Tags []string // 镜像引用（repository:tag），可能为空
+	Size int64    // 占用空间（字节），未知时为 0
+}
+
+// ContainerInfo 容器概况
+type ContainerInfo struct {
+	ID      string
+	Labels  map[string]string
+	Running bool
+	Created time.Time
+}
+
+// ListImages 列出本地镜像
+func (e *DockerExecutor) ListImages(ctx context.Context) ([]ImageInfo, error) {
+	summaries, err := e.client.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	images := make([]ImageInfo, 0, len(summaries))
+	for _, s := range summaries {
+		images = append(images, ImageInfo{ID: s.ID, Tags: s.RepoTags, Size: s.Size})
+	}
+	return images, nil
+}
+
+// RemoveImage 删除镜像引用，最后一个引用删除后释放镜像层；被容器使用的镜像不会被删除
+func (e *DockerExecutor) RemoveImage(ctx context.Context, ref string) error {
+	if _, err := e.client.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+		return fmt.Errorf("failed to remove image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// ListContainers 列出带有全部指定标签的容器，包括已退出的容器
+func (e *DockerExecutor) ListContainers(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	list, err := e.client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	containers := make([]ContainerInfo, 0, len(list))
+	for _, c := range list {
+		containers = append(containers, ContainerInfo{
+			ID:      c.ID,
+			Labels:  c.Labels,
+			Running: c.State == "running",
+			Created: time.Unix(c.Created, 0),
+		})
+	}
+	return containers, nil
+}
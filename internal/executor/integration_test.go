@@ -0,0 +1,67 @@
+//go:build integration
+
+// 针对真实容器运行时的一致性检查，默认不运行：
+//
+//	go test -tags integration ./internal/executor/ ./internal/manager/
+//
+// 通过 DOCKER_HOST 指定 Docker 守护进程，设置 LFS_TEST_NERDCTL_ADDRESS 时同时检查 nerdctl 后端，
+// LFS_TEST_IMAGE 替换检查使用的镜像（默认 busybox）
+package executor_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+)
+
+func testImage() string {
+	if image := os.Getenv("LFS_TEST_IMAGE"); image != "" {
+		return image
+	}
+	return executortest.DefaultImage
+}
+
+// runConformance 以子测试的形式运行全部一致性检查
+func runConformance(t *testing.T, exec executor.Executor) {
+	image := testImage()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := exec.EnsureImage(ctx, image); err != nil {
+		t.Fatalf("failed to prepare image %s: %v", image, err)
+	}
+	for _, c := range executortest.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			caseCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			defer cancel()
+			if err := c.Run(caseCtx, exec, image); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDockerConformance(t *testing.T) {
+	exec, err := executor.NewDockerExecutor(os.Getenv("DOCKER_HOST"))
+	if err != nil {
+		t.Fatalf("failed to connect to docker: %v", err)
+	}
+	defer exec.Close()
+	runConformance(t, exec)
+}
+
+func TestNerdctlConformance(t *testing.T) {
+	address := os.Getenv("LFS_TEST_NERDCTL_ADDRESS")
+	if address == "" {
+		t.Skip("LFS_TEST_NERDCTL_ADDRESS is not set")
+	}
+	exec, err := executor.NewNerdctlExecutor(address, "lfs-auto-grader-test")
+	if err != nil {
+		t.Fatalf("failed to connect to containerd: %v", err)
+	}
+	defer exec.Close()
+	runConformance(t, exec)
+}
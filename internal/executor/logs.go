@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	maxLogLine      = 64 << 10 // 单行日志长度上限，超出部分拆分为多行
+	logQueueSize    = 4096     // 等待回调处理的日志行数上限
+	maxResultOutput = 1 << 20  // ExecuteResult 中保留的 stdout/stderr 大小上限
+)
+
+// errOutputLimit 容器输出超过上限
+var errOutputLimit = errors.New("output limit exceeded")
+
+type logLine struct {
+	stream LogStream
+	text   string
+}
+
+// logPump 读取容器的多路复用日志，拆分为行后交给回调处理。
+// 回调在单独的 goroutine 中顺序执行；回调过慢时 stderr 行会被丢弃并记录数量，
+// stdout 行（可能包含协议消息）不丢弃。容器日志由 Docker 的日志驱动缓存，读取变慢不会阻塞容器本身
+type logPump struct {
+	callback LogCallback
+	limit    int64
+	drain    bool // 超限后继续读取并丢弃其余输出，而不是中止读取
+	total    atomic.Int64
+	exceeded atomic.Bool
+
+	queue   chan logLine
+	dropped int
+}
+
+func newLogPump(callback LogCallback, limit int64, drain bool) *logPump {
+	return &logPump{
+		callback: callback,
+		limit:    limit,
+		drain:    drain,
+		queue:    make(chan logLine, logQueueSize),
+	}
+}
+
+// countingWriter 统计写入的字节数，超过上限时返回 errOutputLimit 以中止读取，
+// drain 时改为丢弃其余输出，使进程不因管道写满而阻塞
+type countingWriter struct {
+	p *logPump
+	w io.Writer
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	total := c.p.total.Add(int64(len(b)))
+	if c.p.limit > 0 && total > c.p.limit {
+		c.p.exceeded.Store(true)
+		if c.p.drain {
+			return len(b), nil
+		}
+		return 0, errOutputLimit
+	}
+	return c.w.Write(b)
+}
+
+// sanitizeLine 将无效 UTF-8 与 NUL 等控制字符替换为可显示的字符，避免二进制输出破坏日志与协议解析
+func sanitizeLine(b []byte) string {
+	line := strings.ToValidUTF8(string(b), "�")
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != 0x1b {
+			return '�'
+		}
+		return r
+	}, line)
+}
+
+// split 按行读取 r，超长的行按 maxLogLine 拆分
+func (p *logPump) split(stream LogStream, r io.Reader) {
+	reader := bufio.NewReaderSize(r, maxLogLine)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			p.enqueue(logLine{stream, sanitizeLine(bytes.TrimRight(chunk, "\r\n"))})
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return
+		}
+	}
+}
+
+func (p *logPump) enqueue(line logLine) {
+	if line.stream == Stdout {
+		p.queue <- line
+		return
+	}
+	select {
+	case p.queue <- line:
+	default:
+		p.dropped++
+	}
+}
+
+// dispatch 顺序调用回调，直到队列关闭；回调返回错误后继续消费但不再调用
+func (p *logPump) dispatch(done chan<- struct{}) {
+	defer close(done)
+	failed := false
+	for line := range p.queue {
+		if failed || p.callback == nil {
+			continue
+		}
+		if err := p.callback(line.stream, line.text); err != nil {
+			failed = true
+		}
+	}
+}
+
+// run 读取并分发 Docker 多路复用日志直到 reader 结束或输出超限，超限时立即调用 onExceeded
+func (p *logPump) run(reader io.Reader, onExceeded func()) {
+	p.pump(onExceeded, func(stdout, stderr io.Writer) {
+		stdcopy.StdCopy(stdout, stderr, reader)
+	})
+}
+
+// runStreams 分别读取 stdout 与 stderr 并分发，用于不使用多路复用日志的后端
+func (p *logPump) runStreams(stdout, stderr io.Reader, onExceeded func()) {
+	var once sync.Once
+	exceeded := func() { once.Do(onExceeded) }
+	p.pump(exceeded, func(stdoutW, stderrW io.Writer) {
+		var wg sync.WaitGroup
+		copyStream := func(w io.Writer, r io.Reader) {
+			defer wg.Done()
+			if _, err := io.Copy(w, r); errors.Is(err, errOutputLimit) {
+				// 立即终止容器，另一路输出随之结束
+				exceeded()
+			}
+			io.Copy(io.Discard, r)
+		}
+		wg.Add(2)
+		go copyStream(stdoutW, stdout)
+		go copyStream(stderrW, stderr)
+		wg.Wait()
+	})
+}
+
+// pump 由 copyOutput 将输出写入计数的 stdout/stderr，按行拆分后分发
+func (p *logPump) pump(onExceeded func(), copyOutput func(stdout, stderr io.Writer)) {
+	dispatched := make(chan struct{})
+	go p.dispatch(dispatched)
+
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.split(Stdout, stdoutR)
+		io.Copy(io.Discard, stdoutR)
+	}()
+	go func() {
+		defer wg.Done()
+		p.split(Stderr, stderrR)
+		io.Copy(io.Discard, stderrR)
+	}()
+
+	copyOutput(&countingWriter{p, stdoutW}, &countingWriter{p, stderrW})
+	if p.exceeded.Load() {
+		onExceeded()
+	}
+	stdoutW.Close()
+	stderrW.Close()
+	wg.Wait()
+
+	if p.dropped > 0 {
+		p.queue <- logLine{Stderr, fmt.Sprintf("[日志处理过慢，丢弃了 %d 行标准错误]", p.dropped)}
+	}
+	if p.exceeded.Load() && p.drain {
+		p.queue <- logLine{Stderr, fmt.Sprintf("[输出超过 %d 字节，其余输出已丢弃]", p.limit)}
+	} else if p.exceeded.Load() {
+		p.queue <- logLine{Stderr, fmt.Sprintf("[输出超过 %d 字节，容器已被终止]", p.limit)}
+	}
+	close(p.queue)
+	<-dispatched
+}
+
+// streamLogs 跟随容器日志并按行回调，输出超过 limit 时调用 onExceeded，drain 时丢弃其余输出直到容器结束。
+// 返回的 channel 在日志读取与回调全部完成后关闭
+func (e *DockerExecutor) streamLogs(ctx context.Context, containerID string, callback LogCallback, limit int64, drain bool, onExceeded func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader, err := e.StreamLogs(ctx, containerID)
+		if err != nil {
+			return
+		}
+		defer reader.Close()
+
+		newLogPump(callback, limit, drain).run(reader, onExceeded)
+	}()
+	return done
+}
+
+// FollowLogs 跟随容器日志直到容器退出或 ctx 取消，按行回调。
+// 返回的 channel 在日志读取与回调全部完成后关闭
+func (e *DockerExecutor) FollowLogs(ctx context.Context, containerID string, callback LogCallback) <-chan struct{} {
+	return e.streamLogs(ctx, containerID, callback, 0, false, func() {})
+}
+
+// limitedBuffer 只保留前 limit 字节的缓冲区，超出部分丢弃
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.buf.Write(p[:max(remaining, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	s := strings.ToValidUTF8(b.buf.String(), "�")
+	if b.truncated {
+		s += "\n[输出过长，已截断]"
+	}
+	return s
+}
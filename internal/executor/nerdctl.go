@@ -0,0 +1,625 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NerdctlExecutor 通过 nerdctl 直接使用 containerd 运行容器，不依赖 Docker 守护进程。
+// 评测容器位于单独的 containerd 命名空间中，资源限制由 nerdctl 写入容器的 cgroup。
+// 不支持网络别名、内部网络、SELinux 标签与检查点，也不采集资源使用情况
+type NerdctlExecutor struct {
+	bin       string
+	address   string
+	namespace string
+}
+
+// NewNerdctlExecutor 创建 nerdctl 执行器，address 为 containerd 套接字地址，为空时使用 nerdctl 的默认值
+func NewNerdctlExecutor(address, namespace string) (*NerdctlExecutor, error) {
+	bin, err := exec.LookPath("nerdctl")
+	if err != nil {
+		return nil, fmt.Errorf("nerdctl not found: %w", err)
+	}
+	return &NerdctlExecutor{bin: bin, address: address, namespace: namespace}, nil
+}
+
+// command 构造带全局参数的 nerdctl 命令
+func (e *NerdctlExecutor) command(ctx context.Context, args ...string) *exec.Cmd {
+	var global []string
+	if e.address != "" {
+		global = append(global, "--address", e.address)
+	}
+	if e.namespace != "" {
+		global = append(global, "--namespace", e.namespace)
+	}
+	return exec.CommandContext(ctx, e.bin, append(global, args...)...)
+}
+
+// run 执行 nerdctl 命令并返回去除首尾空白的标准输出
+func (e *NerdctlExecutor) run(ctx context.Context, args ...string) (string, error) {
+	cmd := e.command(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("nerdctl %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("nerdctl %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// createContainer 根据执行配置创建容器
+func (e *NerdctlExecutor) createContainer(ctx context.Context, config *ExecuteConfig) (string, error) {
+	if len(config.NetworkAliases) > 0 {
+		return "", fmt.Errorf("network aliases are not supported by the nerdctl backend")
+	}
+	if config.SELinuxLabel != "" {
+		return "", fmt.Errorf("SELinux labels are not supported by the nerdctl backend")
+	}
+	if config.RestoreFrom != "" {
+		return "", fmt.Errorf("checkpoint restore is not supported by the nerdctl backend")
+	}
+	if config.CPUTimeLimit > 0 {
+		return "", fmt.Errorf("cpu time limits are not supported by the nerdctl backend")
+	}
+	if config.HugePages > 0 {
+		return "", fmt.Errorf("huge pages are not supported by the nerdctl backend")
+	}
+
+	args := []string{"create"}
+	for k, v := range config.Labels {
+		args = append(args, "--label", k+"="+v)
+	}
+	for k, v := range config.Env {
+		args = append(args, "--env", k+"="+v)
+	}
+	if config.WorkDir != "" {
+		args = append(args, "--workdir", config.WorkDir)
+	}
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+	for _, m := range config.Mounts {
+		mount := "type=bind,source=" + m.Source + ",target=" + m.Target
+		if m.ReadOnly {
+			mount += ",readonly"
+		}
+		args = append(args, "--mount", mount)
+	}
+	for target, options := range config.Tmpfs {
+		if options != "" {
+			target += ":" + options
+		}
+		args = append(args, "--tmpfs", target)
+	}
+
+	// 设置资源限制
+	if config.MemoryLimit > 0 {
+		memory := strconv.FormatInt(config.MemoryLimit, 10) + "m"
+		args = append(args, "--memory", memory, "--memory-swap", memory) // 禁用 swap
+	}
+	if config.CPULimit > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(config.CPULimit, 'f', -1, 64))
+	}
+	if config.CpusetCpus != "" {
+		args = append(args, "--cpuset-cpus", config.CpusetCpus)
+	}
+	if config.CpusetMems != "" {
+		args = append(args, "--cpuset-mems", config.CpusetMems)
+	}
+	if len(config.GPUDevices) > 0 {
+		args = append(args, "--gpus", `"device=`+strings.Join(config.GPUDevices, ",")+`"`)
+	}
+	for _, d := range config.Devices {
+		args = append(args, "--device", d.Source+":"+d.Target+":"+d.Permissions)
+	}
+	if config.ShmSize > 0 {
+		args = append(args, "--shm-size", strconv.FormatInt(config.ShmSize, 10)+"m")
+	}
+	if config.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(config.PidsLimit, 10))
+	}
+	if config.ReadOnlyRootfs {
+		args = append(args, "--read-only")
+	}
+	for _, c := range config.CapDrop {
+		args = append(args, "--cap-drop", c)
+	}
+	for _, c := range config.CapAdd {
+		args = append(args, "--cap-add", c)
+	}
+	if config.CoreDumps {
+		args = append(args, "--ulimit", "core=-1")
+	}
+
+	// 设置安全选项，nerdctl 只接受 seccomp 配置文件路径，创建容器时读取
+	if config.SeccompProfile == "unconfined" {
+		args = append(args, "--security-opt", "seccomp=unconfined")
+	} else if config.SeccompProfile != "" {
+		f, err := os.CreateTemp("", "seccomp-*.json")
+		if err != nil {
+			return "", fmt.Errorf("failed to write seccomp profile: %w", err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(config.SeccompProfile)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to write seccomp profile: %w", err)
+		}
+		args = append(args, "--security-opt", "seccomp="+f.Name())
+	}
+	if config.AppArmorProfile != "" {
+		args = append(args, "--security-opt", "apparmor="+config.AppArmorProfile)
+	}
+	if config.NoNewPrivileges {
+		args = append(args, "--security-opt", "no-new-privileges")
+	}
+
+	if config.Runtime != "" {
+		args = append(args, "--runtime", config.Runtime)
+	}
+
+	if config.NetworkDisabled {
+		args = append(args, "--network", "none")
+	} else if config.Network != "" {
+		args = append(args, "--network", config.Network)
+	}
+
+	args = append(args, config.Image)
+	args = append(args, config.Command...)
+	id, err := e.run(ctx, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	return id, nil
+}
+
+// Execute 执行评测任务
+func (e *NerdctlExecutor) Execute(ctx context.Context, config *ExecuteConfig) (*ExecuteResult, error) {
+	return e.ExecuteWithLogs(ctx, config, nil)
+}
+
+// ExecuteWithLogs 执行评测任务并实时获取日志
+func (e *NerdctlExecutor) ExecuteWithLogs(ctx context.Context, config *ExecuteConfig, callback LogCallback) (*ExecuteResult, error) {
+	containerID, err := e.createContainer(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 确保清理容器
+	defer e.Cleanup(context.Background(), containerID)
+
+	if _, err := e.run(ctx, "start", containerID); err != nil {
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+	if config.OnStart != nil {
+		config.OnStart(containerID)
+	}
+
+	// 设置超时上下文
+	var execCtx context.Context
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		execCtx, cancel = context.WithTimeout(ctx, config.Timeout)
+	} else {
+		execCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// 获取日志，输出超限时终止容器，OutputDrain 时丢弃其余输出直到容器结束
+	var outputExceeded atomic.Bool
+	var logsDone <-chan struct{}
+	if callback != nil || config.OutputLimit > 0 {
+		logsDone = e.streamLogs(execCtx, containerID, callback, config.OutputLimit, config.OutputDrain, func() {
+			outputExceeded.Store(true)
+			if !config.OutputDrain {
+				e.Stop(context.Background(), containerID)
+			}
+		})
+	}
+
+	// 等待容器结束
+	result := &ExecuteResult{}
+	out, err := e.run(execCtx, "wait", containerID)
+	if err != nil {
+		if execCtx.Err() != context.DeadlineExceeded {
+			return nil, fmt.Errorf("error waiting for container: %w", err)
+		}
+		result.TimedOut = true
+		e.Stop(context.Background(), containerID)
+	} else if result.ExitCode, err = strconv.Atoi(out); err != nil {
+		return nil, fmt.Errorf("unexpected exit code %q", out)
+	}
+
+	// 等待剩余日志处理完毕，避免丢失容器最后输出的协议消息
+	if logsDone != nil {
+		select {
+		case <-logsDone:
+		case <-time.After(logDrainTimeout):
+		}
+	}
+	result.OutputLimitExceeded = outputExceeded.Load()
+	// 主进程的 OOMKilled 标记之外，从内核日志查找 cgroup 内被 OOM kill 的子进程
+	result.OOMKills = readKernelOOMKills(containerID)
+	result.OOM = e.oomKilled(ctx, containerID) || len(result.OOMKills) > 0
+
+	// 获取输出
+	stdout := &limitedBuffer{limit: maxResultOutput}
+	stderr := &limitedBuffer{limit: maxResultOutput}
+	cmd := e.command(ctx, "logs", containerID)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if cmd.Run() == nil {
+		result.Stdout = stdout.String()
+		result.Stderr = stderr.String()
+	}
+	return result, nil
+}
+
+// oomKilled 查询容器是否因内存超限被终止
+func (e *NerdctlExecutor) oomKilled(ctx context.Context, containerID string) bool {
+	out, err := e.run(ctx, "inspect", "--mode", "dockercompat", "--format", "{{json .State}}", containerID)
+	if err != nil {
+		return false
+	}
+	var state struct {
+		OOMKilled bool
+	}
+	return json.Unmarshal([]byte(out), &state) == nil && state.OOMKilled
+}
+
+// streamLogs 跟随容器日志并按行回调，输出超过 limit 时调用 onExceeded，drain 时丢弃其余输出直到容器结束。
+// 返回的 channel 在日志读取与回调全部完成后关闭
+func (e *NerdctlExecutor) streamLogs(ctx context.Context, containerID string, callback LogCallback, limit int64, drain bool, onExceeded func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cmd := e.command(ctx, "logs", "--follow", containerID)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			return
+		}
+		newLogPump(callback, limit, drain).runStreams(stdout, stderr, onExceeded)
+		cmd.Wait()
+	}()
+	return done
+}
+
+// FollowLogs 跟随容器日志直到容器退出或 ctx 取消，按行回调。
+// 返回的 channel 在日志读取与回调全部完成后关闭
+func (e *NerdctlExecutor) FollowLogs(ctx context.Context, containerID string, callback LogCallback) <-chan struct{} {
+	return e.streamLogs(ctx, containerID, callback, 0, false, func() {})
+}
+
+// StreamLogs 流式获取容器日志，stdout 与 stderr 合并为同一个流
+func (e *NerdctlExecutor) StreamLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	cmd := e.command(ctx, "logs", "--follow", containerID)
+	r, w := io.Pipe()
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		w.CloseWithError(cmd.Wait())
+	}()
+	return r, nil
+}
+
+// StartDetached 创建并启动容器后立即返回容器 ID，不等待其结束，调用方负责 Cleanup
+func (e *NerdctlExecutor) StartDetached(ctx context.Context, config *ExecuteConfig) (string, error) {
+	containerID, err := e.createContainer(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	if _, err := e.run(ctx, "start", containerID); err != nil {
+		e.Cleanup(context.Background(), containerID)
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	return containerID, nil
+}
+
+// StartSession 创建并启动容器，config.Command 应使容器保持运行（如 sleep infinity）。
+// 调用方必须调用 Close 释放容器
+func (e *NerdctlExecutor) StartSession(ctx context.Context, config *ExecuteConfig) (Session, error) {
+	containerID, err := e.StartDetached(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &nerdctlSession{e: e, containerID: containerID}, nil
+}
+
+// EnsureImage 确保镜像存在于本地，不存在时拉取
+func (e *NerdctlExecutor) EnsureImage(ctx context.Context, ref string) error {
+	if _, err := e.run(ctx, "image", "inspect", ref); err == nil {
+		return nil
+	}
+	return e.PullImage(ctx, ref)
+}
+
+// PullImage 拉取镜像
+func (e *NerdctlExecutor) PullImage(ctx context.Context, ref string) error {
+	if _, err := e.run(ctx, "pull", "--quiet", ref); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// CreateNetwork 创建 bridge 网络，配置了 IPv6 子网时启用双栈
+func (e *NerdctlExecutor) CreateNetwork(ctx context.Context, config *NetworkConfig) (string, error) {
+	if config.Internal {
+		return "", fmt.Errorf("internal networks are not supported by the nerdctl backend")
+	}
+	args := []string{"network", "create", "--driver", "bridge"}
+	for k, v := range config.Labels {
+		args = append(args, "--label", k+"="+v)
+	}
+	if config.IPv6Subnet != "" {
+		args = append(args, "--ipv6", "--subnet", config.IPv6Subnet)
+	}
+	args = append(args, config.Name)
+	if _, err := e.run(ctx, args...); err != nil {
+		return "", fmt.Errorf("failed to create network: %w", err)
+	}
+	// CNI 网络以名称引用
+	return config.Name, nil
+}
+
+// RemoveNetwork 删除网络
+func (e *NerdctlExecutor) RemoveNetwork(ctx context.Context, networkID string) error {
+	_, err := e.run(ctx, "network", "rm", networkID)
+	return err
+}
+
+// Stop 停止容器
+func (e *NerdctlExecutor) Stop(ctx context.Context, containerID string) error {
+	_, err := e.run(ctx, "stop", "--time", "5", containerID)
+	return err
+}
+
+// Cleanup 清理容器
+func (e *NerdctlExecutor) Cleanup(ctx context.Context, containerID string) error {
+	_, err := e.run(ctx, "rm", "--force", "--volumes", containerID)
+	return err
+}
+
+// Checkpoint nerdctl 后端不支持检查点
+func (e *NerdctlExecutor) Checkpoint(ctx context.Context, containerID, dir string) error {
+	return fmt.Errorf("checkpoint is not supported by the nerdctl backend")
+}
+
+// Info 查询 containerd 的运行模式与负载
+func (e *NerdctlExecutor) Info(ctx context.Context) (*DaemonInfo, error) {
+	out, err := e.run(ctx, "info", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get containerd info: %w", err)
+	}
+	var info struct {
+		SecurityOptions   []string
+		ContainersRunning int
+		NCPU              int
+		MemTotal          int64
+	}
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		return nil, fmt.Errorf("failed to parse containerd info: %w", err)
+	}
+	result := &DaemonInfo{
+		ContainersRunning: info.ContainersRunning,
+		NCPU:              info.NCPU,
+		MemTotal:          info.MemTotal,
+	}
+	for _, opt := range info.SecurityOptions {
+		switch {
+		case strings.Contains(opt, "name=rootless"):
+			result.Rootless = true
+		case strings.Contains(opt, "name=userns"):
+			result.UsernsRemap = true
+		}
+	}
+	return result, nil
+}
+
+// ListImages 列出命名空间中的镜像，nerdctl 输出的大小为可读格式，不解析
+func (e *NerdctlExecutor) ListImages(ctx context.Context) ([]ImageInfo, error) {
+	out, err := e.run(ctx, "image", "ls", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	var images []ImageInfo
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			ID         string
+			Repository string
+			Tag        string
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse image list: %w", err)
+		}
+		info := ImageInfo{ID: entry.ID}
+		if entry.Repository != "" && entry.Repository != "<none>" {
+			info.Tags = []string{entry.Repository + ":" + entry.Tag}
+		}
+		images = append(images, info)
+	}
+	return images, nil
+}
+
+// RemoveImage 删除镜像引用
+func (e *NerdctlExecutor) RemoveImage(ctx context.Context, ref string) error {
+	if _, err := e.run(ctx, "image", "rm", ref); err != nil {
+		return fmt.Errorf("failed to remove image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// ListContainers 列出带有全部指定标签的容器，包括已退出的容器
+func (e *NerdctlExecutor) ListContainers(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	args := []string{"ps", "--all", "--format", "{{json .}}"}
+	for k, v := range labels {
+		args = append(args, "--filter", "label="+k+"="+v)
+	}
+	out, err := e.run(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	var containers []ContainerInfo
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			ID        string
+			Status    string
+			CreatedAt string
+			Labels    string // 逗号分隔的 key=value
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse container list: %w", err)
+		}
+		info := ContainerInfo{
+			ID:      entry.ID,
+			Labels:  make(map[string]string),
+			Running: strings.HasPrefix(entry.Status, "Up"),
+		}
+		info.Created, _ = time.Parse("2006-01-02 15:04:05 -0700 MST", entry.CreatedAt)
+		for _, kv := range strings.Split(entry.Labels, ",") {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				info.Labels[k] = v
+			}
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+// Close nerdctl 不保持连接
+func (e *NerdctlExecutor) Close() error {
+	return nil
+}
+
+// nerdctlSession 基于 nerdctl exec 的会话
+type nerdctlSession struct {
+	e           *NerdctlExecutor
+	containerID string
+	stopped     atomic.Bool
+}
+
+// ID 返回容器 ID
+func (s *nerdctlSession) ID() string {
+	return s.containerID
+}
+
+// stop 停止容器，此后的 Exec 均失败
+func (s *nerdctlSession) stop() {
+	if s.stopped.CompareAndSwap(false, true) {
+		s.e.Stop(context.Background(), s.containerID)
+	}
+}
+
+// Exec 在容器内执行命令并按行回调其输出，返回该命令的退出码
+func (s *nerdctlSession) Exec(ctx context.Context, config *ExecConfig, callback LogCallback) (*ExecResult, error) {
+	if s.stopped.Load() {
+		return nil, fmt.Errorf("container %s has been stopped", s.containerID)
+	}
+	args := []string{"exec"}
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+	if config.WorkDir != "" {
+		args = append(args, "--workdir", config.WorkDir)
+	}
+	for k, v := range config.Env {
+		args = append(args, "--env", k+"="+v)
+	}
+	args = append(args, s.containerID)
+	args = append(args, config.Command...)
+
+	// 与 Docker 后端一致，超时与输出超限时停止整个容器
+	cmd := s.e.command(context.Background(), args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start exec: %w", err)
+	}
+
+	result := &ExecResult{}
+	start := time.Now()
+	var outputExceeded atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newLogPump(callback, config.OutputLimit, config.OutputDrain).runStreams(stdout, stderr, func() {
+			outputExceeded.Store(true)
+			if !config.OutputDrain {
+				s.stop()
+			}
+		})
+	}()
+
+	var timeout <-chan time.Time
+	if config.Timeout > 0 {
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		result.TimedOut = true
+		s.stop()
+		<-done
+	case <-ctx.Done():
+		s.stop()
+		<-done
+		cmd.Wait()
+		return nil, ctx.Err()
+	}
+	result.Duration = time.Since(start)
+	result.OutputLimitExceeded = outputExceeded.Load()
+
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to wait for exec: %w", err)
+	}
+	return result, nil
+}
+
+// Close 停止并删除容器，返回是否发生 OOM；nerdctl 后端不采集资源使用情况
+func (s *nerdctlSession) Close(ctx context.Context) *ExecuteResult {
+	result := &ExecuteResult{
+		OOM:      s.e.oomKilled(ctx, s.containerID),
+		OOMKills: readKernelOOMKills(s.containerID),
+	}
+	result.OOM = result.OOM || len(result.OOMKills) > 0
+	s.stop()
+	s.e.Cleanup(context.Background(), s.containerID)
+	return result
+}
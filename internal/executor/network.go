@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// NetworkConfig 单次评测使用的容器网络配置
+type NetworkConfig struct {
+	Name       string            // 网络名
+	Labels     map[string]string // 网络标签
+	IPv6Subnet string            // IPv6 子网（如 fd00:1a6e:0:1::/64），为空时仅 IPv4
+	Internal   bool              // 是否为内部网络（无外网访问）
+}
+
+// CreateNetwork 创建 bridge 网络，配置了 IPv6 子网时启用双栈
+func (e *DockerExecutor) CreateNetwork(ctx context.Context, config *NetworkConfig) (string, error) {
+	options := network.CreateOptions{
+		Driver:   "bridge",
+		Labels:   config.Labels,
+		Internal: config.Internal,
+	}
+	if config.IPv6Subnet != "" {
+		enable := true
+		options.EnableIPv6 = &enable
+		options.IPAM = &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: config.IPv6Subnet}},
+		}
+	}
+	resp, err := e.client.NetworkCreate(ctx, config.Name, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to create network: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// RemoveNetwork 删除网络
+func (e *DockerExecutor) RemoveNetwork(ctx context.Context, networkID string) error {
+	return e.client.NetworkRemove(ctx, networkID)
+}
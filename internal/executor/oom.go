@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+const (
+	oomEventDrainTimeout = 2 * time.Second // 容器结束后等待 die 事件（其前的 oom 事件均已送达）的最长时间
+	kmsgPath             = "/dev/kmsg"
+)
+
+// OOMKill 一次 OOM kill。进程信息来自宿主机内核日志，守护进程位于远程主机或内核日志不可读时为空
+type OOMKill struct {
+	PID     int    `json:"pid,omitempty"`     // 宿主机上的 PID
+	Process string `json:"process,omitempty"` // 进程名
+}
+
+// oomWatcher 订阅容器的 oom 事件。容器 cgroup 内任一进程（包括 mpirun、pytest 启动的子进程）
+// 被 OOM kill 时守护进程都会发送该事件，而 State.OOMKilled 只反映主进程是否因 OOM 退出
+type oomWatcher struct {
+	kills  atomic.Int32
+	died   chan struct{}
+	cancel context.CancelFunc
+}
+
+// watchOOM 开始订阅容器的 oom 与 die 事件，调用方在容器结束后调用 wait
+func (e *DockerExecutor) watchOOM(containerID string) *oomWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &oomWatcher{died: make(chan struct{}), cancel: cancel}
+	// 订阅在后台建立，从当前时间起回放，避免遗漏建立连接前的事件
+	messages, errs := e.client.Events(ctx, events.ListOptions{
+		Since: strconv.FormatInt(time.Now().Unix(), 10),
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("container", containerID),
+			filters.Arg("event", string(events.ActionOOM)),
+			filters.Arg("event", string(events.ActionDie)),
+		),
+	})
+	go func() {
+		var once sync.Once
+		for {
+			select {
+			case msg := <-messages:
+				switch msg.Action {
+				case events.ActionOOM:
+					w.kills.Add(1)
+				case events.ActionDie:
+					once.Do(func() { close(w.died) })
+				}
+			case <-errs:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// wait 等待容器的 die 事件后停止订阅，返回 oom 事件数
+func (w *oomWatcher) wait() int {
+	select {
+	case <-w.died:
+	case <-time.After(oomEventDrainTimeout):
+	}
+	w.cancel()
+	return int(w.kills.Load())
+}
+
+// oomKills 汇总容器的 OOM kill：本机守护进程从内核日志读取被终止的进程，否则按事件数返回空记录
+func oomKills(containerID string, events int, local bool) []OOMKill {
+	var kills []OOMKill
+	if local {
+		kills = readKernelOOMKills(containerID)
+	}
+	for len(kills) < events {
+		kills = append(kills, OOMKill{})
+	}
+	return kills
+}
+
+// readKernelOOMKills 从内核日志中查找 cgroup 路径包含容器 ID 的 oom-kill 记录，如
+// "oom-kill:constraint=CONSTRAINT_MEMCG,...,task_memcg=/system.slice/docker-<id>.scope,task=python3,pid=1234,uid=1000"。
+// 无权限读取 /dev/kmsg 时返回 nil
+func readKernelOOMKills(containerID string) []OOMKill {
+	// 以非阻塞方式直接读取：os.File 会将字符设备交给 netpoll，读到末尾时阻塞
+	fd, err := syscall.Open(kmsgPath, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil
+	}
+	defer syscall.Close(fd)
+
+	var kills []OOMKill
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if errors.Is(err, syscall.EPIPE) {
+			// 读取期间环形缓冲区中的记录被覆盖，继续读取后续记录
+			continue
+		}
+		if err != nil || n <= 0 {
+			return kills
+		}
+		// 每次读取返回一条记录："<优先级>,<序号>,<时间戳>,<标志>;<消息>"
+		_, msg, ok := strings.Cut(string(buf[:n]), ";")
+		if !ok || !strings.HasPrefix(msg, "oom-kill:") || !strings.Contains(msg, containerID) {
+			continue
+		}
+		kill := OOMKill{}
+		for _, field := range strings.Split(strings.TrimSpace(msg), ",") {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "task":
+				kill.Process = value
+			case "pid":
+				kill.PID, _ = strconv.Atoi(value)
+			}
+		}
+		kills = append(kills, kill)
+	}
+}
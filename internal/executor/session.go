@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ExecConfig 在运行中的容器内执行的命令
+type ExecConfig struct {
+	Command     []string          // 执行命令
+	Env         map[string]string // 额外的环境变量
+	WorkDir     string            // 工作目录，为空时使用容器的工作目录
+	User        string            // 运行用户，为空时使用容器的用户
+	Timeout     time.Duration     // 超时时间，0 为不限制；超时后整个容器被停止
+	OutputLimit int64             // 本条命令的输出上限（字节），超出后整个容器被停止
+	OutputDrain bool              // 输出超限后不停止容器，丢弃其余输出直到命令结束
+}
+
+// ExecResult 单条命令的执行结果
+type ExecResult struct {
+	ExitCode            int
+	TimedOut            bool
+	OutputLimitExceeded bool
+	CPUTimeExceeded     bool // 容器的累计 CPU 时间超限，整个容器被停止
+	Duration            time.Duration
+}
+
+// Session 持续运行的评测容器，可依次执行多条命令（如编译、运行、收集结果），
+// 各命令的环境与文件系统状态保持不变，无需重启容器
+type Session interface {
+	// ID 返回容器 ID
+	ID() string
+
+	// Exec 在容器内执行命令并按行回调其输出，返回该命令的退出码
+	Exec(ctx context.Context, config *ExecConfig, callback LogCallback) (*ExecResult, error)
+
+	// Close 停止并删除容器，返回整个会话的资源使用情况与是否发生 OOM
+	Close(ctx context.Context) *ExecuteResult
+}
+
+// dockerSession 基于 Docker exec 的会话
+type dockerSession struct {
+	e           *DockerExecutor
+	containerID string
+	stopStats   context.CancelFunc
+	sampler     *statsSampler
+	oom         *oomWatcher
+	stopped     atomic.Bool
+	cpuExceeded atomic.Bool
+}
+
+// StartSession 创建并启动容器，config.Command 应使容器保持运行（如 sleep infinity）。
+// 调用方必须调用 Close 释放容器
+func (e *DockerExecutor) StartSession(ctx context.Context, config *ExecuteConfig) (Session, error) {
+	containerID, err := e.StartDetached(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	statsCtx, stopStats := context.WithCancel(context.Background())
+	s := &dockerSession{
+		e:           e,
+		containerID: containerID,
+		stopStats:   stopStats,
+		oom:         e.watchOOM(containerID),
+	}
+	// 累计 CPU 时间按整个容器统计，超限时停止容器
+	s.sampler = e.sampleStats(statsCtx, containerID, config.CPUTimeLimit, func() {
+		s.cpuExceeded.Store(true)
+		s.stop()
+	})
+	return s, nil
+}
+
+// ID 返回容器 ID
+func (s *dockerSession) ID() string {
+	return s.containerID
+}
+
+// stop 停止容器，此后的 Exec 均失败
+func (s *dockerSession) stop() {
+	if s.stopped.CompareAndSwap(false, true) {
+		s.e.Stop(context.Background(), s.containerID)
+	}
+}
+
+// Exec 在容器内执行命令并按行回调其输出，返回该命令的退出码
+func (s *dockerSession) Exec(ctx context.Context, config *ExecConfig, callback LogCallback) (*ExecResult, error) {
+	if s.stopped.Load() {
+		return nil, fmt.Errorf("container %s has been stopped", s.containerID)
+	}
+	created, err := s.e.client.ContainerExecCreate(ctx, s.containerID, container.ExecOptions{
+		User:         config.User,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          s.e.buildEnvList(config.Env),
+		WorkingDir:   config.WorkDir,
+		Cmd:          config.Command,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+	attach, err := s.e.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer attach.Close()
+
+	result := &ExecResult{}
+	start := time.Now()
+	var outputExceeded atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newLogPump(callback, config.OutputLimit, config.OutputDrain).run(attach.Reader, func() {
+			outputExceeded.Store(true)
+			if !config.OutputDrain {
+				s.stop()
+			}
+		})
+	}()
+
+	var timeout <-chan time.Time
+	if config.Timeout > 0 {
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		// Docker 无法单独终止 exec 进程，只能停止整个容器
+		result.TimedOut = true
+		s.stop()
+		<-done
+	case <-ctx.Done():
+		s.stop()
+		<-done
+		return nil, ctx.Err()
+	}
+	result.Duration = time.Since(start)
+	result.OutputLimitExceeded = outputExceeded.Load()
+	result.CPUTimeExceeded = s.cpuExceeded.Load()
+
+	// 输出流结束后进程可能尚未被标记为退出，短暂轮询
+	for i := 0; i < 50; i++ {
+		inspect, err := s.e.client.ContainerExecInspect(ctx, created.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect exec: %w", err)
+		}
+		if !inspect.Running {
+			result.ExitCode = inspect.ExitCode
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return result, nil
+}
+
+// Close 停止并删除容器，返回整个会话的资源使用情况与是否发生 OOM
+func (s *dockerSession) Close(ctx context.Context) *ExecuteResult {
+	result := &ExecuteResult{}
+	if inspect, err := s.e.client.ContainerInspect(ctx, s.containerID); err == nil && inspect.State != nil {
+		result.OOM = inspect.State.OOMKilled
+	}
+	s.stop()
+	s.stopStats()
+	result.Usage = s.sampler.result()
+	result.CPUTimeExceeded = s.cpuExceeded.Load()
+	result.OOMKills = oomKills(s.containerID, s.oom.wait(), s.e.local)
+	result.OOM = result.OOM || len(result.OOMKills) > 0
+	s.e.Cleanup(context.Background(), s.containerID)
+	return result
+}
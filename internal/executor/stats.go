@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ResourceUsage 容器资源使用情况
+type ResourceUsage struct {
+	PeakMemory int64         `json:"peakMemory"` // 峰值内存（字节，不含可回收的文件缓存）
+	CPUTime    time.Duration `json:"cpuTime"`    // 累计 CPU 时间
+	IORead     int64         `json:"ioRead"`     // 块设备读取字节数
+	IOWrite    int64         `json:"ioWrite"`    // 块设备写入字节数
+}
+
+// statsSampler 在容器运行期间持续读取 Docker stats 流并记录峰值
+type statsSampler struct {
+	mu    sync.Mutex
+	usage ResourceUsage
+	done  chan struct{}
+
+	cpuLimit      time.Duration // 累计 CPU 时间上限，0 为不限制
+	onCPUExceeded func()        // 首次超过 cpuLimit 时调用
+	cpuExceeded   bool
+}
+
+// sampleStats 启动采样，ctx 取消或容器退出时结束。cpuLimit 非 0 时累计 CPU 时间超限后调用 onCPUExceeded，
+// 精度受 stats 的采样间隔（约 1 秒）限制
+func (e *DockerExecutor) sampleStats(ctx context.Context, containerID string, cpuLimit time.Duration, onCPUExceeded func()) *statsSampler {
+	s := &statsSampler{done: make(chan struct{}), cpuLimit: cpuLimit, onCPUExceeded: onCPUExceeded}
+	go func() {
+		defer close(s.done)
+		resp, err := e.client.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var stats container.StatsResponse
+			if err := dec.Decode(&stats); err != nil {
+				return
+			}
+			if s.observe(&stats) {
+				s.onCPUExceeded()
+			}
+		}
+	}()
+	return s
+}
+
+// observe 记录一次采样，首次超过 CPU 时间上限时返回 true
+func (s *statsSampler) observe(stats *container.StatsResponse) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mem := stats.MemoryStats
+	usage := int64(mem.Usage)
+	// 与 docker stats 一致，扣除可回收的文件缓存（cgroup v2 / v1）
+	if inactive, ok := mem.Stats["inactive_file"]; ok && int64(inactive) < usage {
+		usage -= int64(inactive)
+	} else if inactive, ok := mem.Stats["total_inactive_file"]; ok && int64(inactive) < usage {
+		usage -= int64(inactive)
+	}
+	s.usage.PeakMemory = max(s.usage.PeakMemory, usage, int64(mem.MaxUsage))
+
+	if cpu := time.Duration(stats.CPUStats.CPUUsage.TotalUsage); cpu > s.usage.CPUTime {
+		s.usage.CPUTime = cpu
+	}
+
+	var read, write int64
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += int64(entry.Value)
+		case "write":
+			write += int64(entry.Value)
+		}
+	}
+	s.usage.IORead = max(s.usage.IORead, read)
+	s.usage.IOWrite = max(s.usage.IOWrite, write)
+
+	if s.cpuLimit > 0 && !s.cpuExceeded && s.usage.CPUTime > s.cpuLimit {
+		s.cpuExceeded = true
+		return true
+	}
+	return false
+}
+
+// result 等待采样结束并返回结果
+func (s *statsSampler) result() *ResourceUsage {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := s.usage
+	return &usage
+}
@@ -0,0 +1,116 @@
+// Package logging 配置 runner 日志的级别与输出格式
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatText = "text" // 与标准库 log 相同的单行文本
+	FormatJSON = "json" // 每行一个 JSON 对象，附带 level、runner、solution 等字段，便于 Loki/ELK 查询
+)
+
+// Setup 将标准库 log 与 slog 的输出交给按 level 过滤的 handler 并设为默认。
+// 标准库 log 的输出按 Info 级别记录
+func Setup(w io.Writer, level, format, runnerID string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: must be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "", FormatText:
+		prefix := ""
+		if runnerID != "" {
+			// 以 runner ID 作为前缀，便于区分同一主机上的多个实例
+			prefix = "[" + runnerID + "] "
+		}
+		h = &textHandler{w: w, mu: &sync.Mutex{}, level: lvl, prefix: prefix}
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+		if runnerID != "" {
+			h = h.WithAttrs([]slog.Attr{slog.String("runner", runnerID)})
+		}
+	default:
+		return fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+	slog.SetDefault(slog.New(&solutionHandler{h}))
+	return nil
+}
+
+// textHandler 保持原有的日志格式：时间、runner 前缀与消息，非 Info 级别附带级别标记。
+// 字段只用于 JSON 格式，消息本身应包含需要的信息
+type textHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	level  slog.Level
+	prefix string
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	b.WriteString(t.Format("2006/01/02 15:04:05 "))
+	b.WriteString(h.prefix)
+	if r.Level != slog.LevelInfo {
+		b.WriteString(r.Level.String() + " ")
+	}
+	b.WriteString(strings.TrimSuffix(r.Message, "\n"))
+	b.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textHandler) WithGroup(string) slog.Handler      { return h }
+
+// solutionPattern 匹配 "Solution <id>"、"solution <id>" 形式的日志
+var solutionPattern = regexp.MustCompile(`\b[Ss]olution ([0-9A-Za-z_-]{6,})`)
+
+// solutionHandler 为未带 solution 字段、但消息中提到评测的日志补充该字段，
+// 使标准库 log 输出的自由文本也可以按评测查询
+type solutionHandler struct {
+	slog.Handler
+}
+
+func (h *solutionHandler) Handle(ctx context.Context, r slog.Record) error {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == "solution"
+		return !found
+	})
+	if !found {
+		if m := solutionPattern.FindStringSubmatch(r.Message); m != nil {
+			r = r.Clone()
+			r.AddAttrs(slog.String("solution", m[1]))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *solutionHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &solutionHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *solutionHandler) WithGroup(name string) slog.Handler {
+	return &solutionHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bearerAuth 校验 Authorization: Bearer <token>
+func bearerAuth(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jobStatus 管理接口返回的评测状态
+type jobStatus struct {
+	SolutionID  string       `json:"solutionId"`
+	TaskID      string       `json:"taskId"`
+	UserID      string       `json:"userId"`
+	Problem     string       `json:"problem"`
+	State       State        `json:"state"`
+	History     []Transition `json:"history"`
+	ContainerID string       `json:"containerId,omitempty"`
+	Image       string       `json:"image,omitempty"`
+	ReceivedAt  time.Time    `json:"receivedAt"`
+	Cancelled   bool         `json:"cancelled"`
+
+	ExpectedDuration time.Duration `json:"expectedDuration,omitempty"` // 按历史 p95 估计的运行时间
+}
+
+// serveAdmin 提供本机管理接口直到 ctx 结束：
+//
+//	GET  /                 运维面板（页面本身不含数据，令牌在浏览器中输入）
+//	GET  /jobs             运行中的评测
+//	GET  /stats            最近的评测结果、错误率、排队延迟与各题统计
+//	GET  /jobs/{id}/logs   评测的本地日志（?phase=pre 等读取阶段日志），支持 Range
+//	POST /jobs/{id}/kill   终止评测，以 Cancelled 上报
+//	POST /drain            排空 runner
+//	POST /dry-run          解析请求体中的 judge config，返回评测容器的执行配置
+func (m *Manager) serveAdmin(ctx context.Context) {
+	if m.conf.AdminToken == nil || *m.conf.AdminToken == "" {
+		log.Println("Admin API is disabled: admin-token is required")
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", m.handleListJobs)
+	mux.HandleFunc("GET /jobs/{id}/logs", m.handleJobLogs)
+	mux.HandleFunc("POST /jobs/{id}/kill", m.handleKillJob)
+	mux.HandleFunc("POST /drain", m.handleDrain)
+	mux.HandleFunc("GET /stats", m.handleStats)
+	mux.HandleFunc("POST /dry-run", m.handleDryRun)
+	root := http.NewServeMux()
+	root.HandleFunc("GET /{$}", serveDashboard)
+	root.Handle("/", bearerAuth(*m.conf.AdminToken, mux))
+	srv := &http.Server{
+		Addr:              *m.conf.AdminListen,
+		Handler:           root,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := context.AfterFunc(ctx, func() { srv.Close() })
+	defer stop()
+
+	log.Printf("Serving admin API on %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Admin API stopped: %v", err)
+	}
+}
+
+func (m *Manager) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	m.runningMu.Lock()
+	jobs := []*jobStatus{}
+	for _, job := range m.running {
+		status := &jobStatus{
+			SolutionID:  job.SolutionID,
+			TaskID:      job.TaskID,
+			UserID:      job.soln.UserId,
+			Problem:     job.soln.ProblemConfig.Label,
+			State:       job.State,
+			History:     job.History,
+			ContainerID: job.containerID,
+			ReceivedAt:  job.aoi.receivedAt,
+			Cancelled:   job.cancelled.Load(),
+		}
+		if job.execConfig != nil {
+			status.Image = job.execConfig.Image
+		}
+		if m.history != nil {
+			status.ExpectedDuration, _ = m.history.estimate(status.Problem)
+		}
+		jobs = append(jobs, status)
+	}
+	data, err := json.Marshal(jobs)
+	m.runningMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (m *Manager) handleJobLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("id")
+	if phase := r.URL.Query().Get("phase"); phase != "" {
+		name += "." + phase
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		http.Error(w, "invalid solution id", http.StatusBadRequest)
+		return
+	}
+	path := filepath.Join(m.logDir(), name+".log")
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "no log for this solution", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, path)
+}
+
+func (m *Manager) handleKillJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	m.runningMu.Lock()
+	job := m.running[id]
+	m.runningMu.Unlock()
+	if job == nil {
+		http.Error(w, "no such running job", http.StatusNotFound)
+		return
+	}
+	m.cancelJob(job.SolutionID, job.TaskID, "runner admin")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (m *Manager) handleDrain(w http.ResponseWriter, r *http.Request) {
+	// 保存检查点可能耗时较长，异步执行
+	go m.Drain()
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// admission 跟踪运行中评测申请的 CPU 与内存，下一个评测会使主机超额分配时暂停领取任务，
+// 避免并发评测互相挤占导致换页、计时结果失真
+type admission struct {
+	mu     sync.Mutex
+	cpuCap float64 // 允许申请的 CPU 总量（核心数），0 表示不限制
+	memCap int64   // 允许申请的内存总量（MB），0 表示不限制
+	cpus   float64 // 运行中评测申请的 CPU
+	memory int64   // 运行中评测申请的内存（MB）
+	jobs   int
+	paused bool
+
+	reserved map[string]demand // 已领取但尚未解析配置的评测按估计值预留，solution ID -> 预留
+}
+
+// demand 一个评测申请的资源
+type demand struct {
+	cpus   float64
+	memory int64 // MB
+}
+
+// initAdmission 按主机池的核心数与内存总量计算准入上限，均未配置时不启用
+func (m *Manager) initAdmission() error {
+	cpuRatio, memRatio := 0.0, 0.0
+	if m.conf.AdmissionCPURatio != nil {
+		cpuRatio = *m.conf.AdmissionCPURatio
+	}
+	if m.conf.AdmissionMemoryRatio != nil {
+		memRatio = *m.conf.AdmissionMemoryRatio
+	}
+	if cpuRatio <= 0 && memRatio <= 0 {
+		return nil
+	}
+
+	var ncpu int
+	var memTotal int64
+	for _, host := range m.hosts.all() {
+		ctx, cancel := context.WithTimeout(m.ctx, hostProbeTimeout)
+		info, err := host.exec.Info(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to query capacity of docker host %s: %w", host.name, err)
+		}
+		ncpu += info.NCPU
+		memTotal += info.MemTotal
+	}
+
+	a := &admission{reserved: make(map[string]demand)}
+	if cpuRatio > 0 {
+		if ncpu == 0 {
+			return fmt.Errorf("admission-cpu-ratio is set but the container runtime does not report its core count")
+		}
+		a.cpuCap = float64(ncpu) * cpuRatio
+	}
+	if memRatio > 0 {
+		if memTotal == 0 {
+			return fmt.Errorf("admission-memory-ratio is set but the container runtime does not report its memory")
+		}
+		a.memCap = int64(float64(memTotal>>20) * memRatio)
+	}
+	log.Printf("Admission control enabled: %g cores, %d MB", a.cpuCap, a.memCap)
+	m.admission = a
+	return nil
+}
+
+// jobDemand 返回执行配置申请的资源，不限制 CPU 的评测按 1 核计
+func jobDemand(config *executor.ExecuteConfig) demand {
+	cpus := config.CPULimit
+	if cpus <= 0 {
+		cpus = 1
+	}
+	return demand{cpus: cpus, memory: config.MemoryLimit}
+}
+
+// nextJobDemand 领取任务前无法得知其配置，按 runner 的默认资源限制估计下一个评测的申请
+func (m *Manager) nextJobDemand() demand {
+	config := &executor.ExecuteConfig{}
+	m.fillResourceDefaults(config)
+	if m.conf.MaxMemoryLimit != nil && *m.conf.MaxMemoryLimit > 0 {
+		config.MemoryLimit = min(config.MemoryLimit, *m.conf.MaxMemoryLimit)
+	}
+	return jobDemand(config)
+}
+
+// estimateDemand 按题目历史峰值内存的 p95 加 25% 余量收紧申请：
+// 评测的实际用量通常远低于内存上限，按上限计入会过早暂停领取
+func (m *Manager) estimateDemand(label string, d demand) demand {
+	if m.history == nil {
+		return d
+	}
+	if mem, ok := m.history.memoryEstimate(label); ok {
+		mem += mem / 4
+		if d.memory <= 0 || mem < d.memory {
+			d.memory = mem
+		}
+	}
+	return d
+}
+
+// fits 在已申请的资源上再加入 n 个评测是否不超过上限，调用方持有锁
+func (a *admission) fits(n int, d demand) bool {
+	if a.cpuCap > 0 && a.cpus+float64(n)*d.cpus > a.cpuCap {
+		return false
+	}
+	if a.memCap > 0 && a.memory+int64(n)*d.memory > a.memCap {
+		return false
+	}
+	return true
+}
+
+// admittable 返回 n 个空闲 worker 中可以领取任务的数量。
+// 没有运行中的评测时至少领取一个，避免申请超过上限的题目永远无法评测
+func (a *admission) admittable(n int, next demand) int {
+	if a == nil {
+		return n
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ok := 0
+	for ok < n && a.fits(ok+1, next) {
+		ok++
+	}
+	if ok == 0 && a.jobs == 0 {
+		ok = 1
+	}
+	if paused := ok == 0; paused != a.paused {
+		a.paused = paused
+		if paused {
+			log.Printf("Running jobs have committed %g cores and %d MB, pausing polling", a.cpus, a.memory)
+		} else {
+			log.Printf("Running jobs have committed %g cores and %d MB, resuming polling", a.cpus, a.memory)
+		}
+	}
+	return ok
+}
+
+// reserve 领取任务后按估计值预留资源，直到评测解析出实际申请或结束
+func (a *admission) reserve(solutionID string, d demand) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reserved[solutionID] = d
+	a.cpus += d.cpus
+	a.memory += d.memory
+	a.jobs++
+}
+
+// unreserve 释放评测尚未转为实际申请的预留
+func (a *admission) unreserve(solutionID string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.unreserveLocked(solutionID)
+}
+
+func (a *admission) unreserveLocked(solutionID string) {
+	d, ok := a.reserved[solutionID]
+	if !ok {
+		return
+	}
+	delete(a.reserved, solutionID)
+	a.cpus -= d.cpus
+	a.memory -= d.memory
+	a.jobs--
+}
+
+// commit 以评测的实际申请替换预留，返回释放函数
+func (a *admission) commit(solutionID string, d demand) func() {
+	if a == nil {
+		return func() {}
+	}
+	a.mu.Lock()
+	a.unreserveLocked(solutionID)
+	a.cpus += d.cpus
+	a.memory += d.memory
+	a.jobs++
+	a.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			a.cpus -= d.cpus
+			a.memory -= d.memory
+			a.jobs--
+			a.mu.Unlock()
+		})
+	}
+}
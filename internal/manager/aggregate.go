@@ -0,0 +1,253 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// 多 task 汇总方式
+const (
+	AggregateSum      = "sum"
+	AggregateAverage  = "average"
+	AggregateMin      = "min"
+	AggregateMax      = "max"
+	AggregateWeighted = "weighted"
+)
+
+const (
+	aggregateReport = "report.json"      // 待上报的汇总结果文件名，各 task 的结果保存为 task-<id>.json
+	aggregateTTL    = 7 * 24 * time.Hour // 未能汇总的 task 结果与一直上报失败的汇总结果的保留时间
+)
+
+// AggregateConfig 一个提交拆分为多个 task（阶段）时，按题目的汇总规则计算总分并上报到其中一个 task。
+// 各 task 的结果保存在各 runner 共享的 checkpoint-dir 中，未配置时只保存在本地 work-dir，
+// 此时同一提交的全部 task 必须由同一 runner 评测，否则不会汇总
+type AggregateConfig struct {
+	Tasks   []string           `json:"tasks"`   // 参与汇总的 task ID
+	Method  string             `json:"method"`  // sum（默认）、average、min、max 或 weighted（加权平均）
+	Weights map[string]float64 `json:"weights"` // weighted 时各 task 的权重，未列出的为 1
+	Report  string             `json:"report"`  // 接收汇总结果的 task，默认为 tasks 中的最后一项
+}
+
+// taskResult 一个 task 的最终结果
+type taskResult struct {
+	Score   float64 `json:"score"`
+	Status  string  `json:"status"`
+	Message string  `json:"message"`
+}
+
+func (c *AggregateConfig) method() string {
+	if c.Method == "" {
+		return AggregateSum
+	}
+	return c.Method
+}
+
+func (c *AggregateConfig) reportTask() string {
+	if c.Report != "" {
+		return c.Report
+	}
+	return c.Tasks[len(c.Tasks)-1]
+}
+
+// validate 检查汇总配置，当前 task 须在 tasks 中
+func (c *AggregateConfig) validate(taskID string) error {
+	if len(c.Tasks) == 0 {
+		return fmt.Errorf("aggregate.tasks is empty")
+	}
+	switch c.method() {
+	case AggregateSum, AggregateAverage, AggregateMin, AggregateMax, AggregateWeighted:
+	default:
+		return fmt.Errorf("unknown aggregate method %q", c.Method)
+	}
+	if !slices.Contains(c.Tasks, taskID) {
+		return fmt.Errorf("task %s is not listed in aggregate.tasks", taskID)
+	}
+	if !slices.Contains(c.Tasks, c.reportTask()) {
+		return fmt.Errorf("aggregate.report %s is not listed in aggregate.tasks", c.Report)
+	}
+	for task, weight := range c.Weights {
+		if weight < 0 {
+			return fmt.Errorf("invalid weight %g for task %s", weight, task)
+		}
+	}
+	return nil
+}
+
+// combine 按汇总方式计算总分。全部通过时为 Accepted，否则取第一个未通过的 task 的状态
+func (c *AggregateConfig) combine(results map[string]*taskResult) *aoiclient.SolutionInfo {
+	info := &aoiclient.SolutionInfo{Status: aoiclient.StatusAccepted}
+	var weights float64
+	var parts []string
+	for i, task := range c.Tasks {
+		r := results[task]
+		switch c.method() {
+		case AggregateSum, AggregateAverage:
+			info.Score += r.Score
+		case AggregateMin:
+			if i == 0 || r.Score < info.Score {
+				info.Score = r.Score
+			}
+		case AggregateMax:
+			if i == 0 || r.Score > info.Score {
+				info.Score = r.Score
+			}
+		case AggregateWeighted:
+			weight, ok := c.Weights[task]
+			if !ok {
+				weight = 1
+			}
+			info.Score += weight * r.Score
+			weights += weight
+		}
+		if r.Status != aoiclient.StatusAccepted && info.Status == aoiclient.StatusAccepted {
+			info.Status = r.Status
+		}
+		parts = append(parts, fmt.Sprintf("%s: %.2f（%s）", task, r.Score, r.Status))
+	}
+	switch c.method() {
+	case AggregateAverage:
+		info.Score /= float64(len(c.Tasks))
+	case AggregateWeighted:
+		if weights > 0 {
+			info.Score /= weights
+		}
+	}
+	info.Message = fmt.Sprintf("%d 个阶段汇总（%s）：%s", len(c.Tasks), c.method(), strings.Join(parts, "；"))
+	return info
+}
+
+// aggregateDir 返回保存各提交 task 结果的根目录：配置了共享的 checkpoint-dir 时位于其中，
+// 由不同 runner 评测的 task 同样能汇总；否则位于本地 work-dir
+func (m *Manager) aggregateDir() string {
+	if root := m.checkpointDir(); root != "" {
+		return filepath.Join(root, "aggregate")
+	}
+	return filepath.Join(m.workDir(), "aggregate")
+}
+
+// pendingAggregate 已计算但尚未成功上报的汇总结果，上报失败时保留并由清理循环重试
+type pendingAggregate struct {
+	SolutionID string                  `json:"solutionId"`
+	TaskID     string                  `json:"taskId"`
+	Info       *aoiclient.SolutionInfo `json:"info"`
+}
+
+func aggregateTaskPath(dir, taskID string) string {
+	return filepath.Join(dir, "task-"+url.PathEscape(taskID)+".json")
+}
+
+// aggregateTask 在 task 完成时记录其结果，所有 task 均已完成时将汇总结果上报到 aggregate.report 指定的 task。
+// 每个 task 的结果单独写入一个文件，不同 runner 并发写入时互不覆盖；先写入再检查，
+// 最后完成的 task 中至少有一个能看到全部结果（可能有两个 runner 同时上报，结果相同）
+func (m *Manager) aggregateTask(r *reporter) {
+	conf := r.aggregateConfig()
+	if conf == nil {
+		return
+	}
+	info, _ := r.verdict()
+	if info == nil {
+		return
+	}
+	solutionID, taskID := r.soln.SolutionId, r.soln.TaskId
+
+	m.aggregateMu.Lock()
+	defer m.aggregateMu.Unlock()
+	dir := filepath.Join(m.aggregateDir(), url.PathEscape(solutionID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Failed to save task result of solution %s: %v", solutionID, err)
+		return
+	}
+	data, _ := json.Marshal(&taskResult{Score: info.Score, Status: info.Status, Message: info.Message})
+	if err := writeFileAtomic(aggregateTaskPath(dir, taskID), data); err != nil {
+		log.Printf("Failed to save task result of solution %s: %v", solutionID, err)
+		return
+	}
+
+	results := make(map[string]*taskResult)
+	for _, task := range conf.Tasks {
+		result := &taskResult{}
+		if err := readJSONFile(aggregateTaskPath(dir, task), result); err != nil {
+			// 其余 task 尚未完成
+			return
+		}
+		results[task] = result
+	}
+
+	pending := &pendingAggregate{SolutionID: solutionID, TaskID: conf.reportTask(), Info: conf.combine(results)}
+	log.Printf("Solution %s: aggregated %d tasks, reporting score=%.2f, status=%s to task %s",
+		solutionID, len(conf.Tasks), pending.Info.Score, pending.Info.Status, pending.TaskID)
+	// 先持久化再上报，runner 重启或上报失败时由清理循环重试
+	data, _ = json.Marshal(pending)
+	if err := writeFileAtomic(filepath.Join(dir, aggregateReport), data); err != nil {
+		log.Printf("Failed to save aggregated result of solution %s: %v", solutionID, err)
+	}
+	m.goBackground(func() { m.reportAggregate(dir, pending) })
+}
+
+// reportAggregate 上报汇总结果，成功后删除该提交保存的全部结果
+func (m *Manager) reportAggregate(dir string, pending *pendingAggregate) {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Minute)
+	defer cancel()
+	if err := m.aoi.Solution(pending.SolutionID, pending.TaskID).Patch(ctx, pending.Info); err != nil {
+		log.Printf("Failed to report aggregated result of solution %s, keeping it for retry: %v", pending.SolutionID, err)
+		return
+	}
+	os.RemoveAll(dir)
+}
+
+// retryAggregates 重试上报失败的汇总结果，删除超过 aggregateTTL 未更新的结果（如其余 task 由未共享目录的 runner 评测）
+func (m *Manager) retryAggregates() {
+	root := m.aggregateDir()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if m.ctx.Err() != nil {
+			return
+		}
+		dir := filepath.Join(root, entry.Name())
+		expired := aggregateExpired(dir)
+		pending := &pendingAggregate{}
+		if err := readJSONFile(filepath.Join(dir, aggregateReport), pending); err == nil && pending.Info != nil {
+			if !expired {
+				m.reportAggregate(dir, pending)
+				continue
+			}
+			log.Printf("Giving up on reporting aggregated result of solution %s", pending.SolutionID)
+		}
+		if expired {
+			log.Printf("Removing stale task results in %s", dir)
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// aggregateExpired 判断目录（或旧版的单个结果文件）及其中的文件是否都已超过 aggregateTTL 未更新
+func aggregateExpired(path string) bool {
+	cutoff := time.Now().Add(-aggregateTTL)
+	expired := true
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(cutoff) {
+			expired = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return expired
+}
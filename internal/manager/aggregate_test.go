@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+)
+
+// finishTask 模拟 runner 完成 aggregate 中的一个 task
+func finishTask(m *Manager, conf *AggregateConfig, taskID string, score float64) {
+	r := m.newReporter(context.Background(), &aoiclient.SolutionPoll{SolutionId: "s1", TaskId: taskID})
+	r.setAggregate(conf)
+	r.info = &aoiclient.SolutionInfo{Score: score, Status: aoiclient.StatusAccepted}
+	m.aggregateTask(r)
+	m.pending.Wait()
+}
+
+func TestAggregateAcrossRunners(t *testing.T) {
+	srv := aoitest.NewServer()
+	defer srv.Close()
+	shared := t.TempDir()
+	newRunner := func(id string) *Manager {
+		m := NewManager(&config.ManagerConfig{RunnerID: ptr(id), WorkDir: ptr(t.TempDir()), CheckpointDir: ptr(shared)})
+		m.aoi = srv.Client()
+		return m
+	}
+	first, second := newRunner("runner-a"), newRunner("runner-b")
+	conf := &AggregateConfig{Tasks: []string{"compile", "run"}}
+
+	// 汇总结果第一次上报失败，保留在共享目录中由清理循环重试
+	srv.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPatch, Times: 1, Status: http.StatusServiceUnavailable})
+	finishTask(first, conf, "compile", 40)
+	finishTask(second, conf, "run", 50)
+	if task := srv.Task("s1", "run"); task != nil && len(task.Patches) > 0 {
+		t.Fatalf("aggregated result reported despite the injected failure: %+v", task.Patches)
+	}
+	if _, err := os.Stat(first.aggregateDir()); err != nil {
+		t.Fatalf("pending aggregated result was not kept: %v", err)
+	}
+
+	first.retryAggregates()
+	task := srv.Task("s1", "run")
+	if task == nil || task.Last() == nil || task.Last().Score != 90 {
+		t.Fatalf("aggregated result = %+v, want score 90 on task run", task)
+	}
+	entries, _ := os.ReadDir(first.aggregateDir())
+	if len(entries) != 0 {
+		t.Errorf("%d aggregate entries left after reporting", len(entries))
+	}
+}
+
+func TestStaleAggregatePartialsExpire(t *testing.T) {
+	m := NewManager(&config.ManagerConfig{RunnerID: ptr("runner-a"), WorkDir: ptr(t.TempDir())})
+	conf := &AggregateConfig{Tasks: []string{"compile", "run"}}
+	finishTask(m, conf, "compile", 40)
+
+	m.retryAggregates()
+	dir := filepath.Join(m.aggregateDir(), "s1")
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("fresh partial result was removed: %v", err)
+	}
+
+	old := time.Now().Add(-aggregateTTL - time.Hour)
+	os.Chtimes(aggregateTaskPath(dir, "compile"), old, old)
+	os.Chtimes(dir, old, old)
+	m.retryAggregates()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("stale partial result was kept: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+)
+
+func TestPollErrorsAreRetried(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPoll, Times: 2, Status: 503})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "retry", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusAccepted)
+	}
+	if n := env.aoi.Requests(aoitest.EndpointPoll); n < 3 {
+		t.Errorf("got %d poll requests, want at least 3", n)
+	}
+}
+
+func TestMalformedPollResponse(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPoll, Times: 1, Body: `{"solutionId": 42, "taskId":`})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "malformed", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusAccepted)
+	}
+}
+
+func TestPatchErrorDoesNotAbortJudging(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	// 运行状态上报失败不影响评测
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPatch, Times: 1, Status: 502})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "flaky-api", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusAccepted)
+	}
+}
+
+func TestCancelledByAOI(t *testing.T) {
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.CancelPollInterval = ptr(20 * time.Millisecond)
+	}, executortest.Script{Duration: time.Minute})
+	soln := aoitest.NewSolution("s1", "t1", "cancel", "lfs1", judgeConfig(nil))
+	env.aoi.Enqueue(soln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for len(env.exec.Runs()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("container was never started")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	env.aoi.Cancel("s1", "t1")
+	task, err := env.aoi.WaitComplete(ctx, "s1", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusCancelled {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusCancelled)
+	}
+}
+
+func TestCancelInterruptsPrePhase(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{Duration: time.Minute}, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	soln := aoitest.NewSolution("s1", "t1", "cancel-pre", "lfs1", judgeConfig(map[string]any{"pre_cmd": []string{"sleep", "60"}}))
+	env.aoi.Enqueue(soln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for len(env.exec.Runs()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("pre phase was never started")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	env.m.cancelJob("s1", "t1", "test")
+	task, err := env.aoi.WaitComplete(ctx, "s1", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusCancelled {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusCancelled)
+	}
+	if runs := env.exec.Runs(); len(runs) != 1 {
+		t.Errorf("got %d container runs, want only the pre phase", len(runs))
+	}
+}
+
+func TestSlowAPIResponses(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPoll, Times: 1, Delay: 200 * time.Millisecond})
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPatch, Delay: 50 * time.Millisecond})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "slow-api", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusAccepted)
+	}
+}
+
+func TestBatchPollFallback(t *testing.T) {
+	report := executortest.Script{Files: map[string]string{"/output/report.json": passingReport}}
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.Workers = ptr(2)
+	}, report, report)
+	env.aoi.SetBatchPoll(false)
+
+	first := env.judge(aoitest.NewSolution("s1", "t1", "batch", "lfs1", judgeConfig(nil)))
+	second := env.judge(aoitest.NewSolution("s2", "t1", "batch", "lfs1", judgeConfig(nil)))
+	for _, task := range []*aoitest.Task{first, second} {
+		if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+			t.Errorf("solution %s: final status = %+v, want %q", task.SolutionID, last, aoiclient.StatusAccepted)
+		}
+	}
+	if n := env.aoi.Requests(aoitest.EndpointPoll); n == 0 {
+		t.Error("manager did not fall back to single polling")
+	}
+}
+
+func TestBatchPollExtrasAreJudged(t *testing.T) {
+	report := executortest.Script{Files: map[string]string{"/output/report.json": passingReport}}
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.Workers = ptr(2)
+	}, report, report, report)
+
+	// AOI 返回的任务多于请求数量
+	var solns []*aoiclient.SolutionPoll
+	for _, id := range []string{"s1", "s2", "s3"} {
+		solns = append(solns, aoitest.NewSolution(id, "t1", "extras", "lfs1", judgeConfig(nil)))
+	}
+	body, err := json.Marshal(map[string]any{"solutions": solns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPollBatch, Times: 1, Body: string(body)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, soln := range solns {
+		task, err := env.aoi.WaitComplete(ctx, soln.SolutionId, soln.TaskId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+			t.Errorf("solution %s: final status = %+v, want %q", soln.SolutionId, last, aoiclient.StatusAccepted)
+		}
+	}
+}
+
+func TestFailedCompleteSkipsWebhook(t *testing.T) {
+	var hooks atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks.Add(1)
+	}))
+	defer webhook.Close()
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.ResultWebhook = ptr(webhook.URL)
+	}, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointComplete, Status: 502})
+	env.aoi.Enqueue(aoitest.NewSolution("s1", "t1", "webhook", "lfs1", judgeConfig(nil)))
+
+	// 等待评测结束：完成请求已发出且任务已离开运行列表
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		env.m.runningMu.Lock()
+		running := len(env.m.running)
+		env.m.runningMu.Unlock()
+		if env.aoi.Requests(aoitest.EndpointComplete) > 0 && running == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("solution was not judged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	env.m.pending.Wait()
+	if n := hooks.Load(); n != 0 {
+		t.Errorf("result webhook fired %d times although AOI rejected completion", n)
+	}
+}
+
+func TestLongPollSkipsBackoff(t *testing.T) {
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.LongPollTimeout = ptr(100 * time.Millisecond)
+		conf.PollMinInterval = ptr(5 * time.Second)
+		conf.PollMaxInterval = ptr(10 * time.Second)
+	}, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	// 模拟服务端挂起长轮询请求直到超时
+	env.aoi.Inject(aoitest.Fault{Endpoint: aoitest.EndpointPoll, Delay: 100 * time.Millisecond})
+	time.Sleep(300 * time.Millisecond)
+
+	start := time.Now()
+	env.judge(aoitest.NewSolution("s1", "t1", "long-poll", "lfs1", judgeConfig(nil)))
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("solution was picked up after %s, want no backoff after held long polls", elapsed)
+	}
+}
@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+// 未配置时的评测产物大小上限
+const (
+	defaultArtifactMaxSize  = 4 << 20  // 单个产物（字节）
+	defaultArtifactMaxTotal = 16 << 20 // 单次评测的全部产物（字节）
+)
+
+const maxArtifactName = 128
+
+// artifactState 容器通过 judgerproto 推送的产物，分块传输的产物在最后一块到达后上传
+type artifactState struct {
+	pending     map[string][]byte
+	dropped     map[string]bool // 超过大小上限、丢弃其余分块的产物
+	total       int64           // 已上传与正在接收的产物总大小
+	unsupported bool
+}
+
+func (m *Manager) artifactMaxSize() int64 {
+	if m.conf.ArtifactMaxSize != nil {
+		return *m.conf.ArtifactMaxSize
+	}
+	return defaultArtifactMaxSize
+}
+
+func (m *Manager) artifactMaxTotal() int64 {
+	if m.conf.ArtifactMaxTotal != nil {
+		return *m.conf.ArtifactMaxTotal
+	}
+	return defaultArtifactMaxTotal
+}
+
+// validArtifactName 产物名只能是单个文件名
+func validArtifactName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= maxArtifactName &&
+		!strings.ContainsAny(name, "/\\\x00")
+}
+
+// onFile 接收容器推送的产物，使评测镜像无需上传凭据即可保存图表、diff 等小文件。
+// 超过单个或单次评测的大小上限时丢弃该产物
+func (r *reporter) onFile(body *judgerproto.FileBody) error {
+	if !validArtifactName(body.Name) {
+		return fmt.Errorf("invalid artifact name %q", body.Name)
+	}
+	maxSize, maxTotal := r.m.artifactMaxSize(), r.m.artifactMaxTotal()
+	if maxSize <= 0 || maxTotal <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	if r.artifacts == nil {
+		r.artifacts = &artifactState{pending: make(map[string][]byte), dropped: make(map[string]bool)}
+	}
+	state := r.artifacts
+	if state.unsupported || state.dropped[body.Name] {
+		if !body.More {
+			delete(state.dropped, body.Name)
+		}
+		r.mu.Unlock()
+		return nil
+	}
+	size := int64(len(state.pending[body.Name]) + len(body.Data))
+	if size > maxSize || state.total+int64(len(body.Data)) > maxTotal {
+		state.total -= int64(len(state.pending[body.Name]))
+		delete(state.pending, body.Name)
+		if body.More {
+			state.dropped[body.Name] = true
+		}
+		r.mu.Unlock()
+		return fmt.Errorf("artifact %s exceeds the size limit (%d bytes per artifact, %d bytes per solution)", body.Name, maxSize, maxTotal)
+	}
+	state.total += int64(len(body.Data))
+	data := append(state.pending[body.Name], body.Data...)
+	if body.More {
+		state.pending[body.Name] = data
+		r.mu.Unlock()
+		return nil
+	}
+	delete(state.pending, body.Name)
+	r.mu.Unlock()
+
+	err := r.UploadArtifact(r.ctx, body.Name, data)
+	if err != nil {
+		// 上传失败的产物不计入总量，重试或其他产物仍可使用这部分额度
+		r.mu.Lock()
+		state.total -= int64(len(data))
+		if errors.Is(err, aoiclient.ErrArtifactsUnsupported) {
+			state.unsupported = true
+		}
+		r.mu.Unlock()
+		return fmt.Errorf("failed to upload artifact %s: %w", body.Name, err)
+	}
+	log.Printf("Uploaded artifact %s (%d bytes) for solution %s", body.Name, len(data), r.SolutionID())
+	return nil
+}
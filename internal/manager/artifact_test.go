@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+func TestFailedArtifactUploadIsRefunded(t *testing.T) {
+	var uploads atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"url": "` + srv.URL + `/storage/artifact"}`))
+		case http.MethodPut:
+			// 第一次上传失败
+			if uploads.Add(1) == 1 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	m := NewManager(&config.ManagerConfig{ArtifactMaxSize: ptr[int64](8), ArtifactMaxTotal: ptr[int64](8)})
+	m.aoi = aoiclient.New(srv.URL)
+	r := m.newReporter(context.Background(), &aoiclient.SolutionPoll{SolutionId: "s1", TaskId: "t1"})
+
+	artifact := &judgerproto.FileBody{Name: "plot.png", Data: []byte("12345678")}
+	if err := r.onFile(artifact); err == nil {
+		t.Fatal("first upload did not fail")
+	}
+	if err := r.onFile(artifact); err != nil {
+		t.Fatalf("retry after a failed upload: %v", err)
+	}
+	if r.artifacts.total != 8 {
+		t.Errorf("total = %d, want 8", r.artifacts.total)
+	}
+}
@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// BuildCacheSpec manager 侧定义的构建缓存，宿主机目录为 <build-cache-dir>/<name>[/<scope>]
+type BuildCacheSpec struct {
+	Target   string            `json:"target"`   // 容器内挂载路径
+	Env      map[string]string `json:"env"`      // 指向缓存目录的环境变量
+	ReadOnly bool              `json:"readOnly"` // 只读挂载，缓存由运维预先填充
+}
+
+// defaultBuildCaches 内置的构建缓存，可由 build-cache-config 覆盖或补充
+var defaultBuildCaches = map[string]BuildCacheSpec{
+	"uv":      {Target: "/cache/uv", Env: map[string]string{"UV_CACHE_DIR": "/cache/uv"}},
+	"pip":     {Target: "/cache/pip", Env: map[string]string{"PIP_CACHE_DIR": "/cache/pip"}},
+	"npm":     {Target: "/cache/npm", Env: map[string]string{"npm_config_cache": "/cache/npm"}},
+	"ccache":  {Target: "/cache/ccache", Env: map[string]string{"CCACHE_DIR": "/cache/ccache"}},
+	"sccache": {Target: "/cache/sccache", Env: map[string]string{"SCCACHE_DIR": "/cache/sccache"}},
+	"cargo":   {Target: "/cache/cargo", Env: map[string]string{"CARGO_HOME": "/cache/cargo"}},
+	"go": {Target: "/cache/go", Env: map[string]string{
+		"GOCACHE":    "/cache/go/build",
+		"GOMODCACHE": "/cache/go/mod",
+	}},
+}
+
+// 构建缓存的共享范围
+const (
+	buildCacheShared  = "shared"  // 所有题目共享
+	buildCacheProblem = "problem" // 每道题目单独一份（默认）
+	buildCacheUser    = "user"    // 每个用户单独一份，避免选手之间通过缓存相互影响
+)
+
+var buildCacheNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// BuildCacheConfig judge config 中启用的构建缓存，可以只写缓存名
+type BuildCacheConfig struct {
+	Name     string `json:"name"`
+	ReadOnly bool   `json:"readOnly"` // 只读挂载；manager 侧定义为只读的缓存不能改为可写
+	Scope    string `json:"scope"`    // shared、problem 或 user，默认 problem
+}
+
+func (c *BuildCacheConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*c = BuildCacheConfig{Name: name}
+		return nil
+	}
+	type plain BuildCacheConfig
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// buildCacheMount 解析后的构建缓存挂载
+type buildCacheMount struct {
+	name   string
+	source string
+	spec   BuildCacheSpec
+	mount  executor.Mount
+}
+
+// buildCaches 返回 manager 侧定义的全部构建缓存
+func (m *Manager) buildCaches() (map[string]BuildCacheSpec, error) {
+	caches := make(map[string]BuildCacheSpec, len(defaultBuildCaches))
+	for name, spec := range defaultBuildCaches {
+		caches[name] = spec
+	}
+	if m.conf.BuildCacheConfig == nil || *m.conf.BuildCacheConfig == "" {
+		return caches, nil
+	}
+	data, err := os.ReadFile(*m.conf.BuildCacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build cache config: %w", err)
+	}
+	var custom map[string]BuildCacheSpec
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse build cache config: %w", err)
+	}
+	for name, spec := range custom {
+		if !buildCacheNamePattern.MatchString(name) || !strings.HasPrefix(spec.Target, "/") {
+			return nil, fmt.Errorf("invalid build cache %q", name)
+		}
+		caches[name] = spec
+	}
+	return caches, nil
+}
+
+// resolveBuildCaches 将 judge config 启用的构建缓存解析为挂载，不创建目录
+func (m *Manager) resolveBuildCaches(soln *aoiclient.SolutionPoll, configs []BuildCacheConfig) ([]buildCacheMount, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	if m.conf.BuildCacheDir == nil || *m.conf.BuildCacheDir == "" {
+		return nil, fmt.Errorf("build caches requested but no build cache dir configured")
+	}
+	caches, err := m.buildCaches()
+	if err != nil {
+		return nil, err
+	}
+	var mounts []buildCacheMount
+	for _, c := range configs {
+		spec, ok := caches[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("build cache %q is not configured on this runner", c.Name)
+		}
+		source := filepath.Join(*m.conf.BuildCacheDir, c.Name)
+		switch c.Scope {
+		case buildCacheShared:
+		case "", buildCacheProblem:
+			source = filepath.Join(source, "problem-"+safePathComponent(soln.ProblemConfig.Label))
+		case buildCacheUser:
+			source = filepath.Join(source, "user-"+safePathComponent(soln.UserId))
+		default:
+			return nil, fmt.Errorf("invalid scope %q for build cache %q", c.Scope, c.Name)
+		}
+		mounts = append(mounts, buildCacheMount{
+			name:   c.Name,
+			source: source,
+			spec:   spec,
+			mount: executor.Mount{
+				Source:   source,
+				Target:   spec.Target,
+				ReadOnly: spec.ReadOnly || c.ReadOnly,
+			},
+		})
+	}
+	return mounts, nil
+}
+
+// prepareBuildCaches 创建可写的缓存目录并使容器用户能够写入；只读缓存须已存在
+func (m *Manager) prepareBuildCaches(mounts []buildCacheMount, containerUser string) error {
+	for _, bc := range mounts {
+		if bc.mount.ReadOnly {
+			if _, err := os.Stat(bc.source); err != nil {
+				return fmt.Errorf("read-only build cache %s is missing: %w", bc.source, err)
+			}
+			continue
+		}
+		if _, err := os.Stat(bc.source); err == nil {
+			touchBuildCache(bc.source)
+			continue
+		}
+		if err := os.MkdirAll(bc.source, 0o755); err != nil {
+			return fmt.Errorf("failed to create build cache %s: %w", bc.source, err)
+		}
+		if err := m.prepareSharedDir(bc.source, containerUser); err != nil {
+			return fmt.Errorf("failed to prepare build cache %s: %w", bc.source, err)
+		}
+	}
+	return nil
+}
+
+// safePathComponent 将标签、用户 ID 等转换为可以作为单级目录名的字符串
+func safePathComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || strings.HasPrefix(s, ".") {
+		s = "_" + s
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// errCancelled 平台要求中止评测
+var errCancelled = errors.New("solution was cancelled")
+
+// cancelJob 中止评测：停止运行中的评测容器及辅助容器，评测在当前步骤结束后以 Cancelled 上报。
+// 取消请求可能来自推送、轮询或管理接口，与运行中的评测不符时忽略
+func (m *Manager) cancelJob(solutionID, taskID, by string) {
+	m.runningMu.Lock()
+	job := m.running[solutionID]
+	var containerID string
+	if job != nil {
+		containerID = job.containerID
+	}
+	m.runningMu.Unlock()
+	if job == nil || job.TaskID != taskID {
+		return
+	}
+	if !job.cancelled.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Solution %s: cancelled by %s", solutionID, by)
+	// 中止预处理、编译、服务等辅助容器；上报使用的 job.ctx 不受影响
+	job.stopExec()
+	if containerID != "" {
+		if err := job.exec.Stop(context.Background(), containerID); err != nil {
+			log.Printf("Solution %s: failed to stop container %s: %v", solutionID, containerID, err)
+		}
+	}
+}
+
+// trackContainer 记录评测的主容器；评测已被取消时立即停止该容器
+func (m *Manager) trackContainer(job *Job, containerID string) {
+	m.setContainer(job, containerID)
+	if containerID != "" && job.cancelled.Load() {
+		job.exec.Stop(context.Background(), containerID)
+	}
+	m.runningMu.Lock()
+	rec := job.perfRecorder
+	m.runningMu.Unlock()
+	if containerID != "" && rec != nil {
+		rec.attach(containerID)
+	}
+}
+
+// cancelPollInterval 返回轮询取消状态的间隔，0 表示只接受推送的取消消息
+func (m *Manager) cancelPollInterval() time.Duration {
+	if m.conf.CancelPollInterval == nil {
+		return 0
+	}
+	return *m.conf.CancelPollInterval
+}
+
+// watchCancellation 在评测期间定期查询取消状态，返回停止查询的函数。
+// 平台不支持时停止查询
+func (m *Manager) watchCancellation(job *Job) func() {
+	interval := m.cancelPollInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			cancelled, err := job.aoi.Cancelled(ctx)
+			if errors.Is(err, aoiclient.ErrCancelUnsupported) {
+				return
+			}
+			if err == nil && cancelled {
+				m.cancelJob(job.SolutionID, job.TaskID, "AOI")
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
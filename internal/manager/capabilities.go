@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// capabilities 汇总 runner 的版本、执行后端、可用资源与 adapter
+func (m *Manager) capabilities(ctx context.Context) *aoiclient.RunnerCapabilities {
+	caps := &aoiclient.RunnerCapabilities{
+		Version:  aoiclient.Version,
+		Executor: "docker",
+	}
+	if m.conf.ExecutorBackend != nil && *m.conf.ExecutorBackend != "" {
+		caps.Executor = *m.conf.ExecutorBackend
+	}
+	for _, host := range m.hosts.all() {
+		caps.Hosts++
+		probeCtx, cancel := context.WithTimeout(ctx, hostProbeTimeout)
+		info, err := host.exec.Info(probeCtx)
+		cancel()
+		if err != nil {
+			// 不可用的主机不计入资源，由 dockerHealthLoop 告警
+			log.Printf("Failed to query capacity of docker host %s: %v", host.name, err)
+			continue
+		}
+		caps.CPUs += info.NCPU
+		caps.Memory += info.MemTotal
+	}
+	if m.gpus != nil {
+		caps.GPUs = append(caps.GPUs, m.gpus.devices...)
+	}
+	caps.Adapters = m.availableAdapters()
+	return caps
+}
+
+// availableAdapters 返回内置 adapter 与 adapter 目录中可执行的外部 adapter（exec:<name>）
+func (m *Manager) availableAdapters() []string {
+	names := []string{"lfs1", "proto"}
+	for name := range adapters.ReportAdapters {
+		names = append(names, name)
+	}
+	sort.Strings(names[2:])
+	if m.conf.AdapterDir == nil || *m.conf.AdapterDir == "" {
+		return names
+	}
+	entries, err := os.ReadDir(*m.conf.AdapterDir)
+	if err != nil {
+		log.Printf("Failed to list adapter dir: %v", err)
+		return names
+	}
+	for _, entry := range entries {
+		name := adapters.ExecPrefix + entry.Name()
+		if _, err := adapters.ResolveExec(name, *m.conf.AdapterDir); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// capabilityLoop 启动时及之后每隔 capability-report-interval 向 AOI 上报 runner 能力，
+// 平台不支持时停止上报
+func (m *Manager) capabilityLoop(ctx context.Context) {
+	for {
+		err := m.aoi.ReportCapabilities(ctx, m.capabilities(ctx))
+		switch {
+		case errors.Is(err, aoiclient.ErrCapabilitiesUnsupported):
+			log.Println("Platform does not support capability reporting")
+			return
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Failed to report runner capabilities: %v", err)
+		}
+		if m.conf.CapabilityReportInterval == nil || *m.conf.CapabilityReportInterval <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*m.conf.CapabilityReportInterval):
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// 检查点目录结构：<checkpoint-dir>/<solution>/{criu/, output/, manifest.json}。
+// manifest.json 最后写入，出现即表示检查点完整；其他 runner 将其重命名为 claimed.json 以领取
+const (
+	checkpointManifest = "manifest.json"
+	checkpointClaimed  = "claimed.json"
+	checkpointTimeout  = 5 * time.Minute
+)
+
+// errCheckpointed 评测已保存为检查点，由其他 runner 恢复
+var errCheckpointed = errors.New("job was checkpointed for migration")
+
+// checkpointManifestData 迁移到其他 runner 的评测
+type checkpointManifestData struct {
+	Runner         string                  `json:"runner"`         // 保存检查点的 runner
+	Solution       *aoiclient.SolutionPoll `json:"solution"`       // 原始任务
+	Elapsed        time.Duration           `json:"elapsed"`        // 检查点之前评测容器已运行的时间
+	CheckpointedAt time.Time               `json:"checkpointedAt"` // 保存时间
+}
+
+// checkpointState 正在为运行中的评测保存检查点
+type checkpointState struct {
+	dir  string
+	done chan struct{}
+	err  error
+}
+
+// restoreState 从检查点恢复的评测
+type restoreState struct {
+	dir     string
+	elapsed time.Duration
+}
+
+// checkpointDir 返回共享的检查点目录，为空时不支持迁移
+func (m *Manager) checkpointDir() string {
+	if m.conf.CheckpointDir == nil {
+		return ""
+	}
+	return *m.conf.CheckpointDir
+}
+
+// checkpointable 只有单容器、不依赖本机网络、设备与目录的评测可以迁移。
+// 编译产物、题目数据、构建缓存、core dump 与 hook 输出都挂载自本机目录，绑核依赖本机核心，
+// 恢复时这些挂载在新 runner 上不存在或不一致
+func checkpointable(rc *RunningConfig) bool {
+	return len(rc.Steps) == 0 && len(rc.Services) == 0 && rc.MPI == nil && rc.GPU == nil &&
+		!rc.IsolatedNetwork && rc.NetworkShaping == nil && len(rc.Devices) == 0 &&
+		rc.Compile == nil && rc.ProblemData == nil && rc.CoreDump == nil && len(buildCacheConfigs(rc)) == 0 &&
+		rc.CPUPin == nil && rc.StudentHook == nil
+}
+
+// Drain 停止领取新任务。运行中的评测可以迁移时保存检查点到共享目录，由其他 runner 恢复运行；
+// 否则等待其正常完成
+func (m *Manager) Drain() {
+	log.Println("Draining runner")
+	type pendingCheckpoint struct {
+		job         *Job
+		containerID string
+		cp          *checkpointState
+	}
+	var pending []pendingCheckpoint
+	m.runningMu.Lock()
+	for _, job := range m.running {
+		if job.checkpoint == nil && job.containerID != "" && m.checkpointDir() != "" && checkpointable(job.rc) {
+			job.checkpoint = &checkpointState{
+				dir:  filepath.Join(m.checkpointDir(), job.SolutionID),
+				done: make(chan struct{}),
+			}
+			pending = append(pending, pendingCheckpoint{job, job.containerID, job.checkpoint})
+		}
+	}
+	m.runningMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.checkpointJob(p.job, p.containerID, p.cp)
+		}()
+	}
+	wg.Wait()
+	m.stop()
+}
+
+// checkpointJob 将评测容器保存为检查点，完成后通知评测流程
+func (m *Manager) checkpointJob(job *Job, containerID string, cp *checkpointState) {
+	defer close(cp.done)
+	log.Printf("Solution %s: checkpointing container %s", job.SolutionID, containerID)
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+	os.RemoveAll(cp.dir)
+	cp.err = os.MkdirAll(cp.dir, 0o755)
+	if cp.err == nil {
+		cp.err = job.exec.Checkpoint(ctx, containerID, filepath.Join(cp.dir, "criu"))
+	}
+	if cp.err != nil {
+		log.Printf("Solution %s: checkpoint failed, waiting for it to finish: %v", job.SolutionID, cp.err)
+		os.RemoveAll(cp.dir)
+	}
+}
+
+// saveCheckpoint 在容器因检查点停止后保存输出目录并写入 manifest
+func (m *Manager) saveCheckpoint(job *Job) error {
+	dir := job.checkpoint.dir
+	if err := copyTree(job.outputDir, filepath.Join(dir, "output")); err != nil {
+		return fmt.Errorf("failed to save output dir: %w", err)
+	}
+	elapsed := job.runDuration
+	if job.restore != nil {
+		elapsed += job.restore.elapsed
+	}
+	data, err := json.Marshal(&checkpointManifestData{
+		Runner:         *m.conf.RunnerID,
+		Solution:       job.soln,
+		Elapsed:        elapsed,
+		CheckpointedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, checkpointManifest+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, checkpointManifest))
+}
+
+// claimCheckpoint 领取共享目录中等待恢复的评测，没有时返回 nil
+func (m *Manager) claimCheckpoint() (*checkpointManifestData, *restoreState) {
+	root := m.checkpointDir()
+	if root == "" {
+		return nil, nil
+	}
+	manifests, _ := filepath.Glob(filepath.Join(root, "*", checkpointManifest))
+	for _, path := range manifests {
+		dir := filepath.Dir(path)
+		claimed := filepath.Join(dir, checkpointClaimed)
+		// 重命名是原子的，同一检查点只会被一个 runner 领取
+		if err := os.Rename(path, claimed); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(claimed)
+		manifest := &checkpointManifestData{}
+		if err == nil {
+			err = json.Unmarshal(data, manifest)
+		}
+		if err != nil || manifest.Solution == nil {
+			log.Printf("Discarding invalid checkpoint %s: %v", dir, err)
+			os.RemoveAll(dir)
+			continue
+		}
+		return manifest, &restoreState{dir: dir, elapsed: manifest.Elapsed}
+	}
+	return nil, nil
+}
+
+// prepareRestore 恢复检查点时的输出目录与执行配置，剩余时间扣除已运行的时间
+func (m *Manager) prepareRestore(job *Job) error {
+	if !checkpointable(job.rc) {
+		return fmt.Errorf("judge config cannot be restored from a checkpoint")
+	}
+	if err := copyTree(filepath.Join(job.restore.dir, "output"), job.outputDir); err != nil {
+		return fmt.Errorf("failed to restore output dir: %w", err)
+	}
+	job.execConfig.RestoreFrom = filepath.Join(job.restore.dir, "criu")
+	remaining := job.execConfig.Timeout - job.restore.elapsed
+	job.execConfig.Timeout = max(remaining, time.Second)
+	return nil
+}
+
+// abandonRestore 恢复失败时清空输出目录并恢复完整时限，从头重新评测
+func (m *Manager) abandonRestore(job *Job) error {
+	entries, err := os.ReadDir(job.outputDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(job.outputDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	job.execConfig.RestoreFrom = ""
+	job.execConfig.Timeout += job.restore.elapsed
+	job.restore.elapsed = 0
+	return nil
+}
+
+// copyTree 复制目录，保留权限，不跟随符号链接
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !d.Type().IsRegular():
+			return nil
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
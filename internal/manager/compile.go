@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const (
+	defaultCompileTimeout = 120 // 编译阶段的默认超时（秒）
+	compileSourceTarget   = "/src"
+	defaultCompileTarget  = "/build"
+	compileStepName       = "编译"
+	defaultCompileErrFile = "compile_error.log" // 评测容器自行编译失败时写入输出目录的编译输出
+	maxCompileErrRead     = 1 << 20
+)
+
+// CompileConfig 独立的编译阶段：在带工具链的镜像中编译提交（源码可写挂载在 /src），
+// 产物写入 build 目录后只读挂载到评测容器，评测镜像只需包含运行时依赖
+type CompileConfig struct {
+	Image       string   `json:"image"`       // 编译镜像
+	Cmd         []string `json:"cmd"`         // 编译命令，工作目录为 /src，产物写入 BUILD_DIR
+	Timeout     int64    `json:"timeout"`     // 超时时间（秒），默认 120，不占用评测时间
+	MemoryLimit int64    `json:"memoryLimit"` // 内存限制（MB），默认与评测相同
+	Target      string   `json:"target"`      // 产物目录在两个容器内的路径，默认 /build
+}
+
+// compileTarget 返回产物目录在容器内的路径
+func compileTarget(c *CompileConfig) string {
+	if c.Target == "" {
+		return defaultCompileTarget
+	}
+	return c.Target
+}
+
+// prepareCompile 下载提交源码并创建产物目录，产物目录只读挂载到评测容器
+func (m *Manager) prepareCompile(job *Job, c *CompileConfig) error {
+	if c.Image == "" || len(c.Cmd) == 0 {
+		return fmt.Errorf("compile requires image and cmd")
+	}
+	soln := job.soln
+	srcDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-src-%s-", soln.SolutionId))
+	if err != nil {
+		return err
+	}
+	job.addCleanup(func() { os.RemoveAll(srcDir) })
+	buildDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-build-%s-", soln.SolutionId))
+	if err != nil {
+		return err
+	}
+	job.addCleanup(func() { os.RemoveAll(buildDir) })
+
+	if err := m.cache.ExtractTo(job.ctx, soln.SolutionDataUrl, soln.SolutionDataHash, srcDir); err != nil {
+		return fmt.Errorf("failed to fetch solution for compile: %w", err)
+	}
+	for _, dir := range []string{srcDir, buildDir} {
+		if err := m.prepareSharedTree(dir, job.execConfig.User); err != nil {
+			return fmt.Errorf("failed to prepare compile dir: %w", err)
+		}
+	}
+	job.compileSrc, job.compileBuild = srcDir, buildDir
+
+	target := compileTarget(c)
+	job.execConfig.Mounts = append(job.execConfig.Mounts, executor.Mount{Source: buildDir, Target: target, ReadOnly: true})
+	job.execConfig.Env["BUILD_DIR"] = target
+	return nil
+}
+
+// runCompile 在编译镜像中编译提交。编译失败时记录为 Compile Error 并跳过评测，
+// 编译容器本身无法运行时返回阶段错误
+func (m *Manager) runCompile(job *Job, c *CompileConfig) error {
+	target := compileTarget(c)
+	config := *job.execConfig
+	config.Image = c.Image
+	config.Command = c.Cmd
+	config.WorkDir = compileSourceTarget
+	config.CPUTimeLimit = 0
+	config.Timeout = time.Duration(c.Timeout) * time.Second
+	if config.Timeout <= 0 {
+		config.Timeout = defaultCompileTimeout * time.Second
+	}
+	config.Timeout = m.clampTimeout(job.SolutionID, config.Timeout)
+	if c.MemoryLimit > 0 {
+		config.MemoryLimit = m.clampMemory(job.SolutionID, c.MemoryLimit)
+	}
+	config.NoNewPrivileges = true
+	config.CapDrop = []string{"ALL"}
+	config.Mounts = nil
+	for _, mount := range job.execConfig.Mounts {
+		if mount.Target != target {
+			config.Mounts = append(config.Mounts, mount)
+		}
+	}
+	config.Mounts = append(config.Mounts,
+		executor.Mount{Source: job.compileSrc, Target: compileSourceTarget},
+		executor.Mount{Source: job.compileBuild, Target: target},
+	)
+	config.Env = make(map[string]string, len(job.execConfig.Env)+1)
+	for k, v := range job.execConfig.Env {
+		config.Env[k] = v
+	}
+	config.Env["SOURCE_DIR"] = compileSourceTarget
+
+	local := m.openLocalLog(job, "compile")
+	defer local.close()
+
+	log.Printf("Solution %s: compiling with %s (timeout %s)", job.SolutionID, config.Image, config.Timeout)
+	job.aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+		Status:  "Running",
+		Message: "编译中",
+	})
+	ctx, cancel := context.WithTimeout(job.execCtx, config.Timeout+10*time.Second)
+	defer cancel()
+	output := &tailBuffer{limit: maxStepOutput}
+	result, err := job.exec.ExecuteWithLogs(ctx, &config, func(stream executor.LogStream, line string) error {
+		slog.Info(fmt.Sprintf("[%s compile %s] %s", job.SolutionID, stream, line), "solution", job.SolutionID, "source", "compile", "stream", stream.String())
+		local.write(line)
+		output.write(line)
+		return nil
+	})
+	if err != nil {
+		return &phaseError{"compile", err}
+	}
+
+	var reason string
+	switch {
+	case result.TimedOut:
+		reason = fmt.Sprintf("编译超时（限制 %s 秒）\n", formatSeconds(config.Timeout))
+	case result.OOM:
+		reason = fmt.Sprintf("编译内存超限（限制 %d MB）\n", config.MemoryLimit)
+	case result.OutputLimitExceeded:
+		reason = "编译输出超限\n"
+	case result.ExitCode == 0:
+		log.Printf("Solution %s: compiled successfully", job.SolutionID)
+		return nil
+	}
+	log.Printf("Solution %s: compile failed with code %d", job.SolutionID, result.ExitCode)
+	job.stepFailure = &stepFailure{
+		step:     compileStepName,
+		status:   aoiclient.StatusCompileError,
+		exitCode: result.ExitCode,
+		output:   reason + output.String(),
+	}
+	job.result = &executor.ExecuteResult{ExitCode: result.ExitCode, Usage: result.Usage}
+	return nil
+}
+
+// readCompileError 读取评测容器写入的编译错误标记文件，文件不存在时返回 false。
+// 内容为编译器输出，过长时截断
+func readCompileError(outputDir string, rc *RunningConfig) (string, bool) {
+	name := rc.CompileErrorFile
+	if name == "" {
+		name = defaultCompileErrFile
+	}
+	path := filepath.Join(outputDir, name)
+	if rel, err := filepath.Rel(outputDir, path); err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	// 标记文件由评测容器写入，不跟随符号链接
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxCompileErrRead))
+	if err != nil {
+		return "", false
+	}
+	return adapters.TruncateOutput(strings.ToValidUTF8(string(data), "")), true
+}
+
+// isCompileStep 名为 compile/build 的步骤失败且未设置 fail_status 时按编译错误上报
+func isCompileStep(name string) bool {
+	switch strings.ToLower(name) {
+	case "compile", "build", compileStepName:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const (
+	defaultCompilerCacheSize = 5 << 30
+	ccacheStatsLog           = ".ccache-stats.log" // 位于输出目录中，记录本次评测每次编译的缓存结果
+	maxCcacheStatsRead       = 4 << 20             // 统计日志的读取上限
+)
+
+// compilerCaches 由 manager 限制大小并在 GC 时按最近使用淘汰的编译缓存
+var compilerCaches = []string{"ccache", "sccache"}
+
+// compilerCacheStats 一次评测的编译缓存命中情况
+type compilerCacheStats struct {
+	Hits   int
+	Misses int
+}
+
+func (s *compilerCacheStats) hitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// buildCacheConfigs 返回 judge config 启用的全部构建缓存，cache 为 build_caches 的简写
+func buildCacheConfigs(rc *RunningConfig) []BuildCacheConfig {
+	return append(append([]BuildCacheConfig(nil), rc.BuildCaches...), rc.Cache...)
+}
+
+// compilerCacheSize 返回单个编译缓存目录的大小上限（字节）
+func (m *Manager) compilerCacheSize() int64 {
+	if m.conf.CompilerCacheSize != nil && *m.conf.CompilerCacheSize > 0 {
+		return *m.conf.CompilerCacheSize
+	}
+	return defaultCompilerCacheSize
+}
+
+// compilerCacheEnv 返回编译缓存的大小限制与统计相关的环境变量，超出上限时由缓存工具自行淘汰
+func (m *Manager) compilerCacheEnv(name string) map[string]string {
+	mb := m.compilerCacheSize() >> 20
+	switch name {
+	case "ccache":
+		return map[string]string{
+			"CCACHE_MAXSIZE":  fmt.Sprintf("%dMi", mb),
+			"CCACHE_STATSLOG": "/output/" + ccacheStatsLog,
+		}
+	case "sccache":
+		return map[string]string{"SCCACHE_CACHE_SIZE": fmt.Sprintf("%dM", mb)}
+	}
+	return nil
+}
+
+// usesCcache 判断 judge config 是否挂载了 ccache 编译缓存
+func usesCcache(rc *RunningConfig) bool {
+	for _, c := range buildCacheConfigs(rc) {
+		if c.Name == "ccache" {
+			return true
+		}
+	}
+	return false
+}
+
+// collectCompilerCacheStats 读取本次评测的 ccache 统计日志，没有使用 ccache 时返回 nil。
+// sccache 不提供单次评测的统计。日志由评测容器写入，统计仅供参考
+func collectCompilerCacheStats(rc *RunningConfig, outputDir string) *compilerCacheStats {
+	if !usesCcache(rc) {
+		return nil
+	}
+	path := filepath.Join(outputDir, ccacheStatsLog)
+	// 不跟随符号链接，也不打开 FIFO 等特殊文件
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	stats := &compilerCacheStats{}
+	scanner := bufio.NewScanner(io.LimitReader(f, maxCcacheStatsRead))
+	for scanner.Scan() {
+		switch strings.TrimSpace(scanner.Text()) {
+		case "direct_cache_hit", "preprocessed_cache_hit":
+			stats.Hits++
+		case "cache_miss":
+			stats.Misses++
+		}
+	}
+	return stats
+}
+
+// withCacheMetrics 返回附带编译缓存指标的结果副本
+func withCacheMetrics(info *aoiclient.SolutionInfo, stats *compilerCacheStats) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	metrics["ccache_hits"] = float64(stats.Hits)
+	metrics["ccache_misses"] = float64(stats.Misses)
+	copied := *info
+	copied.Metrics = &metrics
+	return &copied
+}
+
+// withCacheSummary 返回在摘要末尾附带编译缓存命中情况的详情副本
+func withCacheSummary(details *aoiclient.SolutionDetails, stats *compilerCacheStats) *aoiclient.SolutionDetails {
+	copied := *details
+	line := fmt.Sprintf("编译缓存：命中 %d 次，未命中 %d 次，命中率 %.1f%%", stats.Hits, stats.Misses, stats.hitRate()*100)
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += line
+	return &copied
+}
+
+// touchBuildCache 更新缓存目录的修改时间，GC 据此判断最近使用
+func touchBuildCache(dir string) {
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+}
+
+// evictCompilerCaches 编译缓存总大小超过 compiler-cache-total 时，
+// 按最近使用时间删除各题目、各用户的缓存目录，运行中评测挂载的目录不受影响
+func (m *Manager) evictCompilerCaches(ctx context.Context) {
+	if m.conf.BuildCacheDir == nil || *m.conf.BuildCacheDir == "" ||
+		m.conf.CompilerCacheTotal == nil || *m.conf.CompilerCacheTotal <= 0 {
+		return
+	}
+	inUse := make(map[string]bool)
+	m.runningMu.Lock()
+	for _, job := range m.running {
+		if job.execConfig == nil {
+			continue
+		}
+		for _, mount := range job.execConfig.Mounts {
+			inUse[mount.Source] = true
+		}
+	}
+	m.runningMu.Unlock()
+
+	type cacheDir struct {
+		path    string
+		size    int64
+		lastUse time.Time
+	}
+	var dirs []cacheDir
+	var total int64
+	for _, name := range compilerCaches {
+		root := filepath.Join(*m.conf.BuildCacheDir, name)
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			// 只淘汰按题目或用户划分的目录，shared 范围的缓存由缓存工具自行限制大小
+			name := entry.Name()
+			if !entry.IsDir() || !strings.HasPrefix(name, "problem-") && !strings.HasPrefix(name, "user-") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(root, name)
+			size := dirSize(path)
+			total += size
+			if !inUse[path] {
+				dirs = append(dirs, cacheDir{path: path, size: size, lastUse: info.ModTime()})
+			}
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].lastUse.Before(dirs[j].lastUse) })
+	for _, dir := range dirs {
+		if total <= *m.conf.CompilerCacheTotal || ctx.Err() != nil {
+			break
+		}
+		if err := os.RemoveAll(dir.path); err != nil {
+			log.Printf("GC: failed to evict compiler cache %s: %v", dir.path, err)
+			continue
+		}
+		total -= dir.size
+		log.Printf("GC: evicted compiler cache %s, %.1f MB, last used %s", dir.path, float64(dir.size)/(1<<20), dir.lastUse.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	defaultCoreDumpTarget = "/cores"
+	coreDumpMaxFiles      = 8
+)
+
+// CoreDumpConfig core dump 收集配置
+type CoreDumpConfig struct {
+	MaxSize int64 `json:"max_size"` // 单个 core 文件大小上限（MB），超过时丢弃，0 为不限制
+}
+
+// coreDumpTarget 返回容器内 core 文件目录，需与宿主机 kernel.core_pattern 的目录一致
+func (m *Manager) coreDumpTarget() string {
+	if m.conf.CoreDumpTarget != nil && *m.conf.CoreDumpTarget != "" {
+		return *m.conf.CoreDumpTarget
+	}
+	return defaultCoreDumpTarget
+}
+
+// checkCorePattern 检查宿主机 core_pattern 是否会把 core 写到容器内的挂载目录。
+// core_pattern 为全局配置，以路径形式指定时在崩溃进程的挂载命名空间中解析
+func (m *Manager) checkCorePattern() {
+	data, err := os.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return
+	}
+	pattern := strings.TrimSpace(string(data))
+	if strings.HasPrefix(pattern, "|") || !strings.HasPrefix(pattern, m.coreDumpTarget()+"/") {
+		log.Printf("Warning: kernel.core_pattern is %q, core dumps will not land in %s", pattern, m.coreDumpTarget())
+	}
+}
+
+// prepareCoreDumps 为任务创建 core 目录并挂载到容器内
+func (m *Manager) prepareCoreDumps(job *Job) error {
+	m.corePatternOnce.Do(m.checkCorePattern)
+
+	dir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-cores-%s-", job.SolutionID))
+	if err != nil {
+		return fmt.Errorf("failed to create core dump dir: %w", err)
+	}
+	job.addCleanup(func() { os.RemoveAll(dir) })
+	if err := m.prepareSharedDir(dir, job.execConfig.User); err != nil {
+		return err
+	}
+	job.coreDir = dir
+	job.execConfig.CoreDumps = true
+	job.execConfig.Mounts = append(job.execConfig.Mounts, executor.Mount{
+		Source: dir,
+		Target: m.coreDumpTarget(),
+	})
+	return nil
+}
+
+// collectCoreDumps 压缩评测产生的 core 文件并保存到 <workDir>/cores/<solution_id>/
+func (m *Manager) collectCoreDumps(job *Job) {
+	if job.coreDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(job.coreDir)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+
+	dst := filepath.Join(m.workDir(), "cores", job.SolutionID)
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		log.Printf("Failed to create core dump store: %v", err)
+		return
+	}
+	var maxSize int64
+	if job.rc.CoreDump != nil && job.rc.CoreDump.MaxSize > 0 {
+		maxSize = job.rc.CoreDump.MaxSize * 1024 * 1024
+	}
+
+	saved := 0
+	for _, entry := range entries {
+		if saved >= coreDumpMaxFiles {
+			log.Printf("Solution %s: too many core dumps, keeping the first %d", job.SolutionID, coreDumpMaxFiles)
+			break
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if maxSize > 0 && info.Size() > maxSize {
+			log.Printf("Solution %s: discarding core dump %s (%d bytes exceeds limit)", job.SolutionID, entry.Name(), info.Size())
+			continue
+		}
+		path := filepath.Join(dst, entry.Name()+".gz")
+		if err := gzipFile(filepath.Join(job.coreDir, entry.Name()), path); err != nil {
+			log.Printf("Failed to save core dump %s: %v", entry.Name(), err)
+			continue
+		}
+		saved++
+	}
+	if saved > 0 {
+		log.Printf("Solution %s: saved %d core dump(s) to %s", job.SolutionID, saved, dst)
+	}
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+)
+
+// costRecord 单次评测消耗的计算与存储资源
+type costRecord struct {
+	Time         time.Time `json:"time"`
+	SolutionID   string    `json:"solutionId"`
+	UserID       string    `json:"userId"`
+	ContestID    string    `json:"contestId"`
+	Problem      string    `json:"problem"`
+	WallSeconds  float64   `json:"wallSeconds"`
+	CPUSeconds   float64   `json:"cpuSeconds"`
+	GPUSeconds   float64   `json:"gpuSeconds"`
+	StorageBytes int64     `json:"storageBytes"`
+}
+
+// costLedger 以 JSON Lines 追加记录评测开销
+type costLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+func costPath(workDir string) string {
+	return filepath.Join(workDir, "costs.jsonl")
+}
+
+func (l *costLedger) append(rec *costRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// dirSize 统计目录下普通文件的总大小
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// recordCost 作为状态转移回调，在任务结束时记录开销
+func (m *Manager) recordCost(job *Job, from, to State) {
+	if !to.Terminal() {
+		return
+	}
+	rec := &costRecord{
+		Time:        time.Now(),
+		SolutionID:  job.SolutionID,
+		UserID:      job.soln.UserId,
+		ContestID:   job.soln.ContestId,
+		Problem:     job.soln.ProblemConfig.Label,
+		WallSeconds: job.runDuration.Seconds(),
+	}
+	if job.result != nil && job.result.Usage != nil {
+		rec.CPUSeconds = job.result.Usage.CPUTime.Seconds()
+	}
+	if job.execConfig != nil {
+		rec.GPUSeconds = float64(len(job.execConfig.GPUDevices)) * job.runDuration.Seconds()
+	}
+	if job.outputDir != "" {
+		rec.StorageBytes += dirSize(job.outputDir)
+	}
+	if job.coreDir != "" {
+		rec.StorageBytes += dirSize(job.coreDir)
+	}
+	if err := m.costs.append(rec); err != nil {
+		log.Printf("Failed to record cost for solution %s: %v", job.SolutionID, err)
+	}
+}
+
+// costTotal 按维度汇总的开销
+type costTotal struct {
+	key          string
+	solutions    int
+	wallSeconds  float64
+	cpuSeconds   float64
+	gpuSeconds   float64
+	storageBytes int64
+}
+
+// costKey 返回记录在指定汇总维度下的键
+func costKey(rec *costRecord, groupBy string) (string, error) {
+	switch groupBy {
+	case "user":
+		return rec.UserID, nil
+	case "problem":
+		return rec.Problem, nil
+	case "contest":
+		return rec.ContestID, nil
+	case "solution":
+		return rec.SolutionID, nil
+	}
+	return "", fmt.Errorf("unknown group %q (want user, problem, contest or solution)", groupBy)
+}
+
+// ExportCosts 按 user/problem/contest/solution 汇总本 runner 的评测开销并以 CSV 输出，
+// 按 GPU 时间与 CPU 时间从高到低排序，便于发现超出预算或异常频繁的提交
+func ExportCosts(conf *config.ManagerConfig, groupBy string, w io.Writer) error {
+	if err := validateRunnerID(*conf.RunnerID); err != nil {
+		return err
+	}
+	m := NewManager(conf)
+	f, err := os.Open(costPath(m.workDir()))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	totals := make(map[string]*costTotal)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rec := &costRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			continue
+		}
+		key, err := costKey(rec, groupBy)
+		if err != nil {
+			return err
+		}
+		t := totals[key]
+		if t == nil {
+			t = &costTotal{key: key}
+			totals[key] = t
+		}
+		t.solutions++
+		t.wallSeconds += rec.WallSeconds
+		t.cpuSeconds += rec.CPUSeconds
+		t.gpuSeconds += rec.GPUSeconds
+		t.storageBytes += rec.StorageBytes
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	sorted := make([]*costTotal, 0, len(totals))
+	for _, t := range totals {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].gpuSeconds != sorted[j].gpuSeconds {
+			return sorted[i].gpuSeconds > sorted[j].gpuSeconds
+		}
+		return sorted[i].cpuSeconds > sorted[j].cpuSeconds
+	})
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{groupBy, "solutions", "wall_seconds", "cpu_seconds", "gpu_seconds", "storage_mb"})
+	for _, t := range sorted {
+		cw.Write([]string{
+			t.key,
+			strconv.Itoa(t.solutions),
+			strconv.FormatFloat(t.wallSeconds, 'f', 1, 64),
+			strconv.FormatFloat(t.cpuSeconds, 'f', 1, 64),
+			strconv.FormatFloat(t.gpuSeconds, 'f', 1, 64),
+			strconv.FormatFloat(float64(t.storageBytes)/(1<<20), 'f', 1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
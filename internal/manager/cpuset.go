@@ -0,0 +1,276 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// CPUPinConfig CPU 绑核配置
+type CPUPinConfig struct {
+	Cores    int  `json:"cores"`     // 独占核心数，默认取 cpuLimit 向上取整
+	NUMANode *int `json:"numa_node"` // 指定 NUMA 节点，为空时自动选择可容纳的节点
+}
+
+// cpuAllocator 为并发评测分配互不相交的核心集合，优先放在同一 NUMA 节点内
+type cpuAllocator struct {
+	mu      sync.Mutex
+	nodes   map[int][]int // NUMA 节点 -> 可用核心
+	used    map[int]bool
+	online  []int         // 主机的全部在线核心
+	pool    map[int]bool  // 显式配置的绑核池，未绑核的评测不使用其中的核心；未配置时为 nil
+	changed chan struct{} // 每次释放核心时关闭并替换，唤醒等待的分配
+}
+
+// parseCPUList 解析 "0-3,8,10-11" 形式的核心列表
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		for c := start; c <= end; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
+
+// formatCPUList 将核心列表格式化为 Docker cpuset 字符串
+func formatCPUList(cpus []int) string {
+	parts := make([]string, len(cpus))
+	for i, c := range cpus {
+		parts[i] = strconv.Itoa(c)
+	}
+	return strings.Join(parts, ",")
+}
+
+// newCPUAllocator 读取 NUMA 拓扑，pool 为空时使用全部在线核心
+func newCPUAllocator(pool string) (*cpuAllocator, error) {
+	var online []int
+	if data, err := os.ReadFile("/sys/devices/system/cpu/online"); err == nil {
+		online, _ = parseCPUList(string(data))
+	}
+	if len(online) == 0 {
+		for c := 0; c < runtime.NumCPU(); c++ {
+			online = append(online, c)
+		}
+	}
+
+	a := &cpuAllocator{nodes: make(map[int][]int), used: make(map[int]bool), online: online, changed: make(chan struct{})}
+	allowed := online
+	if pool != "" {
+		cpus, err := parseCPUList(pool)
+		if err != nil {
+			return nil, err
+		}
+		allowed = cpus
+		a.pool = make(map[int]bool, len(cpus))
+		for _, c := range cpus {
+			a.pool[c] = true
+		}
+	}
+
+	nodeDirs, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	for _, dir := range nodeDirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		cpus, err := parseCPUList(string(data))
+		if err != nil {
+			continue
+		}
+		for _, c := range cpus {
+			if slices.Contains(allowed, c) {
+				a.nodes[node] = append(a.nodes[node], c)
+			}
+		}
+	}
+	// 无 NUMA 信息时视为单节点
+	if len(a.nodes) == 0 {
+		a.nodes[0] = allowed
+	}
+	return a, nil
+}
+
+func (a *cpuAllocator) free(node int) []int {
+	var result []int
+	for _, c := range a.nodes[node] {
+		if !a.used[c] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// allocate 分配 n 个核心，返回核心列表与对应的内存节点。
+// 空闲核心不足时等待其他评测释放，直到 ctx 结束；申请超过池的容量时直接返回错误
+func (a *cpuAllocator) allocate(ctx context.Context, n int, node *int) ([]int, []int, error) {
+	for {
+		a.mu.Lock()
+		cpus, mems, err := a.tryAllocate(n, node)
+		changed := a.changed
+		a.mu.Unlock()
+		if err != nil || cpus != nil {
+			return cpus, mems, err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// tryAllocate 尝试分配 n 个核心，空闲核心不足时返回 nil，调用方需持有锁
+func (a *cpuAllocator) tryAllocate(n int, node *int) ([]int, []int, error) {
+	var nodes []int
+	if node != nil {
+		if _, ok := a.nodes[*node]; !ok {
+			return nil, nil, fmt.Errorf("NUMA node %d is not available", *node)
+		}
+		if n > len(a.nodes[*node]) {
+			return nil, nil, fmt.Errorf("need %d cores but NUMA node %d only has %d", n, *node, len(a.nodes[*node]))
+		}
+		nodes = []int{*node}
+	} else {
+		for id := range a.nodes {
+			nodes = append(nodes, id)
+		}
+		slices.Sort(nodes)
+		total := 0
+		for _, id := range nodes {
+			total += len(a.nodes[id])
+		}
+		if n > total {
+			return nil, nil, fmt.Errorf("need %d cores but only %d can be pinned", n, total)
+		}
+	}
+
+	// 优先选择能完整容纳的节点
+	for _, id := range nodes {
+		if free := a.free(id); len(free) >= n {
+			cpus := free[:n]
+			for _, c := range cpus {
+				a.used[c] = true
+			}
+			return cpus, []int{id}, nil
+		}
+	}
+	if node != nil {
+		return nil, nil, nil
+	}
+
+	// 跨节点分配
+	var cpus, mems []int
+	for _, id := range nodes {
+		free := a.free(id)
+		if len(free) == 0 {
+			continue
+		}
+		take := min(n-len(cpus), len(free))
+		cpus = append(cpus, free[:take]...)
+		mems = append(mems, id)
+		if len(cpus) == n {
+			break
+		}
+	}
+	if len(cpus) < n {
+		return nil, nil, nil
+	}
+	for _, c := range cpus {
+		a.used[c] = true
+	}
+	return cpus, mems, nil
+}
+
+func (a *cpuAllocator) release(cpus []int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, c := range cpus {
+		delete(a.used, c)
+	}
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// unpinned 返回未绑核的评测可使用的核心：绑核池只占部分核心时排除整个池，
+// 否则排除当前已分配的核心（之后才分配的核心仍可能与该评测重叠）。
+// 没有需要排除的核心时返回空字符串，不限制 cpuset
+func (a *cpuAllocator) unpinned() string {
+	if a == nil {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cpus := a.excluding(func(c int) bool { return a.pool[c] || a.used[c] })
+	if len(cpus) == 0 {
+		cpus = a.excluding(func(c int) bool { return a.used[c] })
+	}
+	if len(cpus) == 0 || len(cpus) == len(a.online) {
+		return ""
+	}
+	return formatCPUList(cpus)
+}
+
+// excluding 返回不满足 skip 的在线核心，调用方需持有锁
+func (a *cpuAllocator) excluding(skip func(int) bool) []int {
+	var cpus []int
+	for _, c := range a.online {
+		if !skip(c) {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus
+}
+
+// pinCPUs 为任务分配独占核心并写入执行配置，返回释放函数。空闲核心不足时等待
+func (m *Manager) pinCPUs(ctx context.Context, solutionID string, pin *CPUPinConfig, config *executor.ExecuteConfig) (func(), error) {
+	n := pin.Cores
+	if n <= 0 {
+		n = int(math.Ceil(config.CPULimit))
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("cpu_pin requires cores or cpuLimit")
+	}
+
+	m.cpus.mu.Lock()
+	cpus, mems, err := m.cpus.tryAllocate(n, pin.NUMANode)
+	m.cpus.mu.Unlock()
+	if err == nil && cpus == nil {
+		log.Printf("Solution %s: waiting for %d free cores", solutionID, n)
+		cpus, mems, err = m.cpus.allocate(ctx, n, pin.NUMANode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	config.CpusetCpus = formatCPUList(cpus)
+	config.CpusetMems = formatCPUList(mems)
+	return func() { m.cpus.release(cpus) }, nil
+}
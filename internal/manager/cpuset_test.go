@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testCPUAllocator() *cpuAllocator {
+	return &cpuAllocator{
+		nodes:   map[int][]int{0: {0, 1, 2, 3}},
+		used:    make(map[int]bool),
+		online:  []int{0, 1, 2, 3, 4, 5, 6, 7},
+		pool:    map[int]bool{0: true, 1: true, 2: true, 3: true},
+		changed: make(chan struct{}),
+	}
+}
+
+func TestCPUAllocateWaitsForRelease(t *testing.T) {
+	a := testCPUAllocator()
+	ctx := context.Background()
+	first, _, err := a.allocate(ctx, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan []int)
+	go func() {
+		cpus, _, err := a.allocate(ctx, 2, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- cpus
+	}()
+	select {
+	case cpus := <-got:
+		t.Fatalf("allocated %v while only one core was free", cpus)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	a.release(first)
+	select {
+	case cpus := <-got:
+		if len(cpus) != 2 {
+			t.Fatalf("got %v, want 2 cores", cpus)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("allocation did not resume after release")
+	}
+}
+
+func TestCPUAllocateCancelled(t *testing.T) {
+	a := testCPUAllocator()
+	if _, _, err := a.allocate(context.Background(), 4, nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := a.allocate(ctx, 1, nil); err == nil {
+		t.Fatal("allocation succeeded with no free cores")
+	}
+}
+
+func TestCPUAllocateOverCapacity(t *testing.T) {
+	a := testCPUAllocator()
+	if _, _, err := a.allocate(context.Background(), 5, nil); err == nil {
+		t.Fatal("allocating more cores than the pool has did not fail")
+	}
+	node := 1
+	if _, _, err := a.allocate(context.Background(), 1, &node); err == nil {
+		t.Fatal("allocating on a missing NUMA node did not fail")
+	}
+}
+
+func TestCPUUnpinnedAvoidsPool(t *testing.T) {
+	a := testCPUAllocator()
+	if got := a.unpinned(); got != "4,5,6,7" {
+		t.Errorf("unpinned() = %q, want 4,5,6,7", got)
+	}
+
+	// 绑核池覆盖全部核心时只排除已分配的核心
+	a.pool = nil
+	a.nodes = map[int][]int{0: a.online}
+	if got := a.unpinned(); got != "" {
+		t.Errorf("unpinned() with nothing allocated = %q, want empty", got)
+	}
+	if _, _, err := a.allocate(context.Background(), 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.unpinned(); got != "2,3,4,5,6,7" {
+		t.Errorf("unpinned() = %q, want 2,3,4,5,6,7", got)
+	}
+}
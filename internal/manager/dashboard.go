@@ -0,0 +1,57 @@
+package manager
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const dashboardRecent = 50 // 面板展示的最近评测数
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// statsResponse 管理接口 /stats 的返回值
+type statsResponse struct {
+	Runner      string          `json:"runner"`
+	Recent      []recentResult  `json:"recent"`
+	FailureRate float64         `json:"failureRate"` // 最近评测中 runner 侧失败的比例
+	P50Queue    time.Duration   `json:"p50Queue"`    // 最近评测从收到任务到开始运行的时间
+	P95Queue    time.Duration   `json:"p95Queue"`
+	Problems    []*ProblemStats `json:"problems"`
+}
+
+// serveDashboard 返回运维面板页面，数据由页面通过管理接口获取
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+func (m *Manager) handleStats(w http.ResponseWriter, r *http.Request) {
+	resp := &statsResponse{
+		Runner:   *m.conf.RunnerID,
+		Recent:   m.history.recent(dashboardRecent),
+		Problems: m.history.all(),
+	}
+	var failed int
+	var queues []time.Duration
+	for _, r := range resp.Recent {
+		if r.Failed {
+			failed++
+		}
+		if r.QueueTime > 0 {
+			queues = append(queues, r.QueueTime)
+		}
+	}
+	if len(resp.Recent) > 0 {
+		resp.FailureRate = float64(failed) / float64(len(resp.Recent))
+	}
+	slices.Sort(queues)
+	resp.P50Queue = percentile(queues, 0.5)
+	resp.P95Queue = percentile(queues, 0.95)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const (
+	dryRunID          = "dry-run"
+	dryRunOutputDir   = "<output-dir>" // 实际评测时为临时创建的输出目录
+	dryRunSecretValue = "<secret>"
+	dryRunMaxInput    = 1 << 20
+)
+
+// dryRunResult 解析后的执行配置，不启动任何容器
+type dryRunResult struct {
+	Config *executor.ExecuteConfig `json:"config"`
+	Notes  []string                `json:"notes,omitempty"` // 实际评测时在准备阶段才确定的配置
+}
+
+// dryRun 解析 judge config 或完整的 SolutionPoll（如 record-dir 录制的 solution.json），
+// 返回评测容器将使用的执行配置。密钥只校验能否解析，值被替换为占位符
+func (m *Manager) dryRun(ctx context.Context, data []byte) (*dryRunResult, error) {
+	soln := &aoiclient.SolutionPoll{}
+	if err := json.Unmarshal(data, soln); err != nil || len(soln.ProblemConfig.Judge.Config) == 0 {
+		soln = &aoiclient.SolutionPoll{
+			SolutionId: dryRunID,
+			TaskId:     dryRunID,
+			UserId:     dryRunID,
+			ContestId:  dryRunID,
+		}
+		soln.ProblemConfig.Label = dryRunID
+		soln.ProblemConfig.Judge.Config = data
+	}
+
+	rc := new(RunningConfig)
+	if err := json.Unmarshal(soln.ProblemConfig.Judge.Config, rc); err != nil {
+		return nil, fmt.Errorf("failed to parse judge config: %w", err)
+	}
+	execConfig, err := m.buildExecuteConfig(ctx, soln, rc, dryRunOutputDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := computeLatePenalty(soln, rc); err != nil {
+		return nil, err
+	}
+	for _, name := range rc.Secrets {
+		execConfig.Env[name] = dryRunSecretValue
+	}
+	return &dryRunResult{Config: execConfig, Notes: dryRunNotes(rc)}, nil
+}
+
+// dryRunNotes 列出准备阶段会进一步修改执行配置的选项
+func dryRunNotes(rc *RunningConfig) []string {
+	var notes []string
+	if rc.CPUPin != nil {
+		notes = append(notes, "cpuset is allocated from the runner's core pool when the job starts")
+	}
+	if rc.IsolatedNetwork || len(rc.Services) > 0 {
+		notes = append(notes, "the container joins a per-job network created when the job starts")
+	}
+	if rc.NetworkShaping != nil {
+		notes = append(notes, "network shaping is applied by a helper container to the judge and every service after the job network is created")
+	}
+	if rc.ProblemData != nil {
+		notes = append(notes, "problem data is downloaded and mounted when the job starts")
+	}
+	if rc.CoreDump != nil {
+		notes = append(notes, "a core dump directory is mounted when the job starts")
+	}
+	if rc.GPU != nil {
+		notes = append(notes, "gpu devices are allocated when the job starts")
+	}
+	if rc.MPI != nil {
+		notes = append(notes, "mpi workers are started on peer runners when the job starts")
+	}
+	if rc.StudentHook != nil {
+		notes = append(notes, "the student hook runs in a separate sandbox before the judge container")
+	}
+	if rc.Compile != nil {
+		notes = append(notes, "the solution is compiled in a separate container and the build dir is mounted read-only when the job starts")
+	}
+	if len(rc.PreCmd) > 0 || len(rc.PostCmd) > 0 {
+		notes = append(notes, "pre/post commands run in separate containers")
+	}
+	if len(rc.Steps) > 0 {
+		notes = append(notes, "steps are executed in the container after docker_cmd starts it")
+	}
+	if rc.WarmPool {
+		notes = append(notes, "docker_cmd may be executed in a pre-started warm container, bypassing the image entrypoint")
+	}
+	return notes
+}
+
+// DryRun 输出 judge config 解析后的执行配置，供运维审计题目实际运行的内容
+func DryRun(conf *config.ManagerConfig, path string, w io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m := NewManager(conf)
+	if err := m.initSecrets(); err != nil {
+		return err
+	}
+	result, err := m.dryRun(context.Background(), data)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(result)
+}
+
+func (m *Manager) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, dryRunMaxInput))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	result, err := m.dryRun(r.Context(), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,155 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const raplRoot = "/sys/class/powercap"
+
+// energyUsage 评测容器运行期间的能耗。RAPL 计量整个 CPU 封装而不是单个容器，
+// 只有独占 CPU（如配置 cpuset）且评测容器运行在 manager 所在主机时才能反映选手程序的能耗
+type energyUsage struct {
+	CPUJoules float64       // RAPL 封装能耗（J）
+	GPUJoules float64       // 分配的 GPU 的能耗（J），由功率采样积分得到
+	Duration  time.Duration // 计量时长
+}
+
+func (e *energyUsage) total() float64 {
+	return e.CPUJoules + e.GPUJoules
+}
+
+// raplDomain 一个 CPU 封装的 RAPL 计数器
+type raplDomain struct {
+	path     string
+	maxRange uint64 // 计数器回绕前的最大值（μJ）
+}
+
+// raplDomains 返回各 CPU 封装的 RAPL 计数器，不包含 core、uncore 等子域以免重复计量
+func raplDomains() []raplDomain {
+	paths, _ := filepath.Glob(filepath.Join(raplRoot, "intel-rapl:*"))
+	var domains []raplDomain
+	for _, path := range paths {
+		if strings.Count(filepath.Base(path), ":") != 1 {
+			continue
+		}
+		maxRange, err := readUint(filepath.Join(path, "max_energy_range_uj"))
+		if err != nil {
+			continue
+		}
+		domains = append(domains, raplDomain{path: path, maxRange: maxRange})
+	}
+	return domains
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readRAPL 读取各封装的累计能耗（μJ）
+func readRAPL(domains []raplDomain) ([]uint64, error) {
+	values := make([]uint64, len(domains))
+	for i, d := range domains {
+		v, err := readUint(filepath.Join(d.path, "energy_uj"))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// measureEnergy 在 judge config 要求时计量评测容器运行期间的能耗，停止时记录到 job.energy。
+// GPU 能耗取自 watchGPUs 的采样，需在停止 GPU 采样之后调用返回的函数
+func (m *Manager) measureEnergy(job *Job) func() {
+	if !job.rc.MeasureEnergy {
+		return func() {}
+	}
+	domains := raplDomains()
+	start, err := readRAPL(domains)
+	if err != nil {
+		log.Printf("Solution %s: failed to read RAPL counters: %v", job.SolutionID, err)
+		domains = nil
+	} else if len(domains) == 0 {
+		log.Printf("Solution %s: RAPL is not available, CPU energy is not measured", job.SolutionID)
+	}
+	begin := time.Now()
+	return func() {
+		usage := &energyUsage{Duration: time.Since(begin)}
+		if len(domains) > 0 {
+			end, err := readRAPL(domains)
+			if err != nil {
+				log.Printf("Solution %s: failed to read RAPL counters: %v", job.SolutionID, err)
+			} else {
+				for i, d := range domains {
+					delta := end[i] - start[i]
+					if end[i] < start[i] {
+						delta = d.maxRange - start[i] + end[i]
+					}
+					usage.CPUJoules += float64(delta) / 1e6
+				}
+			}
+		}
+		if job.gpuUsage != nil {
+			usage.GPUJoules = job.gpuUsage.Energy
+		}
+		job.energy = usage
+		log.Printf("Solution %s used %.1f J (CPU %.1f J, GPU %.1f J) in %s", job.SolutionID, usage.total(), usage.CPUJoules, usage.GPUJoules, usage.Duration)
+	}
+}
+
+// energyEnv 返回附带能耗的环境变量副本，供 post 阶段计算能效得分
+func energyEnv(env map[string]string, usage *energyUsage) map[string]string {
+	env = maps.Clone(env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	env["JUDGE_ENERGY_J"] = strconv.FormatFloat(usage.total(), 'f', 3, 64)
+	env["JUDGE_CPU_ENERGY_J"] = strconv.FormatFloat(usage.CPUJoules, 'f', 3, 64)
+	env["JUDGE_GPU_ENERGY_J"] = strconv.FormatFloat(usage.GPUJoules, 'f', 3, 64)
+	env["JUDGE_RUN_SECONDS"] = strconv.FormatFloat(usage.Duration.Seconds(), 'f', 3, 64)
+	return env
+}
+
+// withEnergyMetrics 返回附带能耗指标的结果副本
+func withEnergyMetrics(info *aoiclient.SolutionInfo, usage *energyUsage) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	metrics["energy_j"] = usage.total()
+	metrics["cpu_energy_j"] = usage.CPUJoules
+	metrics["gpu_energy_j"] = usage.GPUJoules
+	if usage.Duration > 0 {
+		metrics["avg_power_w"] = usage.total() / usage.Duration.Seconds()
+	}
+
+	copied := *info
+	copied.Metrics = &metrics
+	return &copied
+}
+
+// withEnergySummary 返回在摘要末尾附带能耗说明的详情副本
+func withEnergySummary(details *aoiclient.SolutionDetails, usage *energyUsage) *aoiclient.SolutionDetails {
+	copied := *details
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += fmt.Sprintf("能耗：共 %.1f J（CPU %.1f J，GPU %.1f J），运行 %.2f 秒",
+		usage.total(), usage.CPUJoules, usage.GPUJoules, usage.Duration.Seconds())
+	return &copied
+}
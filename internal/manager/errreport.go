@@ -0,0 +1,165 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const errorReportTimeout = 10 * time.Second
+
+// errorReporter 将内部错误发送到 Sentry 兼容的服务（store API），按评测附带 solution、task 等标签
+type errorReporter struct {
+	storeURL string
+	auth     string
+	runnerID string
+}
+
+// sentryEvent Sentry store API 的事件
+type sentryEvent struct {
+	EventID    string            `json:"event_id"`
+	Timestamp  string            `json:"timestamp"`
+	Level      string            `json:"level"`
+	Platform   string            `json:"platform"`
+	Logger     string            `json:"logger"`
+	ServerName string            `json:"server_name,omitempty"`
+	Message    string            `json:"message"`
+	Exception  *sentryExceptions `json:"exception,omitempty"`
+	Tags       map[string]string `json:"tags"`
+	Extra      map[string]any    `json:"extra,omitempty"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// newErrorReporter 解析 DSN（https://<key>@<host>/<project>），dsn 为空时返回 nil
+func newErrorReporter(dsn, runnerID string) (*errorReporter, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error report DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid error report DSN: missing public key")
+	}
+	i := strings.LastIndex(u.Path, "/")
+	project := u.Path[i+1:]
+	if project == "" {
+		return nil, fmt.Errorf("invalid error report DSN: missing project ID")
+	}
+	auth := "Sentry sentry_version=7, sentry_client=lfs-auto-grader/1.0, sentry_key=" + u.User.Username()
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	store := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path[:i] + "/api/" + project + "/store/"}
+	return &errorReporter{storeURL: store.String(), auth: auth, runnerID: runnerID}, nil
+}
+
+// event 创建带有评测上下文的事件，job 为 nil 时只附带 runner
+func (r *errorReporter) event(job *Job, level, kind, message string) *sentryEvent {
+	id := make([]byte, 16)
+	rand.Read(id)
+	ev := &sentryEvent{
+		EventID:    hex.EncodeToString(id),
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Level:      level,
+		Platform:   "go",
+		Logger:     "lfs-auto-grader",
+		ServerName: r.runnerID,
+		Message:    message,
+		Exception:  &sentryExceptions{Values: []sentryException{{Type: kind, Value: message}}},
+		Tags:       map[string]string{"runner": r.runnerID, "kind": kind},
+		Extra:      make(map[string]any),
+	}
+	if job != nil {
+		ev.Tags["solution"] = job.SolutionID
+		ev.Tags["task"] = job.TaskID
+		ev.Tags["state"] = string(job.State)
+		if soln := job.soln; soln != nil {
+			ev.Tags["contest"] = soln.ContestId
+			ev.Tags["problem"] = soln.ProblemConfig.Label
+		}
+		if job.execConfig != nil {
+			ev.Tags["image"] = job.execConfig.Image
+		}
+	}
+	return ev
+}
+
+// send 发送事件
+func (r *errorReporter) send(ev *sentryEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), errorReportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("error report service returned %s", res.Status)
+	}
+	return nil
+}
+
+// captureError 记录评测的内部错误：计入题目的错误告警，配置了 DSN 时异步上报，kind 区分来源（如 job、adapter）
+func (m *Manager) captureError(job *Job, kind string, err error, extra map[string]any) {
+	if err == nil {
+		return
+	}
+	m.noteProblemError(job, err)
+	if m.errReports == nil {
+		return
+	}
+	ev := m.errReports.event(job, "error", kind, err.Error())
+	for k, v := range extra {
+		ev.Extra[k] = v
+	}
+	m.goBackground(func() {
+		if err := m.errReports.send(ev); err != nil {
+			log.Printf("Failed to send error report: %v", err)
+		}
+	})
+}
+
+// capturePanic 在 run 中 defer 调用：同步上报 panic 与调用栈后继续 panic，保持原有的崩溃行为
+func (m *Manager) capturePanic(job *Job) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if m.errReports != nil {
+		ev := m.errReports.event(job, "fatal", "panic", fmt.Sprint(r))
+		ev.Extra["stack"] = string(debug.Stack())
+		if err := m.errReports.send(ev); err != nil {
+			log.Printf("Failed to send panic report: %v", err)
+		}
+	}
+	panic(r)
+}
@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+// runExecAdapter 运行外部 adapter 解析报告。adapter 输出的 Patch/Detail 等消息直接转发给 AOI，
+// Complete 消息表示报告已处理完毕（最终的 Complete 仍由 report 统一发送）
+func (m *Manager) runExecAdapter(job *Job, adapter, reportPath string) (bool, error) {
+	dir := ""
+	if m.conf.AdapterDir != nil {
+		dir = *m.conf.AdapterDir
+	}
+	path, err := adapters.ResolveExec(adapter, dir)
+	if err != nil {
+		return false, err
+	}
+
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"SOLUTION_ID=" + job.SolutionID,
+		"TASK_ID=" + job.TaskID,
+		"PROBLEM_LABEL=" + job.soln.ProblemConfig.Label,
+		"EXIT_CODE=" + strconv.Itoa(job.result.ExitCode),
+	}
+	if vars, ok := job.execConfig.Env["JUDGE_VARIABLES"]; ok {
+		env = append(env, "JUDGE_VARIABLES="+vars)
+	}
+
+	completed := false
+	err = m.adapterLimits().RunExec(job.ctx, path, reportPath, env, func(line string) {
+		msg, err := judgerproto.MessageFromString(line)
+		if err != nil {
+			return
+		}
+		if msg.Action == judgerproto.ActionComplete {
+			completed = true
+			return
+		}
+		m.processMessage(line, job.aoi)
+	}, func(line string) {
+		slog.Info(fmt.Sprintf("[%s adapter] %s", job.SolutionID, line), "solution", job.SolutionID, "source", "adapter")
+	})
+	return completed, err
+}
@@ -0,0 +1,226 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	flakyWindow           = 50  // 每个测试保留的最近结果数
+	flakyMinSamples       = 10  // 样本数不足时不判定
+	defaultFlakyThreshold = 0.3 // 默认翻转率阈值
+)
+
+// FlakyTest 疑似不稳定的测试
+type FlakyTest struct {
+	Label    string
+	NodeID   string
+	Samples  int
+	FlipRate float64
+}
+
+// flakyTracker 按题目记录每个测试最近的通过/失败序列，
+// 用相邻两次结果不同的比例（翻转率）衡量测试是否不稳定
+type flakyTracker struct {
+	path      string
+	threshold float64
+
+	mu       sync.Mutex
+	problems map[string]map[string][]bool
+}
+
+// flakyThreshold 返回判定不稳定测试的翻转率阈值
+func (m *Manager) flakyThreshold() float64 {
+	if m.conf.FlakyThreshold != nil && *m.conf.FlakyThreshold > 0 {
+		return *m.conf.FlakyThreshold
+	}
+	return defaultFlakyThreshold
+}
+
+func flakyPath(workDir string) string {
+	return filepath.Join(workDir, "flaky.json")
+}
+
+// loadFlaky 加载测试结果记录，文件不存在时返回空记录
+func loadFlaky(path string, threshold float64) (*flakyTracker, error) {
+	f := &flakyTracker{path: path, threshold: threshold, problems: make(map[string]map[string][]bool)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.problems); err != nil {
+		log.Printf("Ignoring corrupt flaky file %s: %v", path, err)
+		f.problems = make(map[string]map[string][]bool)
+	}
+	return f, nil
+}
+
+// record 记录一次评测中各测试的结果并保存，跳过的测试不计入。持有锁写入文件
+func (f *flakyTracker) record(label string, report *adapters.PytestReport) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tests := f.problems[label]
+	if tests == nil {
+		tests = make(map[string][]bool)
+		f.problems[label] = tests
+	}
+	for _, t := range report.Tests {
+		var passed bool
+		switch t.Outcome {
+		case "passed":
+			passed = true
+		case "failed", "error":
+		default:
+			continue
+		}
+		wasFlaky := f.isFlakyLocked(label, t.NodeID)
+		results := append(tests[t.NodeID], passed)
+		if len(results) > flakyWindow {
+			results = results[len(results)-flakyWindow:]
+		}
+		tests[t.NodeID] = results
+		if !wasFlaky && f.isFlakyLocked(label, t.NodeID) {
+			log.Printf("Test %s of problem %s flagged as flaky (flip rate %.0f%%)", t.NodeID, label, flipRate(results)*100)
+		}
+	}
+	data, err := json.Marshal(f.problems)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(f.path, data); err != nil {
+		log.Printf("Failed to save flaky file: %v", err)
+	}
+}
+
+func flipRate(results []bool) float64 {
+	if len(results) < 2 {
+		return 0
+	}
+	flips := 0
+	for i := 1; i < len(results); i++ {
+		if results[i] != results[i-1] {
+			flips++
+		}
+	}
+	return float64(flips) / float64(len(results)-1)
+}
+
+func (f *flakyTracker) isFlakyLocked(label, nodeID string) bool {
+	results := f.problems[label][nodeID]
+	return len(results) >= flakyMinSamples && flipRate(results) >= f.threshold
+}
+
+// isFlaky 判断测试是否被标记为不稳定
+func (f *flakyTracker) isFlaky(label, nodeID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.isFlakyLocked(label, nodeID)
+}
+
+// flagged 返回所有被标记为不稳定的测试，按翻转率从高到低排序
+func (f *flakyTracker) flagged() []*FlakyTest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*FlakyTest
+	for label, tests := range f.problems {
+		for nodeID, results := range tests {
+			if f.isFlakyLocked(label, nodeID) {
+				result = append(result, &FlakyTest{
+					Label:    label,
+					NodeID:   nodeID,
+					Samples:  len(results),
+					FlipRate: flipRate(results),
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FlipRate != result[j].FlipRate {
+			return result[i].FlipRate > result[j].FlipRate
+		}
+		return result[i].Label+result[i].NodeID < result[j].Label+result[j].NodeID
+	})
+	return result
+}
+
+// retryFlakyTests 对本次失败且被标记为不稳定的测试重新运行一次容器，
+// 只运行这些测试，重试通过的结果覆盖原结果后重新计分。没有需要重试的测试时返回 nil
+func (m *Manager) retryFlakyTests(job *Job, report *adapters.PytestReport, reportPatterns []string) (*adapters.LFS1Result, error) {
+	if m.conf.FlakyRetry == nil || !*m.conf.FlakyRetry {
+		return nil, nil
+	}
+	label := job.soln.ProblemConfig.Label
+	var retry []string
+	for _, t := range report.Tests {
+		if (t.Outcome == "failed" || t.Outcome == "error") && m.flaky.isFlaky(label, t.NodeID) {
+			retry = append(retry, t.NodeID)
+		}
+	}
+	if len(retry) == 0 {
+		return nil, nil
+	}
+	log.Printf("Solution %s: retrying %d flaky test(s): %s", job.SolutionID, len(retry), strings.Join(retry, " "))
+
+	retryDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-retry-%s-", job.SolutionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retry dir: %w", err)
+	}
+	job.addCleanup(func() { os.RemoveAll(retryDir) })
+	if err := m.prepareSharedDir(retryDir, job.execConfig.User); err != nil {
+		return nil, err
+	}
+
+	// 复制执行配置，替换输出目录并指定要重跑的测试
+	config := *job.execConfig
+	config.Env = make(map[string]string, len(job.execConfig.Env)+1)
+	for k, v := range job.execConfig.Env {
+		config.Env[k] = v
+	}
+	config.Env["JUDGE_RETRY_TESTS"] = strings.Join(retry, " ")
+	config.Mounts = make([]executor.Mount, len(job.execConfig.Mounts))
+	for i, mount := range job.execConfig.Mounts {
+		if mount.Target == "/output" {
+			mount.Source = retryDir
+		}
+		config.Mounts[i] = mount
+	}
+
+	ctx, cancel := context.WithTimeout(job.execCtx, config.Timeout+10*time.Second)
+	defer cancel()
+	result, err := job.exec.Execute(ctx, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run retry container: %w", err)
+	}
+	if result.TimedOut || result.OOM {
+		return nil, fmt.Errorf("retry container did not finish (timed out: %v, oom: %v)", result.TimedOut, result.OOM)
+	}
+
+	reportPaths := findReports(retryDir, reportPatterns)
+	if len(reportPaths) == 0 {
+		return nil, fmt.Errorf("retry container produced no report")
+	}
+	return m.adapterLimits().RunAll(job.ctx, reportPaths, func(paths []string) (*adapters.LFS1Result, error) {
+		retried, err := adapters.ParsePytestReports(paths)
+		if err != nil {
+			return nil, err
+		}
+		recovered := adapters.MergeRetry(report, retried)
+		log.Printf("Solution %s: %d of %d flaky test(s) passed on retry", job.SolutionID, recovered, len(retry))
+		return adapters.CalculateScore(report, job.rc.Score), nil
+	})
+}
@@ -0,0 +1,337 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	defaultGCDiskPath      = "/var/lib/docker"
+	defaultGCHighWatermark = 0.85
+	defaultGCLowWatermark  = 0.70
+	defaultGCTempTTL       = 6 * time.Hour
+	gcTimeout              = 10 * time.Minute
+	danglingGracePeriod    = time.Minute // 刚退出的容器可能仍在由评测流程清理
+)
+
+// tempDirPrefixes 评测期间在 work-dir 中创建的临时目录前缀，格式为 <prefix><solution>-<random>
+var tempDirPrefixes = []string{"judge-", "problem-data-", "hook-"}
+
+// imageUsage 记录本 runner 最后一次使用各评测镜像的时间，GC 据此按 LRU 删除镜像。
+// 每个 runner 只写自己的文件，GC 时合并同一 work-dir 下全部 runner 的记录
+type imageUsage struct {
+	path string
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func imageUsagePath(workDir string) string {
+	return filepath.Join(workDir, "image-usage.json")
+}
+
+func loadImageUsage(path string) (*imageUsage, error) {
+	u := &imageUsage{path: path, last: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &u.last); err != nil {
+		log.Printf("Ignoring corrupt image usage file %s: %v", path, err)
+	}
+	return u, nil
+}
+
+// touch 记录镜像被评测使用
+func (u *imageUsage) touch(image string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.last[normalizeImage(image)] = time.Now()
+	data, err := json.Marshal(u.last)
+	if err == nil {
+		err = os.WriteFile(u.path, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Failed to save image usage: %v", err)
+	}
+}
+
+// recent 返回 since 之后使用过的镜像，按最近使用时间从新到旧排序
+func (u *imageUsage) recent(since time.Time) []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var images []string
+	for image, t := range u.last {
+		if t.After(since) {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return u.last[images[i]].After(u.last[images[j]]) })
+	return images
+}
+
+// allImageUsage 合并同一根目录下全部 runner 的镜像使用记录，取最近的使用时间
+func (m *Manager) allImageUsage() map[string]time.Time {
+	merged := make(map[string]time.Time)
+	paths, _ := filepath.Glob(filepath.Join(filepath.Dir(m.workDir()), "*", filepath.Base(imageUsagePath(""))))
+	for _, path := range paths {
+		u, err := loadImageUsage(path)
+		if err != nil {
+			continue
+		}
+		for image, t := range u.last {
+			if t.After(merged[image]) {
+				merged[image] = t
+			}
+		}
+	}
+	return merged
+}
+
+// normalizeImage 统一镜像引用的写法，gcc、gcc:latest 与 docker.io/library/gcc:latest 视为同一镜像
+func normalizeImage(ref string) string {
+	for _, prefix := range []string{"docker.io/library/", "docker.io/"} {
+		if rest, ok := strings.CutPrefix(ref, prefix); ok {
+			ref = rest
+			break
+		}
+	}
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	if !strings.Contains(name, ":") && !strings.Contains(name, "@") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+// diskUsage 返回 path 所在文件系统的使用率
+func diskUsage(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	total := uint64(st.Blocks) * uint64(st.Bsize)
+	if total == 0 {
+		return 0, nil
+	}
+	free := uint64(st.Bavail) * uint64(st.Bsize)
+	return 1 - float64(free)/float64(total), nil
+}
+
+// gcLoop 定期回收磁盘空间直到 ctx 结束
+func (m *Manager) gcLoop(ctx context.Context) {
+	if m.conf.GCInterval == nil || *m.conf.GCInterval <= 0 {
+		return
+	}
+	for {
+		gcCtx, cancel := context.WithTimeout(ctx, gcTimeout)
+		m.collectGarbage(gcCtx)
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*m.conf.GCInterval):
+		}
+	}
+}
+
+// collectGarbage 清理过期的临时目录与残留的已退出容器；
+// 磁盘使用率超过高水位时按最近使用时间删除评测镜像，直到低于低水位
+func (m *Manager) collectGarbage(ctx context.Context) {
+	m.removeStaleTempDirs()
+	for _, h := range m.hosts.all() {
+		m.removeDanglingContainers(ctx, h)
+	}
+	m.evictCompilerCaches(ctx)
+
+	path := defaultGCDiskPath
+	if m.conf.GCDiskPath != nil && *m.conf.GCDiskPath != "" {
+		path = *m.conf.GCDiskPath
+	}
+	high, low := defaultGCHighWatermark, defaultGCLowWatermark
+	if m.conf.GCHighWatermark != nil && *m.conf.GCHighWatermark > 0 {
+		high = *m.conf.GCHighWatermark
+	}
+	if m.conf.GCLowWatermark != nil && *m.conf.GCLowWatermark > 0 {
+		low = min(*m.conf.GCLowWatermark, high)
+	}
+	usage, err := diskUsage(path)
+	if err != nil {
+		log.Printf("GC: failed to measure disk usage of %s: %v", path, err)
+		return
+	}
+	if usage < high {
+		return
+	}
+	m.alert("disk", path, fmt.Sprintf("disk usage of %s is %.1f%%, above %.1f%%, pruning judge images", path, usage*100, high*100))
+	// 磁盘使用率只能在本机测量，远程主机上的镜像由该主机上的 runner 清理
+	for _, h := range m.hosts.all() {
+		if h.name == "local" || strings.HasPrefix(h.name, "unix://") {
+			usage = m.pruneImages(ctx, h, path, low)
+		}
+	}
+	if usage >= high {
+		m.alert("disk-full", path, fmt.Sprintf("disk usage of %s is still %.1f%% after pruning judge images", path, usage*100))
+	}
+}
+
+// removeStaleTempDirs 删除不属于运行中评测且超过 gc-temp-ttl 未修改的临时目录，
+// 通常是 runner 异常退出时遗留的
+func (m *Manager) removeStaleTempDirs() {
+	ttl := defaultGCTempTTL
+	if m.conf.GCTempTTL != nil && *m.conf.GCTempTTL > 0 {
+		ttl = *m.conf.GCTempTTL
+	}
+	m.runningMu.Lock()
+	running := make([]string, 0, len(m.running))
+	for id := range m.running {
+		running = append(running, id)
+	}
+	m.runningMu.Unlock()
+
+	for _, root := range m.tempRoots() {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			m.removeStaleTempDir(root, entry, running, ttl)
+		}
+	}
+}
+
+// removeStaleTempDir 删除单个过期的临时目录
+func (m *Manager) removeStaleTempDir(root string, entry os.DirEntry, running []string, ttl time.Duration) {
+	name := entry.Name()
+	if !entry.IsDir() || !hasAnyPrefix(name, tempDirPrefixes) {
+		return
+	}
+	if slices.ContainsFunc(running, func(id string) bool { return strings.Contains(name, "-"+id+"-") }) {
+		return
+	}
+	info, err := entry.Info()
+	if err != nil || time.Since(info.ModTime()) < ttl {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+		log.Printf("GC: failed to remove stale temp dir %s: %v", name, err)
+		return
+	}
+	log.Printf("GC: removed stale temp dir %s", name)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDanglingContainers 删除本 runner 创建、已退出且不属于运行中评测的容器
+func (m *Manager) removeDanglingContainers(ctx context.Context, h *dockerHost) {
+	containers, err := h.exec.ListContainers(ctx, map[string]string{executor.LabelRunnerID: *m.conf.RunnerID})
+	if err != nil {
+		log.Printf("GC: failed to list containers on %s: %v", h.name, err)
+		return
+	}
+	for _, c := range containers {
+		if c.Running || time.Since(c.Created) < danglingGracePeriod {
+			continue
+		}
+		m.runningMu.Lock()
+		_, running := m.running[c.Labels[executor.LabelSolutionID]]
+		m.runningMu.Unlock()
+		if running {
+			continue
+		}
+		if err := h.exec.Cleanup(ctx, c.ID); err != nil {
+			log.Printf("GC: failed to remove dangling container %s on %s: %v", c.ID, h.name, err)
+			continue
+		}
+		log.Printf("GC: removed dangling container %s on %s", c.ID, h.name)
+	}
+}
+
+// pruneImages 按最近使用时间从旧到新删除评测用过的镜像，直到磁盘使用率低于 low，返回最后测得的使用率。
+// 只删除出现在使用记录中的镜像，运行中评测的镜像与其他镜像不受影响
+func (m *Manager) pruneImages(ctx context.Context, h *dockerHost, path string, low float64) float64 {
+	usage, _ := diskUsage(path)
+	images, err := h.exec.ListImages(ctx)
+	if err != nil {
+		log.Printf("GC: failed to list images on %s: %v", h.name, err)
+		return usage
+	}
+
+	lastUse := m.allImageUsage()
+	inUse := make(map[string]bool)
+	m.runningMu.Lock()
+	for _, job := range m.running {
+		if job.execConfig != nil {
+			inUse[normalizeImage(job.execConfig.Image)] = true
+		}
+	}
+	m.runningMu.Unlock()
+
+	type candidate struct {
+		image executor.ImageInfo
+		last  time.Time
+	}
+	var candidates []candidate
+	for _, image := range images {
+		var c candidate
+		tracked, busy := false, false
+		for _, tag := range image.Tags {
+			ref := normalizeImage(tag)
+			busy = busy || inUse[ref]
+			if t, ok := lastUse[ref]; ok {
+				tracked = true
+				if t.After(c.last) {
+					c.last = t
+				}
+			}
+		}
+		if tracked && !busy {
+			c.image = image
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].last.Before(candidates[j].last) })
+
+	for _, c := range candidates {
+		if usage < low {
+			break
+		}
+		removed := true
+		for _, tag := range c.image.Tags {
+			if err := h.exec.RemoveImage(ctx, tag); err != nil {
+				log.Printf("GC: %v", err)
+				removed = false
+			}
+		}
+		if removed {
+			log.Printf("GC: removed image %s on %s, last used %s, %.1f MB",
+				strings.Join(c.image.Tags, ","), h.name, c.last.Format(time.RFC3339), float64(c.image.Size)/(1<<20))
+		}
+		if u, err := diskUsage(path); err == nil {
+			usage = u
+		}
+	}
+	return usage
+}
@@ -0,0 +1,263 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const gpuWaitInterval = 2 * time.Second
+
+// GPUConfig GPU 评测配置
+type GPUConfig struct {
+	Count int          `json:"count"` // 需要的 GPU 数量，默认 1
+	Smoke *SmokeConfig `json:"smoke"` // GPU 全忙时先在 CPU 上运行的快速检查
+
+	Fraction float64  `json:"fraction"` // 只使用单个 GPU 的一部分（如 0.25），按 runner 的 gpu-slots 向上取整为份数，0 表示独占
+	MaxWait  Duration `json:"max_wait"` // GPU 全忙时的最长等待时间，超过后放弃评测，0 表示一直等待
+}
+
+// SmokeConfig CPU 预检配置，结果仅作为阶段性反馈，不计入最终成绩
+type SmokeConfig struct {
+	Tests   []string `json:"tests"`   // 预检运行的测试 nodeid，为空时运行全部测试
+	Timeout int64    `json:"timeout"` // 预检超时（秒），默认 60
+}
+
+// gpuAllocator 通过锁文件分配 GPU，同一主机上的多个 manager 实例共享同一组锁。
+// 每个设备划分为 slots 份，独占整个 GPU 的任务锁定全部份，部分 GPU 的任务只锁定所需的份数
+type gpuAllocator struct {
+	devices []string
+	slots   int
+	lockDir string
+}
+
+func newGPUAllocator(devices string, slots int, lockDir string) (*gpuAllocator, error) {
+	a := &gpuAllocator{slots: max(slots, 1), lockDir: lockDir}
+	for _, d := range strings.Split(devices, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			a.devices = append(a.devices, d)
+		}
+	}
+	if err := os.MkdirAll(lockDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create gpu lock dir: %w", err)
+	}
+	return a, nil
+}
+
+// lockPath 返回设备第 slot 份的锁文件，不划分时沿用整个设备的锁文件
+func (a *gpuAllocator) lockPath(id string, slot int) string {
+	if a.slots == 1 {
+		return filepath.Join(a.lockDir, "gpu-"+id+".lock")
+	}
+	return filepath.Join(a.lockDir, fmt.Sprintf("gpu-%s-%d.lock", id, slot))
+}
+
+// tryLockDevice 尝试锁定设备中空闲的 shares 份，不足时释放已锁定的部分并返回 nil
+func (a *gpuAllocator) tryLockDevice(id string, shares int) []*os.File {
+	var files []*os.File
+	for slot := 0; slot < a.slots && len(files) < shares; slot++ {
+		f, err := os.OpenFile(a.lockPath(id, slot), os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			continue
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) < shares {
+		unlockFiles(files)
+		return nil
+	}
+	return files
+}
+
+func unlockFiles(files []*os.File) {
+	for _, f := range files {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+}
+
+// tryAllocate 尝试立即分配 n 个设备、每个设备 shares 份，不足时返回 false。
+// 按设备顺序选择第一个满足的设备，部分 GPU 的任务集中在靠前的设备上，尽量保留完整的空闲设备
+func (a *gpuAllocator) tryAllocate(n, shares int) ([]string, func(), bool) {
+	var ids []string
+	var files []*os.File
+	for _, id := range a.devices {
+		if len(ids) == n {
+			break
+		}
+		locked := a.tryLockDevice(id, shares)
+		if locked == nil {
+			continue
+		}
+		ids = append(ids, id)
+		files = append(files, locked...)
+	}
+	if len(ids) < n {
+		unlockFiles(files)
+		return nil, nil, false
+	}
+	return ids, func() { unlockFiles(files) }, true
+}
+
+// allocate 分配 n 个设备、每个设备 shares 份，全忙时等待直到有空闲设备
+func (a *gpuAllocator) allocate(ctx context.Context, n, shares int) ([]string, func(), error) {
+	if n > len(a.devices) {
+		return nil, nil, fmt.Errorf("need %d GPUs but only %d configured", n, len(a.devices))
+	}
+	for {
+		if ids, release, ok := a.tryAllocate(n, shares); ok {
+			return ids, release, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(gpuWaitInterval):
+		}
+	}
+}
+
+// shares 计算申请所需的份数，fraction 为 0 时独占整个设备
+func (a *gpuAllocator) shares(gpu *GPUConfig) (int, error) {
+	if gpu.Fraction == 0 {
+		return a.slots, nil
+	}
+	if gpu.Fraction < 0 || gpu.Fraction > 1 || gpu.Count > 1 {
+		return 0, fmt.Errorf("gpu fraction must be in (0, 1] and requires a single GPU")
+	}
+	return max(int(math.Ceil(gpu.Fraction*float64(a.slots))), 1), nil
+}
+
+// acquireGPUs 为任务分配 GPU 并写入执行配置，返回释放函数。
+// GPU 全忙且配置了预检时，先在 CPU 上运行预检并上报阶段性结果，再等待 GPU；
+// 配置了 max_wait 时超时后放弃评测
+func (m *Manager) acquireGPUs(job *Job, gpu *GPUConfig) (func(), error) {
+	if m.gpus == nil {
+		return nil, fmt.Errorf("problem requires GPUs but none are configured on this runner")
+	}
+	n := max(gpu.Count, 1)
+	if n > len(m.gpus.devices) {
+		return nil, fmt.Errorf("need %d GPUs but only %d configured", n, len(m.gpus.devices))
+	}
+	shares, err := m.gpus.shares(gpu)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, release, ok := m.gpus.tryAllocate(n, shares)
+	if !ok {
+		log.Printf("Solution %s: all GPUs busy", job.SolutionID)
+		if gpu.Smoke != nil {
+			if err := m.runSmoke(job, gpu.Smoke); err != nil {
+				log.Printf("Solution %s: CPU smoke run failed: %v", job.SolutionID, err)
+			}
+		}
+		ctx := job.ctx
+		if gpu.MaxWait > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(job.ctx, time.Duration(gpu.MaxWait))
+			defer cancel()
+		}
+		ids, release, err = m.gpus.allocate(ctx, n, shares)
+		if err != nil {
+			if job.ctx.Err() == nil {
+				return nil, fmt.Errorf("no GPU became free within %s", time.Duration(gpu.MaxWait))
+			}
+			return nil, err
+		}
+	}
+	log.Printf("Solution %s: allocated GPU(s) %s (%d/%d share(s) each)", job.SolutionID, strings.Join(ids, ","), shares, m.gpus.slots)
+	job.execConfig.GPUDevices = ids
+	return release, nil
+}
+
+// runSmoke 不使用 GPU 运行预检，并将结果作为阶段性结果上报
+func (m *Manager) runSmoke(job *Job, smoke *SmokeConfig) error {
+	if err := m.ensureImage(job, job.execConfig.Image); err != nil {
+		return err
+	}
+
+	smokeDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-smoke-%s-", job.SolutionID))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(smokeDir)
+	if err := m.prepareSharedDir(smokeDir, job.execConfig.User); err != nil {
+		return err
+	}
+
+	config := *job.execConfig
+	config.Timeout = time.Duration(smoke.Timeout) * time.Second
+	if config.Timeout <= 0 {
+		config.Timeout = 60 * time.Second
+	}
+	config.Timeout = m.clampTimeout(job.SolutionID, config.Timeout)
+	config.Env = make(map[string]string, len(job.execConfig.Env)+2)
+	for k, v := range job.execConfig.Env {
+		config.Env[k] = v
+	}
+	config.Env["JUDGE_SMOKE"] = "1"
+	if len(smoke.Tests) > 0 {
+		config.Env["JUDGE_RETRY_TESTS"] = strings.Join(smoke.Tests, " ")
+	}
+	config.Mounts = nil
+	for _, mount := range job.execConfig.Mounts {
+		if mount.Target == "/output" {
+			mount.Source = smokeDir
+		}
+		config.Mounts = append(config.Mounts, mount)
+	}
+
+	job.aoi.SolutionClient.Patch(job.ctx, &aoiclient.SolutionInfo{
+		Status:  "Running",
+		Message: "GPU 繁忙，正在进行 CPU 预检",
+	})
+
+	ctx, cancel := context.WithTimeout(job.execCtx, config.Timeout+10*time.Second)
+	defer cancel()
+	result, err := job.exec.Execute(ctx, &config)
+	if err != nil {
+		return err
+	}
+
+	message := "CPU 预检未生成报告，等待 GPU 完整评测"
+	var details *aoiclient.SolutionDetails
+	if result.TimedOut {
+		message = "CPU 预检超时，等待 GPU 完整评测"
+	} else if reportPaths := findReports(smokeDir, reportPatterns(job.rc)); job.soln.ProblemConfig.Judge.Adapter == "lfs1" && len(reportPaths) > 0 {
+		smokeResult, err := m.adapterLimits().RunAll(job.ctx, reportPaths, func(paths []string) (*adapters.LFS1Result, error) {
+			report, err := adapters.ParsePytestReports(paths)
+			if err != nil {
+				return nil, err
+			}
+			return adapters.CalculateScore(report, job.rc.Score), nil
+		})
+		if err == nil {
+			message = fmt.Sprintf("CPU 预检：%s，等待 GPU 完整评测", smokeResult.Message)
+			details = smokeResult.Details
+		}
+	}
+
+	// 阶段性结果不带分数，最终结果由 GPU 评测给出
+	job.aoi.SolutionClient.Patch(job.ctx, &aoiclient.SolutionInfo{
+		Status:  "Running",
+		Message: message,
+	})
+	if details != nil {
+		details.Summary = "CPU 预检结果（非最终成绩）\n" + details.Summary
+		job.aoi.SolutionClient.SaveDetails(job.ctx, details)
+	}
+	return nil
+}
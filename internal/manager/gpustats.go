@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// gpuUsage 评测容器运行期间所分配 GPU 的使用情况。按设备采样，部分 GPU 的任务包含同一设备上其他任务的占用
+type gpuUsage struct {
+	PeakMemory     int64   // 各设备显存占用之和的峰值（MB）
+	AvgUtilization float64 // 各设备平均利用率（%）在运行期间的平均值
+	Energy         float64 // 各设备功率之和按采样间隔积分得到的能耗（J）
+	Samples        int
+}
+
+// gpuSample 一次采样的结果
+type gpuSample struct {
+	utils  []float64 // 各设备利用率（%）
+	memory int64     // 显存占用之和（MB）
+	power  float64   // 功率之和（W）
+}
+
+// queryGPUs 通过 nvidia-smi 读取设备的利用率、显存占用与功率。
+// MIG 实例不提供利用率与功率，此时只统计显存
+func queryGPUs(ctx context.Context, ids []string) (*gpuSample, error) {
+	out, err := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=utilization.gpu,memory.used,power.draw", "--format=csv,noheader,nounits",
+		"-i", strings.Join(ids, ",")).Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	sample := &gpuSample{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64); err == nil {
+			sample.utils = append(sample.utils, v)
+		}
+		if v, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64); err == nil {
+			sample.memory += v
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64); err == nil {
+			sample.power += v
+		}
+	}
+	return sample, nil
+}
+
+// gpuSampleInterval 返回 GPU 指标的采样间隔，0 表示不采样
+func (m *Manager) gpuSampleInterval() time.Duration {
+	if m.conf.GPUSampleInterval == nil {
+		return 0
+	}
+	return *m.conf.GPUSampleInterval
+}
+
+// watchGPUs 在评测容器运行期间定期采样分配的 GPU，停止时将结果记录到 job.gpuUsage。
+// 返回停止采样的函数
+func (m *Manager) watchGPUs(job *Job) func() {
+	ids := job.execConfig.GPUDevices
+	interval := m.gpuSampleInterval()
+	if len(ids) == 0 || interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(job.ctx)
+	done := make(chan struct{})
+	var usage gpuUsage
+	var utilSum float64
+	var utilSamples int
+	var lastPower float64
+	var lastTime time.Time
+	go func() {
+		defer close(done)
+		for {
+			sample, err := queryGPUs(ctx, ids)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Solution %s: stop sampling GPU metrics: %v", job.SolutionID, err)
+				}
+				return
+			}
+			now := time.Now()
+			if usage.Samples > 0 {
+				usage.Energy += lastPower * now.Sub(lastTime).Seconds()
+			}
+			lastPower, lastTime = sample.power, now
+			usage.Samples++
+			usage.PeakMemory = max(usage.PeakMemory, sample.memory)
+			if len(sample.utils) > 0 {
+				var sum float64
+				for _, u := range sample.utils {
+					sum += u
+				}
+				utilSum += sum / float64(len(sample.utils))
+				utilSamples++
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		if usage.Samples == 0 {
+			return
+		}
+		usage.Energy += lastPower * time.Since(lastTime).Seconds()
+		if utilSamples > 0 {
+			usage.AvgUtilization = utilSum / float64(utilSamples)
+		}
+		job.gpuUsage = &usage
+		log.Printf("Solution %s used peak GPU memory %d MB, average GPU utilization %.1f%%", job.SolutionID, usage.PeakMemory, usage.AvgUtilization)
+	}
+}
+
+// withGPUMetrics 返回附带 GPU 指标的结果副本
+func withGPUMetrics(info *aoiclient.SolutionInfo, usage *gpuUsage) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	metrics["gpu_peak_memory_mb"] = float64(usage.PeakMemory)
+	metrics["gpu_utilization_avg"] = usage.AvgUtilization
+
+	copied := *info
+	copied.Metrics = &metrics
+	return &copied
+}
+
+// withGPUSummary 返回在摘要末尾附带 GPU 使用说明的详情副本
+func withGPUSummary(details *aoiclient.SolutionDetails, usage *gpuUsage) *aoiclient.SolutionDetails {
+	copied := *details
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += fmt.Sprintf("GPU 使用：峰值显存 %d MB，平均利用率 %.1f%%", usage.PeakMemory, usage.AvgUtilization)
+	return &copied
+}
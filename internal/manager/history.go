@@ -0,0 +1,277 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const (
+	historyWindow      = 200 // 每道题保留的最近样本数
+	minEstimateSamples = 5   // 用于准入估计的最少样本数
+)
+
+// runSample 单次评测的运行记录
+type runSample struct {
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration"`
+	PeakMemory int64         `json:"peakMemory"`
+	Status     string        `json:"status"`
+	Failed     bool          `json:"failed"`              // runner 侧失败（非选手原因）
+	QueueTime  time.Duration `json:"queueTime,omitempty"` // 从收到任务到开始运行的时间
+
+	GPUPeakMemory  int64   `json:"gpuPeakMemory,omitempty"`  // 峰值显存（MB）
+	GPUUtilization float64 `json:"gpuUtilization,omitempty"` // 平均 GPU 利用率（%）
+}
+
+// ProblemStats 单道题的运行统计
+type ProblemStats struct {
+	Label       string        `json:"label"`
+	Samples     int           `json:"samples"`
+	P50         time.Duration `json:"p50"`
+	P95         time.Duration `json:"p95"`
+	P50Memory   int64         `json:"p50Memory"`
+	P95Memory   int64         `json:"p95Memory"`
+	P95Queue    time.Duration `json:"p95Queue"`
+	AcceptRate  float64       `json:"acceptRate"`
+	FailureRate float64       `json:"failureRate"`
+}
+
+// recentResult 最近完成的一次评测
+type recentResult struct {
+	Label string `json:"label"`
+	runSample
+}
+
+// history 按题目标签保存最近的运行记录，持久化到本地文件
+type history struct {
+	path string
+
+	mu       sync.Mutex
+	problems map[string][]runSample
+}
+
+func historyPath(workDir string) string {
+	return filepath.Join(workDir, "stats.json")
+}
+
+// loadHistory 加载历史统计，文件不存在时返回空记录
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path, problems: make(map[string][]runSample)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h.problems); err != nil {
+		log.Printf("Ignoring corrupt stats file %s: %v", path, err)
+		h.problems = make(map[string][]runSample)
+	}
+	return h, nil
+}
+
+// record 追加一条记录并保存。持有锁写入文件，保证后写入的快照不会被先前的覆盖
+func (h *history) record(label string, sample runSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := append(h.problems[label], sample)
+	if len(samples) > historyWindow {
+		samples = samples[len(samples)-historyWindow:]
+	}
+	h.problems[label] = samples
+	data, err := json.Marshal(h.problems)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(h.path, data); err != nil {
+		log.Printf("Failed to save stats file: %v", err)
+	}
+}
+
+func percentile[T int64 | time.Duration](sorted []T, p float64) T {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// stats 计算单道题的统计数据
+func (h *history) stats(label string) *ProblemStats {
+	h.mu.Lock()
+	samples := slices.Clone(h.problems[label])
+	h.mu.Unlock()
+
+	ps := &ProblemStats{Label: label, Samples: len(samples)}
+	if len(samples) == 0 {
+		return ps
+	}
+	durations := make([]time.Duration, 0, len(samples))
+	memories := make([]int64, 0, len(samples))
+	queues := make([]time.Duration, 0, len(samples))
+	var accepted, failed int
+	for _, s := range samples {
+		durations = append(durations, s.Duration)
+		memories = append(memories, s.PeakMemory)
+		if s.QueueTime > 0 {
+			queues = append(queues, s.QueueTime)
+		}
+		if s.Status == aoiclient.StatusAccepted {
+			accepted++
+		}
+		if s.Failed {
+			failed++
+		}
+	}
+	slices.Sort(durations)
+	slices.Sort(memories)
+	slices.Sort(queues)
+	ps.P50 = percentile(durations, 0.5)
+	ps.P95 = percentile(durations, 0.95)
+	ps.P50Memory = percentile(memories, 0.5)
+	ps.P95Memory = percentile(memories, 0.95)
+	ps.P95Queue = percentile(queues, 0.95)
+	ps.AcceptRate = float64(accepted) / float64(len(samples))
+	ps.FailureRate = float64(failed) / float64(len(samples))
+	return ps
+}
+
+// all 返回所有题目的统计，按标签排序
+func (h *history) all() []*ProblemStats {
+	h.mu.Lock()
+	labels := make([]string, 0, len(h.problems))
+	for label := range h.problems {
+		labels = append(labels, label)
+	}
+	h.mu.Unlock()
+	sort.Strings(labels)
+
+	result := make([]*ProblemStats, 0, len(labels))
+	for _, label := range labels {
+		result = append(result, h.stats(label))
+	}
+	return result
+}
+
+// recent 返回所有题目中最近完成的 n 次评测，按时间倒序
+func (h *history) recent(n int) []recentResult {
+	h.mu.Lock()
+	var results []recentResult
+	for label, samples := range h.problems {
+		for _, s := range samples {
+			results = append(results, recentResult{Label: label, runSample: s})
+		}
+	}
+	h.mu.Unlock()
+
+	slices.SortFunc(results, func(a, b recentResult) int {
+		return b.Time.Compare(a.Time)
+	})
+	if len(results) > n {
+		results = results[:n]
+	}
+	return results
+}
+
+// estimate 返回题目的预计运行时间（p95），无记录时返回 false
+func (h *history) estimate(label string) (time.Duration, bool) {
+	ps := h.stats(label)
+	if ps.Samples == 0 {
+		return 0, false
+	}
+	return ps.P95, true
+}
+
+// memoryEstimate 返回题目峰值内存的 p95（MB），样本不足时返回 false
+func (h *history) memoryEstimate(label string) (int64, bool) {
+	ps := h.stats(label)
+	if ps.Samples < minEstimateSamples || ps.P95Memory <= 0 {
+		return 0, false
+	}
+	return (ps.P95Memory + 1<<20 - 1) >> 20, true
+}
+
+// recordJob 作为状态转移回调，在任务结束时记录运行数据
+func (m *Manager) recordJob(job *Job, from, to State) {
+	// 迁移的评测在恢复后才记录完整的运行数据
+	if !to.Terminal() || to == StateCheckpointed {
+		return
+	}
+	sample := runSample{
+		Time:      time.Now(),
+		Duration:  time.Since(job.aoi.receivedAt),
+		Failed:    to == StateFailed,
+		QueueTime: job.queueTime,
+	}
+	if info, _ := job.aoi.verdict(); info != nil {
+		sample.Status = info.Status
+		if info.Status == aoiclient.StatusInternalError || info.Status == aoiclient.StatusError {
+			sample.Failed = true
+		}
+	}
+	if job.result != nil && job.result.Usage != nil {
+		sample.PeakMemory = job.result.Usage.PeakMemory
+	}
+	if job.gpuUsage != nil {
+		sample.GPUPeakMemory = job.gpuUsage.PeakMemory
+		sample.GPUUtilization = job.gpuUsage.AvgUtilization
+	}
+	m.history.record(job.soln.ProblemConfig.Label, sample)
+}
+
+// PrintStats 输出本 runner 的历史运行统计，供容量规划使用
+func PrintStats(conf *config.ManagerConfig, w io.Writer) error {
+	if err := validateRunnerID(*conf.RunnerID); err != nil {
+		return err
+	}
+	m := NewManager(conf)
+	h, err := loadHistory(historyPath(m.workDir()))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROBLEM\tSAMPLES\tP50\tP95\tP50 MEM (MB)\tACCEPT\tFAILURE")
+	for _, ps := range h.all() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%.1f\t%.1f%%\t%.1f%%\n",
+			ps.Label, ps.Samples, ps.P50.Round(time.Millisecond), ps.P95.Round(time.Millisecond),
+			float64(ps.P50Memory)/(1<<20), ps.AcceptRate*100, ps.FailureRate*100)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	f, err := loadFlaky(flakyPath(m.workDir()), m.flakyThreshold())
+	if err != nil {
+		return err
+	}
+	flagged := f.flagged()
+	if len(flagged) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "PROBLEM\tFLAKY TEST\tSAMPLES\tFLIP RATE")
+	for _, ft := range flagged {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f%%\n", ft.Label, ft.NodeID, ft.Samples, ft.FlipRate*100)
+	}
+	return tw.Flush()
+}
+
+// logEstimate 在开始评测时输出基于历史数据的预计运行时间
+func (m *Manager) logEstimate(job *Job) {
+	if d, ok := m.history.estimate(job.soln.ProblemConfig.Label); ok {
+		log.Printf("Solution %s: expected runtime up to %s (p95 of problem %s)", job.SolutionID, d.Round(time.Second), job.soln.ProblemConfig.Label)
+	}
+}
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	hostProbeTimeout  = 5 * time.Second  // 选择主机时查询守护进程状态的超时
+	hostUnhealthyTime = 30 * time.Second // 守护进程无响应后暂停分配的时间
+)
+
+// dockerHost 主机池中的一个 Docker 守护进程
+type dockerHost struct {
+	name string
+	exec executor.Executor
+
+	running        int       // 本实例在该主机上运行中的任务数
+	unhealthyUntil time.Time // 在此之前不分配任务
+}
+
+// hostPool 由一个 manager 驱动的多个 Docker 守护进程，每个任务放置在负载最低的健康主机上。
+// 任务的全部容器与网络都在同一主机上创建；远程主机需以相同路径挂载 work-dir 与缓存目录
+type hostPool struct {
+	mu    sync.Mutex
+	hosts []*dockerHost
+}
+
+// newHostPool 为每个主机地址创建执行器，hosts 为空时只使用默认的守护进程
+func newHostPool(hosts []string, newExecutor func(host string) (executor.Executor, error)) (*hostPool, error) {
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	p := &hostPool{}
+	for _, host := range hosts {
+		exec, err := newExecutor(host)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("docker host %s: %w", host, err)
+		}
+		name := host
+		if name == "" {
+			name = "local"
+		}
+		p.hosts = append(p.hosts, &dockerHost{name: name, exec: exec})
+	}
+	return p, nil
+}
+
+// parseDockerHosts 解析逗号分隔的主机地址列表
+func parseDockerHosts(s string) []string {
+	var hosts []string
+	for _, host := range strings.Split(s, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// primary 返回第一个主机，用于不属于具体任务的操作
+func (p *hostPool) primary() *dockerHost {
+	return p.hosts[0]
+}
+
+// all 返回全部主机
+func (p *hostPool) all() []*dockerHost {
+	return p.hosts
+}
+
+// hostLoad 主机负载，按本实例的任务数优先、守护进程上每核运行的容器数其次比较
+type hostLoad struct {
+	host    *dockerHost
+	running int
+	perCPU  float64
+}
+
+func (l hostLoad) less(o hostLoad) bool {
+	if l.running != o.running {
+		return l.running < o.running
+	}
+	return l.perCPU < o.perCPU
+}
+
+// acquire 选择负载最低的健康主机并计入一个运行中的任务，调用方结束后须调用 release。
+// 查询失败的守护进程在一段时间内不再参与分配
+func (p *hostPool) acquire(ctx context.Context) (*dockerHost, error) {
+	p.mu.Lock()
+	// 只有一个主机时无需探测，失败由后续的 Docker 调用报告
+	if len(p.hosts) == 1 {
+		h := p.hosts[0]
+		h.running++
+		p.mu.Unlock()
+		return h, nil
+	}
+	now := time.Now()
+	var candidates []hostLoad
+	for _, h := range p.hosts {
+		if now.After(h.unhealthyUntil) {
+			candidates = append(candidates, hostLoad{host: h, running: h.running})
+		}
+	}
+	p.mu.Unlock()
+
+	var best *hostLoad
+	for i := range candidates {
+		c := &candidates[i]
+		probeCtx, cancel := context.WithTimeout(ctx, hostProbeTimeout)
+		info, err := c.host.exec.Info(probeCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Docker host %s is unhealthy: %v", c.host.name, err)
+			p.mu.Lock()
+			c.host.unhealthyUntil = time.Now().Add(hostUnhealthyTime)
+			p.mu.Unlock()
+			continue
+		}
+		c.perCPU = float64(info.ContainersRunning) / float64(max(info.NCPU, 1))
+		if best == nil || c.less(*best) {
+			best = c
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no healthy docker host available")
+	}
+	p.mu.Lock()
+	best.host.running++
+	p.mu.Unlock()
+	return best.host, nil
+}
+
+// release 任务结束，释放主机上的计数
+func (p *hostPool) release(h *dockerHost) {
+	p.mu.Lock()
+	h.running--
+	p.mu.Unlock()
+}
+
+// close 关闭全部执行器
+func (p *hostPool) close() error {
+	var firstErr error
+	for _, h := range p.hosts {
+		if err := h.exec.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,185 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIncidentCooldown      = 30 * time.Minute
+	defaultIncidentPollFailures  = 5
+	defaultIncidentProblemErrors = 3
+	incidentProblemWindow        = 10 * time.Minute // 统计同一题目内部错误的时间窗口
+	dockerHealthInterval         = 30 * time.Second
+	dockerHealthFailures         = 2 // 守护进程连续无响应多少次后告警
+)
+
+// 告警 webhook 的消息格式
+const (
+	IncidentFormatJSON   = "json"
+	IncidentFormatSlack  = "slack"
+	IncidentFormatFeishu = "feishu"
+)
+
+// incidents 运维告警的状态：按类别与对象抑制重复告警，并统计触发告警的连续失败
+type incidents struct {
+	mu            sync.Mutex
+	lastSent      map[string]time.Time   // kind/key -> 上次发送时间
+	pollFailures  int                    // 连续轮询失败次数
+	problemErrors map[string][]time.Time // 题目 -> 窗口内的内部错误时间
+}
+
+// incidentPayload 通用 JSON 格式的告警
+type incidentPayload struct {
+	RunnerID string    `json:"runnerId"`
+	Kind     string    `json:"kind"`
+	Key      string    `json:"key,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// alert 发出运维告警：记录日志，配置了 incident-webhook 时推送。
+// key 区分同一类别下的不同对象（如镜像、主机、题目），冷却时间内同一对象的重复告警只记录日志
+func (m *Manager) alert(kind, key, message string) {
+	slog.Warn(fmt.Sprintf("[ALERT %s] %s", kind, message), "alert", kind)
+	if m.conf.IncidentWebhook == nil || *m.conf.IncidentWebhook == "" {
+		return
+	}
+	cooldown := defaultIncidentCooldown
+	if m.conf.IncidentCooldown != nil && *m.conf.IncidentCooldown > 0 {
+		cooldown = *m.conf.IncidentCooldown
+	}
+	id := kind + "/" + key
+	now := time.Now()
+	m.incidents.mu.Lock()
+	if last, ok := m.incidents.lastSent[id]; ok && now.Sub(last) < cooldown {
+		m.incidents.mu.Unlock()
+		return
+	}
+	m.incidents.lastSent[id] = now
+	m.incidents.mu.Unlock()
+
+	payload := m.incidentPayload(&incidentPayload{
+		RunnerID: *m.conf.RunnerID,
+		Kind:     kind,
+		Key:      key,
+		Message:  message,
+		Time:     now,
+	})
+	m.goBackground(func() {
+		for _, url := range strings.Split(*m.conf.IncidentWebhook, ",") {
+			if url = strings.TrimSpace(url); url == "" {
+				continue
+			}
+			if err := m.postWebhook(url, payload); err != nil {
+				slog.Warn(fmt.Sprintf("Failed to send %s alert to webhook: %v", kind, err))
+			}
+		}
+	})
+}
+
+// incidentPayload 按 incident-webhook-format 生成消息体
+func (m *Manager) incidentPayload(p *incidentPayload) any {
+	format := IncidentFormatJSON
+	if m.conf.IncidentWebhookFormat != nil && *m.conf.IncidentWebhookFormat != "" {
+		format = *m.conf.IncidentWebhookFormat
+	}
+	text := fmt.Sprintf("[lfs-auto-grader] runner %s: %s", p.RunnerID, p.Message)
+	switch format {
+	case IncidentFormatSlack:
+		return map[string]string{"text": text}
+	case IncidentFormatFeishu:
+		return map[string]any{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+	}
+	return p
+}
+
+// validIncidentFormat 检查告警消息格式
+func validIncidentFormat(format string) bool {
+	switch format {
+	case "", IncidentFormatJSON, IncidentFormatSlack, IncidentFormatFeishu:
+		return true
+	}
+	return false
+}
+
+// notePollResult 记录一次轮询结果，连续失败达到 incident-poll-failures 次时告警
+func (m *Manager) notePollResult(err error) {
+	threshold := defaultIncidentPollFailures
+	if m.conf.IncidentPollFailures != nil && *m.conf.IncidentPollFailures > 0 {
+		threshold = *m.conf.IncidentPollFailures
+	}
+	m.incidents.mu.Lock()
+	if err == nil {
+		m.incidents.pollFailures = 0
+		m.incidents.mu.Unlock()
+		return
+	}
+	m.incidents.pollFailures++
+	count := m.incidents.pollFailures
+	m.incidents.mu.Unlock()
+	if count == threshold {
+		m.alert("poll", "", fmt.Sprintf("polling AOI failed %d times in a row: %v", count, err))
+	}
+}
+
+// noteProblemError 记录一次评测内部错误，同一题目在时间窗口内达到 incident-problem-errors 次时告警
+func (m *Manager) noteProblemError(job *Job, err error) {
+	if job == nil || job.soln == nil {
+		return
+	}
+	threshold := defaultIncidentProblemErrors
+	if m.conf.IncidentProblemErrors != nil && *m.conf.IncidentProblemErrors > 0 {
+		threshold = *m.conf.IncidentProblemErrors
+	}
+	label := job.soln.ProblemConfig.Label
+	now := time.Now()
+	m.incidents.mu.Lock()
+	recent := []time.Time{now}
+	for _, t := range m.incidents.problemErrors[label] {
+		if now.Sub(t) < incidentProblemWindow {
+			recent = append(recent, t)
+		}
+	}
+	m.incidents.problemErrors[label] = recent
+	m.incidents.mu.Unlock()
+	if len(recent) >= threshold {
+		m.alert("problem", label, fmt.Sprintf("problem %s hit %d internal errors within %s, latest on solution %s: %v",
+			label, len(recent), incidentProblemWindow, job.SolutionID, err))
+	}
+}
+
+// dockerHealthLoop 定期探测各 Docker 守护进程，连续无响应时告警
+func (m *Manager) dockerHealthLoop(ctx context.Context) {
+	failures := make(map[string]int)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dockerHealthInterval):
+		}
+		for _, h := range m.hosts.all() {
+			probeCtx, cancel := context.WithTimeout(ctx, hostProbeTimeout)
+			_, err := h.exec.Info(probeCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				failures[h.name] = 0
+				continue
+			}
+			failures[h.name]++
+			if failures[h.name] == dockerHealthFailures {
+				m.alert("docker", h.name, fmt.Sprintf("docker daemon %s is unavailable: %v", h.name, err))
+			}
+		}
+	}
+}
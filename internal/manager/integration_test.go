@@ -0,0 +1,106 @@
+//go:build integration
+
+package manager
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+)
+
+// newDockerTestEnv 与 newTestEnv 相同，但使用 DOCKER_HOST 指定的真实 Docker 守护进程
+func newDockerTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	srv := aoitest.NewServer()
+	t.Cleanup(srv.Close)
+
+	conf := &config.ManagerConfig{
+		Endpoint:        ptr(srv.URL),
+		RunnerID:        ptr("integration-runner"),
+		RunnerKey:       ptr("test-key"),
+		WorkDir:         ptr(t.TempDir()),
+		CacheDir:        ptr(t.TempDir()),
+		DockerHosts:     ptr(os.Getenv("DOCKER_HOST")),
+		PollMinInterval: ptr(50 * time.Millisecond),
+		PollMaxInterval: ptr(200 * time.Millisecond),
+	}
+	m := NewManager(conf)
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		m.Close()
+	})
+	return &testEnv{t: t, aoi: srv, m: m}
+}
+
+func integrationImage() string {
+	if image := os.Getenv("LFS_TEST_IMAGE"); image != "" {
+		return image
+	}
+	return executortest.DefaultImage
+}
+
+func dockerJudgeConfig(script string) map[string]any {
+	return map[string]any{
+		"image":       integrationImage(),
+		"docker_cmd":  []string{"sh", "-c", script},
+		"timeout":     20,
+		"memoryLimit": 128,
+	}
+}
+
+func TestDockerJudgeReportsScore(t *testing.T) {
+	env := newDockerTestEnv(t)
+	script := "echo running tests; cat > /output/report.json <<'EOF'\n" + passingReport + "\nEOF"
+	task := env.judge(aoitest.NewSolution("s1", "t1", "docker-hello", "lfs1", dockerJudgeConfig(script)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted || last.Score != 100 {
+		t.Fatalf("final status = %+v, want %q with score 100", last, aoiclient.StatusAccepted)
+	}
+}
+
+func TestDockerJudgeNonZeroExit(t *testing.T) {
+	env := newDockerTestEnv(t)
+	task := env.judge(aoitest.NewSolution("s1", "t1", "docker-exit", "lfs1", dockerJudgeConfig("exit 3")))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusRuntimeError {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusRuntimeError)
+	}
+}
+
+func TestDockerJudgeTimeout(t *testing.T) {
+	env := newDockerTestEnv(t)
+	conf := dockerJudgeConfig("sleep 60")
+	conf["timeout"] = 2
+	task := env.judge(aoitest.NewSolution("s1", "t1", "docker-timeout", "lfs1", conf))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusTimeLimitExceeded {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusTimeLimitExceeded)
+	}
+}
+
+func TestDockerJudgeMemoryLimit(t *testing.T) {
+	env := newDockerTestEnv(t)
+	conf := dockerJudgeConfig("head -c 512m /dev/zero | tail")
+	conf["memoryLimit"] = 32
+	task := env.judge(aoitest.NewSolution("s1", "t1", "docker-oom", "lfs1", conf))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusMemoryLimitExceeded {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusMemoryLimitExceeded)
+	}
+}
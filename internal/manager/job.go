@@ -0,0 +1,1078 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// State 评测生命周期状态
+type State string
+
+const (
+	StateClaimed   State = "Claimed"
+	StatePreparing State = "Preparing"
+	StatePulling   State = "Pulling"
+	StateRunning   State = "Running"
+	StateReporting State = "Reporting"
+	StateCompleted State = "Completed"
+	StateFailed    State = "Failed"
+
+	StateCheckpointed State = "Checkpointed" // 排空时保存为检查点，由其他 runner 恢复
+)
+
+// transitions 合法的状态转移，Completed、Failed 与 Checkpointed 为终止状态
+var transitions = map[State][]State{
+	StateClaimed:   {StatePreparing, StateFailed},
+	StatePreparing: {StatePulling, StateFailed},
+	StatePulling:   {StateRunning, StateFailed},
+	StateRunning:   {StateReporting, StateFailed, StateCheckpointed},
+	StateReporting: {StateCompleted, StateFailed},
+}
+
+// Terminal 是否为终止状态
+func (s State) Terminal() bool {
+	return s == StateCompleted || s == StateFailed || s == StateCheckpointed
+}
+
+// Transition 一次状态转移记录
+type Transition struct {
+	From State     `json:"from"`
+	To   State     `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// TransitionHook 状态转移回调，在转移完成并持久化后调用
+type TransitionHook func(job *Job, from, to State)
+
+const (
+	imagePullTimeout  = 30 * time.Minute // 评测期间拉取单个镜像的超时
+	dockerCallTimeout = 2 * time.Minute  // 创建容器、网络等 Docker 调用的超时
+)
+
+// Job 单次评测任务
+type Job struct {
+	SolutionID string       `json:"solutionId"`
+	TaskID     string       `json:"taskId"`
+	State      State        `json:"state"`
+	History    []Transition `json:"history"`
+
+	ctx             context.Context // 评测结束时取消，评测期间的 API 与 Docker 调用均以此为父上下文
+	execCtx         context.Context // 由 ctx 派生，评测被取消时一并取消，评测期间运行的容器均以此为父上下文
+	stopExec        context.CancelFunc
+	soln            *aoiclient.SolutionPoll
+	exec            executor.Executor // 任务所在主机的执行器
+	rc              *RunningConfig
+	aoi             *reporter
+	outputDir       string
+	execConfig      *executor.ExecuteConfig // 赋值时持有 Manager.runningMu，管理接口会并发读取
+	result          *executor.ExecuteResult
+	scopedBase      *adapters.PytestReport // 只运行失败测试时的上次完整结果
+	coreDir         string                 // core dump 挂载目录
+	compileSrc      string                 // 编译阶段的源码目录
+	compileBuild    string                 // 编译产物目录，只读挂载到评测容器
+	serviceIDs      []string               // 辅助服务容器，按启动顺序
+	runDuration     time.Duration          // 主评测容器的运行时间
+	queueTime       time.Duration          // 从收到任务到评测容器开始运行的时间
+	stepFailure     *stepFailure           // 设置了 fail_status 的步骤失败
+	checkpoint      *checkpointState       // 排空时正在保存的检查点
+	restore         *restoreState          // 从其他 runner 的检查点恢复
+	cancelled       atomic.Bool            // 平台要求中止评测
+	scratchExceeded atomic.Bool            // 输出目录超过 scratch-job-limit，评测容器已被终止
+	gpuUsage        *gpuUsage              // 评测容器运行期间的 GPU 使用情况
+	energy          *energyUsage           // 评测容器运行期间的能耗
+	perfRecorder    *perfRecorder          // 正在记录的性能计数，由 runningMu 保护
+	perf            *perfCounters          // 评测容器的性能计数
+	containerID     string                 // 运行中的主评测容器，由 Manager.runningMu 保护
+	cleanups        []func()
+}
+
+// addCleanup 注册评测结束时执行的清理函数，按注册的逆序执行
+func (j *Job) addCleanup(fn func()) {
+	j.cleanups = append(j.cleanups, fn)
+}
+
+func (j *Job) cleanup() {
+	for i := len(j.cleanups) - 1; i >= 0; i-- {
+		j.cleanups[i]()
+	}
+	j.cleanups = nil
+}
+
+// OnTransition 注册状态转移回调
+func (m *Manager) OnTransition(hook TransitionHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+func (m *Manager) jobsDir() string {
+	return filepath.Join(m.workDir(), "jobs")
+}
+
+func (m *Manager) jobRecordPath(job *Job) string {
+	return filepath.Join(m.jobsDir(), job.SolutionID+"-"+job.TaskID+".json")
+}
+
+// transition 校验并执行状态转移，持久化后调用回调
+func (m *Manager) transition(job *Job, to State) error {
+	from := job.State
+	if !slices.Contains(transitions[from], to) {
+		return fmt.Errorf("invalid job state transition %s -> %s", from, to)
+	}
+	// 管理接口会并发读取状态
+	m.runningMu.Lock()
+	job.State = to
+	job.History = append(job.History, Transition{From: from, To: to, Time: time.Now()})
+	m.runningMu.Unlock()
+	log.Printf("Solution %s: %s -> %s", job.SolutionID, from, to)
+
+	if err := m.persistJob(job); err != nil {
+		log.Printf("Failed to persist job state for solution %s: %v", job.SolutionID, err)
+	}
+	for _, hook := range m.hooks {
+		hook(job, from, to)
+	}
+	return nil
+}
+
+// persistJob 将任务状态写入本地，终止状态时删除记录
+func (m *Manager) persistJob(job *Job) error {
+	path := m.jobRecordPath(job)
+	if job.State.Terminal() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// recoverJobs 将上次运行遗留的未完成任务以 Internal Error 上报给 AOI 后删除记录，
+// 上报失败时保留记录，下次启动时重试
+func (m *Manager) recoverJobs() error {
+	if err := os.MkdirAll(m.jobsDir(), 0o755); err != nil {
+		return fmt.Errorf("failed to create jobs dir: %w", err)
+	}
+	entries, err := os.ReadDir(m.jobsDir())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(m.jobsDir(), entry.Name())
+		// 写入中断遗留的临时文件
+		if filepath.Ext(entry.Name()) != ".json" {
+			os.Remove(path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		job := &Job{}
+		if err != nil || json.Unmarshal(data, job) != nil || job.SolutionID == "" {
+			log.Printf("Removing unreadable job record %s", entry.Name())
+			os.Remove(path)
+			continue
+		}
+		log.Printf("Found interrupted job: solution %s, task %s, last state %s", job.SolutionID, job.TaskID, job.State)
+		if err := m.reportInterrupted(job); err != nil {
+			log.Printf("Failed to report interrupted solution %s, keeping its record: %v", job.SolutionID, err)
+			continue
+		}
+		os.Remove(path)
+	}
+	return nil
+}
+
+// reportInterrupted 将 runner 重启前未完成的评测标记为失败
+func (m *Manager) reportInterrupted(job *Job) error {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Minute)
+	defer cancel()
+	reason := fmt.Sprintf("评测在 %s 阶段因 runner 重启而中断", job.State)
+	soln := m.aoi.Solution(job.SolutionID, job.TaskID)
+	if err := soln.Patch(ctx, &aoiclient.SolutionInfo{
+		Score:   0,
+		Status:  aoiclient.StatusInternalError,
+		Message: reason,
+	}); err != nil {
+		return err
+	}
+	if err := soln.SaveDetails(ctx, &aoiclient.SolutionDetails{Summary: reason}); err != nil {
+		return err
+	}
+	return soln.Complete(ctx)
+}
+
+// markFailed 将任务转为失败状态。转移失败时仍删除本地记录，
+// 结果已由调用方上报，避免下次启动时被当作中断的任务重复上报
+func (m *Manager) markFailed(job *Job) {
+	if err := m.transition(job, StateFailed); err != nil {
+		log.Printf("Solution %s: %v", job.SolutionID, err)
+		os.Remove(m.jobRecordPath(job))
+	}
+}
+
+// run 按生命周期驱动一次评测，restore 不为 nil 时从检查点恢复
+func (m *Manager) run(soln *aoiclient.SolutionPoll, restore *restoreState) error {
+	// 不随 Close 取消：停止时进行中的评测仍需完成上报
+	ctx, cancel := context.WithCancel(context.Background())
+	execCtx, stopExec := context.WithCancel(ctx)
+	job := &Job{
+		SolutionID: soln.SolutionId,
+		TaskID:     soln.TaskId,
+		State:      StateClaimed,
+		soln:       soln,
+		ctx:        ctx,
+		execCtx:    execCtx,
+		stopExec:   stopExec,
+		aoi:        m.newReporter(ctx, soln),
+		restore:    restore,
+	}
+	defer cancel()
+	defer job.cleanup()
+	defer m.capturePanic(job)
+	// 在清理输出目录之前、最终结果上报之后录制
+	defer m.recordPayload(job)
+	if restore != nil {
+		// 再次被迁移时检查点目录由下一个 runner 负责清理
+		job.addCleanup(func() {
+			if job.State != StateCheckpointed {
+				os.RemoveAll(restore.dir)
+			}
+		})
+	}
+
+	m.runningMu.Lock()
+	m.running[job.SolutionID] = job
+	m.runningMu.Unlock()
+	defer func() {
+		m.runningMu.Lock()
+		delete(m.running, job.SolutionID)
+		m.runningMu.Unlock()
+	}()
+	defer m.watchCancellation(job)()
+	// 先于清理临时目录删除遗留的容器
+	defer m.reapJobContainers(job)
+
+	// 选择负载最低的 Docker 主机，任务的全部容器与网络都在该主机上创建
+	host, err := m.hosts.acquire(m.ctx)
+	if err != nil {
+		m.failSoln(job.aoi, aoiclient.StatusInternalError, "Failed to run solution: "+err.Error())
+		return err
+	}
+	defer m.hosts.release(host)
+	job.exec = host.exec
+	if len(m.hosts.all()) > 1 {
+		log.Printf("Solution %s placed on docker host %s", job.SolutionID, host.name)
+	}
+
+	if err := m.persistJob(job); err != nil {
+		log.Printf("Failed to persist job state for solution %s: %v", job.SolutionID, err)
+	}
+
+	steps := []struct {
+		state State
+		fn    func(*Job) error
+	}{
+		{StatePreparing, m.prepare},
+		{StatePulling, m.pull},
+		{StateRunning, m.execute},
+		{StateReporting, m.report},
+	}
+	for _, step := range steps {
+		err := m.transition(job, step.state)
+		if err == nil {
+			err = step.fn(job)
+		}
+		// 出分之前收到的取消请求覆盖当前步骤的结果
+		if step.state != StateReporting && job.cancelled.Load() {
+			err = errCancelled
+		}
+		if errors.Is(err, errCheckpointed) {
+			log.Printf("Solution %s checkpointed to %s", job.SolutionID, job.checkpoint.dir)
+			return m.transition(job, StateCheckpointed)
+		}
+		if errors.Is(err, errCancelled) {
+			m.markFailed(job)
+			m.failSoln(job.aoi, aoiclient.StatusCancelled, "评测已被取消")
+			log.Printf("Solution %s was cancelled", job.SolutionID)
+			return nil
+		}
+		if err != nil {
+			m.captureError(job, "job", err, nil)
+			m.markFailed(job)
+			// pre/post 阶段失败属于评测环境问题，与选手无关
+			status := aoiclient.StatusError
+			var phaseErr *phaseError
+			if errors.As(err, &phaseErr) {
+				status = aoiclient.StatusInternalError
+			}
+			m.failSoln(job.aoi, status, "Failed to run solution: "+err.Error())
+			return err
+		}
+	}
+	return m.transition(job, StateCompleted)
+}
+
+// prepare 解析配置、上报开始状态并准备目录与执行配置
+func (m *Manager) prepare(job *Job) error {
+	soln := job.soln
+	log.Printf("Starting evaluation for solution %s, task %s", soln.SolutionId, soln.TaskId)
+	m.logEstimate(job)
+
+	// 打印原始配置用于调试
+	slog.Debug(fmt.Sprintf("Raw judge config: %s", string(soln.ProblemConfig.Judge.Config)), "solution", soln.SolutionId)
+
+	// 解析评测配置
+	rc := new(RunningConfig)
+	if err := json.Unmarshal(soln.ProblemConfig.Judge.Config, rc); err != nil {
+		return fmt.Errorf("failed to parse judge config: %w", err)
+	}
+	job.rc = rc
+	job.aoi.setScorePolicy(rc.Score)
+	if rc.Aggregate != nil {
+		if err := rc.Aggregate.validate(soln.TaskId); err != nil {
+			return fmt.Errorf("invalid aggregate config: %w", err)
+		}
+		job.aoi.setAggregate(rc.Aggregate)
+	}
+	penalty, err := computeLatePenalty(soln, rc)
+	if err != nil {
+		return err
+	}
+	if penalty != nil {
+		log.Printf("Solution %s submitted %d hour(s) late, deducting %g%%", soln.SolutionId, penalty.hours, penalty.percent)
+		job.aoi.setLatePenalty(penalty)
+	}
+
+	// 打印解析后的配置用于调试
+	slog.Debug(fmt.Sprintf("Parsed config - Image: %s, DockerCmd: %v", rc.Image, rc.DockerCmd), "solution", soln.SolutionId)
+
+	// 创建临时目录用于存放评测报告
+	outputDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-output-%s-", soln.SolutionId))
+	if err != nil {
+		return fmt.Errorf("failed to create temp output dir: %w", err)
+	}
+	job.addCleanup(func() { os.RemoveAll(outputDir) }) // 评测完成后清理临时目录
+	job.outputDir = outputDir
+
+	log.Printf("Created temp output directory: %s", outputDir)
+
+	execConfig, err := m.buildExecuteConfig(job.ctx, soln, rc, outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to build execute config: %w", err)
+	}
+	m.runningMu.Lock()
+	job.execConfig = execConfig
+	m.runningMu.Unlock()
+	job.addCleanup(m.admission.commit(soln.SolutionId, m.estimateDemand(soln.ProblemConfig.Label, jobDemand(execConfig))))
+
+	// 恢复其他 runner 保存的检查点
+	if job.restore != nil {
+		if err := m.prepareRestore(job); err != nil {
+			return err
+		}
+	}
+
+	// 容器以非 root 用户运行，且 rootless / userns-remap 下存在 UID 映射，需调整属主才能写入报告
+	if err := m.prepareSharedDir(outputDir, execConfig.User); err != nil {
+		return fmt.Errorf("failed to prepare output dir: %w", err)
+	}
+	caches, err := m.resolveBuildCaches(soln, buildCacheConfigs(rc))
+	if err == nil {
+		err = m.prepareBuildCaches(caches, execConfig.User)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 分配独占核心
+	if rc.CPUPin != nil {
+		release, err := m.pinCPUs(job.ctx, soln.SolutionId, rc.CPUPin, execConfig)
+		if err != nil {
+			return fmt.Errorf("failed to pin cpus: %w", err)
+		}
+		job.addCleanup(release)
+	} else {
+		// 未绑核的评测避开绑核池与已分配的核心
+		execConfig.CpusetCpus = m.cpus.unpinned()
+	}
+
+	// 创建独立评测网络
+	if rc.IsolatedNetwork {
+		name, cleanup, err := m.createJobNetwork(job, false)
+		if err != nil {
+			return fmt.Errorf("failed to create job network: %w", err)
+		}
+		job.addCleanup(cleanup)
+		execConfig.Network = name
+	}
+
+	// 启动辅助服务容器，与评测容器共享私有网络
+	if len(rc.Services) > 0 {
+		cleanup, err := m.startServices(job, rc.Services)
+		if err != nil {
+			return err
+		}
+		job.addCleanup(cleanup)
+	}
+
+	// 在其他 runner 上启动 MPI worker
+	if rc.MPI != nil {
+		cleanup, err := m.startMPI(job, rc.MPI)
+		if err != nil {
+			return err
+		}
+		job.addCleanup(cleanup)
+	}
+
+	// 限制网络延迟与带宽
+	if rc.NetworkShaping != nil {
+		cleanup, err := m.applyNetworkShaping(job, rc.NetworkShaping)
+		if err != nil {
+			return err
+		}
+		job.addCleanup(cleanup)
+	}
+
+	// 挂载 core dump 目录
+	if rc.CoreDump != nil {
+		if err := m.prepareCoreDumps(job); err != nil {
+			return err
+		}
+	}
+
+	// 只重跑该用户上次未通过的测试
+	if rc.RerunFailedOnly {
+		m.scopeFailedTests(job)
+	}
+
+	// 准备独立编译阶段的源码与产物目录
+	if rc.Compile != nil {
+		if err := m.prepareCompile(job, rc.Compile); err != nil {
+			return err
+		}
+	}
+
+	// 在沙箱中运行学生提供的 hook
+	if rc.StudentHook != nil {
+		if err := m.runStudentHook(job, rc.StudentHook); err != nil {
+			return err
+		}
+	}
+
+	// 挂载预解压的题目数据
+	if rc.ProblemData != nil {
+		dataDir, err := m.prepareProblemData(job.ctx, soln, rc.ProblemData, execConfig)
+		if dataDir != "" {
+			job.addCleanup(func() { os.RemoveAll(dataDir) })
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prepare problem data: %w", err)
+		}
+	}
+
+	// 分配 GPU，全忙时可能先进行 CPU 预检并等待
+	if rc.GPU != nil {
+		release, err := m.acquireGPUs(job, rc.GPU)
+		if err != nil {
+			return fmt.Errorf("failed to acquire gpus: %w", err)
+		}
+		job.addCleanup(release)
+	}
+	return nil
+}
+
+// pull 确保评测镜像可用
+func (m *Manager) pull(job *Job) error {
+	if err := m.ensureImage(job, job.execConfig.Image); err != nil {
+		return fmt.Errorf("failed to prepare image %s: %w", job.execConfig.Image, err)
+	}
+	if job.rc.Compile != nil {
+		if err := m.ensureImage(job, job.rc.Compile.Image); err != nil {
+			return fmt.Errorf("failed to prepare compile image %s: %w", job.rc.Compile.Image, err)
+		}
+	}
+	return nil
+}
+
+// ensureImage 确保任务所在主机上存在镜像，拉取时间超过 imagePullTimeout 时放弃
+func (m *Manager) ensureImage(job *Job, image string) error {
+	ctx, cancel := context.WithTimeout(job.ctx, imagePullTimeout)
+	defer cancel()
+	m.images.touch(image)
+	return job.exec.EnsureImage(ctx, image)
+}
+
+// execute 运行评测容器
+func (m *Manager) execute(job *Job) error {
+	// 上报评测开始状态，镜像准备完毕后才算真正开始
+	if err := job.aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+		Status:  "Running",
+		Message: "评测开始",
+	}); err != nil {
+		log.Printf("Failed to patch running status: %v", err)
+	}
+	job.queueTime = time.Since(job.aoi.receivedAt)
+	m.observeColdStart(job.execConfig.Image, job.queueTime)
+
+	// 容器输出同时上传到 AOI，便于排查失败的提交
+	logs := m.newLogUploader(job.aoi)
+	defer logs.close()
+	local := m.openLocalLog(job, "")
+	defer local.close()
+
+	// 预处理阶段，单独计时；从检查点恢复时预处理已在原 runner 上完成，结果随输出目录迁移
+	if len(job.rc.PreCmd) > 0 && job.restore == nil {
+		if err := m.runPhase(job, "pre", job.rc.PreCmd, job.rc.PreTimeout); err != nil {
+			return err
+		}
+	}
+
+	// 独立的编译阶段，编译失败时不再运行评测容器
+	if job.rc.Compile != nil {
+		if err := m.runCompile(job, job.rc.Compile); err != nil {
+			return err
+		}
+		if job.stepFailure != nil {
+			return nil
+		}
+	}
+
+	// 设置超时上下文，额外增加 10 秒缓冲时间
+	ctx, cancel := context.WithTimeout(job.execCtx, job.execConfig.Timeout+10*time.Second)
+	defer cancel()
+
+	// 执行评测容器
+	start := time.Now()
+	onLog := func(stream executor.LogStream, line string) error {
+		if stream == executor.Stderr {
+			// 标准错误仅记录与上传，不作为协议消息解析
+			slog.Info(fmt.Sprintf("[%s stderr] %s", job.SolutionID, line), "solution", job.SolutionID, "source", "container", "stream", "stderr")
+			logs.write("[stderr] " + line)
+			local.write("[stderr] " + line)
+			return nil
+		}
+		slog.Info(fmt.Sprintf("[%s] %s", job.SolutionID, line), "solution", job.SolutionID, "source", "container", "stream", "stdout")
+		logs.write(line)
+		local.write(line)
+		m.processMessage(line, job.aoi)
+		return nil
+	}
+	warm := m.acquireWarm(job)
+	stopWatch := m.watchScratch(job)
+	stopGPUs := m.watchGPUs(job)
+	stopEnergy := m.measureEnergy(job)
+	stopPerf := m.watchPerf(job)
+	var result *executor.ExecuteResult
+	var err error
+	if len(job.rc.Steps) > 0 {
+		result, err = m.runSteps(ctx, job, onLog)
+	} else if warm != nil {
+		result, err = m.runWarm(ctx, job, warm, onLog)
+	} else {
+		result, err = m.runMain(ctx, job, onLog)
+	}
+	job.runDuration = time.Since(start)
+	stopWatch()
+	stopGPUs()
+	stopEnergy()
+	stopPerf()
+
+	// 排空时容器已保存为检查点并停止
+	m.runningMu.Lock()
+	cp := job.checkpoint
+	m.runningMu.Unlock()
+	if cp != nil {
+		<-cp.done
+		if cp.err == nil {
+			if err := m.saveCheckpoint(job); err != nil {
+				return fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			return errCheckpointed
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("docker execution failed: %w", err)
+	}
+	job.result = result
+
+	// 后处理阶段
+	if len(job.rc.PostCmd) > 0 {
+		if err := m.runPhase(job, "post", job.rc.PostCmd, job.rc.PostTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMain 运行主评测容器并记录容器 ID，以便排空时保存检查点；从检查点恢复失败时从头重新评测，
+// 重新评测使用新的超时
+func (m *Manager) runMain(ctx context.Context, job *Job, onLog executor.LogCallback) (*executor.ExecuteResult, error) {
+	run := func(ctx context.Context) (*executor.ExecuteResult, error) {
+		config := *job.execConfig
+		config.OnStart = func(id string) { m.trackContainer(job, id) }
+		defer m.setContainer(job, "")
+		return job.exec.ExecuteWithLogs(ctx, &config, onLog)
+	}
+	result, err := run(ctx)
+	if err != nil && job.execConfig.RestoreFrom != "" && ctx.Err() == nil {
+		log.Printf("Solution %s: failed to restore checkpoint, rejudging from scratch: %v", job.SolutionID, err)
+		if err := m.abandonRestore(job); err != nil {
+			return nil, err
+		}
+		// 输出目录已清空，补跑恢复时跳过的预处理阶段
+		if len(job.rc.PreCmd) > 0 {
+			if err := m.runPhase(job, "pre", job.rc.PreCmd, job.rc.PreTimeout); err != nil {
+				return nil, err
+			}
+		}
+		ctx, cancel := context.WithTimeout(job.execCtx, job.execConfig.Timeout+10*time.Second)
+		defer cancel()
+		return run(ctx)
+	}
+	return result, err
+}
+
+// setContainer 记录评测运行中的主评测容器
+func (m *Manager) setContainer(job *Job, id string) {
+	m.runningMu.Lock()
+	job.containerID = id
+	m.runningMu.Unlock()
+}
+
+// reportFileName 返回评测报告文件名（默认为 report.json），report_name 为列表时取第一项
+func reportFileName(rc *RunningConfig) string {
+	return reportPatterns(rc)[0]
+}
+
+// reportErrorMessage 返回报告处理失败时展示的信息，报告结构有误时给出具体问题，便于出题人排查
+func reportErrorMessage(err error) string {
+	var reportErr *adapters.ReportError
+	if errors.As(err, &reportErr) {
+		return err.Error()
+	}
+	return fmt.Sprintf("解析评测报告失败: %v", err)
+}
+
+// reportPatterns 返回 lfs1 评测报告的文件名列表，report_name 可以是字符串或字符串列表，
+// 每一项都可以是通配符（如 "stage-*.json"），多次 pytest 运行的报告合并计分
+func reportPatterns(rc *RunningConfig) []string {
+	if rc.Variables != nil {
+		switch v := rc.Variables["report_name"].(type) {
+		case string:
+			if v != "" {
+				return []string{v}
+			}
+		case []any:
+			var patterns []string
+			for _, item := range v {
+				if name, ok := item.(string); ok && name != "" {
+					patterns = append(patterns, name)
+				}
+			}
+			if len(patterns) > 0 {
+				return patterns
+			}
+		}
+	}
+	return []string{"report.json"}
+}
+
+// findReports 在 dir 中查找匹配的报告文件，按模式顺序排列，同一模式内按文件名排序；
+// 忽略 dir 之外的路径
+func findReports(dir string, patterns []string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			log.Printf("Invalid report pattern %q: %v", pattern, err)
+			continue
+		}
+		for _, path := range matches {
+			if rel, err := filepath.Rel(dir, path); err != nil || !filepath.IsLocal(rel) {
+				continue
+			}
+			if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() || seen[path] {
+				continue
+			}
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// adapterReportFileName 返回报告类 adapter 的报告文件名，未指定 report_name 时使用 adapter 的默认值
+func adapterReportFileName(rc *RunningConfig, ra adapters.ReportAdapter) string {
+	if rc.Variables != nil {
+		if reportName, ok := rc.Variables["report_name"].(string); ok && reportName != "" {
+			return reportName
+		}
+	}
+	return ra.ReportName
+}
+
+// report 根据执行结果与评测报告上报最终结果
+func (m *Manager) report(job *Job) error {
+	soln, rc, aoi := job.soln, job.rc, job.aoi
+	result, execConfig := job.result, job.execConfig
+
+	if result.Usage != nil {
+		aoi.setUsage(result.Usage)
+		log.Printf("Solution %s used peak memory %d bytes, cpu time %s", soln.SolutionId, result.Usage.PeakMemory, result.Usage.CPUTime)
+	}
+	if job.gpuUsage != nil {
+		aoi.setGPUUsage(job.gpuUsage)
+	}
+	if job.energy != nil {
+		aoi.setEnergy(job.energy)
+	}
+	if job.perf != nil {
+		aoi.setPerfCounters(job.perf)
+	}
+	if stats := collectCompilerCacheStats(job.rc, job.outputDir); stats != nil {
+		aoi.setCacheStats(stats)
+		log.Printf("Solution %s: ccache hits %d, misses %d", soln.SolutionId, stats.Hits, stats.Misses)
+	}
+
+	m.collectCoreDumps(job)
+	m.checkSimilarity(job)
+
+	// 输出超限优先于超时：truncate 策略下持续输出的程序通常会运行到超时
+	if result.OutputLimitExceeded {
+		log.Printf("Solution %s exceeded the output limit", soln.SolutionId)
+		summary := fmt.Sprintf("标准输出与标准错误总量超过 %d MB，评测已被终止", execConfig.OutputLimit>>20)
+		if execConfig.OutputDrain {
+			summary = fmt.Sprintf("标准输出与标准错误总量超过 %d MB，超出部分已被丢弃", execConfig.OutputLimit>>20)
+		}
+		aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Score:   0,
+			Status:  aoiclient.StatusOutputLimitExceeded,
+			Message: fmt.Sprintf("输出超限（限制 %d MB）", execConfig.OutputLimit>>20),
+		})
+		aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+			Summary: summary,
+		})
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	// 处理特殊情况
+	if result.CPUTimeExceeded {
+		log.Printf("Solution %s exceeded the cpu time limit", soln.SolutionId)
+		aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Score:   0,
+			Status:  aoiclient.StatusTimeLimitExceeded,
+			Message: fmt.Sprintf("CPU 时间超限（限制 %s 秒）", formatSeconds(execConfig.CPUTimeLimit)),
+		})
+		aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+			Summary: fmt.Sprintf("CPU 时间超限，所有进程与线程累计的 CPU 时间限制 %s 秒", formatSeconds(execConfig.CPUTimeLimit)),
+		})
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	if result.TimedOut {
+		log.Printf("Solution %s timed out", soln.SolutionId)
+		limit := "限制"
+		if execConfig.CPUTimeLimit > 0 {
+			// 同时限制了 CPU 时间时注明触发的是墙钟时间
+			limit = "墙钟时间限制"
+		}
+		aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Score:   0,
+			Status:  aoiclient.StatusTimeLimitExceeded,
+			Message: fmt.Sprintf("评测超时（%s %s 秒）", limit, formatSeconds(execConfig.Timeout)),
+		})
+		aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+			Summary: fmt.Sprintf("评测超时，%s %s 秒", limit, formatSeconds(execConfig.Timeout)),
+		})
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	if result.OOM {
+		killed := describeOOMKills(result.OOMKills)
+		log.Printf("Solution %s ran out of memory, killed: %s", soln.SolutionId, killed)
+		message := fmt.Sprintf("内存超限（限制 %d MB）", execConfig.MemoryLimit)
+		summary := fmt.Sprintf("内存超限，内存限制 %d MB", execConfig.MemoryLimit)
+		if killed != "" {
+			message = fmt.Sprintf("内存超限（限制 %d MB，%s 被终止）", execConfig.MemoryLimit, killed)
+			summary += "\n被 OOM killer 终止的进程：" + killed
+		}
+		aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Score:   0,
+			Status:  aoiclient.StatusMemoryLimitExceeded,
+			Message: message,
+		})
+		aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+			Summary: summary,
+		})
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	if job.scratchExceeded.Load() {
+		limit := m.scratchJobLimit() >> 20
+		log.Printf("Solution %s exceeded the output dir limit", soln.SolutionId)
+		aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Score:   0,
+			Status:  aoiclient.StatusOutputLimitExceeded,
+			Message: fmt.Sprintf("输出目录超限（限制 %d MB）", limit),
+		})
+		aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+			Summary: fmt.Sprintf("写入输出目录的文件总量超过 %d MB，评测已被终止", limit),
+		})
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	// 评测容器自行编译时，编译失败写入标记文件
+	if job.stepFailure == nil {
+		if output, ok := readCompileError(job.outputDir, rc); ok {
+			job.stepFailure = &stepFailure{
+				step:     compileStepName,
+				status:   aoiclient.StatusCompileError,
+				exitCode: result.ExitCode,
+				output:   output,
+			}
+		}
+	}
+	if job.stepFailure != nil {
+		m.reportStepFailure(job)
+		aoi.Complete(job.ctx)
+		return nil
+	}
+
+	log.Printf("Solution %s finished with exit code %d", soln.SolutionId, result.ExitCode)
+
+	// 从外部读取并解析评测报告
+	reportProcessed := false
+	adapter := soln.ProblemConfig.Judge.Adapter
+
+	if len(rc.Adapters) > 0 {
+		log.Printf("Running %d adapters on %s", len(rc.Adapters), job.outputDir)
+		combined, err := m.runAdapterPipeline(job)
+		if err != nil {
+			log.Printf("Failed to parse report: %v", err)
+			m.captureError(job, "adapter", err, map[string]any{"adapter": "pipeline"})
+			aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+				Score:   0,
+				Status:  aoiclient.StatusInternalError,
+				Message: reportErrorMessage(err),
+			})
+		} else if combined != nil {
+			log.Printf("Reporting result: score=%.2f, status=%s", combined.Score, combined.Status)
+			info := &aoiclient.SolutionInfo{
+				Score:   combined.Score,
+				Status:  combined.Status,
+				Message: combined.Message,
+			}
+			if combined.Metrics != nil {
+				info.Metrics = &combined.Metrics
+			}
+			aoi.Patch(job.ctx, info)
+			aoi.SaveDetails(job.ctx, combined.Details)
+			reportProcessed = true
+		} else {
+			log.Printf("No report found for any of the %d adapters in %s", len(rc.Adapters), job.outputDir)
+		}
+	} else if adapter == "lfs1" {
+		patterns := reportPatterns(rc)
+		log.Printf("Looking for report(s) %s in %s", strings.Join(patterns, ", "), job.outputDir)
+
+		if reportPaths := findReports(job.outputDir, patterns); len(reportPaths) > 0 {
+			// 报告文件存在，解析并上报
+			log.Printf("Found %d report file(s), parsing with adapter: %s", len(reportPaths), adapter)
+
+			var raw, report *adapters.PytestReport
+			lfsResult, err := m.adapterLimits().RunAll(job.ctx, reportPaths, func(paths []string) (*adapters.LFS1Result, error) {
+				parsed, err := adapters.ParsePytestReports(paths)
+				if err != nil {
+					return nil, err
+				}
+				raw = parsed
+				// 只运行了上次失败的测试时，与上次的完整结果合并后计分
+				report = m.mergeScopedReport(job, parsed)
+				return adapters.CalculateScore(report, rc.Score), nil
+			})
+			if err == nil {
+				// 记录各测试结果，并重试失败的不稳定测试
+				m.flaky.record(soln.ProblemConfig.Label, raw)
+				retried, retryErr := m.retryFlakyTests(job, report, patterns)
+				if retryErr != nil {
+					log.Printf("Failed to retry flaky tests for solution %s: %v", soln.SolutionId, retryErr)
+				} else if retried != nil {
+					lfsResult = retried
+				}
+				if rc.RerunFailedOnly {
+					m.saveScopedReport(job, report)
+				}
+			}
+			if err != nil {
+				log.Printf("Failed to parse report: %v", err)
+				m.captureError(job, "adapter", err, map[string]any{"adapter": adapter})
+				aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+					Score:   0,
+					Status:  aoiclient.StatusInternalError,
+					Message: reportErrorMessage(err),
+				})
+			} else {
+				// 上报结果给 AOI
+				log.Printf("Reporting result: score=%.2f, status=%s", lfsResult.Score, lfsResult.Status)
+
+				aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+					Score:   lfsResult.Score,
+					Status:  lfsResult.Status,
+					Message: lfsResult.Message,
+				})
+
+				if lfsResult.Details != nil {
+					aoi.SaveDetails(job.ctx, lfsResult.Details)
+				}
+
+				reportProcessed = true
+			}
+		} else {
+			log.Printf("No report file matching %s found in %s", strings.Join(patterns, ", "), job.outputDir)
+		}
+	} else if ra, ok := adapters.ReportAdapters[adapter]; ok {
+		reportPath := filepath.Join(job.outputDir, adapterReportFileName(rc, ra))
+		log.Printf("Looking for report at: %s", reportPath)
+
+		if _, err := os.Stat(reportPath); err == nil {
+			log.Printf("Found report file, parsing with adapter: %s", adapter)
+			adapterResult, err := m.adapterLimits().Run(job.ctx, reportPath, func(path string) (*adapters.LFS1Result, error) {
+				return ra.Parse(path, rc.Variables)
+			})
+			if err != nil {
+				log.Printf("Failed to parse report: %v", err)
+				m.captureError(job, "adapter", err, map[string]any{"adapter": adapter})
+				aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+					Score:   0,
+					Status:  aoiclient.StatusInternalError,
+					Message: reportErrorMessage(err),
+				})
+			} else {
+				// adapter 按百分制计分，按题目的计分规则换算
+				adapterResult.Score = rc.Score.Rescale(adapterResult.Score)
+				log.Printf("Reporting result: score=%.2f, status=%s", adapterResult.Score, adapterResult.Status)
+				info := &aoiclient.SolutionInfo{
+					Score:   adapterResult.Score,
+					Status:  adapterResult.Status,
+					Message: adapterResult.Message,
+				}
+				if adapterResult.Metrics != nil {
+					info.Metrics = &adapterResult.Metrics
+				}
+				aoi.Patch(job.ctx, info)
+				if adapterResult.Details != nil {
+					aoi.SaveDetails(job.ctx, rc.Score.ScaleDetails(adapterResult.Details))
+				}
+				reportProcessed = true
+			}
+		} else {
+			log.Printf("Report file not found at %s: %v", reportPath, err)
+		}
+	} else if adapter == "proto" {
+		// 容器通过 judgerproto 自行上报结果与完成评测，不查找报告文件
+		reported, completed := aoi.protoState()
+		if completed {
+			log.Printf("Solution %s was completed by the container", soln.SolutionId)
+			return nil
+		}
+		reportProcessed = reported
+	} else if strings.HasPrefix(adapter, adapters.ExecPrefix) {
+		// 外部 adapter 读取报告并自行上报结果
+		reportPath := filepath.Join(job.outputDir, reportFileName(rc))
+		if _, err := os.Stat(reportPath); err == nil {
+			log.Printf("Running external adapter %s on %s", adapter, reportPath)
+			processed, err := m.runExecAdapter(job, adapter, reportPath)
+			if err != nil {
+				log.Printf("External adapter failed: %v", err)
+				m.captureError(job, "adapter", err, map[string]any{"adapter": adapter})
+				aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+					Score:   0,
+					Status:  aoiclient.StatusInternalError,
+					Message: reportErrorMessage(err),
+				})
+			}
+			reportProcessed = processed && err == nil
+		} else {
+			log.Printf("Report file not found at %s: %v", reportPath, err)
+		}
+	}
+
+	// 如果没有处理报告，按退出码映射或设置错误状态
+	if !reportProcessed {
+		missing, exitedMessage := "未找到评测报告", "评测容器正常退出但未生成评测报告"
+		if adapter == "proto" {
+			missing, exitedMessage = "评测容器未上报结果", "评测容器正常退出但未上报结果"
+		}
+		if status, ok := rc.ExitStatus[strconv.Itoa(result.ExitCode)]; ok {
+			log.Printf("Solution %s finished with exit code %d and no report, mapped to %s", soln.SolutionId, result.ExitCode, status)
+			score := 0.0
+			if status == aoiclient.StatusAccepted {
+				score = rc.Score.Score(1)
+			}
+			aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+				Score:   score,
+				Status:  status,
+				Message: fmt.Sprintf("评测结束，退出码 %d（%s）", result.ExitCode, status),
+			})
+		} else if result.ExitCode != 0 {
+			log.Printf("Solution %s finished with non-zero exit code %d and no report", soln.SolutionId, result.ExitCode)
+			aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+				Score:   0,
+				Status:  aoiclient.StatusRuntimeError,
+				Message: fmt.Sprintf("评测失败，退出码 %d，%s", result.ExitCode, missing),
+			})
+		} else {
+			log.Printf("Solution %s finished with exit code 0 but no report found", soln.SolutionId)
+			aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+				Score:   0,
+				Status:  aoiclient.StatusRuntimeError,
+				Message: exitedMessage,
+			})
+		}
+	}
+
+	// 完成评测
+	if err := aoi.Complete(job.ctx); err != nil {
+		log.Printf("Failed to complete solution: %v", err)
+	}
+
+	return nil
+}
+
+// describeOOMKills 列出被 OOM kill 的进程名，进程信息不可用时返回空字符串
+func describeOOMKills(kills []executor.OOMKill) string {
+	var names []string
+	for _, kill := range kills {
+		if kill.Process == "" {
+			continue
+		}
+		if kill.PID > 0 {
+			names = append(names, fmt.Sprintf("%s（PID %d）", kill.Process, kill.PID))
+		} else {
+			names = append(names, kill.Process)
+		}
+	}
+	return strings.Join(names, "、")
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	defaultColdStartViolations = 3
+	defaultPrefetchImages      = 5
+	prefetchTimeout            = 30 * time.Minute
+	prefetchWindow             = time.Hour      // 按最近一小时的使用次数选出热门镜像
+	coldImageTTL               = 24 * time.Hour // 冷启动超标的镜像在此期间一直预拉取
+)
+
+// observeColdStart 记录镜像的使用与从收到任务到开始运行的延迟。
+// 同一镜像连续超标时发出告警，并在之后的预拉取中优先拉取该镜像
+func (m *Manager) observeColdStart(image string, latency time.Duration) {
+	image = normalizeImage(image)
+	now := time.Now()
+	m.coldStartMu.Lock()
+	times := m.imageDemand[image]
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(now.Add(-prefetchWindow)) })
+	m.imageDemand[image] = append(times[i:], now)
+	m.coldStartMu.Unlock()
+
+	if m.conf.ColdStartSLO == nil || *m.conf.ColdStartSLO <= 0 {
+		return
+	}
+	threshold := defaultColdStartViolations
+	if m.conf.ColdStartViolations != nil && *m.conf.ColdStartViolations > 0 {
+		threshold = *m.conf.ColdStartViolations
+	}
+
+	m.coldStartMu.Lock()
+	if latency <= *m.conf.ColdStartSLO {
+		delete(m.coldStartViolations, image)
+		m.coldStartMu.Unlock()
+		return
+	}
+	m.coldStartViolations[image]++
+	count := m.coldStartViolations[image]
+	if count >= threshold {
+		delete(m.coldStartViolations, image)
+		m.coldImages[image] = now
+	}
+	m.coldStartMu.Unlock()
+
+	log.Printf("Cold start latency %s exceeded SLO %s for image %s (%d/%d)", latency, *m.conf.ColdStartSLO, image, count, threshold)
+	if count < threshold {
+		return
+	}
+
+	action := "prefetching it on every host"
+	if m.prefetchInterval() <= 0 {
+		action = "enable prefetch-interval to pull it ahead of time"
+	}
+	m.alert("cold-start", image, fmt.Sprintf("image %s exceeded cold start SLO %s %d times in a row, %s", image, *m.conf.ColdStartSLO, count, action))
+}
+
+// prefetchInterval 返回预拉取热门镜像的间隔，0 表示不预拉取
+func (m *Manager) prefetchInterval() time.Duration {
+	if m.conf.PrefetchInterval == nil {
+		return 0
+	}
+	return *m.conf.PrefetchInterval
+}
+
+func (m *Manager) prefetchImages() int {
+	if m.conf.PrefetchImages == nil || *m.conf.PrefetchImages <= 0 {
+		return defaultPrefetchImages
+	}
+	return *m.conf.PrefetchImages
+}
+
+// hotImages 返回需要预拉取的镜像：冷启动超标的镜像，以及最近使用次数最多的镜像，共不超过 n 个热门镜像。
+// 刚启动还没有使用记录时，按本 runner 保存的镜像使用时间补足
+func (m *Manager) hotImages(n int) []string {
+	now := time.Now()
+	m.coldStartMu.Lock()
+	var images []string
+	seen := make(map[string]bool)
+	for image, t := range m.coldImages {
+		if now.Sub(t) > coldImageTTL {
+			delete(m.coldImages, image)
+			continue
+		}
+		images = append(images, image)
+		seen[image] = true
+	}
+	sort.Strings(images)
+
+	counts := make(map[string]int)
+	var hot []string
+	for image, times := range m.imageDemand {
+		i := sort.Search(len(times), func(i int) bool { return times[i].After(now.Add(-prefetchWindow)) })
+		if i == len(times) {
+			delete(m.imageDemand, image)
+			continue
+		}
+		m.imageDemand[image] = times[i:]
+		if !seen[image] {
+			counts[image] = len(times) - i
+			hot = append(hot, image)
+		}
+	}
+	m.coldStartMu.Unlock()
+
+	sort.Slice(hot, func(i, j int) bool {
+		if counts[hot[i]] != counts[hot[j]] {
+			return counts[hot[i]] > counts[hot[j]]
+		}
+		return hot[i] < hot[j]
+	})
+	hot = hot[:min(len(hot), n)]
+	for _, image := range hot {
+		seen[image] = true
+	}
+	if len(hot) < n && m.images != nil {
+		for _, image := range m.images.recent(now.Add(-coldImageTTL)) {
+			if len(hot) == n {
+				break
+			}
+			if !seen[image] {
+				hot = append(hot, image)
+				seen[image] = true
+			}
+		}
+	}
+	return append(images, hot...)
+}
+
+// prefetchLoop 启动时以及之后每隔 prefetch-interval 在全部主机上拉取热门镜像，
+// 使评测开始前镜像已在本地且与仓库中的标签一致，直到 ctx 结束
+func (m *Manager) prefetchLoop(ctx context.Context) {
+	interval := m.prefetchInterval()
+	if interval <= 0 {
+		return
+	}
+	for {
+		m.prefetch(ctx, m.hotImages(m.prefetchImages()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// prefetch 在全部主机上拉取镜像
+func (m *Manager) prefetch(ctx context.Context, images []string) {
+	for _, h := range m.hosts.all() {
+		for _, image := range images {
+			if ctx.Err() != nil {
+				return
+			}
+			pullCtx, cancel := context.WithTimeout(ctx, prefetchTimeout)
+			err := h.exec.PullImage(pullCtx, image)
+			cancel()
+			if err != nil {
+				log.Printf("Failed to prefetch image %s on %s: %v", image, h.name, err)
+			} else {
+				log.Printf("Prefetched image %s on %s", image, h.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+)
+
+func TestHotImages(t *testing.T) {
+	slo := 10 * time.Second
+	violations := 2
+	m := NewManager(&config.ManagerConfig{ColdStartSLO: &slo, ColdStartViolations: &violations})
+	m.images = &imageUsage{path: t.TempDir() + "/image-usage.json", last: map[string]time.Time{
+		"old:1":    time.Now().Add(-48 * time.Hour),
+		"recent:1": time.Now().Add(-time.Hour),
+	}}
+
+	for range 3 {
+		m.observeColdStart("docker.io/library/gcc:13", time.Second)
+	}
+	m.observeColdStart("python:3.12", time.Second)
+	// 连续超标的冷门镜像同样需要预拉取
+	m.observeColdStart("cuda:12", time.Minute)
+	m.observeColdStart("cuda:12", time.Minute)
+
+	got := m.hotImages(3)
+	want := []string{"cuda:12", "gcc:13", "python:3.12", "recent:1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("hotImages(3) = %v, want %v", got, want)
+	}
+	if got := m.hotImages(1); !slices.Equal(got, []string{"cuda:12", "gcc:13"}) {
+		t.Errorf("hotImages(1) = %v, want [cuda:12 gcc:13]", got)
+	}
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+// liveFlushInterval 实时结果上报的最小间隔，避免每个测试都调用一次 AOI
+const liveFlushInterval = 2 * time.Second
+
+// liveResults 评测过程中逐个收到的测试结果
+type liveResults struct {
+	jobs      []*aoiclient.SolutionDetailsJob
+	passed    int
+	lastFlush time.Time
+}
+
+// onLiveTest 记录单个测试结果，并按间隔将进度上报给 AOI
+func (r *reporter) onLiveTest(body *judgerproto.TestBody) {
+	r.mu.Lock()
+	if r.live == nil {
+		r.live = &liveResults{}
+	}
+	live := r.live
+	job := adapters.LiveTestJob(body.Name, body.Outcome, body.Duration, body.Message)
+	live.jobs = append(live.jobs, job)
+	if job.Score > 0 {
+		live.passed++
+	}
+	if time.Since(live.lastFlush) < liveFlushInterval {
+		r.mu.Unlock()
+		return
+	}
+	live.lastFlush = time.Now()
+	message := fmt.Sprintf("评测中：已完成 %d 个测试点，通过 %d 个", len(live.jobs), live.passed)
+	details := &aoiclient.SolutionDetails{
+		Version: 1,
+		Summary: message,
+		Jobs:    append([]*aoiclient.SolutionDetailsJob(nil), live.jobs...),
+	}
+	r.mu.Unlock()
+
+	// 进度上报直接调用底层客户端，不影响最终结果的记录
+	if err := r.SolutionClient.Patch(r.ctx, &aoiclient.SolutionInfo{
+		Status:  "Running",
+		Message: message,
+	}); err != nil {
+		log.Printf("Failed to patch live progress for solution %s: %v", r.SolutionID(), err)
+	}
+	if err := r.SolutionClient.SaveDetails(r.ctx, details); err != nil {
+		log.Printf("Failed to save live details for solution %s: %v", r.SolutionID(), err)
+	}
+}
@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogMaxSize    = 16 << 20 // 单个日志文件轮转大小（字节）
+	defaultLogTTL        = 30 * 24 * time.Hour
+	logMaxBackups        = 3 // 每个提交保留的轮转文件数
+	logCleanupInterval   = 10 * time.Minute
+	localLogWriterBuffer = 32 << 10
+)
+
+// logDir 返回本地评测日志目录
+func (m *Manager) logDir() string {
+	if m.conf.LogDir != nil && *m.conf.LogDir != "" {
+		return *m.conf.LogDir
+	}
+	return filepath.Join(m.workDir(), "logs")
+}
+
+func (m *Manager) logMaxSize() int64 {
+	if m.conf.LogMaxSize != nil && *m.conf.LogMaxSize > 0 {
+		return *m.conf.LogMaxSize
+	}
+	return defaultLogMaxSize
+}
+
+func (m *Manager) logTTL() time.Duration {
+	if m.conf.LogTTL != nil {
+		return *m.conf.LogTTL
+	}
+	return defaultLogTTL
+}
+
+// localLog 将单个提交的完整容器输出写入 <logDir>/<solution_id>.log，
+// 超过大小限制时轮转为 .1、.2 …，保留最近 logMaxBackups 个
+type localLog struct {
+	path    string
+	maxSize int64
+
+	file *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+// openLocalLog 打开（追加）提交的本地日志，phase 非空时写入单独的 <solution_id>.<phase>.log，
+// 失败时只记录错误并返回 nil
+func (m *Manager) openLocalLog(job *Job, phase string) *localLog {
+	dir := m.logDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Failed to create log dir: %v", err)
+		return nil
+	}
+	name := job.SolutionID
+	if phase != "" {
+		name += "." + phase
+	}
+	l := &localLog{
+		path:    filepath.Join(dir, name+".log"),
+		maxSize: m.logMaxSize(),
+	}
+	if err := l.open(); err != nil {
+		log.Printf("Failed to open local log for solution %s: %v", job.SolutionID, err)
+		return nil
+	}
+	// 同一提交重测时追加到同一文件，用分隔行区分
+	fmt.Fprintf(l.w, "=== task %s started at %s ===\n", job.TaskID, time.Now().Format(time.RFC3339))
+	return l
+}
+
+func (l *localLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	l.w = bufio.NewWriterSize(f, localLogWriterBuffer)
+	return nil
+}
+
+// rotate 将当前文件依次后移为 .1、.2 …，并重新打开空文件
+func (l *localLog) rotate() error {
+	l.w.Flush()
+	l.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", l.path, logMaxBackups))
+	for i := logMaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// write 追加一行输出
+func (l *localLog) write(line string) {
+	if l == nil || l.file == nil {
+		return
+	}
+	if l.size+int64(len(line))+1 > l.maxSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			log.Printf("Failed to rotate local log %s: %v", l.path, err)
+			l.file = nil
+			return
+		}
+	}
+	l.w.WriteString(line)
+	l.w.WriteByte('\n')
+	l.size += int64(len(line)) + 1
+}
+
+func (l *localLog) close() {
+	if l == nil || l.file == nil {
+		return
+	}
+	l.w.Flush()
+	l.file.Close()
+}
+
+// cleanupLogs 删除超过保留期限的本地日志
+func (m *Manager) cleanupLogs() {
+	ttl := m.logTTL()
+	if ttl <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(m.logDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < ttl {
+			continue
+		}
+		path := filepath.Join(m.logDir(), entry.Name())
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove expired log %s: %v", path, err)
+		}
+	}
+}
+
+// cleanupLoop 定期清理过期日志与过期的解密题目数据，并重试上报失败的多 task 汇总结果
+func (m *Manager) cleanupLoop(ctx context.Context) {
+	for {
+		m.cleanupLogs()
+		m.retryAggregates()
+		if n := m.cache.WipeExpired(); n > 0 {
+			log.Printf("Wiped %d expired decrypted problem data dir(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logCleanupInterval):
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLogUploadLimit = 1 << 20 // 每次评测上传日志的默认上限（字节）
+	logChunkSize          = 64 << 10
+	logFlushInterval      = 5 * time.Second
+)
+
+// logUploader 将评测容器的输出分块追加到 AOI，超过上限后截断
+type logUploader struct {
+	aoi   *reporter
+	limit int64
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	offset    int64 // 已上传的字节数
+	written   int64 // 已写入缓冲区的字节数
+	truncated bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// logUploadLimit 返回日志上传上限，0 表示不上传
+func (m *Manager) logUploadLimit() int64 {
+	if m.conf.LogUploadLimit != nil {
+		return *m.conf.LogUploadLimit
+	}
+	return defaultLogUploadLimit
+}
+
+// newLogUploader 创建并启动日志上传，未启用时返回 nil
+func (m *Manager) newLogUploader(aoi *reporter) *logUploader {
+	limit := m.logUploadLimit()
+	if limit <= 0 {
+		return nil
+	}
+	u := &logUploader{
+		aoi:   aoi,
+		limit: limit,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go u.run()
+	return u
+}
+
+func (u *logUploader) run() {
+	defer close(u.done)
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.flush()
+		case <-u.stop:
+			u.flush()
+			return
+		}
+	}
+}
+
+// write 追加一行输出，缓冲区满一块时立即上传
+func (u *logUploader) write(line string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	if u.truncated {
+		u.mu.Unlock()
+		return
+	}
+	if u.written+int64(len(line))+1 > u.limit {
+		u.truncated = true
+		fmt.Fprintf(&u.buf, "\n[log truncated: exceeded %d bytes]\n", u.limit)
+	} else {
+		u.buf.WriteString(line)
+		u.buf.WriteByte('\n')
+		u.written += int64(len(line)) + 1
+	}
+	full := u.buf.Len() >= logChunkSize
+	u.mu.Unlock()
+
+	if full {
+		u.flush()
+	}
+}
+
+// flush 上传缓冲区中的内容，失败时保留以便下次重试
+func (u *logUploader) flush() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.buf.Len() == 0 {
+		return
+	}
+	chunk := u.buf.Bytes()
+	if err := u.aoi.AppendLog(u.aoi.ctx, u.offset, chunk); err != nil {
+		log.Printf("Failed to upload logs for solution %s: %v", u.aoi.SolutionID(), err)
+		return
+	}
+	u.offset += int64(len(chunk))
+	u.buf.Reset()
+}
+
+// close 停止定时上传并上传剩余内容
+func (u *logUploader) close() {
+	if u == nil {
+		return
+	}
+	close(u.stop)
+	<-u.done
+}
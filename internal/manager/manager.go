@@ -6,18 +6,29 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
 	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/datacache"
 	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/secrets"
 	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
 	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
 )
 
-const pollInterval = 250 * time.Millisecond
+const (
+	defaultPollMinInterval = 250 * time.Millisecond
+	defaultPollMaxInterval = 5 * time.Second
+)
 
 // MountConfig 挂载配置
 type MountConfig struct {
@@ -28,286 +39,790 @@ type MountConfig struct {
 
 // RunningConfig 评测运行配置，对应 conf.json 中的 judge.config
 type RunningConfig struct {
-	Image       string            `json:"image"`       // Docker 镜像名
-	PreCmd      []string          `json:"pre_cmd"`     // 预处理命令（评测前执行）
-	DockerCmd   []string          `json:"docker_cmd"`  // Docker 容器内执行的命令
-	PostCmd     []string          `json:"post_cmd"`    // 后处理命令（评测后执行）
-	Timeout     int64             `json:"timeout"`     // 超时时间（秒）
-	MemoryLimit int64             `json:"memoryLimit"` // 内存限制（MB）
-	CPULimit    float64           `json:"cpuLimit"`    // CPU 限制（核心数）
-	Env         map[string]string `json:"env"`         // 环境变量
-	WorkDir     string            `json:"workDir"`     // 工作目录
-	Mounts      []MountConfig     `json:"mounts"`      // 挂载配置
-	Variables   map[string]any    `json:"variables"`   // 额外变量
+	Image       string            `json:"image"`        // Docker 镜像名
+	PreCmd      []string          `json:"pre_cmd"`      // 预处理命令（评测前执行）
+	DockerCmd   []string          `json:"docker_cmd"`   // Docker 容器内执行的命令
+	PostCmd     []string          `json:"post_cmd"`     // 后处理命令（评测后执行）
+	HookImage   string            `json:"hook_image"`   // pre/post 命令使用的镜像，需在 manager 信任列表中，默认同 image
+	Timeout     Duration          `json:"timeout"`      // 超时时间，秒数（可为小数）或时长字符串，如 "500ms"
+	PreTimeout  int64             `json:"pre_timeout"`  // 预处理超时（秒），默认 300
+	PostTimeout int64             `json:"post_timeout"` // 后处理超时（秒），默认 300
+	MemoryLimit int64             `json:"memoryLimit"`  // 内存限制（MB）
+	OutputLimit int64             `json:"outputLimit"`  // 容器输出总量限制（MB），默认由 runner 的 output-limit 决定
+	CPULimit    float64           `json:"cpuLimit"`     // CPU 限制（核心数）
+	Env         map[string]string `json:"env"`          // 环境变量
+	WorkDir     string            `json:"workDir"`      // 工作目录
+	Mounts      []MountConfig     `json:"mounts"`       // 挂载配置
+	Variables   map[string]any    `json:"variables"`    // 额外变量
+
+	ProblemData *ProblemDataConfig `json:"problem_data"` // 题目数据预解压配置
+
+	Seccomp      string `json:"seccomp"`       // seccomp 配置名（manager 侧配置目录中），"unconfined" 表示不限制
+	AppArmor     string `json:"apparmor"`      // AppArmor 配置名
+	SELinuxLabel string `json:"selinux_label"` // SELinux 标签
+	Runtime      string `json:"runtime"`       // OCI 运行时（如 runsc、kata-runtime），需在 manager 允许列表中，默认 runc
+	User         string `json:"user"`          // 容器运行用户（uid:gid），默认使用 manager 配置的非 root 用户
+
+	ReadOnlyRootfs bool  `json:"read_only_rootfs"` // 以只读方式挂载镜像文件系统，/tmp 与工作目录改为空的 tmpfs，防止选手程序篡改镜像中的评测脚本
+	TmpfsSize      int64 `json:"tmpfs_size"`       // read_only_rootfs 时每个 tmpfs 的大小上限（MB），默认 64，计入容器的内存限制
+
+	Devices []string `json:"devices"` // 直通的宿主机设备，格式为 "宿主机路径[:容器内路径[:权限]]"，需在 manager 允许列表中
+
+	Secrets []string `json:"secrets"` // 需要注入的密钥名称，值由 manager 侧密钥存储提供
+
+	BuildCaches []BuildCacheConfig `json:"build_caches"` // 挂载的构建缓存（pip、npm、ccache、sccache、cargo、go、uv 等），由 manager 侧定义
+	Cache       []BuildCacheConfig `json:"cache"`        // 同 build_caches
+
+	CPUPin      *CPUPinConfig      `json:"cpu_pin"`      // 独占核心绑定配置，用于对计时敏感的题目
+	StudentHook *StudentHookConfig `json:"student_hook"` // 学生提供的 hook，在受限沙箱中预先执行
+
+	IsolatedNetwork bool                  `json:"isolated_network"`  // 使用独立的评测网络（配置 IPv6 前缀池时为双栈）
+	NetworkShaping  *NetworkShapingConfig `json:"network_shaping"`   // 网络延迟与带宽限制
+	RerunFailedOnly bool                  `json:"rerun_failed_only"` // 同一用户再次提交时只运行上次未通过的测试，并与上次结果合并
+
+	CoreDump *CoreDumpConfig `json:"core_dump"` // 收集评测进程崩溃产生的 core dump
+	GPU      *GPUConfig      `json:"gpu"`       // GPU 评测配置
+	Perf     *PerfConfig     `json:"perf"`      // 记录评测容器的硬件性能计数器（指令数、cache miss、浮点运算估算等）
+
+	Compile          *CompileConfig  `json:"compile"`            // 独立的编译阶段，产物只读挂载到评测容器
+	CompileErrorFile string          `json:"compile_error_file"` // 评测容器自行编译失败时写入输出目录的文件，存在时上报 Compile Error，默认 compile_error.log
+	Steps            []StepConfig    `json:"steps"`              // 在同一容器中依次执行的步骤，配置后 docker_cmd 仅用于保持容器运行
+	Services         []ServiceConfig `json:"services"`           // 与评测容器一同启动的辅助容器，共享私有网络
+	MPI              *MPIConfig      `json:"mpi"`                // 多节点 MPI 评测，需要配置 mpi-peers
+
+	Adapters    []AdapterStageConfig  `json:"adapters"`     // 依次运行的多个 adapter，结果按权重合并，配置后代替题目的 adapter
+	Score       *adapters.ScorePolicy `json:"score"`        // 满分、详情 scoreScale 与取整方式，默认百分制且不取整
+	LatePenalty *LatePenaltyConfig    `json:"late_penalty"` // 迟交处罚，截止时间由题目变量提供
+	Aggregate   *AggregateConfig      `json:"aggregate"`    // 提交拆分为多个 task 时汇总各 task 的分数
+	ExitStatus  map[string]string     `json:"exit_status"`  // 未生成报告时按退出码上报的状态，如 {"42": "Presentation Error"}
+
+	OutputLimitPolicy string   `json:"output_limit_policy"` // 输出超限时 kill（默认，立即终止）或 truncate（丢弃其余输出，运行结束后上报）
+	CPUTimeLimit      Duration `json:"cpu_time_limit"`      // 累计 CPU 时间限制（所有进程与线程之和），格式同 timeout，0 为不限制；与 timeout 的墙钟时间限制同时生效
+
+	ShmSize      int64  `json:"shm_size"`      // /dev/shm 大小（MB），默认为容器运行时的 64MB，计入内存限制
+	HugePages    int64  `json:"hugepages"`     // 挂载到 /dev/hugepages 的 hugetlbfs 大小（MB），不超过 runner 的 max-hugepages
+	HugePageSize string `json:"hugepage_size"` // 大页大小，2M（默认）或 1G
+
+	MeasureEnergy bool `json:"measure_energy"` // 计量评测容器运行期间的 CPU（RAPL）与 GPU 能耗，作为指标上报并提供给 post_cmd
+
+	WarmPool bool `json:"warm_pool"` // 允许在预先启动的容器中 exec docker_cmd，此时不经过镜像的 ENTRYPOINT
 }
 
 type Manager struct {
-	conf *config.ManagerConfig
-	aoi  *aoiclient.Client
-	exec *executor.DockerExecutor
+	conf  *config.ManagerConfig
+	aoi   *aoiclient.Client
+	exec  executor.Executor // 主机池中的第一个主机，用于不属于具体任务的操作
+	hosts *hostPool
+	cache *datacache.Cache
+	hooks []TransitionHook
+	idMap *idMapping
+
+	executorFactory func(host string) (executor.Executor, error) // 替换执行器后端，用于测试与嵌入
+	source          WorkSource                                   // 替换任务来源，为 nil 时从 AOI 领取
+
+	secrets secrets.Chain
+	cpus    *cpuAllocator
+	subnets *subnetAllocator
+
+	history *history
+	flaky   *flakyTracker
+	gpus    *gpuAllocator
+	costs   *costLedger
+	images  *imageUsage
+	warm    *warmPool
+
+	incidents  incidents
+	admission  *admission     // 未启用准入控制时为 nil
+	errReports *errorReporter // 未配置 error-report-dsn 时为 nil
+	prom       *promMetrics
+
+	corePatternOnce sync.Once
+
+	mpiClientOnce sync.Once
+	mpiHTTP       *http.Client // 访问 peer 的客户端，配置了 mpi-tls-ca 时只信任该 CA
+	mpiClientErr  error
+
+	// 生命周期：ctx 在 Close 时取消，loopDone 在 Start 返回时关闭，pending 跟踪后台上报任务
+	ctx      context.Context
+	stop     context.CancelFunc
+	loopMu   sync.Mutex
+	loopDone chan struct{}
+	pending  sync.WaitGroup
+
+	aggregateMu sync.Mutex // 串行化本 runner 对多 task 结果的读写
+
+	coldStartMu         sync.Mutex
+	coldStartViolations map[string]int         // 镜像 -> 连续超标次数
+	coldImages          map[string]time.Time   // 镜像 -> 最近一次连续超标的时间，预拉取时优先拉取
+	imageDemand         map[string][]time.Time // 镜像 -> 最近一小时内的使用时间，用于选出热门镜像
+
+	// 运行中的评测，排空时用于保存检查点，同时供取消与管理接口查找
+	runningMu sync.Mutex
+	running   map[string]*Job // solution ID -> 评测
+
+	batchUnsupported atomic.Bool // 平台不支持批量领取任务
+	scratchPaused    atomic.Bool // 临时目录超出总预算，暂停领取任务
 }
 
 func NewManager(conf *config.ManagerConfig) *Manager {
-	return &Manager{conf: conf}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Manager{
+		conf:                conf,
+		coldStartViolations: make(map[string]int),
+		coldImages:          make(map[string]time.Time),
+		imageDemand:         make(map[string][]time.Time),
+		running:             make(map[string]*Job),
+		prom:                newPromMetrics(),
+		ctx:                 ctx,
+		stop:                stop,
+		incidents: incidents{
+			lastSent:      make(map[string]time.Time),
+			problemErrors: make(map[string][]time.Time),
+		},
+	}
+}
+
+// goBackground 运行后台任务，Close 时等待其结束
+func (m *Manager) goBackground(fn func()) {
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		fn()
+	}()
+}
+
+// SetExecutorFactory 替换创建执行器的函数（如返回 executortest.Fake），须在 Init 之前调用
+func (m *Manager) SetExecutorFactory(fn func(host string) (executor.Executor, error)) {
+	m.executorFactory = fn
+}
+
+// newExecutor 按配置的后端创建执行器，host 为 Docker 守护进程或 containerd 套接字地址
+func (m *Manager) newExecutor(host string) (executor.Executor, error) {
+	if m.executorFactory != nil {
+		return m.executorFactory(host)
+	}
+	backend := "docker"
+	if m.conf.ExecutorBackend != nil && *m.conf.ExecutorBackend != "" {
+		backend = *m.conf.ExecutorBackend
+	}
+	switch backend {
+	case "docker":
+		return executor.NewDockerExecutor(host)
+	case "nerdctl":
+		namespace := ""
+		if m.conf.ContainerdNamespace != nil {
+			namespace = *m.conf.ContainerdNamespace
+		}
+		return executor.NewNerdctlExecutor(host, namespace)
+	}
+	return nil, fmt.Errorf("unknown executor backend %q", backend)
 }
 
 func (m *Manager) Init() error {
-	exec, err := executor.NewDockerExecutor()
+	if err := validateRunnerID(*m.conf.RunnerID); err != nil {
+		return err
+	}
+	var hosts []string
+	if m.conf.DockerHosts != nil {
+		hosts = parseDockerHosts(*m.conf.DockerHosts)
+	}
+	pool, err := newHostPool(hosts, m.newExecutor)
 	if err != nil {
 		return err
 	}
-	m.exec = exec
+	m.hosts = pool
+	m.exec = pool.primary().exec
 
-	aoi := aoiclient.New(*m.conf.Endpoint)
+	// 主机池中的守护进程应使用相同的 userns 配置
+	ctx, cancel := context.WithTimeout(context.Background(), hostProbeTimeout)
+	info, err := m.exec.Info(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	m.idMap = detectIDMapping(info)
+
+	aoi := aoiclient.New(*m.conf.Endpoint, m.aoiOptions()...)
+	if m.conf.LongPollTimeout != nil && *m.conf.LongPollTimeout > 0 {
+		aoi.SetLongPoll(*m.conf.LongPollTimeout)
+	}
 	if *m.conf.RunnerID != "" || *m.conf.RunnerKey != "" {
 		aoi.Authenticate(*m.conf.RunnerID, *m.conf.RunnerKey)
 	} else {
 		return errors.New("runner ID and key must be provided")
 	}
+	// 推送的取消消息中止对应的评测
+	aoi.SetCancelHandler(func(solutionID, taskID string) {
+		m.cancelJob(solutionID, taskID, "AOI push")
+	})
 	m.aoi = aoi
 
-	return nil
-}
+	if m.conf.ScopedTokens != nil && *m.conf.ScopedTokens {
+		missing, err := aoi.EnableScopedTokens(m.ctx)
+		switch {
+		case errors.Is(err, aoiclient.ErrScopesUnsupported):
+			log.Println("Platform does not support scoped tokens, using runner key")
+		case err != nil:
+			log.Printf("Failed to obtain scoped tokens, using runner key: %v", err)
+		case len(missing) > 0:
+			log.Printf("Platform did not grant scopes %v, using runner key for them", missing)
+		}
+	}
+
+	if err := os.MkdirAll(m.workDir(), 0o755); err != nil {
+		return fmt.Errorf("failed to create work dir: %w", err)
+	}
+	if err := os.MkdirAll(m.scratchDir(), 0o755); err != nil {
+		return fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	m.sweepScratch()
+	m.reapContainers()
+	if err := m.recoverJobs(); err != nil {
+		return err
+	}
+
+	h, err := loadHistory(historyPath(m.workDir()))
+	if err != nil {
+		return err
+	}
+	m.history = h
+	m.OnTransition(m.recordJob)
+	m.OnTransition(m.exportJob)
 
-func (m *Manager) Start() error {
-	for {
-		time.Sleep(pollInterval)
+	flaky, err := loadFlaky(flakyPath(m.workDir()), m.flakyThreshold())
+	if err != nil {
+		return err
+	}
+	m.flaky = flaky
+
+	m.costs = &costLedger{path: costPath(m.workDir())}
+	m.OnTransition(m.recordCost)
+
+	if err := m.initSecrets(); err != nil {
+		return err
+	}
 
-		soln, err := m.aoi.Poll(context.TODO())
+	cpuPool := ""
+	if m.conf.CPUSet != nil {
+		cpuPool = *m.conf.CPUSet
+	}
+	cpus, err := newCPUAllocator(cpuPool)
+	if err != nil {
+		return fmt.Errorf("failed to init cpu allocator: %w", err)
+	}
+	m.cpus = cpus
+
+	if m.conf.IPv6SubnetPool != nil && *m.conf.IPv6SubnetPool != "" {
+		subnets, err := newSubnetAllocator(*m.conf.IPv6SubnetPool)
 		if err != nil {
-			log.Println("Failed to poll:", err)
-			continue
+			return err
 		}
+		m.subnets = subnets
+	}
 
-		if soln.SolutionId == "" || soln.TaskId == "" {
-			continue
+	if m.conf.GPUDevices != nil && *m.conf.GPUDevices != "" {
+		slots := 1
+		if m.conf.GPUSlots != nil && *m.conf.GPUSlots > 1 {
+			slots = int(*m.conf.GPUSlots)
+		}
+		gpus, err := newGPUAllocator(*m.conf.GPUDevices, slots, m.gpuLockDir())
+		if err != nil {
+			return err
 		}
+		m.gpus = gpus
+	}
 
-		log.Println("Received solution", soln.SolutionId, "for task", soln.TaskId)
+	cache, err := datacache.New(m.cacheDir())
+	if err != nil {
+		return err
+	}
+	m.cache = cache
 
-		// 打印完整的轮询返回信息
-		if solnJSON, err := json.MarshalIndent(soln, "", "  "); err == nil {
-			log.Printf("Full poll response:\n%s", string(solnJSON))
-		}
+	images, err := loadImageUsage(imageUsagePath(m.workDir()))
+	if err != nil {
+		return err
+	}
+	m.images = images
+
+	if err := m.initAdmission(); err != nil {
+		return err
+	}
 
-		err = m.run(soln)
+	if m.conf.IncidentWebhookFormat != nil && !validIncidentFormat(*m.conf.IncidentWebhookFormat) {
+		return fmt.Errorf("unknown incident webhook format %q", *m.conf.IncidentWebhookFormat)
+	}
+	if m.conf.ErrorReportDSN != nil {
+		reporter, err := newErrorReporter(*m.conf.ErrorReportDSN, *m.conf.RunnerID)
 		if err != nil {
-			log.Println("Failed to run solution:", err)
-			m.failSoln(soln, "Failed to run solution: "+err.Error())
+			return err
 		}
+		m.errReports = reporter
+	}
+
+	go m.cleanupLoop(m.ctx)
+	go m.dockerHealthLoop(m.ctx)
+	go m.capabilityLoop(m.ctx)
+	go m.gcLoop(m.ctx)
+	go m.prefetchLoop(m.ctx)
+	if m.warmPoolSize() > 0 {
+		m.warm = newWarmPool()
+		m.goBackground(func() { m.warmLoop(m.ctx) })
 	}
+
+	return nil
 }
 
-func (m *Manager) failSoln(soln *aoiclient.SolutionPoll, reason string) {
-	s := m.aoi.Solution(soln.SolutionId, soln.TaskId)
-	s.Patch(context.TODO(), &aoiclient.SolutionInfo{
-		Score:   0,
-		Status:  aoiclient.StatusError,
-		Message: reason,
-	})
-	s.SaveDetails(context.TODO(), &aoiclient.SolutionDetails{Summary: reason})
-	s.Complete(context.TODO())
+// runnerIDPattern runner ID 会拼接到目录、网络名与容器标签中，不允许路径分隔符等字符
+var runnerIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validateRunnerID 检查 runner ID 可以安全地用作路径与名称的一部分
+func validateRunnerID(id string) error {
+	if id == "" {
+		return errors.New("runner ID must be provided")
+	}
+	if !runnerIDPattern.MatchString(id) || id == "." || id == ".." {
+		return fmt.Errorf("invalid runner ID %q: only letters, digits, '_', '.' and '-' are allowed", id)
+	}
+	return nil
 }
 
-func (m *Manager) run(soln *aoiclient.SolutionPoll) error {
-	log.Printf("Starting evaluation for solution %s, task %s", soln.SolutionId, soln.TaskId)
+// gpuLockDir 返回 GPU 锁文件目录。同一主机上的 runner 即使使用不同的工作目录也须共享该目录，
+// gpu-devices 有重叠的分区才不会重复分配同一设备
+func (m *Manager) gpuLockDir() string {
+	if m.conf.GPULockDir != nil && *m.conf.GPULockDir != "" {
+		return *m.conf.GPULockDir
+	}
+	return filepath.Join(os.TempDir(), "lfs-auto-grader-gpu-locks")
+}
 
-	// 打印原始配置用于调试
-	log.Printf("Raw judge config: %s", string(soln.ProblemConfig.Judge.Config))
+// workDir 返回当前 runner 专属的临时目录根路径
+func (m *Manager) workDir() string {
+	root := os.TempDir()
+	if m.conf.WorkDir != nil && *m.conf.WorkDir != "" {
+		root = *m.conf.WorkDir
+	}
+	return filepath.Join(root, "lfs-auto-grader", *m.conf.RunnerID)
+}
 
-	// 解析评测配置
-	rc := new(RunningConfig)
-	if err := json.Unmarshal(soln.ProblemConfig.Judge.Config, rc); err != nil {
-		return fmt.Errorf("failed to parse judge config: %w", err)
+// cacheDir 返回题目数据缓存目录，默认在各 runner 之间共享
+func (m *Manager) cacheDir() string {
+	if m.conf.CacheDir != nil && *m.conf.CacheDir != "" {
+		return *m.conf.CacheDir
 	}
+	return filepath.Join(filepath.Dir(m.workDir()), "cache")
+}
 
-	// 打印解析后的配置用于调试
-	log.Printf("Parsed config - Image: %s, DockerCmd: %v", rc.Image, rc.DockerCmd)
+// adapterLimits 返回 manager 侧 adapter 的资源限制
+func (m *Manager) adapterLimits() adapters.Limits {
+	limits := adapters.DefaultLimits()
+	if m.conf.AdapterTimeout != nil && *m.conf.AdapterTimeout > 0 {
+		limits.Timeout = *m.conf.AdapterTimeout
+	}
+	if m.conf.AdapterMaxInputSize != nil && *m.conf.AdapterMaxInputSize > 0 {
+		limits.MaxInputSize = *m.conf.AdapterMaxInputSize
+	}
+	if m.conf.AdapterMaxTests != nil && *m.conf.AdapterMaxTests > 0 {
+		limits.MaxTests = *m.conf.AdapterMaxTests
+	}
+	return limits
+}
 
-	aoi := m.aoi.Solution(soln.SolutionId, soln.TaskId)
+// aoiOptions 返回 AOI 客户端的超时与连接池配置，未设置的项使用客户端默认值
+func (m *Manager) aoiOptions() []aoiclient.Option {
+	var opts []aoiclient.Option
+	if m.conf.APITimeout != nil {
+		opts = append(opts, aoiclient.WithTimeout(*m.conf.APITimeout))
+	}
+	if m.conf.APIUploadTimeout != nil {
+		opts = append(opts, aoiclient.WithUploadTimeout(*m.conf.APIUploadTimeout))
+	}
+	if m.conf.APIKeepAlive != nil && *m.conf.APIKeepAlive > 0 {
+		opts = append(opts, aoiclient.WithKeepAlive(*m.conf.APIKeepAlive))
+	}
+	var maxIdle int
+	var idleTimeout time.Duration
+	if m.conf.APIMaxIdleConns != nil {
+		maxIdle = *m.conf.APIMaxIdleConns
+	}
+	if m.conf.APIIdleConnTimeout != nil {
+		idleTimeout = *m.conf.APIIdleConnTimeout
+	}
+	// 所有请求都发往同一主机，每主机的空闲连接数与总数相同
+	opts = append(opts, aoiclient.WithIdleConns(maxIdle, maxIdle, idleTimeout))
+	return opts
+}
 
-	// 上报评测开始状态
-	if err := aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-		Status:  "Running",
-		Message: "评测开始",
-	}); err != nil {
-		log.Printf("Failed to patch running status: %v", err)
+// pollIntervals 返回轮询间隔的上下限
+func (m *Manager) pollIntervals() (time.Duration, time.Duration) {
+	minInterval, maxInterval := defaultPollMinInterval, defaultPollMaxInterval
+	if m.conf.PollMinInterval != nil && *m.conf.PollMinInterval > 0 {
+		minInterval = *m.conf.PollMinInterval
+	}
+	if m.conf.PollMaxInterval != nil && *m.conf.PollMaxInterval > 0 {
+		maxInterval = *m.conf.PollMaxInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
 	}
+	return minInterval, maxInterval
+}
 
-	// 创建临时目录用于存放评测报告
-	outputDir, err := os.MkdirTemp("", fmt.Sprintf("judge-output-%s-", soln.SolutionId))
-	if err != nil {
-		return fmt.Errorf("failed to create temp output dir: %w", err)
+// workers 返回同时运行的评测数
+func (m *Manager) workers() int {
+	if m.conf.Workers == nil || *m.conf.Workers < 1 {
+		return 1
 	}
-	defer os.RemoveAll(outputDir) // 评测完成后清理临时目录
+	return *m.conf.Workers
+}
 
-	log.Printf("Created temp output directory: %s", outputDir)
+// longPollHeld 判断空的轮询结果是否由服务端挂起请求至长轮询超时得到，
+// 此时服务端已经等待过，不需要再退避；未启用长轮询或服务端立即返回时为 false
+func (m *Manager) longPollHeld(elapsed time.Duration) bool {
+	if m.source != nil || m.conf.LongPollTimeout == nil || *m.conf.LongPollTimeout <= 0 {
+		return false
+	}
+	return elapsed >= *m.conf.LongPollTimeout/2
+}
 
-	execConfig, err := m.buildExecuteConfig(soln, rc, outputDir)
-	if err != nil {
-		return fmt.Errorf("failed to build execute config: %w", err)
+// fetch 领取至多 n 个任务。设置了 WorkSource 时从中领取；推送连接可用时等待推送，最多等待 wait；
+// 否则在平台支持时批量轮询，不支持时退回单个轮询
+func (m *Manager) fetch(ctx context.Context, push *pushDispatcher, n int, wait time.Duration) ([]*aoiclient.SolutionPoll, error) {
+	if m.source != nil {
+		solns, err := m.source.Next(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		return validSolutions(solns), nil
 	}
 
-	// 设置超时上下文，额外增加 10 秒缓冲时间
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(execConfig.Timeout+10)*time.Second)
-	defer cancel()
+	if push != nil && push.connected.Load() {
+		select {
+		case soln := <-push.ch:
+			return []*aoiclient.SolutionPoll{soln}, nil
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
 
-	// 执行评测容器
-	result, err := m.exec.ExecuteWithLogs(ctx, execConfig, func(line string) error {
-		log.Printf("[%s] %s", soln.SolutionId, line)
-		m.processMessage(line, aoi)
-		return nil
-	})
+	if n > 1 && !m.batchUnsupported.Load() {
+		solns, err := m.aoi.PollN(ctx, n)
+		if errors.Is(err, aoiclient.ErrBatchPollUnsupported) {
+			log.Println("Batch polling is not supported by AOI, polling one solution at a time")
+			m.batchUnsupported.Store(true)
+		} else {
+			if err != nil {
+				return nil, err
+			}
+			return validSolutions(solns), nil
+		}
+	}
 
+	soln, err := m.aoi.Poll(ctx)
 	if err != nil {
-		return fmt.Errorf("docker execution failed: %w", err)
+		return nil, err
 	}
+	if soln.SolutionId == "" || soln.TaskId == "" {
+		return nil, nil
+	}
+	return []*aoiclient.SolutionPoll{soln}, nil
+}
 
-	// 处理特殊情况
-	if result.TimedOut {
-		log.Printf("Solution %s timed out", soln.SolutionId)
-		aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-			Score:   0,
-			Status:  aoiclient.StatusTimeLimitExceeded,
-			Message: fmt.Sprintf("评测超时（限制 %d 秒）", execConfig.Timeout),
-		})
-		aoi.SaveDetails(context.TODO(), &aoiclient.SolutionDetails{
-			Summary: fmt.Sprintf("评测超时，时间限制 %d 秒", execConfig.Timeout),
-		})
-		aoi.Complete(context.TODO())
-		return nil
+// Start 开始领取并评测任务，阻塞直到 ctx 取消或 Close 被调用。
+// 正在进行的评测会先完成再返回
+func (m *Manager) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Close 时同样停止主循环
+	stop := context.AfterFunc(m.ctx, cancel)
+	defer stop()
+
+	done := make(chan struct{})
+	defer close(done)
+	m.loopMu.Lock()
+	m.loopDone = done
+	m.loopMu.Unlock()
+
+	minInterval, maxInterval := m.pollIntervals()
+	interval := minInterval
+
+	// idle 在空闲或出错时等待并指数退避，直到达到最大间隔
+	idle := func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
 	}
 
-	if result.OOM {
-		log.Printf("Solution %s ran out of memory", soln.SolutionId)
-		aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-			Score:   0,
-			Status:  aoiclient.StatusMemoryLimitExceeded,
-			Message: fmt.Sprintf("内存超限（限制 %d MB）", execConfig.MemoryLimit),
-		})
-		aoi.SaveDetails(context.TODO(), &aoiclient.SolutionDetails{
-			Summary: fmt.Sprintf("内存超限，内存限制 %d MB", execConfig.MemoryLimit),
-		})
-		aoi.Complete(context.TODO())
-		return nil
+	// 接受其他 runner 的 MPI worker 请求
+	if m.conf.MPIListen != nil && *m.conf.MPIListen != "" {
+		m.goBackground(func() { m.serveMPI(ctx) })
 	}
 
-	log.Printf("Solution %s finished with exit code %d", soln.SolutionId, result.ExitCode)
+	// 本机管理接口
+	if m.conf.AdminListen != nil && *m.conf.AdminListen != "" {
+		m.goBackground(func() { m.serveAdmin(ctx) })
+	}
 
-	// 从外部读取并解析评测报告
-	reportProcessed := false
-	adapter := soln.ProblemConfig.Judge.Adapter
+	// Prometheus 指标
+	if m.conf.MetricsListen != nil && *m.conf.MetricsListen != "" {
+		m.goBackground(func() { m.serveMetrics(ctx) })
+	}
+
+	var push *pushDispatcher
+	if m.source == nil && m.conf.PushDispatch != nil && *m.conf.PushDispatch {
+		push = newPushDispatcher(m.aoi)
+		go push.run(ctx)
+	}
 
-	if adapter == "lfs1" {
-		// 获取报告文件名（默认为 report.json）
-		reportFileName := "report.json"
-		if rc.Variables != nil {
-			if reportName, ok := rc.Variables["report_name"].(string); ok && reportName != "" {
-				reportFileName = reportName
+	// 每个 worker 同时运行一个评测，停止时等待进行中的评测完成
+	workers := m.workers()
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	dispatch := func(soln *aoiclient.SolutionPoll, restore *restoreState) {
+		// 在下一次领取之前预留资源，评测解析配置后替换为实际申请
+		m.admission.reserve(soln.SolutionId, m.estimateDemand(soln.ProblemConfig.Label, m.nextJobDemand()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer m.admission.unreserve(soln.SolutionId)
+			if err := m.run(soln, restore); err != nil {
+				log.Println("Failed to run solution:", err)
 			}
-		}
+		}()
+	}
+	// received 分配 worker 运行新领取的任务，调用方已占用一个 worker
+	received := func(soln *aoiclient.SolutionPoll) {
+		log.Println("Received solution", soln.SolutionId, "for task", soln.TaskId)
 
-		reportPath := filepath.Join(outputDir, reportFileName)
-		log.Printf("Looking for report at: %s", reportPath)
+		// 打印完整的轮询返回信息
+		if solnJSON, err := json.MarshalIndent(soln, "", "  "); err == nil {
+			slog.Debug("Full poll response:\n"+string(solnJSON), "solution", soln.SolutionId)
+		}
 
-		if _, err := os.Stat(reportPath); err == nil {
-			// 报告文件存在，解析并上报
-			log.Printf("Found report file, parsing with adapter: %s", adapter)
+		dispatch(soln, nil)
+	}
+	// 批量领取时 AOI 返回的任务多于空闲 worker 的部分，有空闲 worker 时优先运行
+	var backlog []*aoiclient.SolutionPoll
+
+	for ctx.Err() == nil {
+		// 等待空闲的 worker，并占用其余全部空闲 worker 以便一次领取多个任务
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		free := 1
+	claim:
+		for free < workers {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break claim
+			}
+		}
 
-			report, err := adapters.ParsePytestReport(reportPath)
-			if err != nil {
-				log.Printf("Failed to parse report: %v", err)
-				aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-					Score:   0,
-					Status:  aoiclient.StatusInternalError,
-					Message: fmt.Sprintf("解析评测报告失败: %v", err),
-				})
-			} else {
-				// 使用 adapter 计算分数
-				lfsResult := adapters.CalculateScore(report)
+		// 临时目录超出总预算时暂停领取，等待运行中的评测结束释放空间
+		if m.scratchFull() {
+			for range free {
+				<-slots
+			}
+			idle()
+			continue
+		}
 
-				// 上报结果给 AOI
-				log.Printf("Reporting result: score=%.2f, status=%s", lfsResult.Score, lfsResult.Status)
+		// 下一个评测会使主机超额分配时只保留能容纳的 worker，其余归还
+		if n := m.admission.admittable(free, m.nextJobDemand()); n < free {
+			for range free - n {
+				<-slots
+			}
+			free = n
+			if free == 0 {
+				idle()
+				continue
+			}
+		}
 
-				aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-					Score:   lfsResult.Score,
-					Status:  lfsResult.Status,
-					Message: lfsResult.Message,
-				})
+		for free > 0 && len(backlog) > 0 {
+			received(backlog[0])
+			backlog = backlog[1:]
+			free--
+		}
 
-				if lfsResult.Details != nil {
-					aoi.SaveDetails(context.TODO(), lfsResult.Details)
-				}
+		// 优先恢复其他 runner 排空时迁移过来的评测
+		for free > 0 {
+			manifest, restore := m.claimCheckpoint()
+			if manifest == nil {
+				break
+			}
+			log.Printf("Resuming solution %s checkpointed by runner %s", manifest.Solution.SolutionId, manifest.Runner)
+			dispatch(manifest.Solution, restore)
+			free--
+			interval = minInterval
+		}
 
-				reportProcessed = true
+		var solns []*aoiclient.SolutionPoll
+		var err error
+		var held bool
+		if free > 0 {
+			start := time.Now()
+			solns, err = m.fetch(ctx, push, free, maxInterval)
+			held = m.longPollHeld(time.Since(start))
+			if ctx.Err() == nil {
+				m.notePollResult(err)
 			}
-		} else {
-			log.Printf("Report file not found at %s: %v", reportPath, err)
 		}
-	}
+		if len(solns) > free {
+			log.Printf("AOI returned %d solutions for %d free workers, queuing %d locally", len(solns), free, len(solns)-free)
+			backlog = append(backlog, solns[free:]...)
+			solns = solns[:free]
+		}
+		// 归还未使用的 worker
+		for range free - len(solns) {
+			<-slots
+		}
+		// 已领取的任务在停止时同样需要完成评测
+		for _, soln := range solns {
+			received(soln)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			log.Println("Failed to poll:", err)
+			idle()
+			continue
+		}
+		if len(solns) == 0 {
+			// 等待推送超时后直接重新检查连接状态；服务端已挂起长轮询请求时同样立即重新轮询
+			if free > 0 && (push == nil || !push.connected.Load()) && !held {
+				idle()
+			}
+			if held {
+				interval = minInterval
+			}
+			continue
+		}
 
-	// 如果没有处理报告，设置错误状态
-	if !reportProcessed {
-		if result.ExitCode != 0 {
-			log.Printf("Solution %s finished with non-zero exit code %d and no report", soln.SolutionId, result.ExitCode)
-			aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-				Score:   0,
-				Status:  aoiclient.StatusRuntimeError,
-				Message: fmt.Sprintf("评测失败，退出码 %d，未找到评测报告", result.ExitCode),
-			})
-		} else {
-			log.Printf("Solution %s finished with exit code 0 but no report found", soln.SolutionId)
-			aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
-				Score:   0,
-				Status:  aoiclient.StatusRuntimeError,
-				Message: "评测容器正常退出但未生成评测报告",
-			})
+		// 收到任务后重置间隔，有空闲 worker 时立即再次轮询
+		interval = minInterval
+	}
+	// 推送连接在停止前已收到的任务同样需要完成评测
+	if push != nil {
+		if leftover := push.stopped(); len(leftover) > 0 {
+			log.Printf("Push dispatch delivered %d solutions while stopping, judging them before exit", len(leftover))
+			backlog = append(backlog, leftover...)
 		}
 	}
-
-	// 完成评测
-	if err := aoi.Complete(context.TODO()); err != nil {
-		log.Printf("Failed to complete solution: %v", err)
+	// 停止前等待空闲 worker 运行本地排队的任务
+	for _, soln := range backlog {
+		slots <- struct{}{}
+		received(soln)
 	}
-
+	log.Println("Manager stopped")
 	return nil
 }
 
-func (m *Manager) buildExecuteConfig(soln *aoiclient.SolutionPoll, rc *RunningConfig, outputDir string) (*executor.ExecuteConfig, error) {
+func (m *Manager) failSoln(s *reporter, status, reason string) {
+	s.Patch(s.ctx, &aoiclient.SolutionInfo{
+		Score:   0,
+		Status:  status,
+		Message: reason,
+	})
+	s.SaveDetails(s.ctx, &aoiclient.SolutionDetails{Summary: reason})
+	s.Complete(s.ctx)
+}
+
+func (m *Manager) buildExecuteConfig(ctx context.Context, soln *aoiclient.SolutionPoll, rc *RunningConfig, outputDir string) (*executor.ExecuteConfig, error) {
 	// 使用 docker_cmd 作为容器执行命令
-	command := rc.DockerCmd
-	if len(command) == 0 {
+	if len(rc.DockerCmd) == 0 {
 		return nil, fmt.Errorf("docker_cmd is required in judge config")
 	}
+	if err := rc.Score.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid score config: %w", err)
+	}
+	if err := validateAdapterStages(rc.Adapters); err != nil {
+		return nil, fmt.Errorf("invalid adapters config: %w", err)
+	}
+	for code := range rc.ExitStatus {
+		if _, err := strconv.Atoi(code); err != nil {
+			return nil, fmt.Errorf("invalid exit code %q in exit_status", code)
+		}
+	}
+
+	// image、docker_cmd、env、mounts、workDir 支持 ${...} 模板
+	vars := newTemplateVars(soln, rc)
+	command := vars.expandAll(rc.DockerCmd)
 
 	// 使用配置中的工作目录，如果未指定则使用默认值
-	workDir := rc.WorkDir
+	workDir := vars.expand(rc.WorkDir)
 	if workDir == "" {
 		workDir = "/home/judge"
 	}
 
 	config := &executor.ExecuteConfig{
-		Image:       rc.Image,
+		Image:       vars.expand(rc.Image),
 		Command:     command,
-		Timeout:     rc.Timeout,
+		Timeout:     time.Duration(rc.Timeout),
 		MemoryLimit: rc.MemoryLimit,
 		CPULimit:    rc.CPULimit,
 		Env:         make(map[string]string),
 		WorkDir:     workDir,
+		Labels: map[string]string{
+			executor.LabelRunnerID:   *m.conf.RunnerID,
+			executor.LabelSolutionID: soln.SolutionId,
+			executor.LabelTaskID:     soln.TaskId,
+		},
 	}
 
-	// 设置默认超时时间
-	if config.Timeout == 0 {
-		config.Timeout = 600 // 默认 10 分钟
+	if rc.CPUTimeLimit < 0 {
+		return nil, fmt.Errorf("cpu_time_limit must not be negative")
 	}
-	// 设置默认内存限制
-	if config.MemoryLimit == 0 {
-		config.MemoryLimit = 2048 // 默认 2GB
+	config.CPUTimeLimit = time.Duration(rc.CPUTimeLimit)
+
+	// 填入默认的时间与内存限制，并截断到 runner 的上限
+	m.applyResourceLimits(soln.SolutionId, config)
+	if err := m.applyOutputLimit(soln.SolutionId, rc, config); err != nil {
+		return nil, err
+	}
+	if err := m.applySharedMemory(soln.SolutionId, rc, config); err != nil {
+		return nil, err
+	}
+
+	if err := m.resolveUser(rc, config); err != nil {
+		return nil, err
+	}
+	if err := m.applySecurityProfiles(rc, config); err != nil {
+		return nil, err
+	}
+	if err := m.resolveDevices(rc, config); err != nil {
+		return nil, err
+	}
+	if rc.Perf != nil {
+		if _, err := rc.Perf.events(); err != nil {
+			return nil, err
+		}
 	}
 
 	// 复制用户自定义环境变量
 	for k, v := range rc.Env {
-		config.Env[k] = v
+		if !m.allowEnv(k) {
+			log.Printf("Solution %s: dropping env %s rejected by env policy", soln.SolutionId, k)
+			continue
+		}
+		config.Env[k] = vars.expand(v)
+	}
+
+	// 注入密钥
+	if err := m.injectSecrets(ctx, rc.Secrets, config); err != nil {
+		return nil, err
 	}
 
 	// 注入评测相关环境变量
@@ -342,27 +857,63 @@ func (m *Manager) buildExecuteConfig(soln *aoiclient.SolutionPoll, rc *RunningCo
 	// 添加配置中指定的挂载
 	for _, mount := range rc.Mounts {
 		config.Mounts = append(config.Mounts, executor.Mount{
-			Source:   mount.Source,
-			Target:   mount.Target,
+			Source:   vars.expand(mount.Source),
+			Target:   vars.expand(mount.Target),
 			ReadOnly: mount.ReadOnly,
 		})
 	}
+
+	if rc.ReadOnlyRootfs {
+		applyReadOnlyRootfs(rc, config)
+	}
+
+	// 挂载构建缓存，环境变量覆盖 judge config 中的同名变量
+	caches, err := m.resolveBuildCaches(soln, buildCacheConfigs(rc))
+	if err != nil {
+		return nil, err
+	}
+	for _, bc := range caches {
+		config.Mounts = append(config.Mounts, bc.mount)
+		for k, v := range bc.spec.Env {
+			config.Env[k] = v
+		}
+		// 编译缓存由 manager 限制大小并统计命中情况
+		for k, v := range m.compilerCacheEnv(bc.name) {
+			config.Env[k] = v
+		}
+	}
 	return config, nil
 }
 
+// Close 停止主循环并等待当前评测结束，等待尚未完成的后台上报后释放执行器
 func (m *Manager) Close() error {
-	if m.exec != nil {
-		return m.exec.Close()
+	m.stop()
+
+	m.loopMu.Lock()
+	done := m.loopDone
+	m.loopMu.Unlock()
+	if done != nil {
+		<-done
+	}
+	m.pending.Wait()
+
+	if m.hosts != nil {
+		return m.hosts.close()
 	}
 	return nil
 }
 
-func (m *Manager) processMessage(msg string, aoi *aoiclient.SolutionClient) {
+func (m *Manager) processMessage(msg string, aoi *reporter) {
 	parsed, err := judgerproto.MessageFromString(msg)
 	if err != nil {
 		// 非协议消息，忽略
 		return
 	}
+	// 日志驱动重复投递的消息只处理一次，不带序号的旧版消息照常处理
+	if parsed.Seq != 0 && !aoi.acceptSeq(parsed.Stream, parsed.Seq) {
+		log.Printf("Dropped duplicate message %s#%d for solution %s", parsed.Stream, parsed.Seq, aoi.SolutionID())
+		return
+	}
 
 	switch parsed.Action {
 	case judgerproto.ActionGreet:
@@ -377,47 +928,77 @@ func (m *Manager) processMessage(msg string, aoi *aoiclient.SolutionClient) {
 		// 日志消息
 		var body judgerproto.LogBody
 		if json.Unmarshal(parsed.Body, &body) == nil {
-			log.Printf("[LOG %s] %s", aoi.SolutionID(), string(body))
+			slog.Info(fmt.Sprintf("[LOG %s] %s", aoi.SolutionID(), string(body)), "solution", aoi.SolutionID(), "source", "container")
 		}
 
 	case judgerproto.ActionError:
 		// 错误消息
 		var body judgerproto.ErrorBody
 		if json.Unmarshal(parsed.Body, &body) == nil {
-			log.Printf("[ERROR %s] %s", aoi.SolutionID(), string(body))
+			slog.Warn(fmt.Sprintf("[ERROR %s] %s", aoi.SolutionID(), string(body)), "solution", aoi.SolutionID(), "source", "container")
 			// 上报错误状态
-			aoi.Patch(context.TODO(), &aoiclient.SolutionInfo{
+			aoi.Patch(aoi.ctx, &aoiclient.SolutionInfo{
 				Score:   0,
 				Status:  aoiclient.StatusInternalError,
 				Message: string(body),
 			})
+			aoi.noteProtoResult()
 		}
 
 	case judgerproto.ActionPatch:
 		// 更新评测状态和分数
 		var body judgerproto.PatchBody
 		if json.Unmarshal(parsed.Body, &body) == nil {
-			if err := aoi.Patch(context.TODO(), (*aoiclient.SolutionInfo)(&body)); err != nil {
+			// 容器按百分制上报，按题目的计分规则换算
+			body.Score = aoi.scorePolicy().Rescale(body.Score)
+			if err := aoi.Patch(aoi.ctx, (*aoiclient.SolutionInfo)(&body)); err != nil {
 				log.Printf("Failed to patch solution %s: %v", aoi.SolutionID(), err)
 			} else {
 				log.Printf("Patched solution %s: score=%.2f, status=%s", aoi.SolutionID(), body.Score, body.Status)
 			}
+			aoi.noteProtoResult()
 		}
 
 	case judgerproto.ActionDetail:
 		// 保存评测详情
 		var body judgerproto.DetailBody
 		if json.Unmarshal(parsed.Body, &body) == nil {
-			if err := aoi.SaveDetails(context.TODO(), (*aoiclient.SolutionDetails)(&body)); err != nil {
+			details := aoi.scorePolicy().ScaleDetails((*aoiclient.SolutionDetails)(&body))
+			if err := aoi.SaveDetails(aoi.ctx, details); err != nil {
 				log.Printf("Failed to save details for solution %s: %v", aoi.SolutionID(), err)
 			} else {
 				log.Printf("Saved details for solution %s", aoi.SolutionID())
 			}
 		}
 
+	case judgerproto.ActionTest:
+		// 单个测试用例的实时结果
+		var body judgerproto.TestBody
+		if json.Unmarshal(parsed.Body, &body) == nil {
+			aoi.onLiveTest(&body)
+		}
+
+	case judgerproto.ActionMetric:
+		// 容器上报的数值指标，附加到评测详情
+		var body judgerproto.MetricBody
+		if json.Unmarshal(parsed.Body, &body) == nil {
+			if err := aoi.onMetric(&body); err != nil {
+				log.Printf("Failed to record metric for solution %s: %v", aoi.SolutionID(), err)
+			}
+		}
+
+	case judgerproto.ActionFile:
+		// 容器推送的产物，通过 AOI 的产物接口保存
+		var body judgerproto.FileBody
+		if json.Unmarshal(parsed.Body, &body) == nil {
+			if err := aoi.onFile(&body); err != nil {
+				log.Printf("Failed to store artifact for solution %s: %v", aoi.SolutionID(), err)
+			}
+		}
+
 	case judgerproto.ActionComplete:
 		// 完成评测
-		if err := aoi.Complete(context.TODO()); err != nil {
+		if err := aoi.Complete(aoi.ctx); err != nil {
 			log.Printf("Failed to complete solution %s: %v", aoi.SolutionID(), err)
 		} else {
 			log.Printf("Completed solution %s", aoi.SolutionID())
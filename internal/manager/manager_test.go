@@ -0,0 +1,322 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+// testEnv 连接到模拟 AOI 服务与脚本化执行器的 manager
+type testEnv struct {
+	t    *testing.T
+	aoi  *aoitest.Server
+	exec *executortest.Fake
+	m    *Manager
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// newTestEnv 初始化 manager 并在后台开始领取任务，测试结束时停止
+func newTestEnv(t *testing.T, scripts ...executortest.Script) *testEnv {
+	t.Helper()
+	return newTestEnvWith(t, nil, scripts...)
+}
+
+// newTestEnvWith 与 newTestEnv 相同，configure 不为 nil 时在 Init 之前修改配置
+func newTestEnvWith(t *testing.T, configure func(*config.ManagerConfig), scripts ...executortest.Script) *testEnv {
+	t.Helper()
+	srv := aoitest.NewServer()
+	t.Cleanup(srv.Close)
+
+	conf := &config.ManagerConfig{
+		Endpoint:        ptr(srv.URL),
+		RunnerID:        ptr("test-runner"),
+		RunnerKey:       ptr("test-key"),
+		WorkDir:         ptr(t.TempDir()),
+		CacheDir:        ptr(t.TempDir()),
+		PollMinInterval: ptr(10 * time.Millisecond),
+		PollMaxInterval: ptr(50 * time.Millisecond),
+	}
+	if configure != nil {
+		configure(conf)
+	}
+	exec := executortest.NewFake(scripts...)
+	m := NewManager(conf)
+	m.SetExecutorFactory(func(string) (executor.Executor, error) { return exec, nil })
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		m.Close()
+	})
+	return &testEnv{t: t, aoi: srv, exec: exec, m: m}
+}
+
+// judge 将任务加入队列并等待评测完成
+func (e *testEnv) judge(soln *aoiclient.SolutionPoll) *aoitest.Task {
+	e.t.Helper()
+	e.aoi.Enqueue(soln)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	task, err := e.aoi.WaitComplete(ctx, soln.SolutionId, soln.TaskId)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return task
+}
+
+func judgeConfig(extra map[string]any) map[string]any {
+	conf := map[string]any{
+		"image":      "judge:latest",
+		"docker_cmd": []string{"/judge"},
+		"timeout":    10,
+	}
+	for k, v := range extra {
+		conf[k] = v
+	}
+	return conf
+}
+
+const passingReport = `{
+	"exitcode": 0,
+	"summary": {"passed": 2, "total": 2, "collected": 2},
+	"tests": [
+		{"nodeid": "test_a.py::test_one", "outcome": "passed"},
+		{"nodeid": "test_a.py::test_two", "outcome": "passed"}
+	]
+}`
+
+func TestJudgeReportsScore(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Stdout: []string{"building", "running tests"},
+		Files:  map[string]string{"/output/report.json": passingReport},
+		Usage:  &executor.ResourceUsage{PeakMemory: 64 << 20},
+	})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "hello", "lfs1", judgeConfig(nil)))
+
+	last := task.Last()
+	if last == nil {
+		t.Fatal("no status reported")
+	}
+	if last.Status != aoiclient.StatusAccepted || last.Score != 100 {
+		t.Errorf("final status = %q score %v, want %q score 100", last.Status, last.Score, aoiclient.StatusAccepted)
+	}
+	if task.Details == nil {
+		t.Error("details were not uploaded")
+	}
+	if task.Patches[0].Status != "Running" {
+		t.Errorf("first status = %q, want Running", task.Patches[0].Status)
+	}
+
+	runs := env.exec.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("got %d container runs, want 1", len(runs))
+	}
+	if runs[0].Config.Image != "judge:latest" {
+		t.Errorf("image = %q, want judge:latest", runs[0].Config.Image)
+	}
+	if n := env.exec.Running(); n != 0 {
+		t.Errorf("%d containers left running", n)
+	}
+}
+
+func TestJudgeProtocolMessages(t *testing.T) {
+	patch := judgerproto.NewPatchMessage(&judgerproto.PatchBody{Score: 42, Status: aoiclient.StatusWrongAnswer, Message: "partial"})
+	details := judgerproto.NewDetailMessage(&judgerproto.DetailBody{Summary: "from protocol"})
+	env := newTestEnv(t, executortest.Script{
+		Stdout: []string{"plain log line", patch.String(), details.String(), judgerproto.NewCompleteMessage().String()},
+	})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "proto", "none", judgeConfig(nil)))
+
+	last := task.Last()
+	if last == nil || last.Score != 42 || last.Status != aoiclient.StatusWrongAnswer {
+		t.Fatalf("final status = %+v, want score 42 %q", last, aoiclient.StatusWrongAnswer)
+	}
+	if task.Details == nil || task.Details.Summary != "from protocol" {
+		t.Errorf("details = %+v, want summary from protocol", task.Details)
+	}
+}
+
+func TestJudgeExecutorError(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{Err: errors.New("daemon unavailable")})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "broken", "lfs1", judgeConfig(nil)))
+
+	last := task.Last()
+	if last == nil || last.Status == aoiclient.StatusAccepted || last.Score != 0 {
+		t.Fatalf("final status = %+v, want a failure with score 0", last)
+	}
+}
+
+func TestJudgeTimeLimitExceeded(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{TimedOut: true})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "slow", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusTimeLimitExceeded {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusTimeLimitExceeded)
+	}
+}
+
+func TestJudgeMemoryLimitExceeded(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{OOM: true, ExitCode: 137})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "hungry", "lfs1", judgeConfig(nil)))
+
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusMemoryLimitExceeded {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusMemoryLimitExceeded)
+	}
+}
+
+func TestJudgeInvalidConfig(t *testing.T) {
+	env := newTestEnv(t)
+	soln := aoitest.NewSolution("s1", "t1", "invalid", "lfs1", map[string]any{"image": "judge:latest"})
+	task := env.judge(soln)
+
+	if last := task.Last(); last == nil || last.Status == aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want a failure", last)
+	}
+	if runs := env.exec.Runs(); len(runs) != 0 {
+		t.Errorf("got %d container runs for an invalid config, want 0", len(runs))
+	}
+}
+
+func TestMalformedReport(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": `{"tests": [`},
+	})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "garbled", "lfs1", judgeConfig(nil)))
+
+	var parseErr bool
+	for _, p := range task.Patches {
+		if p.Status == aoiclient.StatusInternalError && strings.Contains(p.Message, "JSON") {
+			parseErr = true
+		}
+	}
+	if !parseErr {
+		t.Errorf("patches = %+v, want an %q patch describing the parse error", task.Patches, aoiclient.StatusInternalError)
+	}
+	if last := task.Last(); last == nil || last.Status == aoiclient.StatusAccepted || last.Score != 0 {
+		t.Fatalf("final status = %+v, want a failure with score 0", last)
+	}
+}
+
+func TestValidateRunnerID(t *testing.T) {
+	for _, id := range []string{"runner-1", "gpu_a.0", "replay-42"} {
+		if err := validateRunnerID(id); err != nil {
+			t.Errorf("validateRunnerID(%q) = %v, want nil", id, err)
+		}
+	}
+	for _, id := range []string{"", ".", "..", "../x", "a/b", "a b", "运行器"} {
+		if err := validateRunnerID(id); err == nil {
+			t.Errorf("validateRunnerID(%q) succeeded, want an error", id)
+		}
+	}
+}
+
+func TestCheckpointableExcludesHostState(t *testing.T) {
+	if !checkpointable(&RunningConfig{PreCmd: []string{"true"}}) {
+		t.Error("a single-container job is not checkpointable")
+	}
+	for name, rc := range map[string]*RunningConfig{
+		"compile":      {Compile: &CompileConfig{}},
+		"problem_data": {ProblemData: &ProblemDataConfig{}},
+		"core_dump":    {CoreDump: &CoreDumpConfig{}},
+		"build_caches": {BuildCaches: []BuildCacheConfig{{}}},
+		"cache":        {Cache: []BuildCacheConfig{{}}},
+		"cpu_pin":      {CPUPin: &CPUPinConfig{}},
+		"student_hook": {StudentHook: &StudentHookConfig{}},
+		"devices":      {Devices: []string{"/dev/kfd"}},
+	} {
+		if checkpointable(rc) {
+			t.Errorf("job with %s is checkpointable", name)
+		}
+	}
+}
+
+func TestNetworkShapingCoversServices(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{}, executortest.Script{}, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+	})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "shaped", "lfs1", judgeConfig(map[string]any{
+		"services":        []map[string]any{{"name": "server"}},
+		"network_shaping": map[string]any{"delay": "20ms"},
+	})))
+	if last := task.Last(); last == nil || last.Status != aoiclient.StatusAccepted {
+		t.Fatalf("final status = %+v, want %q", last, aoiclient.StatusAccepted)
+	}
+
+	var service string
+	shaped := make(map[string]bool)
+	for _, run := range env.exec.Runs() {
+		if run.Config.Labels[executor.LabelService] == "server" {
+			service = run.ContainerID
+		}
+		if len(run.Config.Command) > 0 && run.Config.Command[0] == "tc" {
+			shaped[strings.TrimPrefix(run.Config.Network, "container:")] = true
+		}
+	}
+	if service == "" {
+		t.Fatal("service container was not started")
+	}
+	if !shaped[service] || len(shaped) != 2 {
+		t.Errorf("shaped namespaces = %v, want the judge holder and service %s", shaped, service)
+	}
+}
+
+func TestServiceRootPolicy(t *testing.T) {
+	env := newTestEnv(t)
+	for i, svc := range []map[string]any{
+		{"name": "db", "image": "postgres:16"},
+		{"name": "db", "image": "postgres:16", "user": "0:0"},
+	} {
+		id := string(rune('a' + i))
+		task := env.judge(aoitest.NewSolution("s"+id, "t"+id, "root-service", "lfs1", judgeConfig(map[string]any{
+			"services": []map[string]any{svc},
+		})))
+		if last := task.Last(); last == nil || last.Status == aoiclient.StatusAccepted {
+			t.Errorf("service %v: final status = %+v, want a failure", svc, last)
+		}
+	}
+	if runs := env.exec.Runs(); len(runs) != 0 {
+		t.Errorf("got %d container runs for root services, want 0", len(runs))
+	}
+}
+
+func TestServiceLimitsAreClamped(t *testing.T) {
+	env := newTestEnvWith(t, func(conf *config.ManagerConfig) {
+		conf.MaxCPULimit = ptr(2.0)
+		conf.MaxMemoryLimit = ptr[int64](256)
+	}, executortest.Script{Files: map[string]string{"/output/report.json": passingReport}})
+	env.judge(aoitest.NewSolution("s1", "t1", "greedy-service", "lfs1", judgeConfig(map[string]any{
+		"services": []map[string]any{{"name": "server", "cpuLimit": 8, "memoryLimit": 4096}},
+	})))
+
+	for _, run := range env.exec.Runs() {
+		if run.Config.Labels[executor.LabelService] != "server" {
+			continue
+		}
+		if run.Config.CPULimit != 2 || run.Config.MemoryLimit != 256 {
+			t.Errorf("service limits = %g cores %d MB, want 2 cores 256 MB", run.Config.CPULimit, run.Config.MemoryLimit)
+		}
+		return
+	}
+	t.Fatal("service container was not started")
+}
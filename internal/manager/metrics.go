@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+// 容器上报指标的数量与长度上限
+const (
+	maxMetrics          = 64
+	maxMetricNameLength = 64
+	maxMetricUnitLength = 16
+)
+
+// onMetric 记录容器上报的指标，同名指标以最后一次为准。
+// 指标只在本地合并，随之后的详情上报或在 Complete 时统一上传，不会为每条消息调用 API
+func (r *reporter) onMetric(body *judgerproto.MetricBody) error {
+	if body.Name == "" {
+		return fmt.Errorf("metric name is empty")
+	}
+	if len(body.Name) > maxMetricNameLength || len(body.Unit) > maxMetricUnitLength {
+		return fmt.Errorf("metric name or unit is too long (limits %d and %d bytes)", maxMetricNameLength, maxMetricUnitLength)
+	}
+	if math.IsNaN(body.Value) || math.IsInf(body.Value, 0) {
+		return fmt.Errorf("metric %s has invalid value %v", body.Name, body.Value)
+	}
+	metric := &aoiclient.SolutionMetric{Name: body.Name, Value: body.Value, Unit: body.Unit}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := slices.IndexFunc(r.metrics, func(m *aoiclient.SolutionMetric) bool { return m.Name == body.Name }); i >= 0 {
+		r.metrics[i] = metric
+	} else if len(r.metrics) < maxMetrics {
+		r.metrics = append(r.metrics, metric)
+	} else {
+		// 只提示一次，避免循环上报的容器刷屏
+		if r.metricsDropped {
+			return nil
+		}
+		r.metricsDropped = true
+		return fmt.Errorf("too many metrics, ignoring %s and later new metrics (limit %d)", body.Name, maxMetrics)
+	}
+	r.metricsDirty = r.raw != nil
+	return nil
+}
+
+// withMetrics 返回合并了容器上报指标的详情副本，与详情中已有的同名指标以容器上报为准
+func withMetrics(details *aoiclient.SolutionDetails, metrics []*aoiclient.SolutionMetric) *aoiclient.SolutionDetails {
+	copied := *details
+	copied.Metrics = slices.DeleteFunc(slices.Clone(details.Metrics), func(existing *aoiclient.SolutionMetric) bool {
+		return slices.ContainsFunc(metrics, func(m *aoiclient.SolutionMetric) bool { return m.Name == existing.Name })
+	})
+	copied.Metrics = append(copied.Metrics, metrics...)
+	return &copied
+}
@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/judgerproto"
+)
+
+func TestMetricsAreCappedAndCoalesced(t *testing.T) {
+	stdout := []string{
+		judgerproto.NewPatchMessage(&judgerproto.PatchBody{Score: 100, Status: aoiclient.StatusAccepted}).String(),
+		judgerproto.NewDetailMessage(&judgerproto.DetailBody{Summary: "done"}).String(),
+		judgerproto.NewMetricMessage(&judgerproto.MetricBody{Name: strings.Repeat("x", maxMetricNameLength+1), Value: 1}).String(),
+	}
+	for i := range maxMetrics + 20 {
+		for range 5 {
+			stdout = append(stdout, judgerproto.NewMetricMessage(&judgerproto.MetricBody{Name: fmt.Sprintf("m%d", i), Value: float64(i)}).String())
+		}
+	}
+	env := newTestEnv(t, executortest.Script{Stdout: stdout})
+	task := env.judge(aoitest.NewSolution("s1", "t1", "metrics", "none", judgeConfig(nil)))
+
+	if task.Details == nil {
+		t.Fatal("details were not uploaded")
+	}
+	if n := len(task.Details.Metrics); n != maxMetrics {
+		t.Errorf("got %d metrics, want %d", n, maxMetrics)
+	}
+	// 协议详情一次，Complete 时附带指标再上报一次
+	if n := env.aoi.Requests(aoitest.EndpointUpload); n > 2 {
+		t.Errorf("details were uploaded %d times for %d metric messages", n, len(stdout))
+	}
+}
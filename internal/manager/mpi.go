@@ -0,0 +1,483 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	mpiHostfileTarget = "/etc/mpi/hostfile"
+	mpiStartTimeout   = 5 * time.Minute  // 请求 peer 启动 worker 的超时，包含 peer 拉取镜像的时间
+	mpiReleaseTimeout = 30 * time.Second // 请求 peer 回收 worker 的超时
+	mpiWorkerGrace    = 5 * time.Minute  // lead 未回收时，peer 在评测时限之后多久自行回收 worker
+)
+
+// MPIConfig 多节点 MPI 评测配置。领取任务的 runner 作为 lead，通过内部 RPC
+// 请求 mpi-peers 中的其他 runner 各启动一个 worker 容器。评测容器与 worker 都以 --network host
+// 运行，与宿主机共享网络命名空间，可以访问宿主机上监听的全部端口，只应用于可信的题目与隔离的计算节点。
+// 评测容器通过 /etc/mpi/hostfile 获得各节点地址（lead 在首行），由 mpirun 在真实网络上启动进程。
+// worker 不挂载任何目录，peer 按自身的用户、安全配置、设备与资源上限重新生成其执行配置
+type MPIConfig struct {
+	Nodes        int      `json:"nodes"`          // 节点数（含 lead），至少为 2
+	SlotsPerNode int      `json:"slots_per_node"` // 每个节点的进程槽位，默认为 CPU 限制取整（至少为 1）
+	WorkerCmd    []string `json:"worker_cmd"`     // worker 容器的命令，如在专用端口上运行的 sshd
+}
+
+// mpiWorkerRequest lead 请求 peer 启动 worker
+type mpiWorkerRequest struct {
+	LeadRunner string         `json:"leadRunner"`
+	SolutionID string         `json:"solutionId"`
+	TaskID     string         `json:"taskId"`
+	Worker     *mpiWorkerSpec `json:"worker"`
+}
+
+// mpiWorkerSpec worker 的配置，只包含 judge config 可以选择的项。
+// 用户、安全配置与设备以 judge config 中的名称传递，由 peer 按自身策略解析
+type mpiWorkerSpec struct {
+	Image          string            `json:"image"`
+	Command        []string          `json:"command"`
+	Env            map[string]string `json:"env"`
+	WorkDir        string            `json:"workDir"`
+	Timeout        time.Duration     `json:"timeout"`
+	MemoryLimit    int64             `json:"memoryLimit"`
+	CPULimit       float64           `json:"cpuLimit"`
+	User           string            `json:"user"`
+	Seccomp        string            `json:"seccomp"`
+	AppArmor       string            `json:"apparmor"`
+	SELinuxLabel   string            `json:"selinuxLabel"`
+	Runtime        string            `json:"runtime"`
+	Devices        []string          `json:"devices"`
+	ReadOnlyRootfs bool              `json:"readOnlyRootfs"`
+	TmpfsSize      int64             `json:"tmpfsSize"`
+}
+
+// mpiWorkerResponse peer 启动 worker 后返回容器 ID 与写入 hostfile 的地址
+type mpiWorkerResponse struct {
+	ID   string `json:"id"`
+	Host string `json:"host"`
+}
+
+// mpiWorker lead 侧记录的远程 worker
+type mpiWorker struct {
+	peer string // peer 的 RPC 地址
+	id   string
+	host string
+}
+
+// mpiPeers 返回配置的 peer RPC 地址
+func (m *Manager) mpiPeers() []string {
+	if m.conf.MPIPeers == nil {
+		return nil
+	}
+	var peers []string
+	for _, peer := range strings.Split(*m.conf.MPIPeers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// mpiAdvertise 返回本节点写入 hostfile 的地址
+func (m *Manager) mpiAdvertise() string {
+	if m.conf.MPIAdvertise != nil && *m.conf.MPIAdvertise != "" {
+		return *m.conf.MPIAdvertise
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+func (m *Manager) mpiToken() string {
+	if m.conf.MPIToken == nil {
+		return ""
+	}
+	return *m.conf.MPIToken
+}
+
+// mpiClient 返回访问 peer 的 HTTP 客户端，配置了 mpi-tls-ca 时以该 CA 校验 https:// peer 的证书
+func (m *Manager) mpiClient() (*http.Client, error) {
+	m.mpiClientOnce.Do(func() {
+		m.mpiHTTP = http.DefaultClient
+		if m.conf.MPITLSCA == nil || *m.conf.MPITLSCA == "" {
+			return
+		}
+		pem, err := os.ReadFile(*m.conf.MPITLSCA)
+		if err != nil {
+			m.mpiClientErr = fmt.Errorf("failed to read mpi-tls-ca: %w", err)
+			return
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			m.mpiClientErr = fmt.Errorf("mpi-tls-ca contains no certificates")
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		m.mpiHTTP = &http.Client{Transport: transport}
+	})
+	return m.mpiHTTP, m.mpiClientErr
+}
+
+// mpiURL 将 peer 地址（host:port 或完整 URL）拼接为 RPC 地址，未指定协议时使用明文 HTTP
+func mpiURL(peer, path string) string {
+	if !strings.Contains(peer, "://") {
+		peer = "http://" + peer
+	}
+	return strings.TrimSuffix(peer, "/") + path
+}
+
+// mpiCall 向 peer 发送 RPC 请求，out 不为 nil 时解析响应
+func (m *Manager) mpiCall(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.mpiToken())
+	client, err := m.mpiClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("peer returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// startMPI 在 peer 上启动 worker 并生成 hostfile，返回回收 worker 的清理函数。
+// peer 按配置顺序尝试，不可用的节点被跳过
+func (m *Manager) startMPI(job *Job, mpi *MPIConfig) (func(), error) {
+	rc := job.rc
+	if rc.IsolatedNetwork || len(rc.Services) > 0 || rc.NetworkShaping != nil {
+		return nil, fmt.Errorf("mpi cannot be combined with isolated_network, services or network_shaping")
+	}
+	if mpi.Nodes < 2 {
+		return nil, fmt.Errorf("mpi.nodes must be at least 2")
+	}
+	if len(mpi.WorkerCmd) == 0 {
+		return nil, fmt.Errorf("mpi.worker_cmd is required")
+	}
+	if m.mpiToken() == "" {
+		return nil, fmt.Errorf("this runner has no mpi-token configured")
+	}
+	peers := m.mpiPeers()
+	if len(peers) < mpi.Nodes-1 {
+		return nil, fmt.Errorf("MPI job needs %d peer runners, %d configured", mpi.Nodes-1, len(peers))
+	}
+
+	execConfig := job.execConfig
+	slots := mpi.SlotsPerNode
+	if slots <= 0 {
+		slots = max(int(execConfig.CPULimit), 1)
+	}
+
+	worker := &mpiWorkerSpec{
+		Image:          execConfig.Image,
+		Command:        mpi.WorkerCmd,
+		Env:            execConfig.Env,
+		WorkDir:        execConfig.WorkDir,
+		Timeout:        execConfig.Timeout,
+		MemoryLimit:    execConfig.MemoryLimit,
+		CPULimit:       execConfig.CPULimit,
+		User:           rc.User,
+		Seccomp:        rc.Seccomp,
+		AppArmor:       rc.AppArmor,
+		SELinuxLabel:   rc.SELinuxLabel,
+		Runtime:        rc.Runtime,
+		Devices:        rc.Devices,
+		ReadOnlyRootfs: rc.ReadOnlyRootfs,
+		TmpfsSize:      rc.TmpfsSize,
+	}
+
+	var workers []*mpiWorker
+	cleanup := func() {
+		for _, w := range workers {
+			ctx, cancel := context.WithTimeout(context.Background(), mpiReleaseTimeout)
+			if err := m.mpiCall(ctx, http.MethodDelete, mpiURL(w.peer, "/mpi/workers/"+w.id), nil, nil); err != nil {
+				log.Printf("Solution %s: failed to release MPI worker on %s: %v", job.SolutionID, w.peer, err)
+			}
+			cancel()
+		}
+	}
+
+	for _, peer := range peers {
+		if len(workers) == mpi.Nodes-1 {
+			break
+		}
+		ctx, cancel := context.WithTimeout(m.ctx, mpiStartTimeout)
+		res := &mpiWorkerResponse{}
+		err := m.mpiCall(ctx, http.MethodPost, mpiURL(peer, "/mpi/workers"), &mpiWorkerRequest{
+			LeadRunner: *m.conf.RunnerID,
+			SolutionID: job.SolutionID,
+			TaskID:     job.TaskID,
+			Worker:     worker,
+		}, res)
+		cancel()
+		if err != nil {
+			log.Printf("Solution %s: MPI peer %s unavailable: %v", job.SolutionID, peer, err)
+			continue
+		}
+		host := res.Host
+		if host == "" {
+			host, _, _ = net.SplitHostPort(strings.TrimPrefix(strings.TrimPrefix(peer, "http://"), "https://"))
+		}
+		workers = append(workers, &mpiWorker{peer: peer, id: res.ID, host: host})
+		log.Printf("Solution %s: started MPI worker %s on %s", job.SolutionID, res.ID, host)
+	}
+	if len(workers) < mpi.Nodes-1 {
+		cleanup()
+		return nil, fmt.Errorf("only %d of %d MPI peers are available", len(workers), mpi.Nodes-1)
+	}
+
+	var hostfile strings.Builder
+	fmt.Fprintf(&hostfile, "%s slots=%d\n", m.mpiAdvertise(), slots)
+	for _, w := range workers {
+		fmt.Fprintf(&hostfile, "%s slots=%d\n", w.host, slots)
+	}
+	f, err := os.CreateTemp(m.workDir(), fmt.Sprintf("mpi-hostfile-%s-", job.SolutionID))
+	if err == nil {
+		_, err = f.WriteString(hostfile.String())
+		f.Close()
+	}
+	if err == nil {
+		// 评测容器以非 root 用户运行，需要可读
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err != nil {
+		if f != nil {
+			os.Remove(f.Name())
+		}
+		cleanup()
+		return nil, fmt.Errorf("failed to write MPI hostfile: %w", err)
+	}
+
+	execConfig.Network = "host"
+	execConfig.NetworkDisabled = false
+	execConfig.Mounts = append(execConfig.Mounts, executor.Mount{
+		Source:   f.Name(),
+		Target:   mpiHostfileTarget,
+		ReadOnly: true,
+	})
+	execConfig.Env["MPI_HOSTFILE"] = mpiHostfileTarget
+	execConfig.Env["MPI_NODES"] = strconv.Itoa(mpi.Nodes)
+	execConfig.Env["MPI_SLOTS_PER_NODE"] = strconv.Itoa(slots)
+
+	return func() {
+		cleanup()
+		os.Remove(f.Name())
+	}, nil
+}
+
+// mpiPeer peer 侧为其他 runner 运行的 worker，lead 未回收时到期自动回收
+type mpiPeer struct {
+	m       *Manager
+	mu      sync.Mutex
+	workers map[string]*time.Timer
+}
+
+// serveMPI 接受 lead 的 worker 请求直到 ctx 结束，退出前回收全部 worker。
+// 配置了 mpi-tls-cert 时使用 TLS，否则令牌以明文传输，mpi-listen 只应监听可信网络
+func (m *Manager) serveMPI(ctx context.Context) {
+	if m.mpiToken() == "" {
+		log.Println("MPI RPC is disabled: mpi-token is required")
+		return
+	}
+	p := &mpiPeer{m: m, workers: make(map[string]*time.Timer)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /mpi/workers", p.handleStart)
+	mux.HandleFunc("DELETE /mpi/workers/{id}", p.handleRelease)
+	srv := &http.Server{
+		Addr:              *m.conf.MPIListen,
+		Handler:           bearerAuth(m.mpiToken(), mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := context.AfterFunc(ctx, func() { srv.Close() })
+	defer stop()
+
+	var err error
+	if m.conf.MPITLSCert != nil && *m.conf.MPITLSCert != "" {
+		log.Printf("Accepting MPI worker requests on %s over TLS", srv.Addr)
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		key := ""
+		if m.conf.MPITLSKey != nil {
+			key = *m.conf.MPITLSKey
+		}
+		err = srv.ListenAndServeTLS(*m.conf.MPITLSCert, key)
+	} else {
+		log.Printf("Accepting MPI worker requests on %s over plain HTTP, keep it on a trusted network or set mpi-tls-cert", srv.Addr)
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("MPI RPC server stopped: %v", err)
+	}
+	p.releaseAll()
+}
+
+// handleStart 启动 worker 容器。请求只提供 judge config 可以选择的项，
+// 执行配置由 peer 按自身的用户、安全配置、设备允许列表与资源上限重新生成，
+// 网络、挂载与标签由 peer 决定，不支持检查点恢复、大页与 GPU
+func (p *mpiPeer) handleStart(w http.ResponseWriter, r *http.Request) {
+	req := &mpiWorkerRequest{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec := req.Worker
+	if spec == nil || spec.Image == "" || len(spec.Command) == 0 || spec.Timeout <= 0 {
+		http.Error(w, "worker with image, command and timeout is required", http.StatusBadRequest)
+		return
+	}
+	config, err := p.workerConfig(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := p.m.exec.EnsureImage(r.Context(), config.Image); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id, err := p.m.exec.StartDetached(r.Context(), config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Started MPI worker %s for solution %s (lead %s)", id, req.SolutionID, req.LeadRunner)
+
+	p.mu.Lock()
+	p.workers[id] = time.AfterFunc(config.Timeout+mpiWorkerGrace, func() {
+		if p.release(id) {
+			log.Printf("MPI worker %s for solution %s expired", id, req.SolutionID)
+		}
+	})
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&mpiWorkerResponse{ID: id, Host: p.m.mpiAdvertise()})
+}
+
+// workerConfig 按本 runner 的策略生成 worker 的执行配置，与本 runner 评测容器的检查相同
+func (p *mpiPeer) workerConfig(req *mpiWorkerRequest) (*executor.ExecuteConfig, error) {
+	m, spec := p.m, req.Worker
+	workDir := spec.WorkDir
+	if workDir == "" {
+		workDir = "/home/judge"
+	}
+	config := &executor.ExecuteConfig{
+		Image:       spec.Image,
+		Command:     spec.Command,
+		Env:         make(map[string]string),
+		WorkDir:     workDir,
+		Timeout:     spec.Timeout,
+		MemoryLimit: spec.MemoryLimit,
+		CPULimit:    spec.CPULimit,
+		Network:     "host",
+		Labels: map[string]string{
+			executor.LabelRunnerID:   *m.conf.RunnerID,
+			executor.LabelSolutionID: req.SolutionID,
+			executor.LabelTaskID:     req.TaskID,
+			executor.LabelMPILead:    req.LeadRunner,
+		},
+	}
+	m.applyResourceLimits(req.SolutionID, config)
+
+	rc := &RunningConfig{
+		User:           spec.User,
+		Seccomp:        spec.Seccomp,
+		AppArmor:       spec.AppArmor,
+		SELinuxLabel:   spec.SELinuxLabel,
+		Runtime:        spec.Runtime,
+		Devices:        spec.Devices,
+		ReadOnlyRootfs: spec.ReadOnlyRootfs,
+		TmpfsSize:      spec.TmpfsSize,
+	}
+	if err := m.resolveUser(rc, config); err != nil {
+		return nil, err
+	}
+	if err := m.applySecurityProfiles(rc, config); err != nil {
+		return nil, err
+	}
+	if err := m.resolveDevices(rc, config); err != nil {
+		return nil, err
+	}
+	for k, v := range spec.Env {
+		if m.allowEnv(k) {
+			config.Env[k] = v
+		}
+	}
+	if rc.ReadOnlyRootfs {
+		applyReadOnlyRootfs(rc, config)
+	}
+	return config, nil
+}
+
+// handleRelease 回收 worker 容器
+func (p *mpiPeer) handleRelease(w http.ResponseWriter, r *http.Request) {
+	if !p.release(r.PathValue("id")) {
+		http.Error(w, "no such worker", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// release 停止并删除 worker，不存在时返回 false
+func (p *mpiPeer) release(id string) bool {
+	p.mu.Lock()
+	timer, ok := p.workers[id]
+	delete(p.workers, id)
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	if err := p.m.exec.Cleanup(context.Background(), id); err != nil {
+		log.Printf("Failed to remove MPI worker %s: %v", id, err)
+	}
+	return true
+}
+
+// releaseAll 回收全部 worker
+func (p *mpiPeer) releaseAll() {
+	p.mu.Lock()
+	ids := make([]string, 0, len(p.workers))
+	for id := range p.workers {
+		ids = append(ids, id)
+	}
+	p.mu.Unlock()
+	for _, id := range ids {
+		p.release(id)
+	}
+}
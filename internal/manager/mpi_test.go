@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+)
+
+func TestMPIPeerAppliesItsOwnPolicy(t *testing.T) {
+	exec := executortest.NewFake()
+	m := NewManager(&config.ManagerConfig{
+		RunnerID:       ptr("peer"),
+		MaxTimeout:     ptr[int64](60),
+		MaxMemoryLimit: ptr[int64](512),
+		MaxCPULimit:    ptr(2.0),
+	})
+	m.exec = exec
+	p := &mpiPeer{m: m, workers: make(map[string]*time.Timer)}
+	defer p.releaseAll()
+
+	start := func(spec *mpiWorkerSpec) int {
+		body, _ := json.Marshal(&mpiWorkerRequest{LeadRunner: "lead", SolutionID: "s1", TaskID: "t1", Worker: spec})
+		w := httptest.NewRecorder()
+		p.handleStart(w, httptest.NewRequest(http.MethodPost, "/mpi/workers", bytes.NewReader(body)))
+		return w.Code
+	}
+	base := func() *mpiWorkerSpec {
+		return &mpiWorkerSpec{
+			Image:       "mpi:latest",
+			Command:     []string{"sshd", "-D"},
+			Env:         map[string]string{"OMP_NUM_THREADS": "4", "LD_PRELOAD": "/tmp/evil.so"},
+			Timeout:     time.Hour,
+			MemoryLimit: 8192,
+			CPULimit:    16,
+		}
+	}
+
+	for name, spec := range map[string]func(*mpiWorkerSpec){
+		"root user":          func(s *mpiWorkerSpec) { s.User = "0:0" },
+		"unconfined seccomp": func(s *mpiWorkerSpec) { s.Seccomp = seccompUnconfined },
+		"apparmor":           func(s *mpiWorkerSpec) { s.AppArmor = "unconfined" },
+		"device":             func(s *mpiWorkerSpec) { s.Devices = []string{"/dev/mem"} },
+		"runtime":            func(s *mpiWorkerSpec) { s.Runtime = "runc-privileged" },
+	} {
+		s := base()
+		spec(s)
+		if code := start(s); code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want %d", name, code, http.StatusForbidden)
+		}
+	}
+	if runs := exec.Runs(); len(runs) != 0 {
+		t.Fatalf("started %d workers for rejected requests", len(runs))
+	}
+
+	if code := start(base()); code != http.StatusOK {
+		t.Fatalf("status %d, want %d", code, http.StatusOK)
+	}
+	runs := exec.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("got %d workers, want 1", len(runs))
+	}
+	got := runs[0].Config
+	if got.Timeout != time.Minute || got.MemoryLimit != 512 || got.CPULimit != 2 {
+		t.Errorf("limits = %s %d MB %g cores, want the peer maximum", got.Timeout, got.MemoryLimit, got.CPULimit)
+	}
+	if isRootUser(got.User) {
+		t.Errorf("worker runs as %q", got.User)
+	}
+	if _, ok := got.Env["LD_PRELOAD"]; ok {
+		t.Error("denied env LD_PRELOAD was passed to the worker")
+	}
+	if got.Network != "host" || len(got.Mounts) != 0 || got.RestoreFrom != "" || got.HugePages != 0 {
+		t.Errorf("worker config = %+v, want host network without mounts, restore or hugepages", got)
+	}
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/netip"
+	"sync"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// subnetAllocator 从 IPv6 前缀池中为每次评测分配独立的 /64 子网
+type subnetAllocator struct {
+	prefix netip.Prefix
+
+	mu   sync.Mutex
+	used map[uint64]bool
+	next uint64
+}
+
+// newSubnetAllocator 解析前缀池，前缀长度需在 /32 到 /63 之间
+func newSubnetAllocator(pool string) (*subnetAllocator, error) {
+	prefix, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPv6 subnet pool: %w", err)
+	}
+	if !prefix.Addr().Is6() || prefix.Bits() < 32 || prefix.Bits() > 63 {
+		return nil, fmt.Errorf("IPv6 subnet pool must be an IPv6 prefix between /32 and /63")
+	}
+	return &subnetAllocator{prefix: prefix.Masked(), used: make(map[uint64]bool)}, nil
+}
+
+// allocate 分配一个未使用的 /64 子网
+func (a *subnetAllocator) allocate() (netip.Prefix, uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	size := uint64(1) << (64 - a.prefix.Bits())
+	for i := uint64(0); i < size; i++ {
+		idx := (a.next + i) % size
+		if a.used[idx] {
+			continue
+		}
+		a.used[idx] = true
+		a.next = idx + 1
+
+		raw := a.prefix.Addr().As16()
+		high := binary.BigEndian.Uint64(raw[:8]) | idx
+		binary.BigEndian.PutUint64(raw[:8], high)
+		return netip.PrefixFrom(netip.AddrFrom16(raw), 64), idx, nil
+	}
+	return netip.Prefix{}, 0, fmt.Errorf("IPv6 subnet pool %s exhausted", a.prefix)
+}
+
+func (a *subnetAllocator) release(idx uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.used, idx)
+}
+
+// createJobNetwork 为任务创建独立网络，配置了 IPv6 前缀池时为双栈，返回清理函数
+func (m *Manager) createJobNetwork(job *Job, internal bool) (string, func(), error) {
+	config := &executor.NetworkConfig{
+		Name:     fmt.Sprintf("lfs-%s-%s", *m.conf.RunnerID, job.SolutionID),
+		Labels:   job.execConfig.Labels,
+		Internal: internal,
+	}
+
+	release := func() {}
+	if m.subnets != nil {
+		subnet, idx, err := m.subnets.allocate()
+		if err != nil {
+			return "", nil, err
+		}
+		config.IPv6Subnet = subnet.String()
+		release = func() { m.subnets.release(idx) }
+	}
+
+	ctx, cancel := context.WithTimeout(job.ctx, dockerCallTimeout)
+	defer cancel()
+	id, err := job.exec.CreateNetwork(ctx, config)
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return config.Name, func() {
+		if err := job.exec.RemoveNetwork(context.Background(), id); err != nil {
+			log.Printf("Failed to remove network %s: %v", config.Name, err)
+		}
+		release()
+	}, nil
+}
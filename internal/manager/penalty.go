@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// defaultDeadlineVariable 默认从该题目变量读取截止时间
+const defaultDeadlineVariable = "deadline"
+
+// LatePenaltyConfig 迟交处罚：按提交时间晚于截止时间的小时数（不足一小时按一小时计）扣除分数
+type LatePenaltyConfig struct {
+	PercentPerHour float64 `json:"percent_per_hour"` // 每迟交一小时扣除的百分比
+	Cap            float64 `json:"cap"`              // 最多扣除的百分比，默认 100
+	Variable       string  `json:"variable"`         // 存放截止时间的题目变量名，默认 deadline；值为 RFC 3339 时间或 Unix 秒数
+}
+
+// latePenalty 一次迟交提交的处罚
+type latePenalty struct {
+	deadline    time.Time
+	submittedAt time.Time
+	hours       int     // 迟交的小时数
+	percent     float64 // 扣除的百分比
+	policy      *adapters.ScorePolicy
+}
+
+// computeLatePenalty 根据提交时间与截止时间计算处罚，未迟交或平台未提供提交时间时返回 nil
+func computeLatePenalty(soln *aoiclient.SolutionPoll, rc *RunningConfig) (*latePenalty, error) {
+	cfg := rc.LatePenalty
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.PercentPerHour < 0 || cfg.Cap < 0 || cfg.Cap > 100 {
+		return nil, fmt.Errorf("invalid late penalty: percent_per_hour must not be negative and cap must be within 0-100")
+	}
+	name := cfg.Variable
+	if name == "" {
+		name = defaultDeadlineVariable
+	}
+	deadline, err := parseDeadline(rc.Variables[name])
+	if err != nil {
+		return nil, fmt.Errorf("invalid late penalty deadline variable %q: %w", name, err)
+	}
+	if soln.SubmittedAt.IsZero() || !soln.SubmittedAt.After(deadline) {
+		return nil, nil
+	}
+
+	late := soln.SubmittedAt.Sub(deadline)
+	hours := int(math.Ceil(late.Hours()))
+	limit := cfg.Cap
+	if limit == 0 {
+		limit = 100
+	}
+	return &latePenalty{
+		deadline:    deadline,
+		submittedAt: soln.SubmittedAt,
+		hours:       hours,
+		percent:     math.Min(float64(hours)*cfg.PercentPerHour, limit),
+		policy:      rc.Score,
+	}, nil
+}
+
+// parseDeadline 解析 RFC 3339 时间或 Unix 秒数
+func parseDeadline(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(sec, 0), nil
+		}
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a unix timestamp", v)
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case nil:
+		return time.Time{}, fmt.Errorf("not set")
+	}
+	return time.Time{}, fmt.Errorf("unsupported value %v", v)
+}
+
+// apply 返回扣除处罚后的分数，按题目的计分规则取整
+func (p *latePenalty) apply(score float64) float64 {
+	return p.policy.Round(score * (1 - p.percent/100))
+}
+
+// withLatePenalty 返回扣除迟交处罚后的结果副本
+func withLatePenalty(info *aoiclient.SolutionInfo, p *latePenalty) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	metrics["late_hours"] = float64(p.hours)
+	metrics["late_penalty_percent"] = p.percent
+
+	copied := *info
+	copied.Score = p.apply(info.Score)
+	copied.Metrics = &metrics
+	if info.Score > 0 && copied.Message != "" {
+		copied.Message += fmt.Sprintf("（迟交 %d 小时，扣除 %g%%）", p.hours, p.percent)
+	}
+	return &copied
+}
+
+// withPenaltySummary 返回在摘要末尾附带迟交处罚明细的详情副本，rawScore 为处罚前的分数，未知时为 nil
+func withPenaltySummary(details *aoiclient.SolutionDetails, p *latePenalty, rawScore *float64) *aoiclient.SolutionDetails {
+	copied := *details
+	line := fmt.Sprintf("迟交处罚：截止 %s，提交 %s，迟交 %d 小时，扣除 %g%%",
+		p.deadline.Local().Format(time.DateTime), p.submittedAt.Local().Format(time.DateTime), p.hours, p.percent)
+	if rawScore != nil {
+		line += fmt.Sprintf("，原始分 %g，处罚后 %g", *rawScore, p.apply(*rawScore))
+	}
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += line
+	return &copied
+}
@@ -0,0 +1,270 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// PerfConfig 硬件性能计数器配置。计数由 manager 在宿主机上对评测容器的 cgroup 运行 perf stat 完成，
+// 覆盖容器内的全部进程，选手程序无法篡改；需要宿主机安装 perf 且评测容器运行在 manager 所在主机
+type PerfConfig struct {
+	Events []string `json:"events"` // 计数的事件（perf list 中的名称），默认 instructions、cycles、cache-references、cache-misses、branch-misses
+	FLOPs  bool     `json:"flops"`  // 额外计数 Intel 浮点运算事件并估算浮点运算次数，其他平台上这些事件不受支持时忽略
+}
+
+var defaultPerfEvents = []string{"instructions", "cycles", "cache-references", "cache-misses", "branch-misses"}
+
+// flopsEvents Intel 浮点运算退役指令事件及每条指令对应的浮点运算次数
+var flopsEvents = map[string]float64{
+	"fp_arith_inst_retired.scalar_double":      1,
+	"fp_arith_inst_retired.scalar_single":      1,
+	"fp_arith_inst_retired.128b_packed_double": 2,
+	"fp_arith_inst_retired.128b_packed_single": 4,
+	"fp_arith_inst_retired.256b_packed_double": 4,
+	"fp_arith_inst_retired.256b_packed_single": 8,
+	"fp_arith_inst_retired.512b_packed_double": 8,
+	"fp_arith_inst_retired.512b_packed_single": 16,
+}
+
+var perfEventPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// events 返回需要计数的事件
+func (c *PerfConfig) events() ([]string, error) {
+	events := c.Events
+	if len(events) == 0 {
+		events = defaultPerfEvents
+	}
+	for _, e := range events {
+		if !perfEventPattern.MatchString(e) {
+			return nil, fmt.Errorf("invalid perf event %q", e)
+		}
+	}
+	if c.FLOPs {
+		events = append(slices.Clone(events), slices.Sorted(maps.Keys(flopsEvents))...)
+	}
+	return events, nil
+}
+
+// perfCounters perf stat 的计数结果
+type perfCounters struct {
+	Events []string           // 按配置顺序排列的受支持事件
+	Values map[string]float64 // 事件 -> 计数
+	FLOPs  float64            // 由浮点事件估算的浮点运算次数，未计数时为 0
+}
+
+// perfRecorder 在评测容器启动后对其 cgroup 运行 perf stat，停止时解析计数
+type perfRecorder struct {
+	solutionID string
+	events     []string
+	output     string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stopped bool
+}
+
+// watchPerf 在 judge config 要求时准备性能计数，容器启动后由 trackContainer 开始计数。
+// 返回停止计数的函数，结果记录到 job.perf
+func (m *Manager) watchPerf(job *Job) func() {
+	if job.rc.Perf == nil {
+		return func() {}
+	}
+	// 事件名已在 buildExecuteConfig 中校验
+	events, _ := job.rc.Perf.events()
+	rec := &perfRecorder{
+		solutionID: job.SolutionID,
+		events:     events,
+		output:     filepath.Join(m.scratchDir(), fmt.Sprintf("perf-%s.csv", job.SolutionID)),
+	}
+	m.runningMu.Lock()
+	job.perfRecorder = rec
+	m.runningMu.Unlock()
+	return func() {
+		m.runningMu.Lock()
+		job.perfRecorder = nil
+		m.runningMu.Unlock()
+		job.perf = rec.stop()
+	}
+}
+
+// attach 对容器的 cgroup 开始计数，只对评测的第一个容器生效
+func (r *perfRecorder) attach(containerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cmd != nil || r.stopped {
+		return
+	}
+	cgroup, err := containerCgroup(containerID)
+	if err != nil {
+		log.Printf("Solution %s: perf counters are not collected: %v", r.solutionID, err)
+		r.stopped = true
+		return
+	}
+	args := []string{"stat", "-x", ",", "-o", r.output, "-a", "-e", strings.Join(r.events, ","), "-G", cgroup}
+	cmd := exec.Command("perf", args...)
+	if err := cmd.Start(); err != nil {
+		log.Printf("Solution %s: failed to start perf: %v", r.solutionID, err)
+		r.stopped = true
+		return
+	}
+	r.cmd = cmd
+}
+
+// stop 结束计数并解析结果，未开始计数时返回 nil
+func (r *perfRecorder) stop() *perfCounters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	if r.cmd == nil {
+		return nil
+	}
+	defer os.Remove(r.output)
+	// perf stat 收到 SIGINT 后输出计数并退出
+	r.cmd.Process.Signal(syscall.SIGINT)
+	done := make(chan struct{})
+	go func() {
+		r.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		r.cmd.Process.Kill()
+		<-done
+	}
+	counters, err := parsePerfStat(r.output, r.events)
+	if err != nil {
+		log.Printf("Solution %s: failed to parse perf output: %v", r.solutionID, err)
+		return nil
+	}
+	return counters
+}
+
+// containerCgroup 查找容器在 cgroup 层级中的路径（相对于 perf_event 层级的根），
+// 支持 Docker 与 containerd 的 systemd 及 cgroupfs 驱动
+func containerCgroup(containerID string) (string, error) {
+	roots := []string{"/sys/fs/cgroup/perf_event", "/sys/fs/cgroup"}
+	patterns := []string{
+		"system.slice/docker-%s.scope",
+		"system.slice/nerdctl-%s.scope",
+		"docker/%s",
+		"*/%s",
+	}
+	for _, root := range roots {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		for _, p := range patterns {
+			matches, _ := filepath.Glob(filepath.Join(root, fmt.Sprintf(p, containerID)))
+			if len(matches) > 0 {
+				return filepath.Rel(root, matches[0])
+			}
+		}
+	}
+	return "", fmt.Errorf("cgroup of container %s not found on this host", containerID)
+}
+
+// parsePerfStat 解析 perf stat -x , 的输出：计数,单位,事件,...；不受支持的事件计数为 <not supported>
+func parsePerfStat(path string, events []string) (*perfCounters, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		values[fields[2]] += v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	counters := &perfCounters{Values: values}
+	for _, e := range events {
+		if _, ok := values[e]; !ok {
+			continue
+		}
+		if weight, ok := flopsEvents[e]; ok {
+			counters.FLOPs += weight * values[e]
+			continue
+		}
+		counters.Events = append(counters.Events, e)
+	}
+	return counters, nil
+}
+
+// perfMetricName 将事件名转换为指标名，如 cache-misses -> perf_cache_misses
+func perfMetricName(event string) string {
+	return "perf_" + strings.NewReplacer("-", "_", ".", "_", ":", "_").Replace(event)
+}
+
+// withPerfMetrics 返回附带性能计数的结果副本
+func withPerfMetrics(info *aoiclient.SolutionInfo, counters *perfCounters) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	for _, e := range counters.Events {
+		metrics[perfMetricName(e)] = counters.Values[e]
+	}
+	if counters.FLOPs > 0 {
+		metrics["perf_flops"] = counters.FLOPs
+	}
+
+	copied := *info
+	copied.Metrics = &metrics
+	return &copied
+}
+
+// withPerfSummary 返回在摘要末尾附带性能计数的详情副本
+func withPerfSummary(details *aoiclient.SolutionDetails, counters *perfCounters) *aoiclient.SolutionDetails {
+	var parts []string
+	for _, e := range counters.Events {
+		parts = append(parts, fmt.Sprintf("%s %.0f", e, counters.Values[e]))
+	}
+	if counters.FLOPs > 0 {
+		parts = append(parts, fmt.Sprintf("估算浮点运算 %.3g 次", counters.FLOPs))
+	}
+	if cycles, instructions := counters.Values["cycles"], counters.Values["instructions"]; cycles > 0 && instructions > 0 {
+		parts = append(parts, fmt.Sprintf("IPC %.2f", instructions/cycles))
+	}
+	copied := *details
+	if len(parts) == 0 {
+		return &copied
+	}
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += "性能计数：" + strings.Join(parts, "，")
+	return &copied
+}
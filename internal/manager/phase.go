@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const defaultPhaseTimeout = 300 // pre/post 阶段的默认超时（秒）
+
+// phaseError pre/post 阶段失败，上报为内部错误
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return fmt.Sprintf("%s phase failed: %v", e.phase, e.err)
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+// phaseTimeout 返回阶段超时，未配置时使用默认值
+func phaseTimeout(timeout int64) int64 {
+	if timeout <= 0 {
+		return defaultPhaseTimeout
+	}
+	return timeout
+}
+
+// phaseImage 返回 pre/post 阶段使用的镜像，指定 hook_image 时必须在 manager 信任列表中
+func (m *Manager) phaseImage(job *Job) (string, error) {
+	rc := job.rc
+	if rc.HookImage == "" {
+		return job.execConfig.Image, nil
+	}
+	var trusted []string
+	if m.conf.TrustedHookImages != nil {
+		for _, image := range strings.Split(*m.conf.TrustedHookImages, ",") {
+			trusted = append(trusted, strings.TrimSpace(image))
+		}
+	}
+	if !slices.Contains(trusted, rc.HookImage) {
+		return "", fmt.Errorf("hook image %s is not trusted by this runner", rc.HookImage)
+	}
+	return rc.HookImage, nil
+}
+
+// runPhase 在单独的容器中运行 pre/post 命令，超时独立计算，不占用主评测的时间预算。
+// 容器使用与主评测相同的挂载与安全配置，并额外禁止提权、移除全部 capability；
+// 阶段之间仅通过挂载目录（如 /output）共享数据，输出写入单独的本地日志
+func (m *Manager) runPhase(job *Job, phase string, cmd []string, timeout int64) error {
+	image, err := m.phaseImage(job)
+	if err != nil {
+		return &phaseError{phase, err}
+	}
+	if image != job.execConfig.Image {
+		if err := m.ensureImage(job, image); err != nil {
+			return &phaseError{phase, err}
+		}
+	}
+
+	config := *job.execConfig
+	config.Image = image
+	config.Command = cmd
+	config.Timeout = m.clampTimeout(job.SolutionID, time.Duration(phaseTimeout(timeout))*time.Second)
+	config.CPUTimeLimit = 0
+	config.NoNewPrivileges = true
+	config.CapDrop = []string{"ALL"}
+	if phase == "post" && job.energy != nil {
+		config.Env = energyEnv(config.Env, job.energy)
+	}
+
+	local := m.openLocalLog(job, phase)
+	defer local.close()
+
+	log.Printf("Solution %s: running %s phase (timeout %s)", job.SolutionID, phase, config.Timeout)
+	ctx, cancel := context.WithTimeout(job.execCtx, config.Timeout+10*time.Second)
+	defer cancel()
+
+	result, err := job.exec.ExecuteWithLogs(ctx, &config, func(stream executor.LogStream, line string) error {
+		slog.Info(fmt.Sprintf("[%s %s %s] %s", job.SolutionID, phase, stream, line), "solution", job.SolutionID, "source", phase, "stream", stream.String())
+		local.write(line)
+		return nil
+	})
+	if err != nil {
+		return &phaseError{phase, err}
+	}
+	switch {
+	case result.TimedOut:
+		return &phaseError{phase, fmt.Errorf("timed out after %s", config.Timeout)}
+	case result.OOM:
+		return &phaseError{phase, fmt.Errorf("ran out of memory")}
+	case result.OutputLimitExceeded:
+		return &phaseError{phase, fmt.Errorf("exceeded the output limit")}
+	case result.ExitCode != 0:
+		return &phaseError{phase, fmt.Errorf("exited with code %d", result.ExitCode)}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// AdapterStageConfig 组合评测中的一个 adapter，如 pytest 的正确性测试加上 benchmark1 的性能测试
+type AdapterStageConfig struct {
+	Adapter    string  `json:"adapter"`     // lfs1 或内置的报告类 adapter（如 benchmark1、raw）
+	ReportName string  `json:"report_name"` // 报告文件名，默认使用 adapter 的默认文件名，lfs1 支持通配符
+	Weight     float64 `json:"weight"`      // 在总分中的权重，默认 1
+	Name       string  `json:"name"`        // 详情与提示信息中的名称，默认为 adapter 名
+}
+
+func (s *AdapterStageConfig) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Adapter
+}
+
+// reportName 返回报告文件名
+func (s *AdapterStageConfig) reportName() string {
+	if s.ReportName != "" {
+		return s.ReportName
+	}
+	if ra, ok := adapters.ReportAdapters[s.Adapter]; ok {
+		return ra.ReportName
+	}
+	return "report.json"
+}
+
+func (s *AdapterStageConfig) weight() float64 {
+	if s.Weight == 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// validateAdapterStages 检查组合评测配置，外部 adapter 自行上报结果，无法参与合并
+func validateAdapterStages(stages []AdapterStageConfig) error {
+	for i, stage := range stages {
+		if _, ok := adapters.ReportAdapters[stage.Adapter]; !ok && stage.Adapter != "lfs1" {
+			return fmt.Errorf("adapters[%d]: unsupported adapter %q", i, stage.Adapter)
+		}
+		if stage.Weight < 0 || math.IsNaN(stage.Weight) || math.IsInf(stage.Weight, 0) {
+			return fmt.Errorf("adapters[%d]: invalid weight %g", i, stage.Weight)
+		}
+	}
+	return nil
+}
+
+// stageResult 一个 adapter 的百分制结果，报告不存在时 result 为 nil
+type stageResult struct {
+	stage  *AdapterStageConfig
+	result *adapters.LFS1Result
+}
+
+// runAdapterPipeline 依次运行 adapters 中配置的各个 adapter，按权重合并为一个结果。
+// 报告不存在的 adapter 计 0 分，所有报告都不存在时返回 nil；报告解析失败时返回错误，由调用方按内部错误上报
+func (m *Manager) runAdapterPipeline(job *Job) (*adapters.LFS1Result, error) {
+	rc := job.rc
+	results := make([]stageResult, 0, len(rc.Adapters))
+	found := false
+	for i := range rc.Adapters {
+		stage := &rc.Adapters[i]
+		result, err := m.runAdapterStage(job, stage)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stage.name(), err)
+		}
+		found = found || result != nil
+		results = append(results, stageResult{stage: stage, result: result})
+	}
+	if !found {
+		return nil, nil
+	}
+	return combineStageResults(results, rc.Score), nil
+}
+
+// runAdapterStage 查找并解析一个 adapter 的报告，返回百分制的结果
+func (m *Manager) runAdapterStage(job *Job, stage *AdapterStageConfig) (*adapters.LFS1Result, error) {
+	if stage.Adapter == "lfs1" {
+		pattern := stage.reportName()
+		paths := findReports(job.outputDir, []string{pattern})
+		if len(paths) == 0 {
+			log.Printf("No report matching %s found for adapter %s", pattern, stage.name())
+			return nil, nil
+		}
+		return m.adapterLimits().RunAll(job.ctx, paths, func(paths []string) (*adapters.LFS1Result, error) {
+			report, err := adapters.ParsePytestReports(paths)
+			if err != nil {
+				return nil, err
+			}
+			return adapters.CalculateScore(report, nil), nil
+		})
+	}
+
+	ra := adapters.ReportAdapters[stage.Adapter]
+	name := stage.reportName()
+	paths := findReports(job.outputDir, []string{name})
+	if len(paths) == 0 {
+		log.Printf("Report %s not found for adapter %s", name, stage.name())
+		return nil, nil
+	}
+	return m.adapterLimits().Run(job.ctx, paths[0], func(path string) (*adapters.LFS1Result, error) {
+		return ra.Parse(path, job.rc.Variables)
+	})
+}
+
+// combineStageResults 按权重加权平均各 adapter 的百分制分数后按计分规则换算。
+// 全部通过时为 Accepted，否则取第一个未通过的 adapter 的状态；详情中各测试点名称前加上 adapter 名
+func combineStageResults(results []stageResult, policy *adapters.ScorePolicy) *adapters.LFS1Result {
+	combined := &adapters.LFS1Result{Status: aoiclient.StatusAccepted}
+	details := &aoiclient.SolutionDetails{Version: 1}
+	var score, weights float64
+	var messages, summaries []string
+	for _, r := range results {
+		name, weight := r.stage.name(), r.stage.weight()
+		weights += weight
+		if r.result == nil {
+			if combined.Status == aoiclient.StatusAccepted {
+				combined.Status = aoiclient.StatusRuntimeError
+			}
+			messages = append(messages, fmt.Sprintf("%s: 未找到评测报告", name))
+			summaries = append(summaries, fmt.Sprintf("%s（权重 %g）：未找到评测报告", name, weight))
+			continue
+		}
+		score += weight * r.result.Score
+		if r.result.Status != aoiclient.StatusAccepted && combined.Status == aoiclient.StatusAccepted {
+			combined.Status = r.result.Status
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", name, r.result.Message))
+		summaries = append(summaries, fmt.Sprintf("%s（权重 %g）：%.2f / 100，%s", name, weight, r.result.Score, r.result.Status))
+		for key, value := range r.result.Metrics {
+			if combined.Metrics == nil {
+				combined.Metrics = make(map[string]float64)
+			}
+			combined.Metrics[name+"."+key] = value
+		}
+		if r.result.Details == nil {
+			continue
+		}
+		for _, job := range r.result.Details.Jobs {
+			prefixed := *job
+			prefixed.Name = name + " / " + job.Name
+			details.Jobs = append(details.Jobs, &prefixed)
+		}
+	}
+	if weights > 0 {
+		score /= weights
+	}
+	combined.Score = policy.Rescale(score)
+	combined.Message = strings.Join(messages, "；")
+	details.Summary = strings.Join(summaries, "\n")
+	combined.Details = policy.ScaleDetails(details)
+	return combined
+}
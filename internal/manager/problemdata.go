@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/datacache"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const defaultProblemDataTarget = "/problem-data"
+
+// ProblemDataConfig 题目数据预解压配置
+type ProblemDataConfig struct {
+	Target     string                 `json:"target"`     // 容器内挂载路径，默认 /problem-data
+	Writable   bool                   `json:"writable"`   // 是否以可写方式挂载（可写时复制数据，不与缓存共享 inode）
+	Encryption *ProblemDataEncryption `json:"encryption"` // 题目数据已加密时的解密配置
+}
+
+// ProblemDataEncryption 加密题目数据配置。数据可提前分发到 runner，
+// 仅在比赛进行期间解密到缓存，比赛结束后删除解密结果
+type ProblemDataEncryption struct {
+	Key       string    `json:"key"`        // 比赛密钥在密钥存储中的名称（32 字节，hex 编码）
+	LiveFrom  time.Time `json:"live_from"`  // 比赛开始时间
+	LiveUntil time.Time `json:"live_until"` // 比赛结束时间，之后删除解密数据
+}
+
+// prepareEncryptedData 在比赛进行期间解密题目数据到缓存
+func (m *Manager) prepareEncryptedData(ctx context.Context, soln *aoiclient.SolutionPoll, enc *ProblemDataEncryption) (string, error) {
+	now := time.Now()
+	if now.Before(enc.LiveFrom) || !now.Before(enc.LiveUntil) {
+		return "", fmt.Errorf("encrypted problem data is only available between %s and %s",
+			enc.LiveFrom.Format(time.RFC3339), enc.LiveUntil.Format(time.RFC3339))
+	}
+	if len(m.secrets) == 0 {
+		return "", fmt.Errorf("problem data is encrypted but no secret store is configured")
+	}
+	value, err := m.secrets.Get(ctx, enc.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get problem data key %q: %w", enc.Key, err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("problem data key %q is not valid hex", enc.Key)
+	}
+	return m.cache.PrepareEncrypted(ctx, soln.ProblemDataUrl, soln.ProblemDataHash, key, enc.LiveUntil)
+}
+
+// prepareProblemData 将题目数据解压到缓存并以硬链接克隆到单次评测目录，
+// 返回克隆目录（调用方负责清理）
+func (m *Manager) prepareProblemData(ctx context.Context, soln *aoiclient.SolutionPoll, pd *ProblemDataConfig, config *executor.ExecuteConfig) (string, error) {
+	if soln.ProblemDataUrl == "" {
+		return "", fmt.Errorf("problem has no data")
+	}
+
+	start := time.Now()
+	var cached string
+	var err error
+	if pd.Encryption != nil {
+		cached, err = m.prepareEncryptedData(ctx, soln, pd.Encryption)
+	} else {
+		cached, err = m.cache.Prepare(ctx, soln.ProblemDataUrl, soln.ProblemDataHash)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dataDir, err := os.MkdirTemp(m.workDir(), fmt.Sprintf("problem-data-%s-", soln.SolutionId))
+	if err != nil {
+		return "", err
+	}
+	// 只读挂载使用硬链接；可写挂载必须复制，否则选手程序可以修改缓存中的共享 inode，影响之后的评测
+	clone := m.cache.Clone
+	if pd.Writable {
+		clone = m.cache.Copy
+	}
+	if err := clone(cached, dataDir); err != nil {
+		return dataDir, err
+	}
+	if pd.Writable {
+		if err := m.prepareSharedDir(dataDir, config.User); err != nil {
+			return dataDir, err
+		}
+	}
+	log.Printf("Prepared problem data for solution %s in %s", soln.SolutionId, time.Since(start))
+
+	target := pd.Target
+	if target == "" {
+		target = defaultProblemDataTarget
+	}
+	config.Mounts = append(config.Mounts, executor.Mount{
+		Source:   dataDir,
+		Target:   target,
+		ReadOnly: !pd.Writable,
+	})
+	config.Env["PROBLEM_DATA_DIR"] = target
+	return dataDir, nil
+}
+
+// StageProblemData 预先下载加密的题目数据到缓存，比赛开始后评测时再解密
+func StageProblemData(conf *config.ManagerConfig, url, hash string) error {
+	if err := validateRunnerID(*conf.RunnerID); err != nil {
+		return err
+	}
+	m := NewManager(conf)
+	cache, err := datacache.New(m.cacheDir())
+	if err != nil {
+		return err
+	}
+	path, err := cache.Stage(context.Background(), url, hash)
+	if err != nil {
+		return err
+	}
+	log.Printf("Staged encrypted problem data at %s", path)
+	return nil
+}
@@ -0,0 +1,243 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const gpuScrapeTimeout = 5 * time.Second // 抓取时查询 GPU 状态的超时
+
+// peakMemoryBuckets 评测峰值内存直方图的上界（字节）
+var peakMemoryBuckets = []float64{64 << 20, 256 << 20, 1 << 30, 4 << 30, 16 << 30, 64 << 30}
+
+// promMetrics 累计的评测资源使用量，以 Prometheus 文本格式导出
+type promMetrics struct {
+	mu sync.Mutex
+
+	jobs     map[State]int64  // 终态 -> 评测数
+	verdicts map[string]int64 // 上报的评测状态 -> 评测数
+
+	runSeconds   float64 // 评测容器运行时间之和
+	queueSeconds float64 // 从收到任务到评测容器开始运行的时间之和
+	cpuSeconds   float64 // 评测容器 CPU 时间之和
+
+	memoryCounts []int64 // 峰值内存落入各个桶的评测数，最后一项为 +Inf
+	memorySum    float64
+	memoryCount  int64
+
+	gpuJobs      int64   // 记录了 GPU 使用情况的评测数
+	gpuUtilSum   float64 // 各评测平均 GPU 利用率（%）之和
+	gpuMemorySum float64 // 各评测 GPU 峰值显存（字节）之和
+	gpuEnergySum float64 // GPU 能耗（J）之和
+	cpuEnergySum float64 // RAPL 能耗（J）之和
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		jobs:         make(map[State]int64),
+		verdicts:     make(map[string]int64),
+		memoryCounts: make([]int64, len(peakMemoryBuckets)+1),
+	}
+}
+
+// exportJob 作为状态转移回调，在评测结束时累计资源使用量
+func (m *Manager) exportJob(job *Job, from, to State) {
+	if !to.Terminal() {
+		return
+	}
+	p := m.prom
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs[to]++
+	if to == StateCheckpointed {
+		return
+	}
+	if info, _ := job.aoi.verdict(); info != nil && info.Status != "" {
+		p.verdicts[info.Status]++
+	}
+	p.runSeconds += job.runDuration.Seconds()
+	p.queueSeconds += job.queueTime.Seconds()
+	if job.result != nil && job.result.Usage != nil {
+		usage := job.result.Usage
+		p.cpuSeconds += usage.CPUTime.Seconds()
+		i := sort.SearchFloat64s(peakMemoryBuckets, float64(usage.PeakMemory))
+		p.memoryCounts[i]++
+		p.memorySum += float64(usage.PeakMemory)
+		p.memoryCount++
+	}
+	if job.gpuUsage != nil {
+		p.gpuJobs++
+		p.gpuUtilSum += job.gpuUsage.AvgUtilization
+		p.gpuMemorySum += float64(job.gpuUsage.PeakMemory << 20)
+	}
+	if job.energy != nil {
+		p.cpuEnergySum += job.energy.CPUJoules
+		p.gpuEnergySum += job.energy.GPUJoules
+	}
+}
+
+// promWriter 按 Prometheus 文本格式输出指标，同名指标的 HELP 与 TYPE 只输出一次。
+// 每个样本都带有 runner 标签，同一主机上的多个 runner 导出的指标不会混淆
+type promWriter struct {
+	w      io.Writer
+	runner string
+	seen   map[string]bool
+}
+
+func (pw *promWriter) header(name, typ, help string) {
+	if pw.seen[name] {
+		return
+	}
+	pw.seen[name] = true
+	fmt.Fprintf(pw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 输出一个样本，labels 为交替的标签名与值
+func (pw *promWriter) sample(name string, value float64, labels ...string) {
+	labels = append([]string{"runner", pw.runner}, labels...)
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+promEscaper.Replace(labels[i+1])+`"`)
+	}
+	fmt.Fprintf(pw.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+// promEscaper 按文本格式转义标签值
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (pw *promWriter) gauge(name, help string, value float64, labels ...string) {
+	pw.header(name, "gauge", help)
+	pw.sample(name, value, labels...)
+}
+
+func (pw *promWriter) counter(name, help string, value float64, labels ...string) {
+	pw.header(name, "counter", help)
+	pw.sample(name, value, labels...)
+}
+
+// handleMetrics 以 Prometheus 文本格式导出 runner 与评测的资源使用情况
+func (m *Manager) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	pw := &promWriter{w: w, runner: *m.conf.RunnerID, seen: make(map[string]bool)}
+
+	pw.gauge("lfs_runner_info", "Runner identity.", 1)
+	pw.gauge("lfs_runner_workers", "Number of solutions judged concurrently.", float64(m.workers()))
+	pw.gauge("lfs_runner_scratch_paused", "Whether polling is paused because scratch space is exhausted.", boolGauge(m.scratchPaused.Load()))
+
+	type runningJob struct {
+		solution, problem string
+		state             State
+		age               time.Duration
+	}
+	var running []runningJob
+	m.runningMu.Lock()
+	for _, job := range m.running {
+		running = append(running, runningJob{job.SolutionID, job.soln.ProblemConfig.Label, job.State, time.Since(job.aoi.receivedAt)})
+	}
+	m.runningMu.Unlock()
+	sort.Slice(running, func(i, j int) bool { return running[i].solution < running[j].solution })
+	pw.gauge("lfs_runner_jobs_running", "Number of solutions being judged.", float64(len(running)))
+	for _, job := range running {
+		pw.gauge("lfs_job_age_seconds", "Time since a running solution was received.", job.age.Seconds(),
+			"solution", job.solution, "problem", job.problem, "state", string(job.state))
+	}
+
+	p := m.prom
+	p.mu.Lock()
+	states := make([]string, 0, len(p.jobs))
+	for state := range p.jobs {
+		states = append(states, string(state))
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		pw.counter("lfs_jobs_total", "Solutions that reached a terminal state.", float64(p.jobs[State(state)]), "state", state)
+	}
+	statuses := make([]string, 0, len(p.verdicts))
+	for status := range p.verdicts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		pw.counter("lfs_job_verdicts_total", "Final statuses reported to AOI.", float64(p.verdicts[status]), "status", status)
+	}
+	pw.counter("lfs_job_run_seconds_total", "Wall time of judge containers.", p.runSeconds)
+	pw.counter("lfs_job_queue_seconds_total", "Time from receiving a solution to starting its judge container.", p.queueSeconds)
+	pw.counter("lfs_job_cpu_seconds_total", "CPU time used by judge containers.", p.cpuSeconds)
+
+	pw.header("lfs_job_peak_memory_bytes", "histogram", "Peak memory of judge containers.")
+	var cumulative int64
+	for i, le := range peakMemoryBuckets {
+		cumulative += p.memoryCounts[i]
+		pw.sample("lfs_job_peak_memory_bytes_bucket", float64(cumulative), "le", fmt.Sprintf("%g", le))
+	}
+	cumulative += p.memoryCounts[len(peakMemoryBuckets)]
+	pw.sample("lfs_job_peak_memory_bytes_bucket", float64(cumulative), "le", "+Inf")
+	pw.sample("lfs_job_peak_memory_bytes_sum", p.memorySum)
+	pw.sample("lfs_job_peak_memory_bytes_count", float64(p.memoryCount))
+
+	pw.header("lfs_job_gpu_utilization_percent", "summary", "Average GPU utilization of judge containers.")
+	pw.sample("lfs_job_gpu_utilization_percent_sum", p.gpuUtilSum)
+	pw.sample("lfs_job_gpu_utilization_percent_count", float64(p.gpuJobs))
+	pw.header("lfs_job_gpu_peak_memory_bytes", "summary", "Peak GPU memory of judge containers.")
+	pw.sample("lfs_job_gpu_peak_memory_bytes_sum", p.gpuMemorySum)
+	pw.sample("lfs_job_gpu_peak_memory_bytes_count", float64(p.gpuJobs))
+	pw.counter("lfs_job_energy_joules_total", "Energy measured while judge containers ran.", p.cpuEnergySum, "source", "cpu")
+	pw.counter("lfs_job_energy_joules_total", "Energy measured while judge containers ran.", p.gpuEnergySum, "source", "gpu")
+	p.mu.Unlock()
+
+	m.writeGPUMetrics(r.Context(), pw)
+}
+
+// writeGPUMetrics 查询 runner 管理的各个 GPU 的当前状态，nvidia-smi 失败时跳过
+func (m *Manager) writeGPUMetrics(ctx context.Context, pw *promWriter) {
+	if m.gpus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, gpuScrapeTimeout)
+	defer cancel()
+	for _, id := range m.gpus.devices {
+		sample, err := queryGPUs(ctx, []string{id})
+		if err != nil {
+			log.Printf("Failed to query GPU %s for metrics: %v", id, err)
+			continue
+		}
+		if len(sample.utils) > 0 {
+			pw.gauge("lfs_gpu_utilization_percent", "Current GPU utilization.", sample.utils[0], "gpu", id)
+			pw.gauge("lfs_gpu_power_watts", "Current GPU power draw.", sample.power, "gpu", id)
+		}
+		pw.gauge("lfs_gpu_memory_used_bytes", "Current GPU memory in use.", float64(sample.memory<<20), "gpu", id)
+	}
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// serveMetrics 在 metrics-listen 上提供 GET /metrics 直到 ctx 结束，不需要令牌，应只监听内网地址
+func (m *Manager) serveMetrics(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", m.handleMetrics)
+	srv := &http.Server{
+		Addr:              *m.conf.MetricsListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := context.AfterFunc(ctx, func() { srv.Close() })
+	defer stop()
+
+	log.Printf("Serving Prometheus metrics on %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Metrics endpoint stopped: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	env := newTestEnv(t, executortest.Script{
+		Files: map[string]string{"/output/report.json": passingReport},
+		Usage: &executor.ResourceUsage{PeakMemory: 100 << 20},
+	})
+	env.judge(aoitest.NewSolution("s1", "t1", "metrics", "lfs1", judgeConfig(nil)))
+
+	// 终态回调在 Complete 之后执行
+	var body string
+	for range 100 {
+		rec := httptest.NewRecorder()
+		env.m.handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body = rec.Body.String()
+		if strings.Contains(body, `lfs_jobs_total{runner="test-runner",state="Completed"} 1`) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, want := range []string{
+		`lfs_runner_info{runner="test-runner"} 1`,
+		`lfs_jobs_total{runner="test-runner",state="Completed"} 1`,
+		`lfs_job_verdicts_total{runner="test-runner",status="Accepted"} 1`,
+		`lfs_job_peak_memory_bytes_bucket{runner="test-runner",le="6.7108864e+07"} 0`,
+		`lfs_job_peak_memory_bytes_bucket{runner="test-runner",le="2.68435456e+08"} 1`,
+		`lfs_job_peak_memory_bytes_count{runner="test-runner"} 1`,
+		"# TYPE lfs_job_peak_memory_bytes histogram",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	var b strings.Builder
+	pw := &promWriter{w: &b, runner: "r", seen: make(map[string]bool)}
+	pw.gauge("x", "help", 1, "problem", "a\"b\\c\n题")
+	if got, want := b.String(), "# HELP x help\n# TYPE x gauge\nx{runner=\"r\",problem=\"a\\\"b\\\\c\\n题\"} 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
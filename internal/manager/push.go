@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const pushReconnectInterval = 5 * time.Second
+
+// pushDispatcher 维护到 AOI 的 WebSocket 连接，将推送的任务转发给主循环
+type pushDispatcher struct {
+	aoi       *aoiclient.Client
+	ch        chan *aoiclient.SolutionPoll
+	connected atomic.Bool
+	done      chan struct{} // run 返回时关闭
+
+	// 停止时已收到但未交给主循环的任务，run 返回后由主循环在退出前运行
+	leftover []*aoiclient.SolutionPoll
+}
+
+func newPushDispatcher(aoi *aoiclient.Client) *pushDispatcher {
+	return &pushDispatcher{
+		aoi:  aoi,
+		ch:   make(chan *aoiclient.SolutionPoll),
+		done: make(chan struct{}),
+	}
+}
+
+// run 持续保持连接，断开后按固定间隔重连。ctx 取消后不再转发任务，
+// 连接关闭前已收到的任务保存在 leftover 中，不会被丢弃
+func (p *pushDispatcher) run(ctx context.Context) {
+	defer close(p.done)
+	for ctx.Err() == nil {
+		stream, err := p.aoi.Subscribe(ctx)
+		if err != nil {
+			log.Println("Failed to subscribe to push dispatch, falling back to polling:", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(pushReconnectInterval):
+			}
+			continue
+		}
+		log.Println("Push dispatch connected")
+		p.connected.Store(true)
+		for soln := range stream {
+			if ctx.Err() != nil {
+				p.leftover = append(p.leftover, soln)
+				continue
+			}
+			select {
+			case p.ch <- soln:
+			case <-ctx.Done():
+				p.leftover = append(p.leftover, soln)
+			}
+		}
+		p.connected.Store(false)
+		log.Println("Push dispatch disconnected, falling back to polling")
+	}
+}
+
+// stopped 等待 run 返回，返回停止时已收到但未交给主循环的任务
+func (p *pushDispatcher) stopped() []*aoiclient.SolutionPoll {
+	<-p.done
+	return p.leftover
+}
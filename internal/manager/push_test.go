@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"golang.org/x/net/websocket"
+)
+
+func TestPushKeepsSolutionsReceivedWhileStopping(t *testing.T) {
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for _, id := range []string{"s1", "s2"} {
+			websocket.JSON.Send(conn, map[string]any{"solutionId": id, "taskId": "t1"})
+		}
+		// 保持连接直到 runner 关闭
+		var msg any
+		websocket.JSON.Receive(conn, &msg)
+	}))
+	defer srv.Close()
+
+	push := newPushDispatcher(aoiclient.New(srv.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	go push.run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !push.connected.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("push dispatch did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 主循环已停止，不再从 push.ch 领取任务
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	leftover := push.stopped()
+	if len(leftover) != 2 || leftover[0].SolutionId != "s1" || leftover[1].SolutionId != "s2" {
+		t.Fatalf("leftover = %+v, want s1 and s2", leftover)
+	}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"log"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// reapContainers 启动时删除本 runner 上次运行遗留的全部容器（包括仍在运行的评测、服务与预热容器），
+// 此时本实例尚未启动任何容器
+func (m *Manager) reapContainers() {
+	ctx, cancel := context.WithTimeout(m.ctx, gcTimeout)
+	defer cancel()
+	for _, h := range m.hosts.all() {
+		containers, err := h.exec.ListContainers(ctx, map[string]string{executor.LabelRunnerID: *m.conf.RunnerID})
+		if err != nil {
+			log.Printf("Failed to list leftover containers on %s: %v", h.name, err)
+			continue
+		}
+		for _, c := range containers {
+			if err := h.exec.Cleanup(ctx, c.ID); err != nil {
+				log.Printf("Failed to remove leftover container %s on %s: %v", c.ID, h.name, err)
+				continue
+			}
+			log.Printf("Removed leftover container %s of solution %s on %s", c.ID, c.Labels[executor.LabelSolutionID], h.name)
+		}
+	}
+}
+
+// reapJobContainers 评测结束时删除仍带有该评测标签的容器。正常情况下容器已由执行器删除，
+// 执行器删除失败或在出错路径上遗漏时由此兜底，避免评测进程在容器中继续运行
+func (m *Manager) reapJobContainers(job *Job) {
+	if job.exec == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dockerCallTimeout)
+	defer cancel()
+	containers, err := job.exec.ListContainers(ctx, map[string]string{
+		executor.LabelRunnerID:   *m.conf.RunnerID,
+		executor.LabelSolutionID: job.SolutionID,
+	})
+	if err != nil {
+		log.Printf("Solution %s: failed to list leftover containers: %v", job.SolutionID, err)
+		return
+	}
+	for _, c := range containers {
+		if err := job.exec.Cleanup(ctx, c.ID); err != nil {
+			log.Printf("Solution %s: failed to remove leftover container %s: %v", job.SolutionID, c.ID, err)
+			continue
+		}
+		log.Printf("Solution %s: removed leftover container %s", job.SolutionID, c.ID)
+	}
+}
@@ -0,0 +1,207 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+)
+
+// 录制目录结构：<record-dir>/<solution>-<task>/{solution.json, result.json, output/}。
+// solution.json 为平台下发的原始负载，其中的下载链接可能在一段时间后过期
+const (
+	recordSolution = "solution.json"
+	recordResult   = "result.json"
+	recordOutput   = "output"
+)
+
+// recordedResult 录制的评测结果
+type recordedResult struct {
+	Runner     string                     `json:"runner"`
+	State      State                      `json:"state"`
+	History    []Transition               `json:"history"`
+	Info       *aoiclient.SolutionInfo    `json:"info,omitempty"`    // 最后一次上报的结果
+	Details    *aoiclient.SolutionDetails `json:"details,omitempty"` // 最后一次上传的详情
+	Container  *recordedContainer         `json:"container,omitempty"`
+	RecordedAt time.Time                  `json:"recordedAt"`
+}
+
+// recordedContainer 主评测容器的退出情况，不包含输出内容
+type recordedContainer struct {
+	ExitCode            int                     `json:"exitCode"`
+	TimedOut            bool                    `json:"timedOut"`
+	OOM                 bool                    `json:"oom"`
+	OutputLimitExceeded bool                    `json:"outputLimitExceeded"`
+	Duration            time.Duration           `json:"duration"`
+	Usage               *executor.ResourceUsage `json:"usage,omitempty"`
+}
+
+// recordDir 返回录制目录，为空时不录制
+func (m *Manager) recordDir() string {
+	if m.conf.RecordDir == nil {
+		return ""
+	}
+	return *m.conf.RecordDir
+}
+
+// recordPayload 在评测结束时保存原始负载、评测报告与最终结果。迁移的评测由恢复它的 runner 录制
+func (m *Manager) recordPayload(job *Job) {
+	root := m.recordDir()
+	if root == "" || job.State == StateCheckpointed {
+		return
+	}
+	dir := filepath.Join(root, job.SolutionID+"-"+job.TaskID)
+	if err := m.writeRecord(job, dir); err != nil {
+		log.Printf("Failed to record solution %s: %v", job.SolutionID, err)
+	}
+}
+
+func (m *Manager) writeRecord(job *Job, dir string) error {
+	// 同一任务重新评测时覆盖上次的录制
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeJSONFile(filepath.Join(dir, recordSolution), job.soln); err != nil {
+		return err
+	}
+	if job.outputDir != "" {
+		if err := copyTree(job.outputDir, filepath.Join(dir, recordOutput)); err != nil {
+			return fmt.Errorf("failed to copy output dir: %w", err)
+		}
+	}
+
+	m.runningMu.Lock()
+	rec := &recordedResult{
+		Runner:     *m.conf.RunnerID,
+		State:      job.State,
+		History:    slices.Clone(job.History),
+		RecordedAt: time.Now(),
+	}
+	m.runningMu.Unlock()
+	rec.Info, rec.Details = job.aoi.verdict()
+	if r := job.result; r != nil {
+		rec.Container = &recordedContainer{
+			ExitCode:            r.ExitCode,
+			TimedOut:            r.TimedOut,
+			OOM:                 r.OOM,
+			OutputLimitExceeded: r.OutputLimitExceeded,
+			Duration:            job.runDuration,
+			Usage:               r.Usage,
+		}
+	}
+	return writeJSONFile(filepath.Join(dir, recordResult), rec)
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Replay 使用本机的执行器与配置，通过完整的评测流程重新运行录制的任务。
+// 平台由进程内的 aoitest 服务器替代，结果只输出到 w 并与录制的结果比较，不会上报到真实平台
+func Replay(conf *config.ManagerConfig, dir string, w io.Writer) error {
+	soln := &aoiclient.SolutionPoll{}
+	if err := readJSONFile(filepath.Join(dir, recordSolution), soln); err != nil {
+		return fmt.Errorf("failed to read recorded solution: %w", err)
+	}
+	var recorded *recordedResult
+	if rec := new(recordedResult); readJSONFile(filepath.Join(dir, recordResult), rec) == nil {
+		recorded = rec
+	}
+
+	srv := aoitest.NewServer()
+	defer srv.Close()
+
+	// 使用独立的 runner ID，避免清理或恢复同一主机上正式 runner 的容器与任务
+	runnerID := "replay-" + strconv.Itoa(os.Getpid())
+	runnerKey := "replay"
+	disabled := ""
+	noPush := false
+	conf.Endpoint = &srv.URL
+	conf.RunnerID = &runnerID
+	conf.RunnerKey = &runnerKey
+	conf.PushDispatch = &noPush
+	conf.ScopedTokens = &noPush
+	conf.ResultWebhook = &disabled
+	conf.CheckpointDir = &disabled
+	conf.RecordDir = &disabled
+
+	m := NewManager(conf)
+	if err := m.Init(); err != nil {
+		return err
+	}
+	defer os.RemoveAll(m.workDir())
+
+	log.Printf("Replaying solution %s, task %s from %s", soln.SolutionId, soln.TaskId, dir)
+	start := time.Now()
+	runErr := m.run(soln, nil)
+	elapsed := time.Since(start)
+	if err := m.Close(); err != nil {
+		log.Printf("Failed to close executor: %v", err)
+	}
+
+	task := srv.Task(soln.SolutionId, soln.TaskId)
+	if task == nil {
+		return fmt.Errorf("replay did not report any result: %v", runErr)
+	}
+	fmt.Fprintf(w, "Solution %s, task %s replayed in %s\n", soln.SolutionId, soln.TaskId, elapsed.Round(time.Millisecond))
+	if runErr != nil {
+		fmt.Fprintf(w, "Error: %v\n", runErr)
+	}
+	replayed := task.Last()
+	printVerdict(w, "Replayed", replayed)
+	if recorded == nil {
+		return nil
+	}
+	printVerdict(w, "Recorded", recorded.Info)
+	if c := recorded.Container; c != nil {
+		fmt.Fprintf(w, "Recorded container: exit code %d, timed out %t, oom %t, ran %s on %s\n",
+			c.ExitCode, c.TimedOut, c.OOM, c.Duration.Round(time.Millisecond), recorded.Runner)
+	}
+	if sameVerdict(replayed, recorded.Info) {
+		fmt.Fprintln(w, "Verdict matches the recording")
+	} else {
+		fmt.Fprintln(w, "Verdict DIFFERS from the recording")
+	}
+	return nil
+}
+
+func printVerdict(w io.Writer, name string, info *aoiclient.SolutionInfo) {
+	if info == nil {
+		fmt.Fprintf(w, "%s: no verdict\n", name)
+		return
+	}
+	fmt.Fprintf(w, "%s: %s, score %g, %s\n", name, info.Status, info.Score, info.Message)
+}
+
+// sameVerdict 比较结果状态与分数，消息中可能包含耗时等不稳定的内容，不参与比较
+func sameVerdict(a, b *aoiclient.SolutionInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Status == b.Status && a.Score == b.Score
+}
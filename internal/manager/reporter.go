@@ -0,0 +1,291 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// reporter 包装 SolutionClient，记录最终上报给 AOI 的结果，
+// 在评测首次完成时触发结果镜像等后续处理
+type reporter struct {
+	*aoiclient.SolutionClient
+
+	m          *Manager
+	ctx        context.Context // 所属评测的上下文
+	soln       *aoiclient.SolutionPoll
+	receivedAt time.Time
+
+	mu        sync.Mutex
+	info      *aoiclient.SolutionInfo
+	details   *aoiclient.SolutionDetails
+	usage     *executor.ResourceUsage
+	cache     *compilerCacheStats
+	gpu       *gpuUsage
+	energy    *energyUsage
+	perf      *perfCounters
+	score     *adapters.ScorePolicy
+	penalty   *latePenalty
+	rawScore  *float64                        // 最近一次上报的处罚前分数
+	extraJobs []*aoiclient.SolutionDetailsJob // 附加在详情末尾的项（如查重结果）
+	metrics   []*aoiclient.SolutionMetric     // 容器通过 judgerproto 上报的指标
+	artifacts *artifactState                  // 容器通过 judgerproto 推送的产物
+	streams   map[string]*seqState            // 各消息流已处理的序号
+	raw       *aoiclient.SolutionDetails      // 最近一次上报的未附加任何信息的详情
+	live      *liveResults
+	completed bool
+	protoInfo bool             // 容器通过 judgerproto 上报过结果
+	aggregate *AggregateConfig // 多 task 汇总配置
+
+	metricsDirty   bool // 上次上报详情之后收到了新的指标，Complete 时重新上报
+	metricsDropped bool // 指标数量超过上限，已忽略新的指标
+}
+
+func (m *Manager) newReporter(ctx context.Context, soln *aoiclient.SolutionPoll) *reporter {
+	return &reporter{
+		SolutionClient: m.aoi.Solution(soln.SolutionId, soln.TaskId),
+		m:              m,
+		ctx:            ctx,
+		soln:           soln,
+		receivedAt:     time.Now(),
+	}
+}
+
+// setUsage 记录容器资源使用情况，之后的上报会附带资源指标
+func (r *reporter) setUsage(usage *executor.ResourceUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage = usage
+}
+
+// setGPUUsage 记录 GPU 使用情况，之后的上报会附带 GPU 指标
+func (r *reporter) setGPUUsage(usage *gpuUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gpu = usage
+}
+
+// setEnergy 记录能耗，之后的上报会附带能耗指标
+func (r *reporter) setEnergy(usage *energyUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.energy = usage
+}
+
+// setPerfCounters 记录性能计数，之后的上报会附带计数指标
+func (r *reporter) setPerfCounters(counters *perfCounters) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.perf = counters
+}
+
+// setCacheStats 记录编译缓存命中情况，之后的上报附带该信息
+func (r *reporter) setCacheStats(stats *compilerCacheStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = stats
+}
+
+// setScorePolicy 记录题目的计分规则，容器直接上报的分数按其换算
+func (r *reporter) setScorePolicy(policy *adapters.ScorePolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.score = policy
+}
+
+// setLatePenalty 记录迟交处罚，之后上报的分数扣除处罚并在详情中附带明细
+func (r *reporter) setLatePenalty(p *latePenalty) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.penalty = p
+}
+
+// setAggregate 记录多 task 汇总配置，完成时记录本 task 的结果
+func (r *reporter) setAggregate(conf *AggregateConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aggregate = conf
+}
+
+func (r *reporter) aggregateConfig() *AggregateConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.aggregate
+}
+
+func (r *reporter) scorePolicy() *adapters.ScorePolicy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.score
+}
+
+func (r *reporter) Patch(ctx context.Context, info *aoiclient.SolutionInfo) error {
+	r.mu.Lock()
+	if r.penalty != nil {
+		raw := info.Score
+		r.rawScore = &raw
+		info = withLatePenalty(info, r.penalty)
+	}
+	if r.usage != nil {
+		info = withUsageMetrics(info, r.usage)
+	}
+	if r.gpu != nil {
+		info = withGPUMetrics(info, r.gpu)
+	}
+	if r.energy != nil {
+		info = withEnergyMetrics(info, r.energy)
+	}
+	if r.perf != nil {
+		info = withPerfMetrics(info, r.perf)
+	}
+	if r.cache != nil {
+		info = withCacheMetrics(info, r.cache)
+	}
+	r.info = info
+	r.mu.Unlock()
+	return r.SolutionClient.Patch(ctx, info)
+}
+
+func (r *reporter) SaveDetails(ctx context.Context, details *aoiclient.SolutionDetails) error {
+	r.mu.Lock()
+	r.raw = details
+	r.metricsDirty = false
+	if len(r.extraJobs) > 0 {
+		copied := *details
+		copied.Jobs = append(slices.Clone(details.Jobs), r.extraJobs...)
+		details = &copied
+	}
+	if len(r.metrics) > 0 {
+		details = withMetrics(details, r.metrics)
+	}
+	if r.penalty != nil {
+		details = withPenaltySummary(details, r.penalty, r.rawScore)
+	}
+	if r.usage != nil {
+		details = withUsageSummary(details, r.usage)
+	}
+	if r.gpu != nil {
+		details = withGPUSummary(details, r.gpu)
+	}
+	if r.energy != nil {
+		details = withEnergySummary(details, r.energy)
+	}
+	if r.perf != nil {
+		details = withPerfSummary(details, r.perf)
+	}
+	if r.cache != nil {
+		details = withCacheSummary(details, r.cache)
+	}
+	r.details = details
+	r.mu.Unlock()
+	return r.SolutionClient.SaveDetails(ctx, details)
+}
+
+// attachDetailsJob 在详情末尾附加一项，之后上报的详情都包含该项；已上报过详情时重新上报
+func (r *reporter) attachDetailsJob(ctx context.Context, job *aoiclient.SolutionDetailsJob) error {
+	r.mu.Lock()
+	r.extraJobs = append(r.extraJobs, job)
+	raw := r.raw
+	r.mu.Unlock()
+	if raw == nil {
+		return nil
+	}
+	return r.SaveDetails(ctx, raw)
+}
+
+// withUsageMetrics 返回附带资源指标的结果副本
+func withUsageMetrics(info *aoiclient.SolutionInfo, usage *executor.ResourceUsage) *aoiclient.SolutionInfo {
+	metrics := make(map[string]float64)
+	if info.Metrics != nil {
+		for k, v := range *info.Metrics {
+			metrics[k] = v
+		}
+	}
+	metrics["peak_memory_mb"] = float64(usage.PeakMemory) / (1 << 20)
+	metrics["cpu_time_s"] = usage.CPUTime.Seconds()
+	metrics["io_read_mb"] = float64(usage.IORead) / (1 << 20)
+	metrics["io_write_mb"] = float64(usage.IOWrite) / (1 << 20)
+
+	copied := *info
+	copied.Metrics = &metrics
+	return &copied
+}
+
+// withUsageSummary 返回在摘要末尾附带资源使用说明的详情副本
+func withUsageSummary(details *aoiclient.SolutionDetails, usage *executor.ResourceUsage) *aoiclient.SolutionDetails {
+	copied := *details
+	line := fmt.Sprintf("资源使用：峰值内存 %.1f MB，CPU 时间 %.2f 秒，磁盘读 %.1f MB / 写 %.1f MB",
+		float64(usage.PeakMemory)/(1<<20), usage.CPUTime.Seconds(),
+		float64(usage.IORead)/(1<<20), float64(usage.IOWrite)/(1<<20))
+	if copied.Summary != "" {
+		copied.Summary += "\n"
+	}
+	copied.Summary += line
+	return &copied
+}
+
+func (r *reporter) Complete(ctx context.Context) error {
+	// 上报详情之后又收到了指标时重新上报；只上报了指标而没有详情时，单独上报包含指标的详情
+	r.mu.Lock()
+	raw := r.raw
+	if raw == nil && len(r.metrics) > 0 {
+		raw = &aoiclient.SolutionDetails{Version: 1}
+	} else if !r.metricsDirty {
+		raw = nil
+	}
+	r.mu.Unlock()
+	if raw != nil {
+		if err := r.SaveDetails(ctx, raw); err != nil {
+			log.Printf("Failed to save metrics for solution %s: %v", r.SolutionID(), err)
+		}
+	}
+
+	// AOI 未记录完成时不触发完成回调（如结果 webhook），之后的重试成功时再触发
+	if err := r.SolutionClient.Complete(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	first := !r.completed
+	r.completed = true
+	r.mu.Unlock()
+
+	if first {
+		r.m.onComplete(r)
+	}
+	return nil
+}
+
+// noteProtoResult 记录容器通过 judgerproto 上报了结果
+func (r *reporter) noteProtoResult() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.protoInfo = true
+}
+
+// protoState 返回容器是否通过 judgerproto 上报过结果、评测是否已完成
+func (r *reporter) protoState() (reported, completed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.protoInfo, r.completed
+}
+
+// verdict 返回最后一次上报的结果与详情
+func (r *reporter) verdict() (*aoiclient.SolutionInfo, *aoiclient.SolutionDetails) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info, r.details
+}
+
+// onComplete 评测完成后的处理
+func (m *Manager) onComplete(r *reporter) {
+	m.mirrorVerdict(r)
+	m.aggregateTask(r)
+}
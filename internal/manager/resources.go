@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// 未配置 default-* 时使用的资源默认值
+const (
+	defaultJobTimeout     = 600  // 秒
+	defaultJobMemoryLimit = 2048 // MB
+	defaultJobOutputLimit = 64   // MB
+)
+
+// 输出超限时的处理方式
+const (
+	OutputLimitKill     = "kill"     // 立即终止容器
+	OutputLimitTruncate = "truncate" // 停止转发并丢弃其余输出，容器运行结束后上报
+)
+
+// Duration 评测配置中的时长：数字按秒计（可以是小数，如 0.5），字符串按 Go 的时长格式解析（如 "500ms"、"1m30s"）
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a number of seconds or a string like \"500ms\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// formatSeconds 以秒为单位展示时间限制，如 "2"、"0.5"
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// applyResourceLimits 为未指定资源的题目填入 runner 的默认值，超过 runner 上限的请求截断到上限
+func (m *Manager) applyResourceLimits(solutionID string, config *executor.ExecuteConfig) {
+	m.fillResourceDefaults(config)
+
+	config.Timeout = m.clampTimeout(solutionID, config.Timeout)
+	config.MemoryLimit = m.clampMemory(solutionID, config.MemoryLimit)
+	config.CPULimit = m.clampCPU(solutionID, config.CPULimit)
+}
+
+// fillResourceDefaults 为未指定的时间、内存与 CPU 限制填入 runner 的默认值
+func (m *Manager) fillResourceDefaults(config *executor.ExecuteConfig) {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultJobTimeout * time.Second
+		if m.conf.DefaultTimeout != nil && *m.conf.DefaultTimeout > 0 {
+			config.Timeout = time.Duration(*m.conf.DefaultTimeout) * time.Second
+		}
+	}
+	if config.MemoryLimit <= 0 {
+		config.MemoryLimit = defaultJobMemoryLimit
+		if m.conf.DefaultMemoryLimit != nil && *m.conf.DefaultMemoryLimit > 0 {
+			config.MemoryLimit = *m.conf.DefaultMemoryLimit
+		}
+	}
+	if config.CPULimit <= 0 && m.conf.DefaultCPULimit != nil && *m.conf.DefaultCPULimit > 0 {
+		config.CPULimit = *m.conf.DefaultCPULimit
+	}
+}
+
+// maxTimeout 返回 runner 允许的最长运行时间，0 表示不限制
+func (m *Manager) maxTimeout() time.Duration {
+	if m.conf.MaxTimeout == nil {
+		return 0
+	}
+	return time.Duration(*m.conf.MaxTimeout) * time.Second
+}
+
+// clampTimeout 将超时截断到 runner 上限，manager 启动的每个容器（编译、pre/post、hook、服务就绪检查）都应经过此处
+func (m *Manager) clampTimeout(solutionID string, d time.Duration) time.Duration {
+	if max := m.maxTimeout(); max > 0 && d > max {
+		log.Printf("Solution %s: clamping timeout %s to the runner maximum %s", solutionID, d, max)
+		return max
+	}
+	return d
+}
+
+// clampMemory 将内存限制（MB）截断到 runner 上限
+func (m *Manager) clampMemory(solutionID string, mb int64) int64 {
+	if m.conf.MaxMemoryLimit != nil && *m.conf.MaxMemoryLimit > 0 && mb > *m.conf.MaxMemoryLimit {
+		log.Printf("Solution %s: clamping memory limit %d MB to the runner maximum %d MB", solutionID, mb, *m.conf.MaxMemoryLimit)
+		return *m.conf.MaxMemoryLimit
+	}
+	return mb
+}
+
+// clampCPU 将 CPU 限制（核心数）截断到 runner 上限，不限制（0）同样视为超过上限
+func (m *Manager) clampCPU(solutionID string, cores float64) float64 {
+	if m.conf.MaxCPULimit != nil && *m.conf.MaxCPULimit > 0 && (cores <= 0 || cores > *m.conf.MaxCPULimit) {
+		log.Printf("Solution %s: clamping cpu limit %g to the runner maximum %g", solutionID, cores, *m.conf.MaxCPULimit)
+		return *m.conf.MaxCPULimit
+	}
+	return cores
+}
+
+// applyOutputLimit 设置输出总量上限与超限时的处理方式，题目未指定时使用 runner 的默认值并截断到上限
+func (m *Manager) applyOutputLimit(solutionID string, rc *RunningConfig, config *executor.ExecuteConfig) error {
+	switch rc.OutputLimitPolicy {
+	case "", OutputLimitKill:
+	case OutputLimitTruncate:
+		config.OutputDrain = true
+	default:
+		return fmt.Errorf("unknown output_limit_policy %q", rc.OutputLimitPolicy)
+	}
+	limit := rc.OutputLimit
+	if limit <= 0 {
+		limit = defaultJobOutputLimit
+		if m.conf.OutputLimit != nil && *m.conf.OutputLimit > 0 {
+			limit = *m.conf.OutputLimit
+		}
+	}
+	if m.conf.MaxOutputLimit != nil && *m.conf.MaxOutputLimit > 0 && limit > *m.conf.MaxOutputLimit {
+		log.Printf("Solution %s: clamping output limit %d MB to the runner maximum %d MB", solutionID, limit, *m.conf.MaxOutputLimit)
+		limit = *m.conf.MaxOutputLimit
+	}
+	config.OutputLimit = limit << 20
+	return nil
+}
+
+// applySharedMemory 设置 /dev/shm 大小与大页。/dev/shm 计入容器内存限制，超过时截断到内存限制；
+// 大页不计入内存限制，只能在 runner 的 max-hugepages 范围内申请
+func (m *Manager) applySharedMemory(solutionID string, rc *RunningConfig, config *executor.ExecuteConfig) error {
+	if rc.ShmSize < 0 || rc.HugePages < 0 {
+		return fmt.Errorf("shm_size and hugepages must not be negative")
+	}
+	config.ShmSize = rc.ShmSize
+	if config.MemoryLimit > 0 && config.ShmSize > config.MemoryLimit {
+		log.Printf("Solution %s: clamping shm size %d MB to the memory limit %d MB", solutionID, config.ShmSize, config.MemoryLimit)
+		config.ShmSize = config.MemoryLimit
+	}
+
+	if rc.HugePages == 0 {
+		return nil
+	}
+	if m.conf.MaxHugePages == nil || rc.HugePages > *m.conf.MaxHugePages {
+		return fmt.Errorf("hugepages %d MB exceeds the runner maximum", rc.HugePages)
+	}
+	pageSize, pageMB := "2M", int64(2)
+	switch rc.HugePageSize {
+	case "", "2M":
+	case "1G":
+		pageSize, pageMB = "1G", 1024
+	default:
+		return fmt.Errorf("unsupported hugepage_size %q", rc.HugePageSize)
+	}
+	if rc.HugePages%pageMB != 0 {
+		return fmt.Errorf("hugepages %d MB is not a multiple of the page size %s", rc.HugePages, pageSize)
+	}
+	config.HugePages, config.HugePageSize = rc.HugePages, pageSize
+	return nil
+}
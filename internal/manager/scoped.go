@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+)
+
+// scopedReportPath 返回用户在某题上最近一次完整结果的保存位置
+func (m *Manager) scopedReportPath(label, userID string) string {
+	return filepath.Join(m.workDir(), "reports", url.PathEscape(label), url.PathEscape(userID)+".json")
+}
+
+// scopeFailedTests 对开启了 RerunFailedOnly 的题目，若本 runner 上保存了该用户上次的结果，
+// 则本次只运行上次未通过的测试，出分时与上次结果合并。
+// 结果只保存在本地，由其他 runner 评测过的提交会回退为完整运行
+func (m *Manager) scopeFailedTests(job *Job) {
+	data, err := os.ReadFile(m.scopedReportPath(job.soln.ProblemConfig.Label, job.soln.UserId))
+	if err != nil {
+		return
+	}
+	base, err := adapters.ParsePytestReportFromBytes(data)
+	if err != nil {
+		log.Printf("Ignoring previous report for solution %s: %v", job.SolutionID, err)
+		return
+	}
+	failed := adapters.FailedTests(base)
+	if len(failed) == 0 {
+		return
+	}
+	log.Printf("Solution %s: running only %d previously failed test(s) of %d", job.SolutionID, len(failed), len(base.Tests))
+	job.execConfig.Env["JUDGE_RETRY_TESTS"] = strings.Join(failed, " ")
+	job.scopedBase = base
+}
+
+// mergeScopedReport 将本次运行的结果合并到上次的完整结果中，返回用于计分的报告
+func (m *Manager) mergeScopedReport(job *Job, report *adapters.PytestReport) *adapters.PytestReport {
+	if job.scopedBase == nil {
+		return report
+	}
+	adapters.MergeResults(job.scopedBase, report)
+	return job.scopedBase
+}
+
+// saveScopedReport 保存用户本题的最新完整结果，供下次提交只运行失败的测试
+func (m *Manager) saveScopedReport(job *Job, report *adapters.PytestReport) {
+	path := m.scopedReportPath(job.soln.ProblemConfig.Label, job.soln.UserId)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("Failed to save report for scoped re-run: %v", err)
+		return
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		log.Printf("Failed to save report for scoped re-run: %v", err)
+	}
+}
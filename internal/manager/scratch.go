@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// scratchCheckInterval 评测期间检查输出目录大小的间隔
+const scratchCheckInterval = 5 * time.Second
+
+// scratchDir 返回评测输出等临时目录的根路径，未配置时位于 work-dir 中。
+// 远程 Docker 主机需以相同路径挂载该目录
+func (m *Manager) scratchDir() string {
+	if m.conf.ScratchDir != nil && *m.conf.ScratchDir != "" {
+		return filepath.Join(*m.conf.ScratchDir, *m.conf.RunnerID)
+	}
+	return m.workDir()
+}
+
+// scratchJobLimit 返回单个评测临时目录的大小上限（字节），0 表示不限制
+func (m *Manager) scratchJobLimit() int64 {
+	if m.conf.ScratchJobLimit == nil {
+		return 0
+	}
+	return *m.conf.ScratchJobLimit
+}
+
+// sweepScratch 启动时删除上次运行遗留的评测临时目录，此时没有运行中的评测
+func (m *Manager) sweepScratch() {
+	for _, dir := range m.tempRoots() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !hasAnyPrefix(entry.Name(), tempDirPrefixes) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				log.Printf("Failed to remove orphaned temp dir %s: %v", entry.Name(), err)
+				continue
+			}
+			log.Printf("Removed orphaned temp dir %s", entry.Name())
+		}
+	}
+}
+
+// tempRoots 返回评测临时目录所在的目录
+func (m *Manager) tempRoots() []string {
+	if m.scratchDir() == m.workDir() {
+		return []string{m.workDir()}
+	}
+	return []string{m.scratchDir(), m.workDir()}
+}
+
+// scratchFull 临时目录总大小是否达到 scratch-total-limit，达到时暂停领取新任务
+func (m *Manager) scratchFull() bool {
+	if m.conf.ScratchTotalLimit == nil || *m.conf.ScratchTotalLimit <= 0 {
+		return false
+	}
+	size := dirSize(m.scratchDir())
+	full := size >= *m.conf.ScratchTotalLimit
+	if m.scratchPaused.Swap(full) != full {
+		if full {
+			log.Printf("Scratch dir uses %d MB, at or above the %d MB budget, pausing polling", size>>20, *m.conf.ScratchTotalLimit>>20)
+		} else {
+			log.Printf("Scratch dir uses %d MB, resuming polling", size>>20)
+		}
+	}
+	return full
+}
+
+// watchScratch 在评测容器运行期间定期检查输出目录大小，超过单个评测的上限时终止容器。
+// 返回停止检查的函数
+func (m *Manager) watchScratch(job *Job) func() {
+	limit := m.scratchJobLimit()
+	if limit <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(job.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(scratchCheckInterval):
+			}
+			size := dirSize(job.outputDir)
+			if size <= limit {
+				continue
+			}
+			log.Printf("Solution %s: output dir uses %d MB, above the %d MB limit, stopping container", job.SolutionID, size>>20, limit>>20)
+			job.scratchExceeded.Store(true)
+			m.runningMu.Lock()
+			containerID := job.containerID
+			m.runningMu.Unlock()
+			if containerID != "" {
+				job.exec.Stop(context.Background(), containerID)
+			}
+			return
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/secrets"
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// initSecrets 根据配置初始化密钥存储，文件优先于 Vault
+func (m *Manager) initSecrets() error {
+	if m.conf.SecretsFile != nil && *m.conf.SecretsFile != "" {
+		store, err := secrets.NewFileStore(*m.conf.SecretsFile)
+		if err != nil {
+			return err
+		}
+		m.secrets = append(m.secrets, store)
+	}
+	if m.conf.VaultAddr != nil && *m.conf.VaultAddr != "" {
+		if m.conf.VaultPath == nil || *m.conf.VaultPath == "" {
+			return errors.New("vault path must be provided with vault address")
+		}
+		token := ""
+		if m.conf.VaultToken != nil {
+			token = *m.conf.VaultToken
+		}
+		m.secrets = append(m.secrets, secrets.NewVaultStore(*m.conf.VaultAddr, token, *m.conf.VaultPath))
+	}
+	return nil
+}
+
+// injectSecrets 将 judge config 引用的密钥以同名环境变量注入容器
+func (m *Manager) injectSecrets(ctx context.Context, names []string, config *executor.ExecuteConfig) error {
+	for _, name := range names {
+		if !secretNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+		value, err := m.secrets.Get(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to resolve secret %q: %w", name, err)
+		}
+		config.Env[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	seccompUnconfined = "unconfined"
+	defaultUser       = "1000:1000"
+	defaultTmpfsSize  = 64 // MB
+)
+
+// isRootUser 判断容器用户是否为 root，数字形式按 uid 判断（"00" 与 "0000:0" 同样是 root）
+func isRootUser(u string) bool {
+	name, _, _ := strings.Cut(u, ":")
+	if name == "" || name == "root" {
+		return true
+	}
+	uid, err := strconv.Atoi(name)
+	return err == nil && uid == 0
+}
+
+// resolveUser 确定容器运行用户，默认使用非 root 用户，
+// 未允许 root 时拒绝要求以 root 运行的 judge config
+func (m *Manager) resolveUser(rc *RunningConfig, config *executor.ExecuteConfig) error {
+	u := rc.User
+	if u == "" && m.conf.DefaultUser != nil {
+		u = *m.conf.DefaultUser
+	}
+	if u == "" {
+		u = defaultUser
+	}
+	if isRootUser(u) && (m.conf.AllowRoot == nil || !*m.conf.AllowRoot) {
+		return fmt.Errorf("judge config requests root user %q but running as root is not allowed", u)
+	}
+	config.User = u
+	return nil
+}
+
+// applyReadOnlyRootfs 以只读方式挂载根文件系统，为 /tmp 与工作目录挂载限制大小的 tmpfs。
+// 已有挂载的路径（如输出目录、构建缓存）保持不变；tmpfs 允许执行，以便编译运行选手程序
+func applyReadOnlyRootfs(rc *RunningConfig, config *executor.ExecuteConfig) {
+	size := rc.TmpfsSize
+	if size <= 0 {
+		size = defaultTmpfsSize
+	}
+	options := fmt.Sprintf("rw,exec,nosuid,nodev,size=%dm,mode=1777", size)
+	config.ReadOnlyRootfs = true
+	config.Tmpfs = make(map[string]string)
+	for _, target := range []string{"/tmp", config.WorkDir} {
+		target = filepath.Clean(target)
+		if target == "/" || slices.ContainsFunc(config.Mounts, func(m executor.Mount) bool { return filepath.Clean(m.Target) == target }) {
+			continue
+		}
+		config.Tmpfs[target] = options
+	}
+}
+
+// applySecurityProfiles 根据 judge config 与 manager 默认值设置 seccomp / AppArmor / SELinux 与 OCI 运行时。
+// judge config 只能选择 seccomp 目录中的命名配置与 manager 允许列表中的配置，
+// 不能绕过 runner 的默认加固（如 unconfined）
+func (m *Manager) applySecurityProfiles(rc *RunningConfig, config *executor.ExecuteConfig) error {
+	if rc.Seccomp == seccompUnconfined && !m.allowSecurityProfile("seccomp", rc.Seccomp) {
+		return fmt.Errorf("seccomp profile %s is not allowed by this runner", rc.Seccomp)
+	}
+	if rc.AppArmor != "" && !m.allowSecurityProfile("apparmor", rc.AppArmor) {
+		return fmt.Errorf("apparmor profile %s is not allowed by this runner", rc.AppArmor)
+	}
+	if rc.SELinuxLabel != "" && !m.allowSecurityProfile("selinux", rc.SELinuxLabel) {
+		return fmt.Errorf("selinux label %s is not allowed by this runner", rc.SELinuxLabel)
+	}
+
+	seccomp := rc.Seccomp
+	if seccomp == "" && m.conf.SeccompProfile != nil {
+		seccomp = *m.conf.SeccompProfile
+	}
+	if seccomp != "" {
+		profile, err := m.loadSeccompProfile(seccomp)
+		if err != nil {
+			return err
+		}
+		config.SeccompProfile = profile
+	}
+
+	config.AppArmorProfile = rc.AppArmor
+	if config.AppArmorProfile == "" && m.conf.AppArmorProfile != nil {
+		config.AppArmorProfile = *m.conf.AppArmorProfile
+	}
+	config.SELinuxLabel = rc.SELinuxLabel
+
+	if rc.Runtime != "" {
+		if !m.allowRuntime(rc.Runtime) {
+			return fmt.Errorf("runtime %s is not allowed by this runner", rc.Runtime)
+		}
+		config.Runtime = rc.Runtime
+	}
+	return nil
+}
+
+// allowSecurityProfile 判断 judge config 选择的安全配置是否在 manager 允许列表中，列表项形如 kind:name
+func (m *Manager) allowSecurityProfile(kind, name string) bool {
+	if m.conf.AllowedSecurityProfiles == nil {
+		return false
+	}
+	for _, profile := range strings.Split(*m.conf.AllowedSecurityProfiles, ",") {
+		if strings.TrimSpace(profile) == kind+":"+name {
+			return true
+		}
+	}
+	return false
+}
+
+// allowRuntime 判断 OCI 运行时是否在 manager 允许列表中
+func (m *Manager) allowRuntime(name string) bool {
+	if m.conf.AllowedRuntimes == nil {
+		return false
+	}
+	for _, runtime := range strings.Split(*m.conf.AllowedRuntimes, ",") {
+		if strings.TrimSpace(runtime) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDevices 解析 judge config 请求直通的设备，只允许 manager 允许列表中的 /dev 路径，
+// 使 RDMA、ROCm 等题目无需 --privileged 即可访问所需硬件
+func (m *Manager) resolveDevices(rc *RunningConfig, config *executor.ExecuteConfig) error {
+	config.Devices = nil
+	for _, spec := range rc.Devices {
+		parts := strings.Split(spec, ":")
+		if len(parts) > 3 {
+			return fmt.Errorf("invalid device %q", spec)
+		}
+		device := executor.Device{Source: filepath.Clean(parts[0]), Permissions: "rwm"}
+		device.Target = device.Source
+		if len(parts) > 1 && parts[1] != "" {
+			device.Target = filepath.Clean(parts[1])
+		}
+		if len(parts) > 2 {
+			device.Permissions = parts[2]
+		}
+		if !strings.HasPrefix(device.Source, "/dev/") || !filepath.IsAbs(device.Target) {
+			return fmt.Errorf("invalid device %q", spec)
+		}
+		if device.Permissions == "" || strings.Trim(device.Permissions, "rwm") != "" {
+			return fmt.Errorf("invalid permissions %q for device %s", device.Permissions, device.Source)
+		}
+		if !m.allowDevice(device.Source) {
+			return fmt.Errorf("device %s is not allowed by this runner", device.Source)
+		}
+		config.Devices = append(config.Devices, device)
+	}
+	return nil
+}
+
+// allowDevice 判断宿主机设备路径是否匹配 manager 允许列表
+func (m *Manager) allowDevice(path string) bool {
+	if m.conf.AllowedDevices == nil {
+		return false
+	}
+	for _, pattern := range strings.Split(*m.conf.AllowedDevices, ",") {
+		if ok, _ := filepath.Match(strings.TrimSpace(pattern), path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSeccompProfile 从 manager 配置目录中读取命名的 seccomp 配置，
+// judge config 只能引用名称，不能指定任意路径
+func (m *Manager) loadSeccompProfile(name string) (string, error) {
+	if name == seccompUnconfined {
+		return seccompUnconfined, nil
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid seccomp profile name %q", name)
+	}
+	if m.conf.SeccompDir == nil || *m.conf.SeccompDir == "" {
+		return "", fmt.Errorf("seccomp profile %q requested but no seccomp dir configured", name)
+	}
+	data, err := os.ReadFile(filepath.Join(*m.conf.SeccompDir, name+".json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to load seccomp profile %q: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// defaultEnvDenylist 默认禁止 judge config 设置的环境变量，可能改变动态链接、
+// 容器运行时或网络出口行为
+const defaultEnvDenylist = "LD_*,DOCKER_*,HTTP_PROXY,HTTPS_PROXY,FTP_PROXY,ALL_PROXY,NO_PROXY"
+
+// parseEnvPatterns 解析逗号分隔的变量名模式，支持 * 通配，不区分大小写
+func parseEnvPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, strings.ToUpper(p))
+		}
+	}
+	return patterns
+}
+
+func matchEnvPattern(patterns []string, name string) bool {
+	name = strings.ToUpper(name)
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowEnv 判断 judge config 中的环境变量是否符合 manager 的变量策略：
+// 配置了白名单时只允许白名单内的变量，且任何情况下都不允许黑名单内的变量
+func (m *Manager) allowEnv(name string) bool {
+	deny := defaultEnvDenylist
+	if m.conf.EnvDenylist != nil {
+		deny = *m.conf.EnvDenylist
+	}
+	if matchEnvPattern(parseEnvPatterns(deny), name) {
+		return false
+	}
+	if m.conf.EnvAllowlist != nil && *m.conf.EnvAllowlist != "" {
+		return matchEnvPattern(parseEnvPatterns(*m.conf.EnvAllowlist), name)
+	}
+	return true
+}
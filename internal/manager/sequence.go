@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// maxTrackedGap 记录为缺失的最大序号跨度，更大的跳跃只告警，其中迟到的消息按重复丢弃
+const maxTrackedGap = 1024
+
+// seqState 一个消息流已处理的最大序号与其间尚未收到的序号
+type seqState struct {
+	max     uint64
+	missing map[uint64]bool
+}
+
+// acceptSeq 判断消息流中的序号是否首次出现，重复的消息返回 false；出现跳跃时告警。
+// 每个容器进程使用独立的消息流，步骤、重试与 exec adapter 的序号互不影响；
+// 首次见到的流（如从检查点恢复的进程）不检查之前的序号
+func (r *reporter) acceptSeq(stream string, seq uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams == nil {
+		r.streams = make(map[string]*seqState)
+	}
+	state, ok := r.streams[stream]
+	if !ok {
+		r.streams[stream] = &seqState{max: seq, missing: make(map[uint64]bool)}
+		return true
+	}
+	switch {
+	case seq > state.max:
+		if seq > state.max+1 {
+			slog.Warn(fmt.Sprintf("Solution %s: messages %d-%d of stream %s are missing", r.SolutionID(), state.max+1, seq-1, stream),
+				"solution", r.SolutionID(), "stream", stream, "from", state.max+1, "to", seq-1)
+			if seq-state.max-1 <= maxTrackedGap {
+				for s := state.max + 1; s < seq; s++ {
+					state.missing[s] = true
+				}
+			}
+		}
+		state.max = seq
+		return true
+	case state.missing[seq]:
+		// 乱序到达的消息
+		delete(state.missing, seq)
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// judgeAlias 评测容器在任务网络中的主机名
+const judgeAlias = "judge"
+
+// 就绪检查默认值
+const (
+	defaultReadyTimeout  = 60
+	defaultReadyInterval = 1
+)
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,30}$`)
+
+// ServiceConfig 与评测容器一同启动的辅助容器（如被测服务端、数据库、Redis），
+// 所有容器加入同一个私有网络并以服务名互相访问，评测容器的主机名为 judge。
+// 只有评测容器的输出作为 judgerproto 协议解析；服务的输出写入本地日志。
+// 服务按声明顺序启动，配置了 ready 时等待其就绪后再启动下一个
+type ServiceConfig struct {
+	Name        string            `json:"name"`        // 服务名，同时作为网络中的主机名
+	Image       string            `json:"image"`       // 镜像，默认与评测容器相同；配置了 trusted-service-images 时必须在其中
+	Cmd         []string          `json:"cmd"`         // 执行命令，为空时使用镜像默认命令
+	Env         map[string]string `json:"env"`         // 额外的环境变量
+	User        string            `json:"user"`        // 运行用户，仅指定了 image 时生效，为空时使用镜像默认用户（视为 root）
+	MemoryLimit int64             `json:"memoryLimit"` // 内存限制（MB），默认与评测容器相同
+	CPULimit    float64           `json:"cpuLimit"`    // CPU 限制（核心数），默认与评测容器相同
+	Ready       *ReadyConfig      `json:"ready"`       // 就绪检查
+}
+
+// ReadyConfig 服务就绪检查，在服务容器内反复执行 cmd 直到退出码为 0
+type ReadyConfig struct {
+	Cmd      []string `json:"cmd"`      // 检查命令，如 ["pg_isready", "-U", "postgres"]
+	Timeout  int64    `json:"timeout"`  // 等待就绪的最长时间（秒），默认 60
+	Interval int64    `json:"interval"` // 检查间隔（秒），默认 1
+}
+
+// startServices 创建任务网络（已有独立网络时复用）并启动各服务容器，返回清理函数。
+// 服务沿用评测容器的安全配置与只读挂载，不挂载 /output
+func (m *Manager) startServices(job *Job, services []ServiceConfig) (func(), error) {
+	var cleanups []func()
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+
+	execConfig := job.execConfig
+	if execConfig.Network == "" || execConfig.NetworkDisabled {
+		name, release, err := m.createJobNetwork(job, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job network: %w", err)
+		}
+		cleanups = append(cleanups, release)
+		execConfig.Network = name
+		execConfig.NetworkDisabled = false
+	}
+	execConfig.NetworkAliases = []string{judgeAlias}
+
+	seen := map[string]bool{judgeAlias: true}
+	for _, svc := range services {
+		if !serviceNamePattern.MatchString(svc.Name) || seen[svc.Name] {
+			cleanup()
+			return nil, fmt.Errorf("invalid or duplicate service name %q", svc.Name)
+		}
+		seen[svc.Name] = true
+
+		config := *execConfig
+		config.Command = svc.Cmd
+		config.NetworkAliases = []string{svc.Name}
+		config.Labels = maps.Clone(execConfig.Labels)
+		config.Labels[executor.LabelService] = svc.Name
+		config.Env = maps.Clone(execConfig.Env)
+		for k, v := range svc.Env {
+			if m.allowEnv(k) {
+				config.Env[k] = v
+			}
+		}
+		if svc.Image != "" {
+			// 第三方服务镜像（如数据库）通常需要以自身的用户运行并写入根文件系统，
+			// 但与评测容器一样受 runner 的 root 策略约束：未指定 user 时使用镜像默认用户，通常是 root
+			if err := m.checkServiceImage(svc); err != nil {
+				cleanup()
+				return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+			config.Image = svc.Image
+			config.User = svc.User
+			config.ReadOnlyRootfs = false
+			config.Tmpfs = nil
+			if err := m.ensureImage(job, svc.Image); err != nil {
+				cleanup()
+				return nil, fmt.Errorf("failed to prepare image for service %s: %w", svc.Name, err)
+			}
+		}
+		if svc.MemoryLimit > 0 {
+			config.MemoryLimit = m.clampMemory(job.SolutionID, svc.MemoryLimit)
+		}
+		if svc.CPULimit > 0 {
+			config.CPULimit = m.clampCPU(job.SolutionID, svc.CPULimit)
+		}
+		config.Mounts = nil
+		for _, mount := range execConfig.Mounts {
+			if mount.Target == "/output" {
+				continue
+			}
+			mount.ReadOnly = true
+			config.Mounts = append(config.Mounts, mount)
+		}
+		config.GPUDevices = nil
+		config.Devices = nil
+		config.HugePages = 0
+
+		startCtx, cancelStart := context.WithTimeout(job.ctx, dockerCallTimeout)
+		session, err := job.exec.StartSession(startCtx, &config)
+		cancelStart()
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to start service %s: %w", svc.Name, err)
+		}
+		id := session.ID()
+		job.serviceIDs = append(job.serviceIDs, id)
+		log.Printf("Solution %s: started service %s (%s)", job.SolutionID, svc.Name, id)
+
+		local := m.openLocalLog(job, "svc-"+svc.Name)
+		ctx, cancel := context.WithCancel(job.ctx)
+		logsDone := job.exec.FollowLogs(ctx, id, func(stream executor.LogStream, line string) error {
+			local.write(line)
+			return nil
+		})
+		cleanups = append(cleanups, func() {
+			session.Close(context.Background())
+			cancel()
+			<-logsDone
+			local.close()
+		})
+
+		if svc.Ready != nil {
+			if err := waitReady(job.execCtx, session, svc.Ready, m.maxTimeout()); err != nil {
+				cleanup()
+				return nil, fmt.Errorf("service %s is not ready: %w", svc.Name, err)
+			}
+			log.Printf("Solution %s: service %s is ready", job.SolutionID, svc.Name)
+		}
+	}
+	return cleanup, nil
+}
+
+// checkServiceImage 检查服务镜像是否受信任，以及服务用户是否符合 runner 的 root 策略。
+// 未指定 user 时无法确定镜像默认用户，按 root 处理
+func (m *Manager) checkServiceImage(svc ServiceConfig) error {
+	if m.conf.TrustedServiceImages != nil && *m.conf.TrustedServiceImages != "" {
+		var trusted []string
+		for _, image := range strings.Split(*m.conf.TrustedServiceImages, ",") {
+			trusted = append(trusted, strings.TrimSpace(image))
+		}
+		if !slices.Contains(trusted, svc.Image) {
+			return fmt.Errorf("image %s is not trusted by this runner", svc.Image)
+		}
+	}
+	if isRootUser(svc.User) && (m.conf.AllowRoot == nil || !*m.conf.AllowRoot) {
+		if svc.User == "" {
+			return fmt.Errorf("user is required for image %s because running as root is not allowed", svc.Image)
+		}
+		return fmt.Errorf("root user %q is not allowed by this runner", svc.User)
+	}
+	return nil
+}
+
+// waitReady 反复执行就绪检查命令，直到成功、超时或 ctx 取消，超时不超过 runner 上限 max（0 表示不限制）
+func waitReady(ctx context.Context, session executor.Session, ready *ReadyConfig, max time.Duration) error {
+	if len(ready.Cmd) == 0 {
+		return fmt.Errorf("ready.cmd is required")
+	}
+	timeout := ready.Timeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	interval := ready.Interval
+	if interval <= 0 {
+		interval = defaultReadyInterval
+	}
+
+	wait := time.Duration(timeout) * time.Second
+	if max > 0 && wait > max {
+		wait = max
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	var lastOutput string
+	for {
+		result, err := session.Exec(ctx, &executor.ExecConfig{Command: ready.Cmd}, func(stream executor.LogStream, line string) error {
+			lastOutput = line
+			return nil
+		})
+		if err == nil && result.ExitCode == 0 {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			// 检查命令无法执行（如容器已退出）
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return ctx.Err()
+			}
+			if lastOutput != "" {
+				return fmt.Errorf("timed out after %ds: %s", timeout, lastOutput)
+			}
+			return fmt.Errorf("timed out after %ds", timeout)
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+	}
+}
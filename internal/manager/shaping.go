@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const defaultShapingImage = "nicolaka/netshoot"
+
+var (
+	netemTimePattern = regexp.MustCompile(`^\d+(\.\d+)?(us|ms|s)$`)
+	netemRatePattern = regexp.MustCompile(`^\d+(\.\d+)?(bit|kbit|mbit|gbit)$`)
+)
+
+// NetworkShapingConfig 评测容器网络的延迟与带宽限制（tc netem），用于网络相关题目的可复现评测
+type NetworkShapingConfig struct {
+	Delay  string  `json:"delay"`  // 单向延迟，如 "20ms"
+	Jitter string  `json:"jitter"` // 延迟抖动，如 "5ms"
+	Loss   float64 `json:"loss"`   // 丢包率（百分比）
+	Rate   string  `json:"rate"`   // 带宽上限，如 "100mbit"
+}
+
+// netemArgs 校验配置并生成 tc 命令
+func (c *NetworkShapingConfig) netemArgs() ([]string, error) {
+	args := []string{"tc", "qdisc", "replace", "dev", "eth0", "root", "netem"}
+	if c.Delay != "" {
+		if !netemTimePattern.MatchString(c.Delay) {
+			return nil, fmt.Errorf("invalid netem delay %q", c.Delay)
+		}
+		args = append(args, "delay", c.Delay)
+		if c.Jitter != "" {
+			if !netemTimePattern.MatchString(c.Jitter) {
+				return nil, fmt.Errorf("invalid netem jitter %q", c.Jitter)
+			}
+			args = append(args, c.Jitter)
+		}
+	}
+	if c.Loss > 0 {
+		if c.Loss > 100 {
+			return nil, fmt.Errorf("invalid netem loss %v", c.Loss)
+		}
+		args = append(args, "loss", strconv.FormatFloat(c.Loss, 'f', -1, 64)+"%")
+	}
+	if c.Rate != "" {
+		if !netemRatePattern.MatchString(c.Rate) {
+			return nil, fmt.Errorf("invalid netem rate %q", c.Rate)
+		}
+		args = append(args, "rate", c.Rate)
+	}
+	return args, nil
+}
+
+func (m *Manager) shapingImage() string {
+	if m.conf.ShapingImage != nil && *m.conf.ShapingImage != "" {
+		return *m.conf.ShapingImage
+	}
+	return defaultShapingImage
+}
+
+// applyNetworkShaping 启动持有网络命名空间的 pause 容器，以具备 NET_ADMIN 的辅助容器
+// 在其中配置 netem，再让评测容器共享该网络命名空间。评测容器本身不获得任何额外权限，
+// 且在启动前限速已生效。netem 只作用于出方向，因此各个服务容器的网络命名空间也同样配置，
+// 评测容器与服务之间两个方向的流量都受限（往返延迟为两倍 delay）；服务的就绪检查在限速前完成
+func (m *Manager) applyNetworkShaping(job *Job, shaping *NetworkShapingConfig) (func(), error) {
+	args, err := shaping.netemArgs()
+	if err != nil {
+		return nil, err
+	}
+	image := m.shapingImage()
+	if err := m.ensureImage(job, image); err != nil {
+		return nil, fmt.Errorf("failed to prepare shaping image: %w", err)
+	}
+
+	startCtx, cancelStart := context.WithTimeout(job.ctx, dockerCallTimeout)
+	defer cancelStart()
+	pauseID, err := job.exec.StartDetached(startCtx, &executor.ExecuteConfig{
+		Image:           image,
+		Command:         []string{"sleep", "infinity"},
+		Labels:          job.execConfig.Labels,
+		Network:         job.execConfig.Network,
+		NetworkAliases:  job.execConfig.NetworkAliases,
+		NoNewPrivileges: true,
+		CapDrop:         []string{"ALL"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start network holder: %w", err)
+	}
+	cleanup := func() { job.exec.Cleanup(context.Background(), pauseID) }
+
+	for _, id := range append([]string{pauseID}, job.serviceIDs...) {
+		if err := m.runNetem(job, image, args, id); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to apply network shaping: %w", err)
+		}
+	}
+
+	job.execConfig.Network = "container:" + pauseID
+	return cleanup, nil
+}
+
+// runNetem 在容器 id 的网络命名空间中执行 tc 命令
+func (m *Manager) runNetem(job *Job, image string, args []string, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := job.exec.Execute(ctx, &executor.ExecuteConfig{
+		Image:           image,
+		Command:         args,
+		Timeout:         20 * time.Second,
+		Labels:          job.execConfig.Labels,
+		Network:         "container:" + id,
+		NoNewPrivileges: true,
+		CapDrop:         []string{"ALL"},
+		CapAdd:          []string{"NET_ADMIN"},
+	})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("tc exited with code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+const (
+	defaultSimilarityTimeout = 60        // 秒
+	maxSimilaritySource      = 4 << 20   // 上传给查重服务的源码总大小上限
+	maxSimilarityReport      = 256 << 10 // 查重报告大小上限
+	similarityDefaultContest = "*"       // 未单独配置的比赛使用的配置
+	similarityDetailsName    = "相似度检查"   // 详情中查重结果一项的名称
+	similarityMaxMatches     = 10        // 详情中展示的相似提交数上限
+)
+
+// SimilarityCheckConfig 评测完成后的查重配置，按比赛配置在 similarity-config 文件中
+type SimilarityCheckConfig struct {
+	URL      string   `json:"url"`      // MOSS/JPlag 兼容的查重服务地址，以 JSON 上传提交的源码
+	Command  []string `json:"command"`  // 本地查重工具，提交内容的解压目录以 SOLUTION_DIR 传入，报告输出到 stdout
+	Language string   `json:"language"` // 源码语言，原样传给查重服务
+	Timeout  int64    `json:"timeout"`  // 超时时间（秒），默认 60
+	Problems []string `json:"problems"` // 只检查这些题目（label），为空时检查比赛的全部题目
+}
+
+// similarityRequest 上传给查重服务的提交内容
+type similarityRequest struct {
+	SolutionID   string            `json:"solutionId"`
+	UserID       string            `json:"userId"`
+	ContestID    string            `json:"contestId"`
+	ProblemLabel string            `json:"problemLabel"`
+	Language     string            `json:"language,omitempty"`
+	Files        map[string]string `json:"files"` // 相对路径 -> 内容，只包含 UTF-8 文本文件
+}
+
+// similarityReport 查重服务或本地工具返回的报告
+type similarityReport struct {
+	MaxSimilarity float64 `json:"max_similarity"` // 与其他提交的最高相似度（0-1）
+	ReportURL     string  `json:"report_url"`     // 完整报告地址
+	Summary       string  `json:"summary"`
+	Matches       []struct {
+		Solution   string  `json:"solution"`
+		User       string  `json:"user"`
+		Similarity float64 `json:"similarity"`
+	} `json:"matches"`
+}
+
+// similarityConfig 返回提交所在比赛的查重配置，未配置时返回 nil
+func (m *Manager) similarityConfig(soln *aoiclient.SolutionPoll) (*SimilarityCheckConfig, error) {
+	if m.conf.SimilarityConfig == nil || *m.conf.SimilarityConfig == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(*m.conf.SimilarityConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read similarity config: %w", err)
+	}
+	var contests map[string]*SimilarityCheckConfig
+	if err := json.Unmarshal(data, &contests); err != nil {
+		return nil, fmt.Errorf("failed to parse similarity config: %w", err)
+	}
+	c, ok := contests[soln.ContestId]
+	if !ok {
+		c = contests[similarityDefaultContest]
+	}
+	if c == nil || len(c.Problems) > 0 && !slices.Contains(c.Problems, soln.ProblemConfig.Label) {
+		return nil, nil
+	}
+	if (c.URL == "") == (len(c.Command) == 0) {
+		return nil, fmt.Errorf("similarity check for contest %s needs exactly one of url and command", soln.ContestId)
+	}
+	return c, nil
+}
+
+// checkSimilarity 评测结束后将提交的源码交给查重服务或本地工具，结果作为详情中的一项附加。
+// 查重不影响评分，失败时只在详情中说明
+func (m *Manager) checkSimilarity(job *Job) {
+	c, err := m.similarityConfig(job.soln)
+	if err != nil {
+		log.Printf("Solution %s: %v", job.SolutionID, err)
+		return
+	}
+	if c == nil {
+		return
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultSimilarityTimeout
+	}
+	ctx, cancel := context.WithTimeout(job.ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	report, err := m.runSimilarityCheck(ctx, job, c)
+	section := &aoiclient.SolutionDetailsJob{
+		Name:   similarityDetailsName,
+		Status: aoiclient.StatusSuccess,
+		Tests:  []*aoiclient.SolutionDetailsTest{},
+	}
+	if err != nil {
+		log.Printf("Solution %s: similarity check failed: %v", job.SolutionID, err)
+		section.Status = aoiclient.StatusError
+		section.Summary = fmt.Sprintf("查重失败：%v", err)
+	} else {
+		log.Printf("Solution %s: max similarity %.1f%%", job.SolutionID, report.MaxSimilarity*100)
+		section.Summary = report.format()
+	}
+	if err := job.aoi.attachDetailsJob(job.ctx, section); err != nil {
+		log.Printf("Failed to save similarity report for solution %s: %v", job.SolutionID, err)
+	}
+}
+
+// runSimilarityCheck 下载提交内容并运行查重
+func (m *Manager) runSimilarityCheck(ctx context.Context, job *Job, c *SimilarityCheckConfig) (*similarityReport, error) {
+	soln := job.soln
+	dir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("judge-similarity-%s-", soln.SolutionId))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := m.cache.ExtractTo(ctx, soln.SolutionDataUrl, soln.SolutionDataHash, dir); err != nil {
+		return nil, fmt.Errorf("failed to fetch solution: %w", err)
+	}
+
+	var data []byte
+	if c.URL != "" {
+		data, err = postSimilarity(ctx, c, soln, dir)
+	} else {
+		data, err = execSimilarity(ctx, c, soln, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := &similarityReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("invalid similarity report: %w", err)
+	}
+	return report, nil
+}
+
+// postSimilarity 将源码上传到查重服务，返回其响应
+func postSimilarity(ctx context.Context, c *SimilarityCheckConfig, soln *aoiclient.SolutionPoll, dir string) ([]byte, error) {
+	files, err := readSourceFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&similarityRequest{
+		SolutionID:   soln.SolutionId,
+		UserID:       soln.UserId,
+		ContestID:    soln.ContestId,
+		ProblemLabel: soln.ProblemConfig.Label,
+		Language:     c.Language,
+		Files:        files,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSimilarityReport+1))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("similarity service returned %s", res.Status)
+	}
+	if len(data) > maxSimilarityReport {
+		return nil, fmt.Errorf("similarity report too large")
+	}
+	return data, nil
+}
+
+// execSimilarity 运行本地查重工具，返回其标准输出
+func execSimilarity(ctx context.Context, c *SimilarityCheckConfig, soln *aoiclient.SolutionPoll, dir string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"SOLUTION_DIR=" + dir,
+		"SOLUTION_ID=" + soln.SolutionId,
+		"USER_ID=" + soln.UserId,
+		"CONTEST_ID=" + soln.ContestId,
+		"PROBLEM_LABEL=" + soln.ProblemConfig.Label,
+		"LANGUAGE=" + c.Language,
+	}
+	var stdout, stderr limitedBuffer
+	stdout.limit, stderr.limit = maxSimilarityReport, 4<<10
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("similarity report too large")
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer 最多保留 limit 字节的输出
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// readSourceFiles 读取目录中的 UTF-8 文本文件，跳过二进制文件，总大小超过上限时报错
+func readSourceFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	total := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+		if total += len(data); total > maxSimilaritySource {
+			return fmt.Errorf("solution source exceeds %d MB", maxSimilaritySource>>20)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return files, err
+}
+
+// format 生成详情中展示的查重摘要
+func (r *similarityReport) format() string {
+	lines := []string{fmt.Sprintf("最高相似度 %.1f%%", r.MaxSimilarity*100)}
+	if r.Summary != "" {
+		lines = append(lines, r.Summary)
+	}
+	for i, match := range r.Matches {
+		if i == similarityMaxMatches {
+			lines = append(lines, fmt.Sprintf("另有 %d 个相似提交", len(r.Matches)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s（用户 %s）：%.1f%%", match.Solution, match.User, match.Similarity*100))
+	}
+	if r.ReportURL != "" {
+		lines = append(lines, "完整报告："+r.ReportURL)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"context"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// WorkSource 替换从 AOI 领取任务的来源（如 CI 队列），评测结果仍通过 AOI 接口上报
+type WorkSource interface {
+	// Next 返回至多 n 个待评测任务，没有任务时返回空列表；
+	// 可阻塞等待新任务，但须在 ctx 取消时返回
+	Next(ctx context.Context, n int) ([]*aoiclient.SolutionPoll, error)
+}
+
+// SetWorkSource 替换任务来源，设置后不再轮询 AOI 或接收推送，须在 Start 之前调用
+func (m *Manager) SetWorkSource(source WorkSource) {
+	m.source = source
+}
+
+// validSolutions 去掉缺少 solution 或 task ID 的任务
+func validSolutions(solns []*aoiclient.SolutionPoll) []*aoiclient.SolutionPoll {
+	var valid []*aoiclient.SolutionPoll
+	for _, soln := range solns {
+		if soln != nil && soln.SolutionId != "" && soln.TaskId != "" {
+			valid = append(valid, soln)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// maxStepOutput 步骤失败时展示的输出大小上限（取末尾）
+const maxStepOutput = 16 << 10
+
+// StepConfig 在同一个评测容器中依次执行的步骤（如编译、运行、收集结果）。
+// 配置 steps 时 docker_cmd 只用于保持容器运行（如 ["sleep", "infinity"]），
+// 各步骤共享容器的环境与文件系统，不需要重复初始化
+type StepConfig struct {
+	Name       string   `json:"name"`        // 步骤名称，用于日志与结果展示
+	Cmd        []string `json:"cmd"`         // 执行命令
+	Timeout    Duration `json:"timeout"`     // 超时时间，格式同 timeout，默认使用剩余的总时间
+	FailStatus string   `json:"fail_status"` // 退出码非零时直接上报的状态（如 "Compile Error"），为空时停止后续步骤并按报告处理；名为 compile/build 的步骤默认为 Compile Error
+}
+
+// stepFailure 设置了 fail_status 的步骤失败时的结果
+type stepFailure struct {
+	step     string
+	status   string
+	exitCode int
+	output   string
+}
+
+// tailBuffer 保留最近写入的 limit 字节
+type tailBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) write(line string) {
+	b.buf = append(b.buf, line...)
+	b.buf = append(b.buf, '\n')
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+}
+
+func (b *tailBuffer) String() string {
+	return strings.ToValidUTF8(string(b.buf), "")
+}
+
+// runSteps 启动评测容器并依次执行各步骤，返回与单次运行相同形式的结果：
+// 退出码为最后执行的步骤的退出码，超时与输出超限以任一步骤为准
+func (m *Manager) runSteps(ctx context.Context, job *Job, onLog executor.LogCallback) (*executor.ExecuteResult, error) {
+	session, err := job.exec.StartSession(ctx, job.execConfig)
+	if err != nil {
+		return nil, err
+	}
+	m.trackContainer(job, session.ID())
+	defer m.setContainer(job, "")
+
+	deadline := time.Now().Add(job.execConfig.Timeout)
+	var last *executor.ExecResult
+	for i, step := range job.rc.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			last = &executor.ExecResult{TimedOut: true}
+			break
+		}
+		timeout := remaining
+		if step.Timeout > 0 && time.Duration(step.Timeout) < remaining {
+			timeout = time.Duration(step.Timeout)
+		}
+
+		log.Printf("Solution %s: running step %s", job.SolutionID, name)
+		job.aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+			Status:  "Running",
+			Message: fmt.Sprintf("评测中：%s", name),
+		})
+		output := &tailBuffer{limit: maxStepOutput}
+		last, err = session.Exec(ctx, &executor.ExecConfig{
+			Command:     step.Cmd,
+			Timeout:     timeout,
+			OutputLimit: job.execConfig.OutputLimit,
+			OutputDrain: job.execConfig.OutputDrain,
+		}, func(stream executor.LogStream, line string) error {
+			output.write(line)
+			return onLog(stream, line)
+		})
+		if err != nil {
+			session.Close(context.Background())
+			return nil, fmt.Errorf("step %s failed to run: %w", name, err)
+		}
+		log.Printf("Solution %s: step %s exited with code %d in %s", job.SolutionID, name, last.ExitCode, last.Duration)
+		if last.TimedOut || last.OutputLimitExceeded || last.CPUTimeExceeded {
+			break
+		}
+		if last.ExitCode != 0 {
+			failStatus := step.FailStatus
+			if failStatus == "" && isCompileStep(step.Name) {
+				failStatus = aoiclient.StatusCompileError
+			}
+			if failStatus != "" {
+				job.stepFailure = &stepFailure{
+					step:     name,
+					status:   failStatus,
+					exitCode: last.ExitCode,
+					output:   output.String(),
+				}
+			}
+			break
+		}
+	}
+
+	result := session.Close(context.Background())
+	if last != nil {
+		result.ExitCode = last.ExitCode
+		result.TimedOut = last.TimedOut
+		result.OutputLimitExceeded = last.OutputLimitExceeded
+	}
+	return result, nil
+}
+
+// reportStepFailure 上报设置了 fail_status 的步骤失败，展示该步骤的输出
+func (m *Manager) reportStepFailure(job *Job) {
+	f := job.stepFailure
+	log.Printf("Solution %s: step %s failed with code %d, reporting %s", job.SolutionID, f.step, f.exitCode, f.status)
+	job.aoi.Patch(job.ctx, &aoiclient.SolutionInfo{
+		Score:   0,
+		Status:  f.status,
+		Message: fmt.Sprintf("%s 失败，退出码 %d", f.step, f.exitCode),
+	})
+	job.aoi.SaveDetails(job.ctx, &aoiclient.SolutionDetails{
+		Version: 1,
+		Summary: fmt.Sprintf("%s 失败，退出码 %d\n%s", f.step, f.exitCode, f.output),
+		Jobs:    []*aoiclient.SolutionDetailsJob{},
+	})
+}
@@ -0,0 +1,180 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// 学生 hook 的默认限制，比正式评测严格得多
+const (
+	defaultHookTimeout     = 30
+	defaultHookMemoryLimit = 256
+	defaultHookCPULimit    = 1.0
+	defaultHookPidsLimit   = 64
+	hookResultFile         = "hook.json"
+	maxHookResultSize      = 64 << 10
+)
+
+// StudentHookConfig 学生提供的 hook（如基准测试配置或插件）的受限执行配置。
+// hook 在独立的最小容器中运行：无网络、只读根文件系统、严格资源限制，
+// 提交内容只读挂载在 /solution，结果需写入 /output/hook.json 并通过 schema 校验。
+type StudentHookConfig struct {
+	Image       string            `json:"image"`       // hook 运行镜像（由题目作者指定的最小镜像）
+	Cmd         []string          `json:"cmd"`         // 执行命令
+	Timeout     int64             `json:"timeout"`     // 超时时间（秒），默认 30
+	MemoryLimit int64             `json:"memoryLimit"` // 内存限制（MB），默认 256
+	Schema      map[string]string `json:"schema"`      // 结果字段 -> 类型（string/number/boolean/array/object）
+}
+
+// runStudentHook 在沙箱中运行学生 hook，校验结果后以 JUDGE_HOOK_RESULT 传给正式评测容器
+func (m *Manager) runStudentHook(job *Job, hook *StudentHookConfig) error {
+	if hook.Image == "" || len(hook.Cmd) == 0 {
+		return fmt.Errorf("student hook requires image and cmd")
+	}
+	soln := job.soln
+
+	solutionDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("hook-solution-%s-", soln.SolutionId))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(solutionDir)
+	outputDir, err := os.MkdirTemp(m.scratchDir(), fmt.Sprintf("hook-output-%s-", soln.SolutionId))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	// hook 容器无网络，由 manager 代为下载提交内容
+	if err := m.cache.ExtractTo(job.ctx, soln.SolutionDataUrl, soln.SolutionDataHash, solutionDir); err != nil {
+		return fmt.Errorf("failed to fetch solution for hook: %w", err)
+	}
+
+	config := &executor.ExecuteConfig{
+		Image:           hook.Image,
+		Command:         hook.Cmd,
+		Timeout:         time.Duration(hook.Timeout) * time.Second,
+		MemoryLimit:     hook.MemoryLimit,
+		CPULimit:        defaultHookCPULimit,
+		Env:             map[string]string{"OUTPUT_DIR": "/output"},
+		WorkDir:         "/solution",
+		Labels:          job.execConfig.Labels,
+		User:            job.execConfig.User,
+		NetworkDisabled: true,
+		ReadOnlyRootfs:  true,
+		PidsLimit:       defaultHookPidsLimit,
+		NoNewPrivileges: true,
+		CapDrop:         []string{"ALL"},
+		Mounts: []executor.Mount{
+			{Source: solutionDir, Target: "/solution", ReadOnly: true},
+			{Source: outputDir, Target: "/output"},
+		},
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHookTimeout * time.Second
+	}
+	if config.MemoryLimit <= 0 {
+		config.MemoryLimit = defaultHookMemoryLimit
+	}
+	config.Timeout = m.clampTimeout(job.SolutionID, config.Timeout)
+	config.MemoryLimit = m.clampMemory(job.SolutionID, config.MemoryLimit)
+	if err := m.prepareSharedDir(outputDir, config.User); err != nil {
+		return err
+	}
+	if err := m.ensureImage(job, config.Image); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(job.execCtx, config.Timeout+10*time.Second)
+	defer cancel()
+	result, err := job.exec.ExecuteWithLogs(ctx, config, func(stream executor.LogStream, line string) error {
+		slog.Info(fmt.Sprintf("[%s hook %s] %s", soln.SolutionId, stream, line), "solution", soln.SolutionId, "source", "hook", "stream", stream.String())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("student hook execution failed: %w", err)
+	}
+	if result.TimedOut || result.OOM || result.ExitCode != 0 {
+		return fmt.Errorf("student hook failed (exit code %d, timed out: %v, oom: %v)", result.ExitCode, result.TimedOut, result.OOM)
+	}
+
+	data, err := readLimited(filepath.Join(outputDir, hookResultFile), maxHookResultSize)
+	if err != nil {
+		return fmt.Errorf("failed to read student hook result: %w", err)
+	}
+	if err := validateHookResult(data, hook.Schema); err != nil {
+		return fmt.Errorf("invalid student hook result: %w", err)
+	}
+	job.execConfig.Env["JUDGE_HOOK_RESULT"] = string(data)
+	return nil
+}
+
+// readLimited 读取容器写入的文件，超过上限时报错。不跟随符号链接，拒绝 FIFO 等特殊文件
+func readLimited(path string, limit int64) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", filepath.Base(path))
+	}
+	if info.Size() > limit {
+		return nil, fmt.Errorf("file too large: %d bytes (limit %d)", info.Size(), limit)
+	}
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file too large: more than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// validateHookResult 校验结果为 JSON 对象，且仅包含 schema 中声明的字段与类型
+func validateHookResult(data []byte, schema map[string]string) error {
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("result must be a JSON object: %w", err)
+	}
+	for key, value := range obj {
+		want, ok := schema[key]
+		if !ok {
+			return fmt.Errorf("unexpected field %q", key)
+		}
+		if got := jsonType(value); got != want {
+			return fmt.Errorf("field %q must be %s, got %s", key, want, got)
+		}
+	}
+	return nil
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// templatePattern 匹配 ${name}。未定义的名称保持原样，
+// 以免影响 docker_cmd 中交给容器内 shell 展开的 ${VAR}
+var templatePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// templateVars judge config 模板变量：rc.Variables 与内置变量，内置变量优先
+type templateVars map[string]string
+
+func newTemplateVars(soln *aoiclient.SolutionPoll, rc *RunningConfig) templateVars {
+	vars := make(templateVars, len(rc.Variables)+6)
+	for k, v := range rc.Variables {
+		switch v := v.(type) {
+		case string:
+			vars[k] = v
+		case nil:
+		default:
+			vars[k] = fmt.Sprint(v)
+		}
+	}
+	vars["solution_id"] = soln.SolutionId
+	vars["task_id"] = soln.TaskId
+	vars["user_id"] = soln.UserId
+	vars["contest_id"] = soln.ContestId
+	vars["problem_label"] = soln.ProblemConfig.Label
+	vars["output_dir"] = "/output"
+	return vars
+}
+
+// expand 展开字符串中的 ${name}
+func (v templateVars) expand(s string) string {
+	return templatePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if value, ok := v[match[2:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// expandAll 展开字符串列表，返回新的切片
+func (v templateVars) expandAll(list []string) []string {
+	result := make([]string, len(list))
+	for i, s := range list {
+		result[i] = v.expand(s)
+	}
+	return result
+}
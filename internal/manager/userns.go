@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"bufio"
+	"io/fs"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+// idMapping 容器内 UID/GID 到宿主机的映射
+type idMapping struct {
+	remapped bool // 守护进程启用了 rootless 或 userns-remap
+
+	// rootless 模式下容器内 root 映射为守护进程用户，其余 ID 从 subuid 起点开始；
+	// userns-remap 模式下所有 ID 从 subuid 起点开始
+	rootUID, rootGID int
+	subUID, subGID   int
+	rootless         bool
+	known            bool // 是否成功解析出映射
+}
+
+// toHost 将容器内 ID 转换为宿主机 ID
+func (im *idMapping) toHost(uid, gid int) (int, int, bool) {
+	if !im.remapped {
+		return uid, gid, true
+	}
+	if !im.known {
+		return 0, 0, false
+	}
+	if im.rootless {
+		hostUID, hostGID := im.rootUID, im.rootGID
+		if uid > 0 {
+			hostUID = im.subUID + uid - 1
+		}
+		if gid > 0 {
+			hostGID = im.subGID + gid - 1
+		}
+		return hostUID, hostGID, true
+	}
+	return im.subUID + uid, im.subGID + gid, true
+}
+
+// detectIDMapping 根据守护进程模式解析 /etc/subuid 与 /etc/subgid
+func detectIDMapping(info *executor.DaemonInfo) *idMapping {
+	im := &idMapping{remapped: info.Rootless || info.UsernsRemap, rootless: info.Rootless}
+	if !im.remapped {
+		return im
+	}
+
+	// userns-remap 默认使用 dockremap 用户；rootless 使用运行守护进程的用户，
+	// 通常与 manager 为同一用户
+	name := "dockremap"
+	if info.Rootless {
+		u, err := user.Current()
+		if err != nil {
+			return im
+		}
+		name = u.Username
+		im.rootUID, im.rootGID = os.Getuid(), os.Getgid()
+	}
+
+	subUID, okUID := readSubID("/etc/subuid", name)
+	subGID, okGID := readSubID("/etc/subgid", name)
+	if okUID && okGID {
+		im.subUID, im.subGID = subUID, subGID
+		im.known = true
+	}
+	if info.Rootless {
+		log.Printf("Docker daemon is rootless, container IDs map from %d (known: %v)", im.subUID, im.known)
+	} else {
+		log.Printf("Docker daemon uses userns-remap, container IDs map from %d (known: %v)", im.subUID, im.known)
+	}
+	return im
+}
+
+// readSubID 读取 subuid/subgid 文件中指定用户的起始 ID
+func readSubID(path, name string) (int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || fields[0] != name {
+			continue
+		}
+		start, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, false
+		}
+		return start, true
+	}
+	return 0, false
+}
+
+// parseUser 解析 "uid[:gid]" 形式的容器用户，非数字用户名无法在宿主机侧解析
+func parseUser(u string) (int, int, bool) {
+	if u == "" {
+		return 0, 0, true
+	}
+	uidStr, gidStr, hasGID := strings.Cut(u, ":")
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	gid := uid
+	if hasGID {
+		if gid, err = strconv.Atoi(gidStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return uid, gid, true
+}
+
+// prepareSharedDir 使容器内的用户能够写入宿主机目录。
+// 能确定映射且有权限时 chown，否则退化为放宽目录权限。
+func (m *Manager) prepareSharedDir(dir string, containerUser string) error {
+	uid, gid, ok := parseUser(containerUser)
+	if !ok {
+		return os.Chmod(dir, 0o777)
+	}
+	hostUID, hostGID, ok := m.idMap.toHost(uid, gid)
+	if ok && hostUID == os.Geteuid() {
+		return nil
+	}
+	if ok && os.Geteuid() == 0 {
+		return os.Chown(dir, hostUID, hostGID)
+	}
+	return os.Chmod(dir, 0o777)
+}
+
+// prepareSharedTree 对目录树执行与 prepareSharedDir 相同的处理，使容器用户能够修改其中的文件。
+// 不能 chown 时普通文件只增加读写权限，不改变可执行位
+func (m *Manager) prepareSharedTree(root string, containerUser string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink != 0 {
+			return err
+		}
+		if d.IsDir() {
+			return m.prepareSharedDir(path, containerUser)
+		}
+		uid, gid, ok := parseUser(containerUser)
+		if ok {
+			hostUID, hostGID, mapped := m.idMap.toHost(uid, gid)
+			if mapped && hostUID == os.Geteuid() {
+				return nil
+			}
+			if mapped && os.Geteuid() == 0 {
+				return os.Lchown(path, hostUID, hostGID)
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.Chmod(path, info.Mode().Perm()|0o666)
+	})
+}
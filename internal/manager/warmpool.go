@@ -0,0 +1,329 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+)
+
+const (
+	defaultWarmPoolProblems = 3
+	defaultWarmPoolIdle     = 30 * time.Minute
+	warmDemandWindow        = time.Hour       // 按该时间内的评测次数选出热门题目
+	warmCheckInterval       = time.Minute     // 检查空闲预热容器的间隔
+	warmStartTimeout        = 2 * time.Minute // 启动单个预热容器的超时
+	warmOutputTarget        = "/output"       // 输出目录在容器内的路径，预热容器使用自己的输出目录
+	warmOutputPrefix        = "judge-warm-"   // 预热容器输出目录的前缀，属于 tempDirPrefixes 中的 judge-
+)
+
+// warmContainer 预先启动、以 sleep infinity 空闲等待的评测容器
+type warmContainer struct {
+	session   executor.Session
+	outputDir string
+	startedAt time.Time
+}
+
+// warmShape 执行配置中与具体提交无关的部分相同的一组评测，可以复用同一批预热容器
+type warmShape struct {
+	exec     executor.Executor
+	config   *executor.ExecuteConfig // 启动预热容器使用的配置
+	idle     []*warmContainer
+	starting int
+	demand   []time.Time // 最近的评测时间
+}
+
+type warmKey struct {
+	exec  executor.Executor
+	shape string
+}
+
+// warmPool 为热门题目预先启动的评测容器，评测时在其中 exec docker_cmd，
+// 省去提交高峰时创建容器与启动运行时的延迟。每个容器只使用一次
+type warmPool struct {
+	mu     sync.Mutex
+	shapes map[warmKey]*warmShape
+}
+
+func newWarmPool() *warmPool {
+	return &warmPool{shapes: make(map[warmKey]*warmShape)}
+}
+
+func (m *Manager) warmPoolSize() int {
+	if m.conf.WarmPoolSize == nil {
+		return 0
+	}
+	return *m.conf.WarmPoolSize
+}
+
+func (m *Manager) warmPoolProblems() int {
+	if m.conf.WarmPoolProblems != nil && *m.conf.WarmPoolProblems > 0 {
+		return *m.conf.WarmPoolProblems
+	}
+	return defaultWarmPoolProblems
+}
+
+func (m *Manager) warmPoolIdle() time.Duration {
+	if m.conf.WarmPoolIdle != nil && *m.conf.WarmPoolIdle > 0 {
+		return *m.conf.WarmPoolIdle
+	}
+	return defaultWarmPoolIdle
+}
+
+// warmEligible 评测能否使用预热容器。绑定核心、GPU、独立网络、检查点恢复与挂载评测专属目录的配置
+// 在准备阶段才确定，无法预先创建容器；CPU 时间按整个容器统计，预热期间的用量无法扣除
+func (m *Manager) warmEligible(job *Job) bool {
+	rc, config := job.rc, job.execConfig
+	return m.warm != nil && rc.WarmPool && len(rc.Steps) == 0 &&
+		rc.ProblemData == nil && rc.CoreDump == nil && rc.MPI == nil && rc.Compile == nil &&
+		config.RestoreFrom == "" && config.CpusetCpus == "" && len(config.GPUDevices) == 0 && config.Network == "" &&
+		config.CPUTimeLimit == 0 && len(config.Devices) == 0
+}
+
+// warmShapeOf 去除执行配置中与具体提交相关的字段（命令、环境变量、标签、时限与输出目录），
+// 返回其摘要与启动预热容器使用的配置
+func (m *Manager) warmShapeOf(config *executor.ExecuteConfig) (string, *executor.ExecuteConfig) {
+	shape := *config
+	shape.Command = nil
+	shape.Env = nil
+	shape.Labels = nil
+	shape.Timeout = 0
+	shape.OutputLimit = 0
+	shape.OutputDrain = false
+	shape.OnStart = nil
+	shape.Mounts = nil
+	for _, mount := range config.Mounts {
+		if mount.Target != warmOutputTarget {
+			shape.Mounts = append(shape.Mounts, mount)
+		}
+	}
+	data, _ := json.Marshal(&shape)
+	sum := sha256.Sum256(data)
+
+	shape.Command = []string{"sleep", "infinity"}
+	shape.Labels = map[string]string{
+		executor.LabelRunnerID: *m.conf.RunnerID,
+		executor.LabelWarm:     "true",
+	}
+	return hex.EncodeToString(sum[:8]), &shape
+}
+
+// acquireWarm 记录评测需求并取出一个空闲的预热容器，没有时返回 nil。
+// 取出后评测改用该容器的输出目录，原输出目录中已准备的文件一并移入
+func (m *Manager) acquireWarm(job *Job) *warmContainer {
+	if !m.warmEligible(job) {
+		return nil
+	}
+	shape, config := m.warmShapeOf(job.execConfig)
+	key := warmKey{exec: job.exec, shape: shape}
+
+	m.warm.mu.Lock()
+	s := m.warm.shapes[key]
+	if s == nil {
+		s = &warmShape{exec: job.exec, config: config}
+		m.warm.shapes[key] = s
+	}
+	s.demand = append(s.demand, time.Now())
+	var wc *warmContainer
+	if n := len(s.idle); n > 0 {
+		wc = s.idle[n-1]
+		s.idle = s.idle[:n-1]
+	}
+	m.warm.mu.Unlock()
+	m.goBackground(func() { m.refillWarm(key) })
+
+	if wc == nil {
+		return nil
+	}
+	if err := adoptOutputDir(job.outputDir, wc.outputDir); err != nil {
+		log.Printf("Solution %s: failed to move output dir into warm container, starting a new one: %v", job.SolutionID, err)
+		closeWarm(wc)
+		return nil
+	}
+	log.Printf("Solution %s: using warm container %s, idle for %s", job.SolutionID, wc.session.ID(), time.Since(wc.startedAt).Round(time.Second))
+	job.addCleanup(func() { os.RemoveAll(wc.outputDir) })
+	m.runningMu.Lock()
+	job.outputDir = wc.outputDir
+	for i := range job.execConfig.Mounts {
+		if job.execConfig.Mounts[i].Target == warmOutputTarget {
+			job.execConfig.Mounts[i].Source = wc.outputDir
+		}
+	}
+	m.runningMu.Unlock()
+	return wc
+}
+
+// adoptOutputDir 将 from 中的文件移入预热容器的输出目录
+func adoptOutputDir(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWarm 在预热容器中执行 docker_cmd，返回与单次运行相同形式的结果
+func (m *Manager) runWarm(ctx context.Context, job *Job, wc *warmContainer, onLog executor.LogCallback) (*executor.ExecuteResult, error) {
+	m.trackContainer(job, wc.session.ID())
+	defer m.setContainer(job, "")
+	last, err := wc.session.Exec(ctx, &executor.ExecConfig{
+		Command:     job.execConfig.Command,
+		Env:         job.execConfig.Env,
+		Timeout:     job.execConfig.Timeout,
+		OutputLimit: job.execConfig.OutputLimit,
+		OutputDrain: job.execConfig.OutputDrain,
+	}, onLog)
+	result := wc.session.Close(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	result.ExitCode = last.ExitCode
+	result.TimedOut = last.TimedOut
+	result.OutputLimitExceeded = last.OutputLimitExceeded
+	return result, nil
+}
+
+// hotShapes 返回最近评测次数最多的 warm-pool-problems 组配置，调用方须持有 m.warm.mu
+func (m *Manager) hotShapes() map[warmKey]bool {
+	cutoff := time.Now().Add(-warmDemandWindow)
+	keys := make([]warmKey, 0, len(m.warm.shapes))
+	for key, s := range m.warm.shapes {
+		i := sort.Search(len(s.demand), func(i int) bool { return s.demand[i].After(cutoff) })
+		s.demand = s.demand[i:]
+		if len(s.demand) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(m.warm.shapes[keys[i]].demand) > len(m.warm.shapes[keys[j]].demand)
+	})
+	hot := make(map[warmKey]bool)
+	for _, key := range keys[:min(len(keys), m.warmPoolProblems())] {
+		hot[key] = true
+	}
+	return hot
+}
+
+// refillWarm 热门配置的空闲容器不足 warm-pool-size 时启动新的预热容器
+func (m *Manager) refillWarm(key warmKey) {
+	m.warm.mu.Lock()
+	s := m.warm.shapes[key]
+	want := 0
+	if s != nil && m.hotShapes()[key] {
+		want = m.warmPoolSize() - len(s.idle) - s.starting
+	}
+	if want <= 0 {
+		m.warm.mu.Unlock()
+		return
+	}
+	s.starting += want
+	m.warm.mu.Unlock()
+
+	for i := range want {
+		wc, err := m.startWarm(s)
+		m.warm.mu.Lock()
+		s.starting--
+		if err != nil {
+			// 放弃本轮剩余的容器，下次评测时再补充
+			s.starting -= want - i - 1
+		} else if m.ctx.Err() == nil {
+			s.idle = append(s.idle, wc)
+			wc = nil
+		}
+		m.warm.mu.Unlock()
+		if err != nil {
+			log.Printf("Failed to start warm container for %s: %v", s.config.Image, err)
+			return
+		}
+		if wc != nil {
+			closeWarm(wc)
+		}
+	}
+}
+
+// startWarm 创建输出目录并启动一个预热容器
+func (m *Manager) startWarm(s *warmShape) (*warmContainer, error) {
+	outputDir, err := os.MkdirTemp(m.scratchDir(), warmOutputPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	if err := m.prepareSharedDir(outputDir, s.config.User); err != nil {
+		os.RemoveAll(outputDir)
+		return nil, fmt.Errorf("failed to prepare output dir: %w", err)
+	}
+	config := *s.config
+	config.Mounts = append([]executor.Mount{{Source: outputDir, Target: warmOutputTarget}}, s.config.Mounts...)
+	config.Env = map[string]string{"OUTPUT_DIR": warmOutputTarget}
+
+	ctx, cancel := context.WithTimeout(m.ctx, warmStartTimeout)
+	defer cancel()
+	session, err := s.exec.StartSession(ctx, &config)
+	if err != nil {
+		os.RemoveAll(outputDir)
+		return nil, err
+	}
+	return &warmContainer{session: session, outputDir: outputDir, startedAt: time.Now()}, nil
+}
+
+// closeWarm 删除预热容器及其输出目录
+func closeWarm(wc *warmContainer) {
+	wc.session.Close(context.Background())
+	os.RemoveAll(wc.outputDir)
+}
+
+// warmLoop 定期删除空闲过久或不再热门的预热容器，ctx 结束时删除全部预热容器
+func (m *Manager) warmLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			m.drainWarm(func(*warmContainer, bool) bool { return true })
+			return
+		case <-time.After(warmCheckInterval):
+		}
+		idle := m.warmPoolIdle()
+		m.drainWarm(func(wc *warmContainer, hot bool) bool {
+			return !hot || time.Since(wc.startedAt) > idle
+		})
+	}
+}
+
+// drainWarm 删除满足 remove 的空闲预热容器，并丢弃最近没有评测的配置
+func (m *Manager) drainWarm(remove func(wc *warmContainer, hot bool) bool) {
+	var removed []*warmContainer
+	m.warm.mu.Lock()
+	hot := m.hotShapes()
+	for key, s := range m.warm.shapes {
+		kept := s.idle[:0]
+		for _, wc := range s.idle {
+			if remove(wc, hot[key]) {
+				removed = append(removed, wc)
+			} else {
+				kept = append(kept, wc)
+			}
+		}
+		s.idle = kept
+		if len(s.demand) == 0 && len(s.idle) == 0 && s.starting == 0 {
+			delete(m.warm.shapes, key)
+		}
+	}
+	m.warm.mu.Unlock()
+
+	for _, wc := range removed {
+		log.Printf("Removing warm container %s", wc.session.ID())
+		closeWarm(wc)
+	}
+}
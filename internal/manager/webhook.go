@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const resultWebhookTimeout = 10 * time.Second
+
+// verdictPayload 推送给结果 webhook 的最终评测结果
+type verdictPayload struct {
+	RunnerID     string    `json:"runnerId"`
+	SolutionID   string    `json:"solutionId"`
+	TaskID       string    `json:"taskId"`
+	UserID       string    `json:"userId"`
+	ContestID    string    `json:"contestId"`
+	ProblemLabel string    `json:"problemLabel"`
+	Score        float64   `json:"score"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message"`
+	DetailsHash  string    `json:"detailsHash,omitempty"` // 详情 JSON 的 sha256
+	ReceivedAt   time.Time `json:"receivedAt"`
+	CompletedAt  time.Time `json:"completedAt"`
+	DurationMs   int64     `json:"durationMs"`
+}
+
+// mirrorVerdict 将最终结果异步推送到配置的 webhook，失败仅记录日志
+func (m *Manager) mirrorVerdict(r *reporter) {
+	if m.conf.ResultWebhook == nil || *m.conf.ResultWebhook == "" {
+		return
+	}
+
+	info, details := r.verdict()
+	now := time.Now()
+	payload := &verdictPayload{
+		RunnerID:     *m.conf.RunnerID,
+		SolutionID:   r.soln.SolutionId,
+		TaskID:       r.soln.TaskId,
+		UserID:       r.soln.UserId,
+		ContestID:    r.soln.ContestId,
+		ProblemLabel: r.soln.ProblemConfig.Label,
+		ReceivedAt:   r.receivedAt,
+		CompletedAt:  now,
+		DurationMs:   now.Sub(r.receivedAt).Milliseconds(),
+	}
+	if info != nil {
+		payload.Score = info.Score
+		payload.Status = info.Status
+		payload.Message = info.Message
+	}
+	if details != nil {
+		if data, err := json.Marshal(details); err == nil {
+			sum := sha256.Sum256(data)
+			payload.DetailsHash = hex.EncodeToString(sum[:])
+		}
+	}
+
+	m.goBackground(func() {
+		if err := m.postWebhook(*m.conf.ResultWebhook, payload); err != nil {
+			log.Printf("Failed to mirror verdict of solution %s: %v", payload.SolutionID, err)
+		}
+	})
+}
+
+// postWebhook 以 JSON 发送 webhook，配置了密钥时附带 HMAC-SHA256 签名
+func (m *Manager) postWebhook(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resultWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.conf.WebhookSecret != nil && *m.conf.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(*m.conf.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 密钥不存在
+var ErrNotFound = errors.New("secret not found")
+
+// Store 密钥存储
+type Store interface {
+	// Get 按名称获取密钥
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// FileStore 从 KEY=VALUE 格式的文件读取密钥
+type FileStore struct {
+	values map[string]string
+}
+
+// NewFileStore 加载密钥文件，忽略空行与 # 开头的注释
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	return &FileStore{values: values}, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, name string) (string, error) {
+	v, ok := s.values[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+const vaultCacheTTL = time.Minute
+
+// VaultStore 从 Vault KV v2 引擎的单个路径读取密钥，结果短暂缓存
+type VaultStore struct {
+	addr  string
+	token string
+	path  string
+
+	mu       sync.Mutex
+	values   map[string]string
+	loadedAt time.Time
+}
+
+// NewVaultStore 创建 Vault 密钥存储，path 形如 "secret/data/judge"
+func NewVaultStore(addr, token, path string) *VaultStore {
+	return &VaultStore{
+		addr:  strings.TrimSuffix(addr, "/"),
+		token: token,
+		path:  strings.Trim(path, "/"),
+	}
+}
+
+func (s *VaultStore) Get(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil || time.Since(s.loadedAt) > vaultCacheTTL {
+		values, err := s.load(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.values, s.loadedAt = values, time.Now()
+	}
+	v, ok := s.values[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *VaultStore) load(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.addr+"/v1/"+s.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read vault: %s", res.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return body.Data.Data, nil
+}
+
+// Chain 依次查询多个存储，返回第一个找到的值
+type Chain []Store
+
+func (c Chain) Get(ctx context.Context, name string) (string, error) {
+	for _, s := range c {
+		v, err := s.Get(ctx, name)
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", ErrNotFound
+}
@@ -0,0 +1,42 @@
+"""pytest 插件：每个测试用例结束时输出一行 judgerproto 消息，供 manager 实时上报进度。
+
+用法：PYTHONPATH=/opt/judge pytest -p judgerproto_plugin
+"""
+
+import json
+import sys
+from datetime import datetime, timezone
+
+
+def _emit(body):
+    message = {
+        "t": datetime.now(timezone.utc).isoformat(),
+        "a": "t",
+        "b": body,
+    }
+    sys.stdout.write("\n" + json.dumps(message, ensure_ascii=False) + "\n")
+    sys.stdout.flush()
+
+
+def pytest_runtest_logreport(report):
+    # 仅在 call 阶段或 setup 失败/跳过时上报一次
+    if report.when != "call" and not (report.when == "setup" and not report.passed):
+        return
+
+    outcome = report.outcome
+    if hasattr(report, "wasxfail"):
+        outcome = "xfailed" if report.skipped else "xpassed"
+    elif report.when == "setup" and report.failed:
+        outcome = "error"
+
+    message = ""
+    if report.failed and report.longrepr is not None:
+        crash = getattr(report.longrepr, "reprcrash", None)
+        message = crash.message if crash is not None else str(report.longrepr)
+
+    _emit({
+        "name": report.nodeid,
+        "outcome": outcome,
+        "duration": report.duration,
+        "message": message,
+    })
@@ -52,7 +52,8 @@ cp /tmp/adapters.py ./tests/adapters.py
 echo "3.13" >> .python-version
 uv sync
 uv add pytest-json-report
-uv run pytest --json-report --json-report-file=report.json || true
+# JUDGE_RETRY_TESTS 非空时只运行指定的测试（不稳定测试重试、只重跑失败测试）
+uv run pytest -p judgerproto_plugin --json-report --json-report-file=report.json $JUDGE_RETRY_TESTS || true
 
 # 输出报告内容 (用于调试)
 echo "=== Test Report ==="
@@ -0,0 +1,397 @@
+// Package aoitest 提供进程内的模拟 AOI 服务，实现 runner 使用的领取、状态上报、详情上传、
+// 日志与完成接口，并可按脚本注入错误、延迟与格式错误的响应，用于自动化测试 manager 的行为。
+// 未实现的接口（注册、分类令牌、推送、能力上报、产物上传）返回 404，客户端会按平台不支持处理。
+package aoitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+)
+
+// Endpoint 模拟服务的接口类别，用于注入故障与统计请求数
+type Endpoint string
+
+const (
+	EndpointPoll      Endpoint = "poll"       // 领取单个任务
+	EndpointPollBatch Endpoint = "poll/batch" // 批量领取任务
+	EndpointPatch     Endpoint = "patch"      // 上报状态
+	EndpointDetails   Endpoint = "details"    // 获取详情上传地址
+	EndpointUpload    Endpoint = "upload"     // 向对象存储上传详情
+	EndpointComplete  Endpoint = "complete"   // 完成评测
+	EndpointLog       Endpoint = "log"        // 追加评测日志
+	EndpointCancel    Endpoint = "cancel"     // 查询取消状态
+)
+
+// Fault 注入的故障，匹配 Endpoint 的请求先等待 Delay，
+// 然后在 Status 非 0 时返回该状态码，Body 非空时原样返回 Body 作为 JSON 响应
+type Fault struct {
+	Endpoint Endpoint
+	Times    int // 生效次数，0 表示一直生效
+	Delay    time.Duration
+	Status   int
+	Body     string
+}
+
+// Task 一次评测在模拟服务中的上报记录
+type Task struct {
+	SolutionID string
+	TaskID     string
+	Patches    []aoiclient.SolutionInfo   // 按顺序收到的状态上报
+	Details    *aoiclient.SolutionDetails // 最后一次上传的详情，无法解析时为 nil
+	RawDetails []byte                     // 最后一次上传的原始详情
+	Log        []byte                     // 按 offset 拼接的评测日志
+	Completed  bool
+	Cancelled  bool
+}
+
+// Last 返回最后一次状态上报，没有时返回 nil
+func (t *Task) Last() *aoiclient.SolutionInfo {
+	if len(t.Patches) == 0 {
+		return nil
+	}
+	return &t.Patches[len(t.Patches)-1]
+}
+
+// Server 模拟 AOI 服务
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	queue          []*aoiclient.SolutionPoll
+	tasks          map[string]*Task
+	faults         []*Fault
+	requests       map[Endpoint]int
+	batch          bool
+	cancelDisabled bool
+	changed        chan struct{} // 每次任务记录变化时关闭并替换
+}
+
+// NewServer 启动模拟服务，调用方结束时应调用 Close
+func NewServer() *Server {
+	s := &Server{
+		tasks:    make(map[string]*Task),
+		requests: make(map[Endpoint]int),
+		batch:    true,
+		changed:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/runner/solution/poll", s.handle(EndpointPoll, s.poll))
+	mux.HandleFunc("POST /api/runner/solution/poll/batch", s.handle(EndpointPollBatch, s.pollBatch))
+	mux.HandleFunc("PATCH /api/runner/solution/task/{solution}/{task}", s.handle(EndpointPatch, s.patch))
+	mux.HandleFunc("GET /api/runner/solution/task/{solution}/{task}/details/upload", s.handle(EndpointDetails, s.detailsURL))
+	mux.HandleFunc("PUT /storage/{solution}/{task}/details", s.handle(EndpointUpload, s.upload))
+	mux.HandleFunc("POST /api/runner/solution/task/{solution}/{task}/complete", s.handle(EndpointComplete, s.complete))
+	mux.HandleFunc("POST /api/runner/solution/task/{solution}/{task}/log", s.handle(EndpointLog, s.appendLog))
+	mux.HandleFunc("GET /api/runner/solution/task/{solution}/{task}/cancel", s.handle(EndpointCancel, s.cancelled))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client 返回连接到模拟服务的客户端
+func (s *Server) Client(opts ...aoiclient.Option) *aoiclient.Client {
+	return aoiclient.New(s.URL, opts...).Authenticate("aoitest", "aoitest")
+}
+
+// NewSolution 构造一个任务，adapter 为题目的评测适配器（如 lfs1），judgeConfig 序列化为 judge config
+func NewSolution(solutionID, taskID, label, adapter string, judgeConfig any) *aoiclient.SolutionPoll {
+	config, err := json.Marshal(judgeConfig)
+	if err != nil {
+		panic(fmt.Sprintf("aoitest: invalid judge config: %v", err))
+	}
+	return &aoiclient.SolutionPoll{
+		TaskId:     taskID,
+		SolutionId: solutionID,
+		UserId:     "aoitest-user",
+		ProblemConfig: aoiclient.ProblemConfig{
+			Label: label,
+			Judge: aoiclient.ProblemConfigJudge{Adapter: adapter, Config: config},
+		},
+	}
+}
+
+// Enqueue 将任务加入待领取队列
+func (s *Server) Enqueue(solns ...*aoiclient.SolutionPoll) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, solns...)
+}
+
+// Inject 注入故障，多个故障匹配同一请求时按注入顺序取第一个
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// SetBatchPoll 设置是否支持批量领取，不支持时批量接口返回 404
+func (s *Server) SetBatchPoll(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batch = enabled
+}
+
+// SetCancelSupported 设置是否支持查询取消状态，不支持时取消接口返回 404
+func (s *Server) SetCancelSupported(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelDisabled = !enabled
+}
+
+// Cancel 将评测标记为已取消，之后的取消状态查询返回 true
+func (s *Server) Cancel(solutionID, taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.task(solutionID, taskID).Cancelled = true
+	s.notify()
+}
+
+// Requests 返回某类接口收到的请求数，包括被注入故障的请求
+func (s *Server) Requests(ep Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[ep]
+}
+
+// Task 返回评测记录的副本，没有任何上报时返回 nil
+func (s *Server) Task(solutionID, taskID string) *Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[taskKey(solutionID, taskID)]
+	if !ok {
+		return nil
+	}
+	return t.clone()
+}
+
+// WaitComplete 等待评测完成并返回其记录
+func (s *Server) WaitComplete(ctx context.Context, solutionID, taskID string) (*Task, error) {
+	for {
+		s.mu.Lock()
+		t, ok := s.tasks[taskKey(solutionID, taskID)]
+		if ok && t.Completed {
+			t = t.clone()
+			s.mu.Unlock()
+			return t, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("solution %s task %s did not complete: %w", solutionID, taskID, ctx.Err())
+		}
+	}
+}
+
+func taskKey(solutionID, taskID string) string {
+	return solutionID + "/" + taskID
+}
+
+func (t *Task) clone() *Task {
+	c := *t
+	c.Patches = append([]aoiclient.SolutionInfo(nil), t.Patches...)
+	c.RawDetails = append([]byte(nil), t.RawDetails...)
+	c.Log = append([]byte(nil), t.Log...)
+	return &c
+}
+
+// task 返回评测记录，不存在时创建，调用方需持有锁
+func (s *Server) task(solutionID, taskID string) *Task {
+	key := taskKey(solutionID, taskID)
+	t, ok := s.tasks[key]
+	if !ok {
+		t = &Task{SolutionID: solutionID, TaskID: taskID}
+		s.tasks[key] = t
+	}
+	return t
+}
+
+// notify 唤醒等待中的 WaitComplete，调用方需持有锁
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// handle 统计请求并按注入的故障处理，未命中故障时交给 fn
+func (s *Server) handle(ep Endpoint, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[ep]++
+		var fault *Fault
+		for i, f := range s.faults {
+			if f.Endpoint != ep {
+				continue
+			}
+			fault = f
+			if f.Times > 0 {
+				if f.Times--; f.Times == 0 {
+					s.faults = append(s.faults[:i], s.faults[i+1:]...)
+				}
+			}
+			break
+		}
+		s.mu.Unlock()
+
+		if fault == nil {
+			fn(w, r)
+			return
+		}
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case fault.Body != "":
+			// 以 JSON 类型返回，客户端会尝试解析，用于模拟格式错误的响应
+			w.Header().Set("Content-Type", "application/json")
+			if fault.Status != 0 {
+				w.WriteHeader(fault.Status)
+			}
+			io.WriteString(w, fault.Body)
+		case fault.Status != 0:
+			writeError(w, fault.Status, "injected fault")
+		default:
+			fn(w, r)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&aoiclient.APIError{
+		Message:    message,
+		ErrorName:  http.StatusText(status),
+		StatusCode: status,
+	})
+}
+
+func (s *Server) poll(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	soln := &aoiclient.SolutionPoll{}
+	if len(s.queue) > 0 {
+		soln = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+	s.mu.Unlock()
+	writeJSON(w, soln)
+}
+
+func (s *Server) pollBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	if !s.batch {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	n := min(max(req.Count, 0), len(s.queue))
+	solns := append([]*aoiclient.SolutionPoll{}, s.queue[:n]...)
+	s.queue = s.queue[n:]
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{"solutions": solns})
+}
+
+func (s *Server) patch(w http.ResponseWriter, r *http.Request) {
+	info := aoiclient.SolutionInfo{}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	t := s.task(r.PathValue("solution"), r.PathValue("task"))
+	t.Patches = append(t.Patches, info)
+	s.notify()
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{})
+}
+
+func (s *Server) detailsURL(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"url": s.URL + "/storage/" + r.PathValue("solution") + "/" + r.PathValue("task") + "/details",
+	})
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var details *aoiclient.SolutionDetails
+	if json.Unmarshal(data, &details) != nil {
+		details = nil
+	}
+	s.mu.Lock()
+	t := s.task(r.PathValue("solution"), r.PathValue("task"))
+	t.RawDetails = data
+	t.Details = details
+	s.notify()
+	s.mu.Unlock()
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.task(r.PathValue("solution"), r.PathValue("task")).Completed = true
+	s.notify()
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{})
+}
+
+func (s *Server) appendLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Offset  int64  `json:"offset"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.task(r.PathValue("solution"), r.PathValue("task"))
+	// 按 offset 去重：重复的分段忽略，出现缺口时拒绝
+	switch {
+	case req.Offset > int64(len(t.Log)):
+		writeError(w, http.StatusConflict, "log offset is ahead of received content")
+		return
+	case req.Offset+int64(len(req.Content)) > int64(len(t.Log)):
+		t.Log = append(t.Log[:req.Offset], req.Content...)
+		s.notify()
+	}
+	writeJSON(w, map[string]any{})
+}
+
+func (s *Server) cancelled(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelDisabled {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	t, ok := s.tasks[taskKey(r.PathValue("solution"), r.PathValue("task"))]
+	writeJSON(w, map[string]bool{"cancelled": ok && t.Cancelled})
+}
@@ -0,0 +1,40 @@
+package aoiclient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fedstackjs/azukiiro/storage"
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrArtifactsUnsupported 平台不支持上传评测产物
+var ErrArtifactsUnsupported = errors.New("artifact upload is not supported by the platform")
+
+func getSolutionTaskArtifactUrl(ctx context.Context, http *resty.Client, solutionId, taskId, name string) (string, error) {
+	res := &urlResponse{}
+	raw, err := http.R().
+		SetContext(ctx).
+		SetQueryParam("name", name).
+		SetResult(res).
+		Get("/api/runner/solution/task/" + solutionId + "/" + taskId + "/artifact/upload")
+	if err == nil && raw.StatusCode() == 404 {
+		return "", ErrArtifactsUnsupported
+	}
+	if err := loadError(raw, err); err != nil {
+		return "", err
+	}
+	return res.URL, nil
+}
+
+// UploadArtifact 上传评测产物（如图表、diff 文件），同名产物覆盖之前的内容。
+// 平台不支持时返回 ErrArtifactsUnsupported
+func (sc *SolutionClient) UploadArtifact(ctx context.Context, name string, data []byte) error {
+	ctx, cancel := withTimeout(ctx, sc.c.uploadTimeout)
+	defer cancel()
+	url, err := getSolutionTaskArtifactUrl(ctx, sc.c.r, sc.solutionID, sc.taskID, name)
+	if err != nil {
+		return err
+	}
+	return storage.Upload(ctx, url, data)
+}
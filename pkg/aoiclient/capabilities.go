@@ -0,0 +1,41 @@
+package aoiclient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrCapabilitiesUnsupported 平台不支持上报 runner 能力
+var ErrCapabilitiesUnsupported = errors.New("capability reporting is not supported by the platform")
+
+// RunnerCapabilities runner 的版本、执行后端与可用资源
+type RunnerCapabilities struct {
+	Version  string   `json:"version"`
+	Executor string   `json:"executor"`       // 容器运行时后端：docker 或 nerdctl
+	Hosts    int      `json:"hosts"`          // 容器运行时主机数
+	CPUs     int      `json:"cpus"`           // 各主机的 CPU 核心数之和
+	Memory   int64    `json:"memory"`         // 各主机的内存总量之和（字节）
+	GPUs     []string `json:"gpus,omitempty"` // 可分配的 GPU 设备 ID
+	Adapters []string `json:"adapters"`       // 可用的评测 adapter
+}
+
+func reportCapabilities(ctx context.Context, http *resty.Client, req *RunnerCapabilities) error {
+	raw, err := http.R().
+		SetContext(ctx).
+		SetBody(req).
+		Post("/api/runner/capabilities")
+	if err == nil && raw.StatusCode() == 404 {
+		return ErrCapabilitiesUnsupported
+	}
+	return loadError(raw, err)
+}
+
+// ReportCapabilities 上报 runner 的版本与可用资源，供平台统计集群组成、发现过期的 runner。
+// 平台不支持时返回 ErrCapabilitiesUnsupported
+func (c *Client) ReportCapabilities(ctx context.Context, caps *RunnerCapabilities) error {
+	ctx, cancel := withTimeout(ctx, c.callTimeout)
+	defer cancel()
+	return reportCapabilities(ctx, c.r, caps)
+}
@@ -2,20 +2,126 @@ package aoiclient
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
-const DefaultUA = "lfs-auto-grader/v0.1.0-alpha"
+// Version runner 的版本
+const Version = "v0.1.0-alpha"
+
+const DefaultUA = "lfs-auto-grader/" + Version
+
+const (
+	defaultCallTimeout   = 30 * time.Second // 单次 API 调用的默认超时
+	defaultUploadTimeout = 5 * time.Minute  // 上传详情与日志的默认超时
+)
 
 type Client struct {
 	r *resty.Client
+
+	longPoll      time.Duration
+	callTimeout   time.Duration
+	uploadTimeout time.Duration
+	keepAlive     time.Duration
+	tokens        *tokenSet
+	onCancel      func(solutionID, taskID string)
+}
+
+// happyEyeballsDelay 双栈环境下 IPv6 连接未建立时回退 IPv4 的等待时间
+const happyEyeballsDelay = 300 * time.Millisecond
+
+// clientOptions 客户端的超时与连接池配置
+type clientOptions struct {
+	callTimeout         time.Duration
+	uploadTimeout       time.Duration
+	keepAlive           time.Duration
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+}
+
+// Option 客户端配置项
+type Option func(o *clientOptions)
+
+// WithTimeout 设置单次 API 调用的超时，调用方传入的 ctx 没有截止时间时生效；
+// 长轮询在此基础上再加上等待时间。0 表示不限制
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) { o.callTimeout = d }
+}
+
+// WithUploadTimeout 设置上传评测详情与日志的超时，0 表示不限制
+func WithUploadTimeout(d time.Duration) Option {
+	return func(o *clientOptions) { o.uploadTimeout = d }
+}
+
+// WithKeepAlive 设置 TCP keep-alive 探测间隔
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *clientOptions) { o.keepAlive = d }
+}
+
+// WithIdleConns 设置空闲连接池大小与空闲连接的保留时间，0 表示使用默认值
+func WithIdleConns(max, maxPerHost int, timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.maxIdleConns = max
+		o.maxIdleConnsPerHost = maxPerHost
+		o.idleConnTimeout = timeout
+	}
 }
 
-func New(addr string) *Client {
+// newDialer 创建支持双栈（happy eyeballs）的拨号器，IPv6-only 网络下同样可用
+func newDialer(keepAlive time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     keepAlive,
+		FallbackDelay: happyEyeballsDelay,
+	}
+}
+
+func New(addr string, opts ...Option) *Client {
+	o := &clientOptions{
+		callTimeout:   defaultCallTimeout,
+		uploadTimeout: defaultUploadTimeout,
+		keepAlive:     30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = newDialer(o.keepAlive).DialContext
+	if o.maxIdleConns > 0 {
+		transport.MaxIdleConns = o.maxIdleConns
+	}
+	if o.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+	}
+	if o.idleConnTimeout > 0 {
+		transport.IdleConnTimeout = o.idleConnTimeout
+	}
 	return &Client{
-		r: resty.New().SetBaseURL(addr).SetHeader("User-Agent", DefaultUA),
+		r:             resty.New().SetBaseURL(addr).SetHeader("User-Agent", DefaultUA).SetTransport(transport),
+		callTimeout:   o.callTimeout,
+		uploadTimeout: o.uploadTimeout,
+		keepAlive:     o.keepAlive,
+	}
+}
+
+// withTimeout 在 ctx 没有截止时间时加上超时，避免无响应的 API 调用永久阻塞
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// pollTimeout 轮询的超时，需要覆盖服务端长轮询的等待时间
+func (c *Client) pollTimeout() time.Duration {
+	if c.callTimeout <= 0 {
+		return 0
 	}
+	return c.callTimeout + c.longPoll
 }
 
 func (c *Client) SetUA(ua string) *Client {
@@ -23,6 +129,12 @@ func (c *Client) SetUA(ua string) *Client {
 	return c
 }
 
+// SetLongPoll 设置服务端长轮询等待时间，0 表示立即返回
+func (c *Client) SetLongPoll(wait time.Duration) *Client {
+	c.longPoll = wait
+	return c
+}
+
 func (c *Client) Authenticate(id string, key string) *Client {
 	c.r.SetHeader("X-AOI-Runner-Id", id).SetHeader("X-AOI-Runner-Key", key)
 	return c
@@ -41,6 +153,8 @@ func (c *Client) Register(
 		Version:           version,
 		RegistrationToken: token,
 	}
+	ctx, cancel := withTimeout(ctx, c.callTimeout)
+	defer cancel()
 	res, err := register(ctx, c.r, req)
 	if err != nil {
 		return "", "", err
@@ -49,13 +163,23 @@ func (c *Client) Register(
 }
 
 func (c *Client) Poll(ctx context.Context) (*SolutionPoll, error) {
-	res, err := pollSolution(ctx, c.r)
+	ctx, cancel := withTimeout(ctx, c.pollTimeout())
+	defer cancel()
+	res, err := pollSolution(ctx, c.r, &pollRequest{Wait: c.longPoll.Milliseconds()})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// PollN 一次领取至多 n 个任务，没有任务时返回空列表。
+// 平台不支持时返回 ErrBatchPollUnsupported，调用方应改用 Poll
+func (c *Client) PollN(ctx context.Context, n int) ([]*SolutionPoll, error) {
+	ctx, cancel := withTimeout(ctx, c.pollTimeout())
+	defer cancel()
+	return pollSolutions(ctx, c.r, &pollBatchRequest{Count: n, Wait: c.longPoll.Milliseconds()})
+}
+
 type SolutionClient struct {
 	taskID     string
 	solutionID string
@@ -80,13 +204,36 @@ func (sc *SolutionClient) SolutionID() string {
 }
 
 func (sc *SolutionClient) Patch(ctx context.Context, info *SolutionInfo) error {
+	ctx, cancel := withTimeout(ctx, sc.c.callTimeout)
+	defer cancel()
 	return patchSolutionTask(ctx, sc.c.r, sc.solutionID, sc.taskID, info)
 }
 
 func (sc *SolutionClient) Complete(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, sc.c.callTimeout)
+	defer cancel()
 	return completeSolutionTask(ctx, sc.c.r, sc.solutionID, sc.taskID)
 }
 
 func (sc *SolutionClient) SaveDetails(ctx context.Context, details *SolutionDetails) error {
+	ctx, cancel := withTimeout(ctx, sc.c.uploadTimeout)
+	defer cancel()
 	return saveSolutionDetails(ctx, sc.c.r, sc.solutionID, sc.taskID, details)
 }
+
+// Cancelled 查询平台是否要求中止该评测，平台不支持时返回 ErrCancelUnsupported
+func (sc *SolutionClient) Cancelled(ctx context.Context) (bool, error) {
+	ctx, cancel := withTimeout(ctx, sc.c.callTimeout)
+	defer cancel()
+	return getSolutionTaskCancelled(ctx, sc.c.r, sc.solutionID, sc.taskID)
+}
+
+// AppendLog 追加一段评测日志，offset 为该段在整个日志中的起始字节位置
+func (sc *SolutionClient) AppendLog(ctx context.Context, offset int64, content []byte) error {
+	ctx, cancel := withTimeout(ctx, sc.c.uploadTimeout)
+	defer cancel()
+	return appendSolutionLog(ctx, sc.c.r, sc.solutionID, sc.taskID, &appendLogRequest{
+		Offset:  offset,
+		Content: string(content),
+	})
+}
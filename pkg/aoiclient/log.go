@@ -0,0 +1,21 @@
+package aoiclient
+
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type appendLogRequest struct {
+	// Offset 本段内容在日志中的起始字节位置，服务端据此去重与检测缺失
+	Offset  int64  `json:"offset"`
+	Content string `json:"content"`
+}
+
+func appendSolutionLog(ctx context.Context, http *resty.Client, solutionId, taskId string, req *appendLogRequest) error {
+	raw, err := http.R().
+		SetContext(ctx).
+		SetBody(req).
+		Post("/api/runner/solution/task/" + solutionId + "/" + taskId + "/log")
+	return loadError(raw, err)
+}
@@ -0,0 +1,89 @@
+package aoiclient
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// pushURL 根据 HTTP 基地址生成 WebSocket 推送地址
+func pushURL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/runner/solution/ws"
+	return u.String(), nil
+}
+
+// pushMessage 推送消息，cancel 为 true 时表示中止该评测而不是新任务
+type pushMessage struct {
+	SolutionPoll
+	Cancel bool `json:"cancel"`
+}
+
+// SetCancelHandler 设置收到推送的取消消息时的回调，回调应尽快返回
+func (c *Client) SetCancelHandler(fn func(solutionID, taskID string)) *Client {
+	c.onCancel = fn
+	return c
+}
+
+// Subscribe 建立 WebSocket 连接并接收服务端推送的评测任务。
+// 返回的 channel 在连接断开或 ctx 取消时关闭，调用方应回退到 Poll 并择机重连。
+// ctx 取消时连接立即关闭，但已收到的任务仍会送入 channel，调用方须读取直到 channel 关闭，
+// 否则这些已分配给本 runner 的任务会丢失。
+// 取消消息不进入 channel，而是交给 SetCancelHandler 设置的回调
+func (c *Client) Subscribe(ctx context.Context) (<-chan *SolutionPoll, error) {
+	addr, err := pushURL(c.r.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := websocket.NewConfig(addr, c.r.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	conf.Header = c.r.Header.Clone()
+	c.authorize(conf.Header, "/api/runner/solution/ws")
+	conf.Dialer = newDialer(c.keepAlive)
+
+	dialCtx, cancel := withTimeout(ctx, c.callTimeout)
+	conn, err := conf.DialContext(dialCtx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *SolutionPoll)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	go func() {
+		defer close(ch)
+		defer conn.Close()
+		defer stop()
+		for {
+			msg := &pushMessage{}
+			if err := websocket.JSON.Receive(conn, msg); err != nil {
+				return
+			}
+			// 空消息作为心跳
+			if msg.SolutionId == "" || msg.TaskId == "" {
+				continue
+			}
+			if msg.Cancel {
+				if c.onCancel != nil {
+					c.onCancel(msg.SolutionId, msg.TaskId)
+				}
+				continue
+			}
+			ch <- &msg.SolutionPoll
+		}
+	}()
+	return ch, nil
+}
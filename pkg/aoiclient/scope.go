@@ -0,0 +1,167 @@
+package aoiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Scope 操作类别，平台支持时每类操作使用单独的短期令牌，
+// runner key 仅用于换取令牌，泄露单个令牌时影响范围有限
+type Scope string
+
+const (
+	ScopePoll     Scope = "poll"     // 领取任务（轮询与推送）
+	ScopeSolution Scope = "solution" // 上报状态、详情与完成
+	ScopeLog      Scope = "log"      // 上传评测日志
+)
+
+// AllScopes runner 使用的全部操作类别
+var AllScopes = []Scope{ScopePoll, ScopeSolution, ScopeLog}
+
+// ErrScopesUnsupported 平台不支持分类令牌，调用方应继续使用 runner key
+var ErrScopesUnsupported = errors.New("scoped tokens are not supported by the platform")
+
+const tokenRefreshMargin = time.Minute
+
+type scopedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type tokenRequest struct {
+	Scopes []Scope `json:"scopes"`
+}
+
+type tokenResponse struct {
+	Tokens map[Scope]*scopedToken `json:"tokens"`
+}
+
+// tokenSet 当前持有的分类令牌
+type tokenSet struct {
+	mu     sync.RWMutex
+	tokens map[Scope]*scopedToken
+}
+
+// get 返回未过期的令牌，不存在时返回空字符串
+func (s *tokenSet) get(scope Scope) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t := s.tokens[scope]
+	if t == nil || time.Now().After(t.ExpiresAt) {
+		return ""
+	}
+	return t.Token
+}
+
+func requestTokens(ctx context.Context, http *resty.Client, scopes []Scope) (map[Scope]*scopedToken, error) {
+	res := &tokenResponse{}
+	raw, err := http.R().
+		SetContext(ctx).
+		SetBody(&tokenRequest{Scopes: scopes}).
+		SetResult(res).
+		Post("/api/runner/token")
+	if err == nil && raw.StatusCode() == 404 {
+		return nil, ErrScopesUnsupported
+	}
+	if err := loadError(raw, err); err != nil {
+		return nil, err
+	}
+	return res.Tokens, nil
+}
+
+// scopeForPath 根据请求路径判断操作类别，无法判断时返回空（使用 runner key）
+func scopeForPath(path string) Scope {
+	switch {
+	case path == "/api/runner/solution/poll", path == "/api/runner/solution/ws":
+		return ScopePoll
+	case strings.HasPrefix(path, "/api/runner/solution/task/") && strings.HasSuffix(path, "/log"):
+		return ScopeLog
+	case strings.HasPrefix(path, "/api/runner/solution/task/"):
+		return ScopeSolution
+	}
+	return ""
+}
+
+// authorize 有对应令牌时以令牌替换 runner key
+func (c *Client) authorize(header http.Header, path string) {
+	// 基地址可能带有路径前缀
+	if i := strings.Index(path, "/api/runner/"); i > 0 {
+		path = path[i:]
+	}
+	scope := scopeForPath(path)
+	if scope == "" {
+		return
+	}
+	if token := c.tokens.get(scope); token != "" {
+		header.Del("X-AOI-Runner-Key")
+		header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// EnableScopedTokens 为各操作类别申请分类令牌，并在过期前自动续期直到 ctx 结束，
+// 返回平台未授予的类别。平台不支持时返回 ErrScopesUnsupported；
+// 未授予的类别以及续期失败后过期的令牌回退为使用 runner key
+func (c *Client) EnableScopedTokens(ctx context.Context, scopes ...Scope) ([]Scope, error) {
+	if len(scopes) == 0 {
+		scopes = AllScopes
+	}
+	reqCtx, cancel := withTimeout(ctx, c.callTimeout)
+	tokens, err := requestTokens(reqCtx, c.r, scopes)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	var missing []Scope
+	for _, scope := range scopes {
+		if tokens[scope] == nil {
+			missing = append(missing, scope)
+		}
+	}
+	c.tokens = &tokenSet{tokens: tokens}
+	c.r.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		c.authorize(req.Header, req.URL.Path)
+		return nil
+	})
+	go c.refreshTokens(ctx, scopes)
+	return missing, nil
+}
+
+// refreshTokens 在最早过期的令牌到期前重新申请
+func (c *Client) refreshTokens(ctx context.Context, scopes []Scope) {
+	for {
+		wait := time.Hour
+		c.tokens.mu.RLock()
+		for _, t := range c.tokens.tokens {
+			if t != nil {
+				wait = min(wait, time.Until(t.ExpiresAt)-tokenRefreshMargin)
+			}
+		}
+		c.tokens.mu.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(max(wait, 10*time.Second)):
+		}
+
+		// 续期失败时保留旧令牌，过期后自动回退为 runner key
+		reqCtx, cancel := withTimeout(ctx, c.callTimeout)
+		tokens, err := requestTokens(reqCtx, c.r, scopes)
+		cancel()
+		if err != nil {
+			continue
+		}
+		c.tokens.mu.Lock()
+		c.tokens.tokens = tokens
+		c.tokens.mu.Unlock()
+	}
+}
@@ -3,6 +3,8 @@ package aoiclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/fedstackjs/azukiiro/storage"
 	"github.com/go-resty/resty/v2"
@@ -31,13 +33,19 @@ type SolutionPoll struct {
 	SolutionDataUrl  string        `json:"solutionDataUrl"`
 	SolutionDataHash string        `json:"solutionDataHash"`
 	ErrMsg           string        `json:"errMsg"`
+	SubmittedAt      time.Time     `json:"submittedAt,omitzero"` // 提交时间，平台未提供时为零值
 }
 
-func pollSolution(ctx context.Context, http *resty.Client) (*SolutionPoll, error) {
+type pollRequest struct {
+	// Wait 长轮询等待时间（毫秒），不支持长轮询的服务端会忽略该字段
+	Wait int64 `json:"wait,omitempty"`
+}
+
+func pollSolution(ctx context.Context, http *resty.Client, req *pollRequest) (*SolutionPoll, error) {
 	res := &SolutionPoll{}
 	raw, err := http.R().
 		SetContext(ctx).
-		SetBody(struct{}{}).
+		SetBody(req).
 		SetResult(res).
 		Post("/api/runner/solution/poll")
 	err = loadError(raw, err)
@@ -47,6 +55,35 @@ func pollSolution(ctx context.Context, http *resty.Client) (*SolutionPoll, error
 	return res, nil
 }
 
+// ErrBatchPollUnsupported 平台不支持批量领取任务
+var ErrBatchPollUnsupported = errors.New("batch polling is not supported by the platform")
+
+type pollBatchRequest struct {
+	Count int   `json:"count"`
+	Wait  int64 `json:"wait,omitempty"`
+}
+
+type pollBatchResponse struct {
+	Solutions []*SolutionPoll `json:"solutions"`
+}
+
+func pollSolutions(ctx context.Context, http *resty.Client, req *pollBatchRequest) ([]*SolutionPoll, error) {
+	res := &pollBatchResponse{}
+	raw, err := http.R().
+		SetContext(ctx).
+		SetBody(req).
+		SetResult(res).
+		Post("/api/runner/solution/poll/batch")
+	if err == nil && raw.StatusCode() == 404 {
+		return nil, ErrBatchPollUnsupported
+	}
+	err = loadError(raw, err)
+	if err != nil {
+		return nil, err
+	}
+	return res.Solutions, nil
+}
+
 type SolutionDetailsTest struct {
 	Name       string  `json:"name"`
 	Score      float64 `json:"score"`
@@ -68,6 +105,14 @@ type SolutionDetails struct {
 	Version int                   `json:"version"`
 	Jobs    []*SolutionDetailsJob `json:"jobs"`
 	Summary string                `json:"summary"`
+	Metrics []*SolutionMetric     `json:"metrics,omitempty"`
+}
+
+// SolutionMetric 评测上报的数值指标，供排行榜排序
+type SolutionMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
 }
 
 type SolutionInfo struct {
@@ -120,3 +165,25 @@ func getSolutionTaskDetailsUrl(ctx context.Context, http *resty.Client, solution
 	}
 	return res.URL, nil
 }
+
+// ErrCancelUnsupported 平台不支持查询取消状态
+var ErrCancelUnsupported = errors.New("solution cancellation is not supported by the platform")
+
+type cancelResponse struct {
+	Cancelled bool `json:"cancelled"`
+}
+
+func getSolutionTaskCancelled(ctx context.Context, http *resty.Client, solutionId, taskId string) (bool, error) {
+	res := &cancelResponse{}
+	raw, err := http.R().
+		SetContext(ctx).
+		SetResult(res).
+		Get("/api/runner/solution/task/" + solutionId + "/" + taskId + "/cancel")
+	if err == nil && raw.StatusCode() == 404 {
+		return false, ErrCancelUnsupported
+	}
+	if err := loadError(raw, err); err != nil {
+		return false, err
+	}
+	return res.Cancelled, nil
+}
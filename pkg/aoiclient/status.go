@@ -7,7 +7,9 @@ const (
 	StatusWrongAnswer         = "Wrong Answer"
 	StatusTimeLimitExceeded   = "Time Limit Exceeded"
 	StatusMemoryLimitExceeded = "Memory Limit Exceeded"
+	StatusOutputLimitExceeded = "Output Limit Exceeded"
 	StatusRuntimeError        = "Runtime Error"
 	StatusCompileError        = "Compile Error"
 	StatusInternalError       = "Internal Error"
+	StatusCancelled           = "Cancelled"
 )
@@ -1,8 +1,11 @@
 package judgerproto
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
@@ -19,12 +22,28 @@ const (
 	ActionQuit     Action = "q"
 	ActionPatch    Action = "p"
 	ActionDetail   Action = "d"
+	ActionTest     Action = "t"
+	ActionMetric   Action = "m"
+	ActionFile     Action = "f"
 )
 
 type Message struct {
 	Time   time.Time       `json:"t"`
 	Action Action          `json:"a"`
 	Body   json.RawMessage `json:"b,omitempty"`
+	Stream string          `json:"r,omitempty"` // 发送进程的消息流 ID，每个进程随机生成
+	Seq    uint64          `json:"s,omitempty"` // 流内从 1 开始递增的序号，runner 据此丢弃重复消息并检测丢失
+}
+
+var (
+	stream = newStreamID()
+	seq    atomic.Uint64
+)
+
+func newStreamID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 type ErrorBody string
@@ -33,6 +52,29 @@ type LogBody string
 type PatchBody aoiclient.SolutionInfo
 type DetailBody aoiclient.SolutionDetails
 
+// TestBody 单个测试用例完成时的实时结果
+type TestBody struct {
+	Name     string  `json:"name"`
+	Outcome  string  `json:"outcome"` // pytest outcome: passed/failed/skipped/xfailed/xpassed/error
+	Duration float64 `json:"duration"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// MetricBody 评测容器上报的数值指标（如吞吐量、延迟），同名指标以最后一次为准
+type MetricBody struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// FileBody 评测容器推送的产物，Data 在 JSON 中以 base64 编码。
+// 较大的文件可分块发送：除最后一块外 More 为 true，各块按顺序拼接
+type FileBody struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+	More bool   `json:"more,omitempty"`
+}
+
 func newMessage(action Action, body interface{}) *Message {
 	var raw json.RawMessage
 	if body != nil {
@@ -42,6 +84,8 @@ func newMessage(action Action, body interface{}) *Message {
 		Time:   time.Now(),
 		Action: action,
 		Body:   raw,
+		Stream: stream,
+		Seq:    seq.Add(1),
 	}
 }
 
@@ -77,6 +121,35 @@ func NewDetailMessage(details *DetailBody) *Message {
 	return newMessage(ActionDetail, DetailBody(*details))
 }
 
+func NewTestMessage(test *TestBody) *Message {
+	return newMessage(ActionTest, test)
+}
+
+func NewMetricMessage(metric *MetricBody) *Message {
+	return newMessage(ActionMetric, metric)
+}
+
+// MaxFileChunk 单条产物消息的数据大小上限。runner 按 64 KiB 拆分过长的输出行，
+// base64 编码后的消息需小于该长度
+const MaxFileChunk = 32 << 10
+
+func NewFileMessage(file *FileBody) *Message {
+	return newMessage(ActionFile, file)
+}
+
+// NewFileMessages 将产物按 MaxFileChunk 分块，依次输出即可
+func NewFileMessages(name string, data []byte) []*Message {
+	var messages []*Message
+	for {
+		n := min(len(data), MaxFileChunk)
+		messages = append(messages, NewFileMessage(&FileBody{Name: name, Data: data[:n], More: n < len(data)}))
+		data = data[n:]
+		if len(data) == 0 {
+			return messages
+		}
+	}
+}
+
 func (m *Message) String() string {
 	b, err := json.Marshal(m)
 	if err != nil {
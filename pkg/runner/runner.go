@@ -0,0 +1,188 @@
+// Package runner 将评测 manager 的核心（任务来源、执行器、adapter 与结果上报）
+// 以库的形式暴露，供课程专用评测机、CI 机器人等项目嵌入使用。
+package runner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/manager"
+)
+
+// DefaultEndpoint 默认 AOI 地址
+const DefaultEndpoint = "https://hpcgame.pku.edu.cn"
+
+// Option 评测器配置项
+type Option func(r *Runner)
+
+// TransitionHook 评测状态转移回调
+type TransitionHook func(solutionID, taskID, from, to string)
+
+// Runner 可嵌入的评测器
+type Runner struct {
+	conf            *config.ManagerConfig
+	hooks           []TransitionHook
+	executorFactory func(host string) (Executor, error)
+	source          WorkSource
+	adapters        map[string]adapters.ReportAdapter
+	m               *manager.Manager
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// WithEndpoint 设置 AOI 地址
+func WithEndpoint(endpoint string) Option {
+	return func(r *Runner) { r.conf.Endpoint = ptr(endpoint) }
+}
+
+// WithCredentials 设置 runner ID 与密钥
+func WithCredentials(id, key string) Option {
+	return func(r *Runner) {
+		r.conf.RunnerID = ptr(id)
+		r.conf.RunnerKey = ptr(key)
+	}
+}
+
+// WithWorkDir 设置临时目录根路径
+func WithWorkDir(dir string) Option {
+	return func(r *Runner) { r.conf.WorkDir = ptr(dir) }
+}
+
+// WithCacheDir 设置题目数据缓存目录
+func WithCacheDir(dir string) Option {
+	return func(r *Runner) { r.conf.CacheDir = ptr(dir) }
+}
+
+// WithPollInterval 设置轮询间隔的上下限
+func WithPollInterval(min, max time.Duration) Option {
+	return func(r *Runner) {
+		r.conf.PollMinInterval = ptr(min)
+		r.conf.PollMaxInterval = ptr(max)
+	}
+}
+
+// WithLongPoll 设置服务端长轮询等待时间
+func WithLongPoll(wait time.Duration) Option {
+	return func(r *Runner) { r.conf.LongPollTimeout = ptr(wait) }
+}
+
+// WithWorkers 设置同时运行的评测数
+func WithWorkers(n int) Option {
+	return func(r *Runner) { r.conf.Workers = ptr(n) }
+}
+
+// WithPushDispatch 启用 WebSocket 推送
+func WithPushDispatch(enabled bool) Option {
+	return func(r *Runner) { r.conf.PushDispatch = ptr(enabled) }
+}
+
+// WithAdapterLimits 设置 manager 侧 adapter 的超时与输入大小上限
+func WithAdapterLimits(timeout time.Duration, maxInputSize int64) Option {
+	return func(r *Runner) {
+		r.conf.AdapterTimeout = ptr(timeout)
+		r.conf.AdapterMaxInputSize = ptr(maxInputSize)
+	}
+}
+
+// WithSecurityProfiles 设置 seccomp 配置目录与默认 seccomp / AppArmor 配置
+func WithSecurityProfiles(seccompDir, seccompProfile, appArmorProfile string) Option {
+	return func(r *Runner) {
+		r.conf.SeccompDir = ptr(seccompDir)
+		r.conf.SeccompProfile = ptr(seccompProfile)
+		r.conf.AppArmorProfile = ptr(appArmorProfile)
+	}
+}
+
+// WithUser 设置容器默认运行用户以及是否允许 root
+func WithUser(defaultUser string, allowRoot bool) Option {
+	return func(r *Runner) {
+		r.conf.DefaultUser = ptr(defaultUser)
+		r.conf.AllowRoot = ptr(allowRoot)
+	}
+}
+
+// WithConfig 直接修改完整配置，用于设置没有单独配置项的参数
+func WithConfig(fn func(conf *Config)) Option {
+	return func(r *Runner) { fn(r.conf) }
+}
+
+// WithExecutorFactory 替换创建执行器的函数，host 为 docker-hosts 中的地址（未配置时为空）
+func WithExecutorFactory(fn func(host string) (Executor, error)) Option {
+	return func(r *Runner) { r.executorFactory = fn }
+}
+
+// WithWorkSource 从 source 领取任务，代替轮询 AOI 与推送
+func WithWorkSource(source WorkSource) Option {
+	return func(r *Runner) { r.source = source }
+}
+
+// WithReportAdapter 注册报告类 adapter，题目以 name 作为 adapter 名称使用。
+// reportName 为默认报告文件名，parse 解析报告并返回百分制结果，vars 为题目变量。
+// adapter 在进程内全局注册，同一名称只能注册一次
+func WithReportAdapter(name, reportName string, parse func(path string, vars map[string]any) (*Result, error)) Option {
+	return func(r *Runner) {
+		if r.adapters == nil {
+			r.adapters = make(map[string]adapters.ReportAdapter)
+		}
+		r.adapters[name] = adapters.ReportAdapter{ReportName: reportName, Parse: parse}
+	}
+}
+
+// WithTransitionHook 注册评测状态转移回调
+func WithTransitionHook(hook TransitionHook) Option {
+	return func(r *Runner) { r.hooks = append(r.hooks, hook) }
+}
+
+// New 创建并初始化评测器
+func New(opts ...Option) (*Runner, error) {
+	r := &Runner{
+		conf: &config.ManagerConfig{
+			Endpoint:  ptr(DefaultEndpoint),
+			RunnerID:  ptr(""),
+			RunnerKey: ptr(""),
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if *r.conf.RunnerID == "" || *r.conf.RunnerKey == "" {
+		return nil, errors.New("runner ID and key must be provided")
+	}
+	for name, ra := range r.adapters {
+		if err := adapters.RegisterReportAdapter(name, ra); err != nil {
+			return nil, err
+		}
+	}
+
+	r.m = manager.NewManager(r.conf)
+	if r.executorFactory != nil {
+		r.m.SetExecutorFactory(r.executorFactory)
+	}
+	if r.source != nil {
+		r.m.SetWorkSource(r.source)
+	}
+	for _, hook := range r.hooks {
+		r.m.OnTransition(func(job *manager.Job, from, to manager.State) {
+			hook(job.SolutionID, job.TaskID, string(from), string(to))
+		})
+	}
+	if err := r.m.Init(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run 开始接收并评测任务，阻塞直到 ctx 取消或 Close 被调用
+func (r *Runner) Run(ctx context.Context) error {
+	return r.m.Start(ctx)
+}
+
+// Close 停止接收任务，等待当前评测与后台上报完成后释放资源
+func (r *Runner) Close() error {
+	return r.m.Close()
+}
@@ -0,0 +1,93 @@
+package runner_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor/executortest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/aoiclient/aoitest"
+	"github.com/lcpu-club/lfs-auto-grader/pkg/runner"
+)
+
+// queue 以 channel 提供任务的 WorkSource
+type queue chan *aoiclient.SolutionPoll
+
+func (q queue) Next(ctx context.Context, n int) ([]*aoiclient.SolutionPoll, error) {
+	select {
+	case soln := <-q:
+		return []*aoiclient.SolutionPoll{soln}, nil
+	case <-ctx.Done():
+		return nil, nil
+	case <-time.After(50 * time.Millisecond):
+		return nil, nil
+	}
+}
+
+func TestEmbeddedRunner(t *testing.T) {
+	srv := aoitest.NewServer()
+	defer srv.Close()
+	exec := executortest.NewFake(executortest.Script{
+		Files: map[string]string{"/output/score.txt": "87"},
+	})
+	source := make(queue, 1)
+
+	r, err := runner.New(
+		runner.WithEndpoint(srv.URL),
+		runner.WithCredentials("embedded", "test-key"),
+		runner.WithWorkDir(t.TempDir()),
+		runner.WithCacheDir(t.TempDir()),
+		runner.WithPollInterval(10*time.Millisecond, 50*time.Millisecond),
+		runner.WithConfig(func(conf *runner.Config) {
+			workers := 1
+			conf.Workers = &workers
+		}),
+		runner.WithExecutorFactory(func(string) (runner.Executor, error) { return exec, nil }),
+		runner.WithWorkSource(source),
+		runner.WithReportAdapter("score-file", "score.txt", func(path string, _ map[string]any) (*runner.Result, error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(string(data)) != "87" {
+				t.Errorf("report = %q, want 87", data)
+			}
+			return &runner.Result{Score: 87, Status: aoiclient.StatusWrongAnswer}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		r.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+		r.Close()
+	}()
+
+	source <- aoitest.NewSolution("s1", "t1", "embedded", "score-file", map[string]any{
+		"image":      "judge:latest",
+		"docker_cmd": []string{"/judge"},
+		"timeout":    10,
+	})
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancelWait()
+	task, err := srv.WaitComplete(waitCtx, "s1", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := task.Last(); last == nil || last.Score != 87 || last.Status != aoiclient.StatusWrongAnswer {
+		t.Fatalf("final status = %+v, want score 87 %q", last, aoiclient.StatusWrongAnswer)
+	}
+	if n := srv.Requests(aoitest.EndpointPoll); n != 0 {
+		t.Errorf("AOI was polled %d times with a work source", n)
+	}
+}
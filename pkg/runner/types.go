@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"github.com/lcpu-club/lfs-auto-grader/internal/adapters"
+	"github.com/lcpu-club/lfs-auto-grader/internal/config"
+	"github.com/lcpu-club/lfs-auto-grader/internal/executor"
+	"github.com/lcpu-club/lfs-auto-grader/internal/manager"
+)
+
+// Config 完整的 manager 配置，字段与 cmd/manager 的命令行参数一一对应，nil 表示使用默认值
+type Config = config.ManagerConfig
+
+// WorkSource 替换从 AOI 领取任务的来源，评测结果仍上报到 AOI 地址
+type WorkSource = manager.WorkSource
+
+// Result adapter 的解析结果，分数按百分制计算
+type Result = adapters.LFS1Result
+
+// 自定义执行器需要实现 Executor 接口，以下类型为其方法签名中用到的类型
+type (
+	Executor      = executor.Executor
+	ExecuteConfig = executor.ExecuteConfig
+	ExecuteResult = executor.ExecuteResult
+	Mount         = executor.Mount
+	Device        = executor.Device
+	ResourceUsage = executor.ResourceUsage
+	OOMKill       = executor.OOMKill
+	LogStream     = executor.LogStream
+	LogCallback   = executor.LogCallback
+	Session       = executor.Session
+	ExecConfig    = executor.ExecConfig
+	ExecResult    = executor.ExecResult
+	NetworkConfig = executor.NetworkConfig
+	DaemonInfo    = executor.DaemonInfo
+	ImageInfo     = executor.ImageInfo
+	ContainerInfo = executor.ContainerInfo
+)
+
+// 日志流
+const (
+	Stdout = executor.Stdout
+	Stderr = executor.Stderr
+)